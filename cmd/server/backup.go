@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"agent-orchestration/use_cases"
+)
+
+// runBackup implements `server backup [flags] [create|list]`. It saves a
+// backup of the configured store, or lists the stored backups.
+func runBackup(args []string) error {
+	cfg, rest, err := loadConfig("backup", args)
+	if err != nil {
+		return err
+	}
+	action := "create"
+	if len(rest) == 1 {
+		action = rest[0]
+	}
+	if len(rest) > 1 || (action != "create" && action != "list") {
+		return fmt.Errorf("usage: server backup [flags] [create|list]")
+	}
+
+	repos, err := newStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer repos.close()
+
+	ctx := context.Background()
+	userUseCase := use_cases.NewUserUseCase(repos.users,
+		use_cases.WithBackups(repos.backups(cfg)),
+	)
+
+	if action == "list" {
+		infos, err := userUseCase.ListBackups(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTAKEN\tUSERS\tSIZE")
+		for _, info := range infos {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", info.Name, info.Taken.Format(time.RFC3339), info.Users, info.Size)
+		}
+		return w.Flush()
+	}
+
+	info, err := userUseCase.BackupUsers(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Backed up %d users to %s", info.Users, info.Name)
+	return nil
+}
+
+// runRestore implements `server restore [flags] NAME`. It replaces every
+// user of the configured store with the ones in the named backup. The
+// server should not be running against the same store meanwhile.
+func runRestore(args []string) error {
+	cfg, rest, err := loadConfig("restore", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: server restore [flags] NAME")
+	}
+
+	repos, err := newStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer repos.close()
+
+	userUseCase := use_cases.NewUserUseCase(repos.users,
+		use_cases.WithBackups(repos.backups(cfg)),
+	)
+	snapshot, err := userUseCase.RestoreUsers(context.Background(), rest[0])
+	if err != nil {
+		return err
+	}
+	log.Printf("Restored %d users from %s taken at %s", len(snapshot.Users), rest[0], snapshot.Taken.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/backup"
+	"agent-orchestration/infrastructure/changefeed"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/infrastructure/notification"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+// Supported user store backends
+const (
+	storeMemory  = "memory"
+	storeSharded = "sharded"
+	storeSQLite  = "sqlite"
+)
+
+// config holds the server configuration
+type config struct {
+	Addr         string
+	Store        string
+	DatabasePath string
+	AutoMigrate  bool
+
+	// Shards is the number of shards of the sharded store
+	Shards int
+
+	// Transactions runs every change to a user and its history in one
+	// transaction. The sharded store doesn't take part in transactions, so
+	// it requires them to be disabled.
+	Transactions bool
+
+	// DataDir enables the write-ahead log of the memory store when set
+	DataDir          string
+	WALSync          bool
+	SnapshotEvery    int
+	SnapshotInterval time.Duration
+
+	// PurgeGrace is how long soft-deleted users are kept before the purge
+	// job deletes them permanently
+	PurgeGrace    time.Duration
+	PurgeInterval time.Duration
+
+	// CacheSize enables the user lookup cache when positive
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+
+	// Backups are written to BackupDir and pruned down to the newest
+	// BackupKeep ones younger than BackupMaxAge
+	BackupDir    string
+	BackupKeep   int
+	BackupMaxAge time.Duration
+
+	// EmailKeyring enables the encryption of stored emails with the keys in
+	// this file when set
+	EmailKeyring      string
+	ReencryptInterval time.Duration
+
+	// RetentionMonths enables the removal of users inactive for this many
+	// months when positive. They are warned through RetentionWebhook, or
+	// only in the log without one, RetentionWarning before.
+	RetentionMonths   int
+	RetentionWarning  time.Duration
+	RetentionInterval time.Duration
+	RetentionWebhook  string
+
+	// StoreSlowThreshold is the latency from which user store calls are
+	// logged as slow
+	StoreSlowThreshold time.Duration
+
+	// Chaos enables the injection of user store faults, driven through
+	// /admin/chaos. It is meant for tests only.
+	Chaos bool
+
+	// ChangeLogSize is the number of user changes retained for watchers to
+	// resume from
+	ChangeLogSize int
+
+	// AccessControl checks the roles of the principal of every request.
+	// Admins lists principals holding the admin role without a user record,
+	// comma-separated, so the first roles can be assigned.
+	AccessControl bool
+	Admins        string
+
+	// Tenant is the tenant the export and import subcommands act in
+	Tenant string
+}
+
+// backupOptions returns the backup store configuration
+func (cfg config) backupOptions() backup.Options {
+	return backup.Options{
+		Dir:    cfg.BackupDir,
+		Keep:   cfg.BackupKeep,
+		MaxAge: cfg.BackupMaxAge,
+	}
+}
+
+// retention returns the use case option enforcing the retention policy, or
+// nil when it is disabled
+func (cfg config) retention() use_cases.UserUseCaseOption {
+	if cfg.RetentionMonths <= 0 {
+		return nil
+	}
+	policy := use_cases.RetentionPolicy{Months: cfg.RetentionMonths, Warning: cfg.RetentionWarning}
+	var notifier use_cases.RetentionNotifier = notification.LogNotifier{}
+	if cfg.RetentionWebhook != "" {
+		notifier = notification.NewWebhookNotifier(cfg.RetentionWebhook, notification.DefaultWebhookTimeout)
+	}
+	return use_cases.WithRetention(policy, notifier)
+}
+
+// accessControl returns the use case option checking the roles of
+// principals, or nil when access control is disabled
+func (cfg config) accessControl() use_cases.UserUseCaseOption {
+	if !cfg.AccessControl {
+		return nil
+	}
+	var admins []string
+	for _, admin := range strings.Split(cfg.Admins, ",") {
+		if admin = strings.TrimSpace(admin); admin != "" {
+			admins = append(admins, admin)
+		}
+	}
+	return use_cases.WithAccessControl(admins...)
+}
+
+// tenantContext returns ctx scoped to the configured tenant
+func (cfg config) tenantContext(ctx context.Context) context.Context {
+	return repository.WithTenant(ctx, cfg.Tenant)
+}
+
+// reencryptCheckpoint returns the file recording the progress of an
+// interrupted re-encryption pass
+func (cfg config) reencryptCheckpoint() string {
+	return cfg.EmailKeyring + ".reencrypt"
+}
+
+// loadConfig reads the configuration from flags, falling back to environment
+// variables and then to defaults. The arguments left after the flags are
+// returned for subcommands.
+func loadConfig(name string, args []string) (config, []string, error) {
+	var cfg config
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&cfg.Addr, "addr", envOrDefault("SERVER_ADDR", ":8080"), "HTTP listen address")
+	fs.StringVar(&cfg.Store, "store", envOrDefault("USER_STORE", storeMemory), "user store backend (memory, sharded or sqlite)")
+	fs.StringVar(&cfg.DatabasePath, "db", envOrDefault("DATABASE_PATH", "users.db"), "SQLite database file used by the sqlite store")
+	fs.IntVar(&cfg.Shards, "shards", envInt("SHARD_COUNT", database.DefaultShardCount), "number of shards of the sharded store")
+	fs.BoolVar(&cfg.Transactions, "transactions", envBool("TRANSACTIONS_ENABLED", true), "change users and their history in one transaction (the sharded store requires false)")
+	fs.BoolVar(&cfg.AutoMigrate, "auto-migrate", envBool("AUTO_MIGRATE", true), "apply pending schema migrations on startup")
+	fs.StringVar(&cfg.DataDir, "data-dir", envOrDefault("DATA_DIR", ""), "persist the memory store to a write-ahead log in this directory")
+	fs.BoolVar(&cfg.WALSync, "wal-sync", envBool("WAL_SYNC", true), "fsync the write-ahead log after every change")
+	fs.IntVar(&cfg.SnapshotEvery, "snapshot-every", envInt("SNAPSHOT_EVERY", 1000), "compact the write-ahead log after this many changes")
+	fs.DurationVar(&cfg.SnapshotInterval, "snapshot-interval", envDuration("SNAPSHOT_INTERVAL", 5*time.Minute), "compact the write-ahead log on this interval")
+
+	fs.DurationVar(&cfg.PurgeGrace, "purge-grace", envDuration("PURGE_GRACE", 30*24*time.Hour), "keep soft-deleted users for this long before purging them")
+	fs.DurationVar(&cfg.PurgeInterval, "purge-interval", envDuration("PURGE_INTERVAL", time.Hour), "run the purge of soft-deleted users on this interval (0 disables it)")
+
+	fs.IntVar(&cfg.CacheSize, "cache-size", envInt("USER_CACHE_SIZE", 0), "cache up to this many user lookups (0 disables the cache)")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", envDuration("USER_CACHE_TTL", time.Minute), "serve cached users for this long")
+	fs.DurationVar(&cfg.CacheNegativeTTL, "cache-negative-ttl", envDuration("USER_CACHE_NEGATIVE_TTL", 5*time.Second), "serve cached lookups of missing users for this long (0 disables negative caching)")
+
+	fs.StringVar(&cfg.BackupDir, "backup-dir", envOrDefault("BACKUP_DIR", "backups"), "directory holding user store backups")
+	fs.IntVar(&cfg.BackupKeep, "backup-keep", envInt("BACKUP_KEEP", 7), "keep this many backups (0 keeps all)")
+	fs.DurationVar(&cfg.BackupMaxAge, "backup-max-age", envDuration("BACKUP_MAX_AGE", 0), "drop backups older than this, except the newest (0 keeps them)")
+
+	fs.StringVar(&cfg.EmailKeyring, "email-keyring", envOrDefault("EMAIL_KEYRING", ""), "encrypt stored emails with the keys in this keyring file")
+	fs.DurationVar(&cfg.ReencryptInterval, "reencrypt-interval", envDuration("REENCRYPT_INTERVAL", 24*time.Hour), "seal stored emails with the primary key on this interval (0 disables it)")
+
+	fs.IntVar(&cfg.RetentionMonths, "retention-months", envInt("RETENTION_MONTHS", 0), "delete users inactive for this many months (0 disables the retention policy)")
+	fs.DurationVar(&cfg.RetentionWarning, "retention-warning", envDuration("RETENTION_WARNING", 14*24*time.Hour), "warn inactive users this long before deleting them")
+	fs.DurationVar(&cfg.RetentionInterval, "retention-interval", envDuration("RETENTION_INTERVAL", 24*time.Hour), "enforce the retention policy on this interval (0 disables the job)")
+	fs.StringVar(&cfg.RetentionWebhook, "retention-webhook", envOrDefault("RETENTION_WEBHOOK", ""), "post the warnings of inactive users to this URL (they are only logged without one)")
+
+	fs.DurationVar(&cfg.StoreSlowThreshold, "store-slow-threshold", envDuration("STORE_SLOW_THRESHOLD", 100*time.Millisecond), "log user store calls taking this long or longer (0 disables the log)")
+	fs.BoolVar(&cfg.Chaos, "chaos", envBool("CHAOS_ENABLED", false), "inject user store faults configured through /admin/chaos (for tests only)")
+	fs.IntVar(&cfg.ChangeLogSize, "change-log-size", envInt("CHANGE_LOG_SIZE", changefeed.DefaultLogSize), "retain this many user changes for watchers to resume from")
+
+	fs.BoolVar(&cfg.AccessControl, "access-control", envBool("ACCESS_CONTROL_ENABLED", false), "allow principals only what the roles of their user grant")
+	fs.StringVar(&cfg.Admins, "admin-principals", envOrDefault("ADMIN_PRINCIPALS", ""), "comma-separated principals holding the admin role without a user record")
+
+	fs.StringVar(&cfg.Tenant, "tenant", envOrDefault("USER_TENANT", entities.DefaultTenant), "tenant the export and import subcommands act in")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	switch cfg.Store {
+	case storeMemory, storeSharded, storeSQLite:
+	default:
+		return cfg, nil, fmt.Errorf("unknown store %q", cfg.Store)
+	}
+	if cfg.Store == storeSharded {
+		if cfg.Shards <= 0 {
+			return cfg, nil, fmt.Errorf("invalid shards %d", cfg.Shards)
+		}
+		if cfg.Transactions {
+			return cfg, nil, fmt.Errorf("the sharded store does not take part in transactions, run it with -transactions=false")
+		}
+		if cfg.DataDir != "" {
+			return cfg, nil, fmt.Errorf("the sharded store can't be persisted to a data-dir")
+		}
+	}
+	if cfg.BackupKeep < 0 {
+		return cfg, nil, fmt.Errorf("invalid backup-keep %d", cfg.BackupKeep)
+	}
+	if cfg.RetentionMonths < 0 || cfg.RetentionWarning < 0 {
+		return cfg, nil, fmt.Errorf("invalid retention policy of %d months with a %s warning", cfg.RetentionMonths, cfg.RetentionWarning)
+	}
+	if cfg.StoreSlowThreshold < 0 {
+		return cfg, nil, fmt.Errorf("invalid store-slow-threshold %s", cfg.StoreSlowThreshold)
+	}
+	if cfg.ChangeLogSize <= 0 {
+		return cfg, nil, fmt.Errorf("invalid change-log-size %d", cfg.ChangeLogSize)
+	}
+	if !entities.ValidTenantID(cfg.Tenant) {
+		return cfg, nil, fmt.Errorf("invalid tenant %q", cfg.Tenant)
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// envOrDefault returns the value of the environment variable key or def if unset
+func envOrDefault(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
+
+// envBool returns the boolean value of the environment variable key or def
+// if it is unset or not a valid boolean
+func envBool(key string, def bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return def
+	}
+	return parsed
+}
+
+// envInt returns the integer value of the environment variable key or def
+// if it is unset or not a valid integer
+func envInt(key string, def int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return parsed
+}
+
+// envDuration returns the duration value of the environment variable key or
+// def if it is unset or not a valid duration
+func envDuration(key string, def time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return def
+	}
+	return parsed
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+// runEmailDuplicates implements `server email-duplicates [flags]`. It lists
+// the users whose emails collide once normalized, so they can be merged or
+// renamed by hand. Every tenant is searched.
+func runEmailDuplicates(args []string) error {
+	cfg, _, err := loadConfig("email-duplicates", args)
+	if err != nil {
+		return err
+	}
+
+	repos, err := newStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer repos.close()
+
+	duplicates, err := use_cases.NewUserUseCase(repos.users).FindDuplicateEmails(repository.IncludeAllTenants(context.Background()))
+	if err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		fmt.Println("No duplicate emails found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TENANT\tEMAIL KEY\tID\tEMAIL\tDELETED")
+	for _, group := range duplicates {
+		for _, user := range group.Users {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%t\n", group.Tenant, group.EmailKey, user.ID, user.Email, user.IsDeleted())
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"agent-orchestration/infrastructure/encryption"
+)
+
+// errNoKeyring is returned by the keyring subcommands without a keyring
+// file to work on
+var errNoKeyring = errors.New("no keyring configured, set -email-keyring or EMAIL_KEYRING")
+
+// runKeyring implements `server keyring [flags] init|rotate`. init creates
+// the keyring file with a first key; rotate adds a new primary key, after
+// which `server reencrypt` or the server's background job seals the stored
+// emails with it.
+func runKeyring(args []string) error {
+	cfg, rest, err := loadConfig("keyring", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: server keyring [flags] init|rotate")
+	}
+	if cfg.EmailKeyring == "" {
+		return errNoKeyring
+	}
+
+	switch rest[0] {
+	case "init":
+		if _, err := os.Stat(cfg.EmailKeyring); err == nil {
+			return fmt.Errorf("keyring %s already exists", cfg.EmailKeyring)
+		}
+		keyring, err := encryption.NewKeyring()
+		if err != nil {
+			return err
+		}
+		if err := keyring.Save(cfg.EmailKeyring); err != nil {
+			return err
+		}
+		log.Printf("Created keyring %s with key %d", cfg.EmailKeyring, keyring.Primary())
+	case "rotate":
+		keyring, err := encryption.LoadKeyring(cfg.EmailKeyring)
+		if err != nil {
+			return err
+		}
+		id, err := keyring.Rotate()
+		if err != nil {
+			return err
+		}
+		if err := keyring.Save(cfg.EmailKeyring); err != nil {
+			return err
+		}
+		log.Printf("Rotated keyring %s to key %d; restart the server to seal new emails with it", cfg.EmailKeyring, id)
+	default:
+		return fmt.Errorf("usage: server keyring [flags] init|rotate")
+	}
+	return nil
+}
+
+// runReencrypt implements `server reencrypt [flags]`. It seals every stored
+// email with the primary key, resuming a pass that was interrupted. The
+// server's background job does the same; don't run both at once.
+func runReencrypt(args []string) error {
+	cfg, rest, err := loadConfig("reencrypt", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("usage: server reencrypt [flags]")
+	}
+	if cfg.EmailKeyring == "" {
+		return errNoKeyring
+	}
+
+	repos, err := newStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer repos.close()
+
+	reencryptor := encryption.NewReencryptor(repos.encrypted, repos.users, encryption.ReencryptOptions{
+		Checkpoint: cfg.reencryptCheckpoint(),
+	})
+	report, err := reencryptor.Run(context.Background())
+	if err != nil {
+		return err
+	}
+
+	resumed := ""
+	if report.Resumed {
+		resumed = " (resumed)"
+	}
+	log.Printf("Checked %d users, sealed %d emails with the primary key, skipped %d duplicates%s",
+		report.Checked, report.Resealed, report.Skipped, resumed)
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
@@ -12,16 +13,185 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"agent-orchestration/infrastructure/backup"
+	"agent-orchestration/infrastructure/cache"
+	"agent-orchestration/infrastructure/chaos"
+	"agent-orchestration/infrastructure/changefeed"
 	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/infrastructure/encryption"
+	"agent-orchestration/infrastructure/metrics"
+	"agent-orchestration/infrastructure/scheduler"
+	"agent-orchestration/infrastructure/search"
 	httphandler "agent-orchestration/interfaces/http"
+	"agent-orchestration/interfaces/repository"
 	"agent-orchestration/use_cases"
 )
 
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := runMigrate(args[1:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		case "export":
+			if err := runExport(args[1:]); err != nil {
+				log.Fatalf("Export failed: %v", err)
+			}
+			return
+		case "import":
+			if err := runImport(args[1:]); err != nil {
+				log.Fatalf("Import failed: %v", err)
+			}
+			return
+		case "backup":
+			if err := runBackup(args[1:]); err != nil {
+				log.Fatalf("Backup failed: %v", err)
+			}
+			return
+		case "restore":
+			if err := runRestore(args[1:]); err != nil {
+				log.Fatalf("Restore failed: %v", err)
+			}
+			return
+		case "keyring":
+			if err := runKeyring(args[1:]); err != nil {
+				log.Fatalf("Keyring failed: %v", err)
+			}
+			return
+		case "reencrypt":
+			if err := runReencrypt(args[1:]); err != nil {
+				log.Fatalf("Re-encryption failed: %v", err)
+			}
+			return
+		case "retention":
+			if err := runRetention(args[1:]); err != nil {
+				log.Fatalf("Retention failed: %v", err)
+			}
+			return
+		case "email-duplicates":
+			if err := runEmailDuplicates(args[1:]); err != nil {
+				log.Fatalf("Finding duplicate emails failed: %v", err)
+			}
+			return
+		}
+	}
+
+	runServer(args)
+}
+
+// runServer starts the HTTP server and blocks until it is shut down
+func runServer(args []string) {
+	cfg, _, err := loadConfig("server", args)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// Initialize dependencies
-	userRepo := database.NewInMemoryUserRepository()
-	userUseCase := use_cases.NewUserUseCase(userRepo)
-	userHandler := httphandler.NewUserHandler(userUseCase)
+	repos, err := newStores(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize user store: %v", err)
+	}
+	defer repos.close()
+
+	// Faults are injected right in front of the store, so everything above
+	// it sees them as the store's own
+	var injector *chaos.Injector
+	store := repos.users
+	if cfg.Chaos {
+		log.Println("Fault injection is enabled, never do this in production")
+		injector = chaos.NewInjector()
+		store = chaos.NewFaultyUserRepository(store, injector)
+	}
+
+	// Measure the calls reaching the store, behind the cache
+	instrumented := metrics.NewInstrumentedUserRepository(store, metrics.Options{
+		SlowThreshold: cfg.StoreSlowThreshold,
+	})
+	expvar.Publish("user_store", expvar.Func(func() any { return instrumented.Stats() }))
+
+	var users repository.UserRepository = instrumented
+	if cfg.CacheSize > 0 {
+		cached := cache.NewCachedUserRepository(users, cache.Options{
+			Size:        cfg.CacheSize,
+			TTL:         cfg.CacheTTL,
+			NegativeTTL: cfg.CacheNegativeTTL,
+		})
+		expvar.Publish("user_cache", expvar.Func(func() any { return cached.Stats() }))
+		users = cached
+	}
+
+	// Keep the search index in sync with every write from here on
+	searchIndex := search.NewIndex()
+	if err := searchIndex.Rebuild(context.Background(), users); err != nil {
+		log.Fatalf("Failed to build search index: %v", err)
+	}
+	users = search.NewIndexedUserRepository(users, searchIndex)
+
+	// Publish every committed write to the watchers of the change stream
+	users = changefeed.NewWatchedUserRepository(users, changefeed.NewLog(cfg.ChangeLogSize))
+
+	opts := []use_cases.UserUseCaseOption{
+		use_cases.WithHistory(repos.history),
+		use_cases.WithTransactions(repos.tx),
+		use_cases.WithSearch(searchIndex),
+		use_cases.WithBackups(repos.backups(cfg)),
+	}
+	retention := cfg.retention()
+	if retention != nil {
+		opts = append(opts, retention)
+	}
+	if access := cfg.accessControl(); access != nil {
+		opts = append(opts, access)
+	}
+	userUseCase := use_cases.NewUserUseCase(users, opts...)
+	var handlerOpts []httphandler.UserHandlerOption
+	if injector != nil {
+		handlerOpts = append(handlerOpts, httphandler.WithFaultInjector(injector))
+	}
+	userHandler := httphandler.NewUserHandler(userUseCase, handlerOpts...)
+
+	if repos.encrypted != nil && cfg.ReencryptInterval > 0 {
+		reencryptor := encryption.NewReencryptor(repos.encrypted, users, encryption.ReencryptOptions{
+			Checkpoint: cfg.reencryptCheckpoint(),
+		})
+		reencrypt := scheduler.NewPeriodic("reencrypt-emails", cfg.ReencryptInterval, func(ctx context.Context) error {
+			report, err := reencryptor.Run(ctx)
+			if report.Resealed > 0 {
+				log.Printf("Sealed %d emails with the primary key", report.Resealed)
+			}
+			return err
+		})
+		reencrypt.Start()
+		defer reencrypt.Stop()
+	}
+
+	if cfg.PurgeInterval > 0 {
+		purge := scheduler.NewPeriodic("purge-deleted-users", cfg.PurgeInterval, func(ctx context.Context) error {
+			purged, err := userUseCase.PurgeDeletedUsers(use_cases.SystemContext(repository.IncludeAllTenants(ctx)), cfg.PurgeGrace)
+			if purged > 0 {
+				log.Printf("Purged %d deleted users", purged)
+			}
+			return err
+		})
+		purge.Start()
+		defer purge.Stop()
+	}
+
+	if retention != nil && cfg.RetentionInterval > 0 {
+		enforce := scheduler.NewPeriodic("enforce-retention", cfg.RetentionInterval, func(ctx context.Context) error {
+			report, err := userUseCase.EnforceRetention(use_cases.SystemContext(repository.IncludeAllTenants(ctx)), false)
+			if report != nil && report.Warned+report.Deleted+report.Failed > 0 {
+				log.Printf("Retention policy warned %d inactive users and deleted %d; %d warnings failed",
+					report.Warned, report.Deleted, report.Failed)
+			}
+			return err
+		})
+		enforce.Start()
+		defer enforce.Stop()
+	}
 
 	// Setup router
 	router := chi.NewRouter()
@@ -30,6 +200,7 @@ func main() {
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.RequestID)
+	router.Use(httphandler.RequestContext)
 	router.Use(middleware.RealIP)
 	
 	// CORS middleware for testing
@@ -37,7 +208,8 @@ func main() {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, X-Principal, X-Tenant-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
 			
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -52,12 +224,48 @@ func main() {
 	router.Route("/users", func(r chi.Router) {
 		r.Post("/", userHandler.CreateUser)
 		r.Get("/", userHandler.ListUsers)
+		r.Get("/search", userHandler.SearchUsers)
+		r.Get("/export", userHandler.ExportUsers)
+		r.Post("/import", userHandler.ImportUsers)
+		r.Get("/changes", userHandler.WatchUsers)
 		r.Route("/{id}", func(r chi.Router) {
 			r.Get("/", userHandler.GetUser)
 			r.Put("/", userHandler.UpdateUser)
 			r.Delete("/", userHandler.DeleteUser)
+			r.Post("/restore", userHandler.RestoreUser)
+			r.Get("/history", userHandler.GetUserHistory)
+			r.Put("/legal-hold", userHandler.PlaceLegalHold)
+			r.Delete("/legal-hold", userHandler.ReleaseLegalHold)
+			r.Post("/activate", userHandler.ActivateUser)
+			r.Post("/suspend", userHandler.SuspendUser)
+			r.Post("/deactivate", userHandler.DeactivateUser)
+			r.Put("/roles/{role}", userHandler.AssignRole)
+			r.Delete("/roles/{role}", userHandler.RevokeRole)
 		})
 	})
+	router.Get("/roles", userHandler.ListRoles)
+
+	// Administration
+	router.Route("/admin/backups", func(r chi.Router) {
+		r.Post("/", userHandler.CreateBackup)
+		r.Get("/", userHandler.ListBackups)
+		r.Post("/{name}/restore", userHandler.RestoreBackup)
+	})
+	router.Route("/admin/retention", func(r chi.Router) {
+		r.Get("/", userHandler.RetentionReport)
+		r.Post("/", userHandler.EnforceRetention)
+	})
+
+	if injector != nil {
+		router.Route("/admin/chaos", func(r chi.Router) {
+			r.Get("/", userHandler.ChaosRules)
+			r.Put("/", userHandler.SetChaosRules)
+			r.Delete("/", userHandler.ClearChaosRules)
+		})
+	}
+
+	// Counters, including the user cache statistics when it is enabled
+	router.Handle("/debug/vars", expvar.Handler())
 
 	// Health check
 	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -68,7 +276,7 @@ func main() {
 
 	// Start server
 	server := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Addr,
 		Handler: router,
 	}
 
@@ -88,10 +296,117 @@ func main() {
 		}
 	}()
 
-	log.Println("Server starting on :8080")
+	log.Printf("Server starting on %s (store: %s)", cfg.Addr, cfg.Store)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 
 	log.Println("Server stopped")
-}
\ No newline at end of file
+}
+
+// stores holds the repositories selected by the configuration
+type stores struct {
+	users   repository.UserRepository
+	history repository.HistoryRepository
+
+	// tx is nil when transactions are disabled
+	tx repository.TransactionManager
+
+	// encrypted is the user store seen through the email encryption, and
+	// keyring its keys, when it is enabled
+	encrypted *encryption.EncryptedUserRepository
+	keyring   *encryption.Keyring
+
+	// close releases any resources held by the repositories
+	close func() error
+}
+
+// newStores opens the stores selected by the configuration and, when a
+// keyring is configured, seals the emails written to them
+func newStores(cfg config) (*stores, error) {
+	s, err := openStores(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Transactions {
+		s.tx = nil
+	}
+	if cfg.EmailKeyring == "" {
+		return s, nil
+	}
+
+	keyring, err := encryption.LoadKeyring(cfg.EmailKeyring)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	s.keyring = keyring
+	s.encrypted = encryption.NewEncryptedUserRepository(s.users, keyring)
+	s.users = s.encrypted
+	s.history = encryption.NewEncryptedHistoryRepository(s.history, keyring)
+	return s, nil
+}
+
+// backups returns the backup store, which seals the emails of backups when
+// the emails of the user store are
+func (s *stores) backups(cfg config) repository.BackupStore {
+	var store repository.BackupStore = backup.NewFileStore(cfg.backupOptions())
+	if s.keyring != nil {
+		store = encryption.NewEncryptedBackupStore(store, s.keyring)
+	}
+	return store
+}
+
+// openStores creates the user and history stores selected by the
+// configuration, along with the transaction manager spanning them. The memory store keeps the history only for the lifetime
+// of the process, as does the sharded store, which keeps its users that
+// way too.
+func openStores(cfg config) (*stores, error) {
+	switch cfg.Store {
+	case storeSQLite:
+		db, err := database.OpenSQLite(cfg.DatabasePath)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.AutoMigrate {
+			if err := migrateUp(context.Background(), db); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+		return &stores{
+			users:   database.NewSQLUserRepository(db),
+			history: database.NewSQLHistoryRepository(db),
+			tx:      database.NewSQLTransactionManager(db),
+			close:   db.Close,
+		}, nil
+	case storeSharded:
+		return &stores{
+			users:   database.NewShardedInMemoryUserRepository(cfg.Shards),
+			history: database.NewInMemoryHistoryRepository(),
+			close:   func() error { return nil },
+		}, nil
+	default:
+		s := &stores{
+			history: database.NewInMemoryHistoryRepository(),
+			tx:      database.NewInMemoryTransactionManager(),
+			close:   func() error { return nil },
+		}
+		if cfg.DataDir == "" {
+			s.users = database.NewInMemoryUserRepository()
+			return s, nil
+		}
+		repo, err := database.NewDurableInMemoryUserRepository(database.DurableOptions{
+			Dir:              cfg.DataDir,
+			SyncWrites:       cfg.WALSync,
+			SnapshotEvery:    cfg.SnapshotEvery,
+			SnapshotInterval: cfg.SnapshotInterval,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.users = repo
+		s.close = repo.Close
+		return s, nil
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+// runRetention implements `server retention [flags] report|enforce`.
+// report shows what enforcing the retention policy on the configured store
+// would do without warning or deleting anyone; enforce does it once, as the
+// server's background job does. Every tenant is covered.
+func runRetention(args []string) error {
+	cfg, rest, err := loadConfig("retention", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 || (rest[0] != "report" && rest[0] != "enforce") {
+		return fmt.Errorf("usage: server retention [flags] report|enforce")
+	}
+	dryRun := rest[0] == "report"
+
+	retention := cfg.retention()
+	if retention == nil {
+		return errors.New("no retention policy configured, set -retention-months or RETENTION_MONTHS")
+	}
+
+	repos, err := newStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer repos.close()
+
+	userUseCase := use_cases.NewUserUseCase(repos.users,
+		use_cases.WithHistory(repos.history),
+		use_cases.WithTransactions(repos.tx),
+		retention,
+	)
+	report, err := userUseCase.EnforceRetention(repository.IncludeAllTenants(context.Background()), dryRun)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tUPDATED\tSTATUS\tDELETE AFTER\tERROR")
+	for _, row := range report.Rows {
+		deleteAfter, message := "", ""
+		if !row.DeleteAfter.IsZero() {
+			deleteAfter = row.DeleteAfter.UTC().Format(time.RFC3339)
+		}
+		if row.Err != nil {
+			message = row.Err.Error()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", row.ID, row.Email, row.Updated.UTC().Format(time.RFC3339), row.Status, deleteAfter, message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	summary := "Warned %d users and deleted %d; %d pending, %d on legal hold, %d failed\n"
+	if report.DryRun {
+		summary = "Would warn %d users and delete %d; %d pending, %d on legal hold, %d failed\n"
+	}
+	fmt.Printf(summary, report.Warned, report.Deleted, report.Pending, report.Held, report.Failed)
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/interfaces/userio"
+	"agent-orchestration/use_cases"
+)
+
+// runExport implements `server export [flags] jsonl|csv [file]`. It writes
+// every user of the configured tenant, soft-deleted ones included, to file
+// or to standard output.
+func runExport(args []string) error {
+	cfg, rest, err := loadConfig("export", args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 || len(rest) > 2 {
+		return fmt.Errorf("usage: server export [flags] jsonl|csv [file]")
+	}
+	format, err := userio.ParseFormat(rest[0])
+	if err != nil {
+		return err
+	}
+
+	repos, err := newStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer repos.close()
+
+	out := os.Stdout
+	if len(rest) == 2 && rest[1] != "-" {
+		if out, err = os.Create(rest[1]); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	writer := userio.NewWriter(out, format)
+	exported := 0
+	ctx := repository.IncludeDeleted(cfg.tenantContext(context.Background()))
+	err = use_cases.NewUserUseCase(repos.users).ExportUsers(ctx, func(user *entities.User) error {
+		exported++
+		return writer.Write(user)
+	})
+	if err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil && out != os.Stdout {
+		return err
+	}
+
+	log.Printf("Exported %d users", exported)
+	return nil
+}
+
+// runImport implements `server import [flags] jsonl|csv [-dry-run] [file]`.
+// It creates the users read from file, or from standard input, in the
+// configured tenant and prints a report of every row.
+func runImport(args []string) error {
+	cfg, rest, err := loadConfig("import", args)
+	if err != nil {
+		return err
+	}
+	usage := fmt.Errorf("usage: server import [flags] jsonl|csv [-dry-run] [file]")
+	if len(rest) == 0 {
+		return usage
+	}
+	format, err := userio.ParseFormat(rest[0])
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "check the rows without creating any user")
+	if err := fs.Parse(rest[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usage
+	}
+
+	in := io.Reader(os.Stdin)
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	repos, err := newStores(cfg)
+	if err != nil {
+		return err
+	}
+	defer repos.close()
+
+	reader, err := userio.NewReader(in, format)
+	if err != nil {
+		return err
+	}
+	userUseCase := use_cases.NewUserUseCase(repos.users,
+		use_cases.WithHistory(repos.history),
+		use_cases.WithTransactions(repos.tx),
+	)
+	report, err := userUseCase.ImportUsers(cfg.tenantContext(context.Background()), reader, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tSTATUS\tID\tEMAIL\tERROR")
+	for _, row := range report.Rows {
+		id, message := "", ""
+		if row.ID != 0 {
+			id = fmt.Sprint(row.ID)
+		}
+		if row.Err != nil {
+			message = row.Err.Error()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", row.Line, row.Status, id, row.Email, message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	verb := "Created"
+	if report.DryRun {
+		verb = "Would create"
+	}
+	fmt.Printf("%s %d users, skipped %d duplicates and %d invalid rows\n", verb, report.Created, report.Duplicates, report.Invalid)
+	return nil
+}
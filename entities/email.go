@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// NormalizeEmail returns the canonical form of an email address, which is
+// used for uniqueness and lookups. Surrounding whitespace is trimmed and the
+// domain, the part after the last "@", is lowercased and converted to its
+// punycode form, so "John@Bücher.example " and "John@xn--bcher-kva.example"
+// are the same address. The local part is kept as given, since RFC 5321
+// leaves its case to the receiving mail server.
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], strings.ToLower(email[at+1:])
+
+	// Leave domains that are not valid IDNA untouched; validation reports
+	// them separately
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+	return local + "@" + domain
+}
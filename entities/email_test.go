@@ -0,0 +1,35 @@
+package entities_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+)
+
+var _ = Describe("NormalizeEmail", func() {
+	DescribeTable("canonical forms",
+		func(email, expected string) {
+			Expect(entities.NormalizeEmail(email)).To(Equal(expected))
+		},
+		Entry("already canonical", "john@example.com", "john@example.com"),
+		Entry("mixed case domain", "John.Doe@Example.COM", "John.Doe@example.com"),
+		Entry("surrounding whitespace", "  john@example.com\t\n", "john@example.com"),
+		Entry("internationalized domain", "user@Bücher.example", "user@xn--bcher-kva.example"),
+		Entry("punycode domain", "user@XN--BCHER-KVA.example", "user@xn--bcher-kva.example"),
+		Entry("quoted local part with @", `"a@b"@example.com`, `"a@b"@example.com`),
+		Entry("no @", " Not-An-Email ", "Not-An-Email"),
+	)
+
+	It("should give the same key to addresses that differ only in form", func() {
+		a := &entities.User{Email: " John@Bücher.Example "}
+		b := &entities.User{Email: "John@xn--bcher-kva.example"}
+		Expect(a.EmailKey()).To(Equal(b.EmailKey()))
+	})
+
+	It("should tell apart addresses whose local parts differ in case", func() {
+		a := &entities.User{Email: "John@example.com"}
+		b := &entities.User{Email: "john@example.com"}
+		Expect(a.EmailKey()).NotTo(Equal(b.EmailKey()))
+	})
+})
@@ -1,15 +1,226 @@
 package entities
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
+
+// ErrorCode identifies the kind of a domain error. Codes are stable, so
+// clients can rely on them where messages may change.
+type ErrorCode string
+
+// Error codes
+const (
+	CodeUserNotFound        ErrorCode = "user_not_found"
+	CodeUserNameRequired    ErrorCode = "user_name_required"
+	CodeUserEmailRequired   ErrorCode = "user_email_required"
+	CodeUserAlreadyExists   ErrorCode = "user_already_exists"
+	CodeVersionConflict     ErrorCode = "version_conflict"
+	CodePreconditionFailed  ErrorCode = "precondition_failed"
+	CodeUserNotDeleted      ErrorCode = "user_not_deleted"
+	CodeInvalidID           ErrorCode = "invalid_id"
+	CodeInvalidPagination   ErrorCode = "invalid_pagination"
+	CodeInvalidCursor       ErrorCode = "invalid_cursor"
+	CodeInvalidSortField    ErrorCode = "invalid_sort_field"
+	CodeSearchQueryRequired ErrorCode = "search_query_required"
+	CodeInvalidParameter    ErrorCode = "invalid_parameter"
+	CodeInvalidRecord       ErrorCode = "invalid_record"
+	CodeInternal            ErrorCode = "internal"
+	CodeBackupNotFound      ErrorCode = "backup_not_found"
+	CodeInvalidBackup       ErrorCode = "invalid_backup"
+	CodeBackupsUnsupported  ErrorCode = "backups_unsupported"
+	CodeRetentionDisabled   ErrorCode = "retention_disabled"
+	CodeWatchTooFarBehind   ErrorCode = "watch_too_far_behind"
+	CodeInvalidSequence     ErrorCode = "invalid_sequence"
+	CodeWatchUnsupported    ErrorCode = "watch_unsupported"
+	CodeValidationFailed    ErrorCode = "validation_failed"
+	CodeUserNameTooLong     ErrorCode = "user_name_too_long"
+	CodeInvalidUserName     ErrorCode = "invalid_user_name"
+	CodeInvalidEmail        ErrorCode = "invalid_email"
+
+	// Status codes
+	CodeInvalidStatus           ErrorCode = "invalid_status"
+	CodeInvalidStatusTransition ErrorCode = "invalid_status_transition"
+	CodeStatusReasonRequired    ErrorCode = "status_reason_required"
+
+	// Access control codes
+	CodeForbidden   ErrorCode = "forbidden"
+	CodeUnknownRole ErrorCode = "unknown_role"
+
+	// Tenant codes
+	CodeInvalidTenant ErrorCode = "invalid_tenant"
+)
+
+// Error is a domain error. Errors with the same Code are the same error to
+// errors.Is, whatever their field, details or cause, so the sentinels below
+// keep matching errors derived from them with WithField, WithDetail or
+// Wrap.
+//
+// A validation error gathers every violation found in its Violations, and
+// errors.Is matches it against the code of any of them as well.
+type Error struct {
+	Code    ErrorCode
+	Message string
+
+	// Field names the input the error is about, if any
+	Field string
+
+	// Details describe the error further, for clients
+	Details map[string]any
+
+	// Err is the underlying cause, if any
+	Err error
+
+	// Violations are the individual failures of a validation error
+	Violations []*Error
+}
+
+// NewError creates a domain error
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Error returns the message, followed by the violations or the cause if
+// there are any
+func (e *Error) Error() string {
+	if len(e.Violations) > 0 {
+		messages := make([]string, len(e.Violations))
+		for i, violation := range e.Violations {
+			messages[i] = violation.Error()
+		}
+		return e.Message + ": " + strings.Join(messages, "; ")
+	}
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is a domain error with the same code as the
+// error or one of its violations
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if t.Code == e.Code {
+		return true
+	}
+	for _, violation := range e.Violations {
+		if violation.Code == t.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// WithField returns a copy of the error about field
+func (e *Error) WithField(field string) *Error {
+	derived := *e
+	derived.Field = field
+	return &derived
+}
+
+// WithDetail returns a copy of the error with the detail key set to value
+func (e *Error) WithDetail(key string, value any) *Error {
+	derived := *e
+	derived.Details = make(map[string]any, len(e.Details)+1)
+	for k, v := range e.Details {
+		derived.Details[k] = v
+	}
+	derived.Details[key] = value
+	return &derived
+}
+
+// Wrap returns a copy of the error caused by err
+func (e *Error) Wrap(err error) *Error {
+	derived := *e
+	derived.Err = err
+	return &derived
+}
+
+// CodeOf returns the code of the domain error in the chain of err, or
+// CodeInternal if there is none
+func CodeOf(err error) ErrorCode {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	return CodeInternal
+}
+
+// NewValidationError creates the error reporting violations, which must not
+// be empty
+func NewValidationError(violations ...*Error) *Error {
+	err := *ErrValidationFailed
+	err.Violations = violations
+	return &err
+}
+
+// Violations returns the violations of the validation error in the chain of
+// err, if there is one
+func Violations(err error) []*Error {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Violations
+	}
+	return nil
+}
 
 var (
 	// User errors
-	ErrUserNotFound      = errors.New("user not found")
-	ErrUserNameRequired  = errors.New("user name is required")
-	ErrUserEmailRequired = errors.New("user email is required")
-	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrUserNotFound       = NewError(CodeUserNotFound, "user not found")
+	ErrUserNameRequired   = NewError(CodeUserNameRequired, "user name is required").WithField("name")
+	ErrUserEmailRequired  = NewError(CodeUserEmailRequired, "user email is required").WithField("email")
+	ErrUserAlreadyExists  = NewError(CodeUserAlreadyExists, "user already exists").WithField("email")
+	ErrVersionConflict    = NewError(CodeVersionConflict, "user was modified concurrently")
+	ErrPreconditionFailed = NewError(CodePreconditionFailed, "user version does not match")
+	ErrUserNotDeleted     = NewError(CodeUserNotDeleted, "user is not deleted")
+
+	// Validation errors. The ones about a field are reported as violations
+	// of ErrValidationFailed.
+	ErrValidationFailed = NewError(CodeValidationFailed, "user is invalid")
+	ErrUserNameTooLong  = NewError(CodeUserNameTooLong, "user name is too long").WithField("name")
+	ErrInvalidUserName  = NewError(CodeInvalidUserName, "user name contains control characters").WithField("name")
+	ErrInvalidEmail     = NewError(CodeInvalidEmail, "user email is not a valid address").WithField("email")
+
+	// Status errors
+	ErrInvalidStatus           = NewError(CodeInvalidStatus, "unknown user status")
+	ErrInvalidStatusTransition = NewError(CodeInvalidStatusTransition, "user status transition is not allowed")
+	ErrStatusReasonRequired    = NewError(CodeStatusReasonRequired, "status change reason is required").WithField("reason")
+
+	// Access control errors
+	ErrForbidden   = NewError(CodeForbidden, "principal is not allowed to do this")
+	ErrUnknownRole = NewError(CodeUnknownRole, "unknown role")
+
+	// Tenant errors
+	ErrInvalidTenant = NewError(CodeInvalidTenant, "invalid tenant ID")
 
 	// General errors
-	ErrInvalidID         = errors.New("invalid ID")
-	ErrInternalServer    = errors.New("internal server error")
-)
\ No newline at end of file
+	ErrInvalidID           = NewError(CodeInvalidID, "invalid ID")
+	ErrInvalidPagination   = NewError(CodeInvalidPagination, "invalid pagination parameters")
+	ErrInvalidCursor       = NewError(CodeInvalidCursor, "invalid cursor")
+	ErrInvalidSortField    = NewError(CodeInvalidSortField, "invalid sort field")
+	ErrSearchQueryRequired = NewError(CodeSearchQueryRequired, "search query is required")
+	ErrInvalidParameter    = NewError(CodeInvalidParameter, "invalid query parameter")
+	ErrInvalidRecord       = NewError(CodeInvalidRecord, "invalid record")
+	ErrInternalServer      = NewError(CodeInternal, "internal server error")
+
+	// Backup errors
+	ErrBackupNotFound     = NewError(CodeBackupNotFound, "backup not found")
+	ErrInvalidBackup      = NewError(CodeInvalidBackup, "invalid backup")
+	ErrBackupsUnsupported = NewError(CodeBackupsUnsupported, "backups are not supported by the user store")
+
+	// Retention errors
+	ErrRetentionDisabled = NewError(CodeRetentionDisabled, "no retention policy is configured")
+
+	// Change stream errors
+	ErrWatchTooFarBehind = NewError(CodeWatchTooFarBehind, "watcher is too far behind the change stream")
+	ErrInvalidSequence   = NewError(CodeInvalidSequence, "sequence number has not been issued")
+	ErrWatchUnsupported  = NewError(CodeWatchUnsupported, "watching changes is not supported by the user store")
+)
@@ -0,0 +1,67 @@
+package entities_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+)
+
+var _ = Describe("Error", func() {
+	It("should match errors derived from it by code", func() {
+		derived := entities.ErrUserNotFound.WithDetail("id", 7)
+
+		Expect(errors.Is(derived, entities.ErrUserNotFound)).To(BeTrue())
+		Expect(errors.Is(fmt.Errorf("loading user: %w", derived), entities.ErrUserNotFound)).To(BeTrue())
+		Expect(errors.Is(derived, entities.ErrUserAlreadyExists)).To(BeFalse())
+		Expect(entities.ErrUserNotFound.Details).To(BeEmpty())
+	})
+
+	It("should be found in a chain of errors", func() {
+		err := fmt.Errorf("saving: %w", entities.ErrUserEmailRequired)
+
+		var domainErr *entities.Error
+		Expect(errors.As(err, &domainErr)).To(BeTrue())
+		Expect(domainErr.Code).To(Equal(entities.CodeUserEmailRequired))
+		Expect(domainErr.Field).To(Equal("email"))
+		Expect(entities.CodeOf(err)).To(Equal(entities.CodeUserEmailRequired))
+	})
+
+	It("should describe and unwrap its cause", func() {
+		cause := errors.New("checksum mismatch")
+		err := entities.ErrInvalidBackup.Wrap(cause)
+
+		Expect(err.Error()).To(Equal("invalid backup: checksum mismatch"))
+		Expect(errors.Is(err, cause)).To(BeTrue())
+		Expect(errors.Is(err, entities.ErrInvalidBackup)).To(BeTrue())
+	})
+
+	It("should keep the field and details of the error it derives from", func() {
+		err := entities.NewError(entities.CodeInvalidID, "invalid ID").
+			WithField("id").
+			WithDetail("min", 1).
+			WithDetail("max", 10)
+
+		Expect(err.Field).To(Equal("id"))
+		Expect(err.Details).To(Equal(map[string]any{"min": 1, "max": 10}))
+	})
+
+	It("should match a validation error by the codes of its violations", func() {
+		err := fmt.Errorf("creating user: %w", entities.NewValidationError(entities.ErrUserNameRequired, entities.ErrInvalidEmail))
+
+		Expect(errors.Is(err, entities.ErrValidationFailed)).To(BeTrue())
+		Expect(errors.Is(err, entities.ErrUserNameRequired)).To(BeTrue())
+		Expect(errors.Is(err, entities.ErrInvalidEmail)).To(BeTrue())
+		Expect(errors.Is(err, entities.ErrUserEmailRequired)).To(BeFalse())
+		Expect(entities.CodeOf(err)).To(Equal(entities.CodeValidationFailed))
+		Expect(entities.Violations(err)).To(HaveLen(2))
+		Expect(err).To(MatchError("creating user: user is invalid: user name is required; user email is not a valid address"))
+	})
+
+	It("should report other errors as internal", func() {
+		Expect(entities.CodeOf(errors.New("disk full"))).To(Equal(entities.CodeInternal))
+	})
+})
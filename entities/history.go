@@ -0,0 +1,102 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// Operations recorded in a user's change history
+const (
+	OperationCreated  = "created"
+	OperationUpdated  = "updated"
+	OperationDeleted  = "deleted"
+	OperationRestored = "restored"
+	OperationPurged   = "purged"
+	OperationHeld     = "held"
+	OperationReleased = "released"
+	OperationWarned   = "warned"
+
+	// Status changes
+	OperationActivated   = "activated"
+	OperationSuspended   = "suspended"
+	OperationDeactivated = "deactivated"
+
+	// Role assignments
+	OperationRoleAssigned = "role_assigned"
+	OperationRoleRevoked  = "role_revoked"
+)
+
+// FieldChange is the before and after value of a single user field. Values
+// are rendered as strings; an empty value means the field was unset.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// HistoryEntry records one change made to a user. TenantID is the tenant
+// of the user, the only one its history is listed in.
+type HistoryEntry struct {
+	ID        int           `json:"id"`
+	UserID    int           `json:"user_id"`
+	Operation string        `json:"operation"`
+	Changes   []FieldChange `json:"changes"`
+	Timestamp time.Time     `json:"timestamp"`
+	RequestID string        `json:"request_id,omitempty"`
+	Principal string        `json:"principal,omitempty"`
+	TenantID  string        `json:"tenant_id,omitempty"`
+}
+
+// Tenant returns the tenant of the entry. Entries recorded before tenants
+// were introduced have none and belong to DefaultTenant.
+func (e *HistoryEntry) Tenant() string {
+	if e.TenantID == "" {
+		return DefaultTenant
+	}
+	return e.TenantID
+}
+
+// DiffUsers returns the changed fields between two states of a user. A nil
+// before or after stands for a user that does not exist.
+func DiffUsers(before, after *User) []FieldChange {
+	var b, a User
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+
+	changes := make([]FieldChange, 0)
+	add := func(field, before, after string) {
+		if before != after {
+			changes = append(changes, FieldChange{Field: field, Before: before, After: after})
+		}
+	}
+
+	add("name", b.Name, a.Name)
+	add("email", b.Email, a.Email)
+	add("deleted_at", formatTime(b.DeletedAt), formatTime(a.DeletedAt))
+	add("legal_hold", formatBool(b.LegalHold), formatBool(a.LegalHold))
+	add("inactivity_warned_at", formatTime(b.InactivityWarnedAt), formatTime(a.InactivityWarnedAt))
+	add("status", string(b.Status), string(a.Status))
+	add("status_reason", b.StatusReason, a.StatusReason)
+	add("roles", strings.Join(b.Roles, ","), strings.Join(a.Roles, ","))
+	return changes
+}
+
+// formatTime renders an optional timestamp for a field change
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// formatBool renders a flag for a field change, leaving an unset one empty
+func formatBool(b bool) string {
+	if !b {
+		return ""
+	}
+	return "true"
+}
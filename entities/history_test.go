@@ -0,0 +1,93 @@
+package entities_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+)
+
+var _ = Describe("DiffUsers", func() {
+	var user *entities.User
+
+	BeforeEach(func() {
+		user = &entities.User{
+			ID:    1,
+			Name:  "John Doe",
+			Email: "john@example.com",
+		}
+	})
+
+	It("should list every field of a created user", func() {
+		changes := entities.DiffUsers(nil, user)
+		Expect(changes).To(ConsistOf(
+			entities.FieldChange{Field: "name", Before: "", After: "John Doe"},
+			entities.FieldChange{Field: "email", Before: "", After: "john@example.com"},
+		))
+	})
+
+	It("should only list fields that changed", func() {
+		after := *user
+		after.Email = "johnny@example.com"
+		after.Updated = time.Now().Add(time.Hour)
+
+		changes := entities.DiffUsers(user, &after)
+		Expect(changes).To(Equal([]entities.FieldChange{
+			{Field: "email", Before: "john@example.com", After: "johnny@example.com"},
+		}))
+	})
+
+	It("should render the deletion timestamp", func() {
+		deletedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		after := *user
+		after.DeletedAt = &deletedAt
+
+		changes := entities.DiffUsers(user, &after)
+		Expect(changes).To(Equal([]entities.FieldChange{
+			{Field: "deleted_at", Before: "", After: "2024-01-02T03:04:05Z"},
+		}))
+	})
+
+	It("should render the legal hold and inactivity warning", func() {
+		warnedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		after := *user
+		after.LegalHold = true
+		after.InactivityWarnedAt = &warnedAt
+
+		changes := entities.DiffUsers(user, &after)
+		Expect(changes).To(Equal([]entities.FieldChange{
+			{Field: "legal_hold", Before: "", After: "true"},
+			{Field: "inactivity_warned_at", Before: "", After: "2024-01-02T03:04:05Z"},
+		}))
+	})
+
+	It("should render a status change with its reason", func() {
+		after := *user
+		Expect(after.ChangeStatus(entities.StatusSuspended, "chargeback")).To(Succeed())
+
+		changes := entities.DiffUsers(user, &after)
+		Expect(changes).To(Equal([]entities.FieldChange{
+			{Field: "status", Before: "", After: "suspended"},
+			{Field: "status_reason", Before: "", After: "chargeback"},
+		}))
+	})
+
+	It("should render the roles as a list", func() {
+		after := *user
+		Expect(after.AssignRole(entities.RoleSupport)).To(Succeed())
+		Expect(after.AssignRole(entities.RoleAdmin)).To(Succeed())
+
+		changes := entities.DiffUsers(user, &after)
+		Expect(changes).To(Equal([]entities.FieldChange{
+			{Field: "roles", Before: "", After: "admin,support"},
+		}))
+	})
+
+	It("should return an empty, non-nil slice when nothing changed", func() {
+		changes := entities.DiffUsers(user, user)
+		Expect(changes).NotTo(BeNil())
+		Expect(changes).To(BeEmpty())
+	})
+})
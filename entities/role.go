@@ -0,0 +1,137 @@
+package entities
+
+import (
+	"slices"
+	"sort"
+)
+
+// Permission allows a kind of operation on users
+type Permission string
+
+// Permissions
+const (
+	// PermissionReadUsers allows reading, listing, searching, exporting and
+	// watching users and their history
+	PermissionReadUsers Permission = "users:read"
+
+	// PermissionCreateUsers allows creating and importing users
+	PermissionCreateUsers Permission = "users:create"
+
+	// PermissionUpdateUsers allows changing the name and email of users
+	PermissionUpdateUsers Permission = "users:update"
+
+	// PermissionDeleteUsers allows deleting, restoring and purging users
+	PermissionDeleteUsers Permission = "users:delete"
+
+	// PermissionSuspendUsers allows suspending users
+	PermissionSuspendUsers Permission = "users:suspend"
+
+	// PermissionChangeUserStatus allows activating and deactivating users
+	PermissionChangeUserStatus Permission = "users:status"
+
+	// PermissionAdministerUsers allows the operations on the user store as
+	// a whole: backups, retention, legal holds and duplicate reports
+	PermissionAdministerUsers Permission = "users:admin"
+
+	// PermissionManageRoles allows assigning roles to users and revoking them
+	PermissionManageRoles Permission = "roles:manage"
+)
+
+// Role is a named set of permissions assigned to users. The permissions of
+// a role limited to the own record only apply to the user the principal
+// acts as.
+type Role struct {
+	Name          string       `json:"name"`
+	Permissions   []Permission `json:"permissions"`
+	OwnRecordOnly bool         `json:"own_record_only,omitempty"`
+}
+
+// Built-in roles
+const (
+	RoleAdmin       = "admin"
+	RoleSupport     = "support"
+	RoleSelfService = "self-service"
+)
+
+// builtinRoles holds the roles users can be assigned, by name
+var builtinRoles = map[string]Role{
+	RoleAdmin: {
+		Name: RoleAdmin,
+		Permissions: []Permission{
+			PermissionReadUsers,
+			PermissionCreateUsers,
+			PermissionUpdateUsers,
+			PermissionDeleteUsers,
+			PermissionSuspendUsers,
+			PermissionChangeUserStatus,
+			PermissionAdministerUsers,
+			PermissionManageRoles,
+		},
+	},
+	RoleSupport: {
+		Name:        RoleSupport,
+		Permissions: []Permission{PermissionReadUsers, PermissionSuspendUsers},
+	},
+	RoleSelfService: {
+		Name:          RoleSelfService,
+		Permissions:   []Permission{PermissionReadUsers, PermissionUpdateUsers, PermissionDeleteUsers},
+		OwnRecordOnly: true,
+	},
+}
+
+// LookupRole returns the role called name, or ErrUnknownRole
+func LookupRole(name string) (*Role, error) {
+	role, ok := builtinRoles[name]
+	if !ok {
+		return nil, ErrUnknownRole.WithDetail("role", name)
+	}
+	role.Permissions = slices.Clone(role.Permissions)
+	return &role, nil
+}
+
+// Roles returns every role, ordered by name
+func Roles() []*Role {
+	roles := make([]*Role, 0, len(builtinRoles))
+	for name := range builtinRoles {
+		role, _ := LookupRole(name)
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+// Grants reports whether the role has permission
+func (r *Role) Grants(permission Permission) bool {
+	return slices.Contains(r.Permissions, permission)
+}
+
+// HasRole reports whether the user is assigned the role called name
+func (u *User) HasRole(name string) bool {
+	return slices.Contains(u.Roles, name)
+}
+
+// AssignRole assigns the role called name to the user. The roles are kept
+// sorted, in a new slice, so copies of the user don't share them.
+func (u *User) AssignRole(name string) error {
+	if _, err := LookupRole(name); err != nil {
+		return err
+	}
+	if u.HasRole(name) {
+		return nil
+	}
+	roles := append(slices.Clone(u.Roles), name)
+	sort.Strings(roles)
+	u.Roles = roles
+	return nil
+}
+
+// RevokeRole revokes the role called name from the user, if it has it
+func (u *User) RevokeRole(name string) {
+	if !u.HasRole(name) {
+		return
+	}
+	u.Roles = slices.DeleteFunc(slices.Clone(u.Roles), func(role string) bool { return role == name })
+	if len(u.Roles) == 0 {
+		u.Roles = nil
+	}
+}
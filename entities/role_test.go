@@ -0,0 +1,91 @@
+package entities_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+)
+
+var _ = Describe("Role", func() {
+	DescribeTable("built-in roles",
+		func(name string, permission entities.Permission, granted bool) {
+			role, err := entities.LookupRole(name)
+			Expect(err).To(BeNil())
+			Expect(role.Grants(permission)).To(Equal(granted))
+		},
+		Entry("admin manages roles", entities.RoleAdmin, entities.PermissionManageRoles, true),
+		Entry("admin administers users", entities.RoleAdmin, entities.PermissionAdministerUsers, true),
+		Entry("support reads users", entities.RoleSupport, entities.PermissionReadUsers, true),
+		Entry("support suspends users", entities.RoleSupport, entities.PermissionSuspendUsers, true),
+		Entry("support doesn't activate users", entities.RoleSupport, entities.PermissionChangeUserStatus, false),
+		Entry("support doesn't delete users", entities.RoleSupport, entities.PermissionDeleteUsers, false),
+		Entry("self-service updates users", entities.RoleSelfService, entities.PermissionUpdateUsers, true),
+		Entry("self-service doesn't create users", entities.RoleSelfService, entities.PermissionCreateUsers, false),
+	)
+
+	It("should limit self-service to the own record", func() {
+		role, _ := entities.LookupRole(entities.RoleSelfService)
+		Expect(role.OwnRecordOnly).To(BeTrue())
+	})
+
+	It("should reject an unknown role", func() {
+		_, err := entities.LookupRole("root")
+		Expect(err).To(MatchError(entities.ErrUnknownRole))
+	})
+
+	It("should list the roles by name without sharing them", func() {
+		roles := entities.Roles()
+		names := []string{}
+		for _, role := range roles {
+			names = append(names, role.Name)
+		}
+		Expect(names).To(Equal([]string{entities.RoleAdmin, entities.RoleSelfService, entities.RoleSupport}))
+
+		roles[0].Permissions[0] = "users:everything"
+		role, _ := entities.LookupRole(entities.RoleAdmin)
+		Expect(role.Permissions[0]).To(Equal(entities.PermissionReadUsers))
+	})
+
+	Describe("User", func() {
+		It("should keep the roles sorted and unique", func() {
+			user := &entities.User{}
+			Expect(user.AssignRole(entities.RoleSupport)).To(Succeed())
+			Expect(user.AssignRole(entities.RoleAdmin)).To(Succeed())
+			Expect(user.AssignRole(entities.RoleSupport)).To(Succeed())
+			Expect(user.Roles).To(Equal([]string{entities.RoleAdmin, entities.RoleSupport}))
+		})
+
+		It("should not share the roles with a copy", func() {
+			user := &entities.User{Roles: []string{entities.RoleAdmin, entities.RoleSupport}}
+			before := *user
+
+			user.RevokeRole(entities.RoleAdmin)
+			Expect(user.AssignRole(entities.RoleSelfService)).To(Succeed())
+
+			Expect(before.Roles).To(Equal([]string{entities.RoleAdmin, entities.RoleSupport}))
+			Expect(user.Roles).To(Equal([]string{entities.RoleSelfService, entities.RoleSupport}))
+		})
+
+		It("should drop the roles when the last one is revoked", func() {
+			user := &entities.User{Roles: []string{entities.RoleSupport}}
+			user.RevokeRole(entities.RoleSupport)
+			Expect(user.Roles).To(BeNil())
+		})
+
+		It("should refuse to assign an unknown role", func() {
+			user := &entities.User{}
+			Expect(user.AssignRole("root")).To(MatchError(entities.ErrUnknownRole))
+			Expect(user.Roles).To(BeEmpty())
+		})
+
+		It("should reject an unknown role on validation", func() {
+			user := &entities.User{Name: "John Doe", Email: "john@example.com", Roles: []string{"root"}}
+
+			err := user.Validate()
+
+			Expect(err).To(MatchError(entities.ErrUnknownRole))
+			Expect(entities.Violations(err)[0].Field).To(Equal("roles"))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// Status is the stage of its lifecycle a user is at
+type Status string
+
+// User statuses. A new user is pending until it is activated.
+const (
+	StatusPending     Status = "pending"
+	StatusActive      Status = "active"
+	StatusSuspended   Status = "suspended"
+	StatusDeactivated Status = "deactivated"
+)
+
+// statusTransitions lists the statuses a user may move to from each status.
+// A user never moves to the status it is already at.
+var statusTransitions = map[Status][]Status{
+	StatusPending:     {StatusActive, StatusDeactivated},
+	StatusActive:      {StatusSuspended, StatusDeactivated},
+	StatusSuspended:   {StatusActive, StatusDeactivated},
+	StatusDeactivated: {StatusActive},
+}
+
+// Valid reports whether s is a known status
+func (s Status) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanBecome reports whether the transition table allows a user at status s
+// to move to status to
+func (s Status) CanBecome(to Status) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentStatus returns the status of the user. Users stored before
+// statuses were introduced have none and are active.
+func (u *User) CurrentStatus() Status {
+	if u.Status == "" {
+		return StatusActive
+	}
+	return u.Status
+}
+
+// ChangeStatus moves the user to status, recording reason as the cause. It
+// returns ErrInvalidStatusTransition when the transition table does not
+// allow the move.
+func (u *User) ChangeStatus(status Status, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return NewValidationError(ErrStatusReasonRequired)
+	}
+	if from := u.CurrentStatus(); !from.CanBecome(status) {
+		return ErrInvalidStatusTransition.
+			WithDetail("from", string(from)).
+			WithDetail("to", string(status))
+	}
+	u.Status = status
+	u.StatusReason = reason
+	u.Updated = time.Now()
+	return nil
+}
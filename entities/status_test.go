@@ -0,0 +1,96 @@
+package entities_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+)
+
+var _ = Describe("Status", func() {
+	DescribeTable("transition table",
+		func(from, to entities.Status, allowed bool) {
+			Expect(from.CanBecome(to)).To(Equal(allowed))
+		},
+		Entry("pending to active", entities.StatusPending, entities.StatusActive, true),
+		Entry("pending to suspended", entities.StatusPending, entities.StatusSuspended, false),
+		Entry("pending to deactivated", entities.StatusPending, entities.StatusDeactivated, true),
+		Entry("active to suspended", entities.StatusActive, entities.StatusSuspended, true),
+		Entry("active to deactivated", entities.StatusActive, entities.StatusDeactivated, true),
+		Entry("active to pending", entities.StatusActive, entities.StatusPending, false),
+		Entry("active to active", entities.StatusActive, entities.StatusActive, false),
+		Entry("suspended to active", entities.StatusSuspended, entities.StatusActive, true),
+		Entry("suspended to deactivated", entities.StatusSuspended, entities.StatusDeactivated, true),
+		Entry("deactivated to active", entities.StatusDeactivated, entities.StatusActive, true),
+		Entry("deactivated to suspended", entities.StatusDeactivated, entities.StatusSuspended, false),
+		Entry("unknown status", entities.Status("banned"), entities.StatusActive, false),
+	)
+
+	It("should only know the lifecycle statuses", func() {
+		Expect(entities.StatusSuspended.Valid()).To(BeTrue())
+		Expect(entities.Status("banned").Valid()).To(BeFalse())
+		Expect(entities.Status("").Valid()).To(BeFalse())
+	})
+
+	Describe("User", func() {
+		var user *entities.User
+
+		BeforeEach(func() {
+			user = &entities.User{
+				ID:      1,
+				Name:    "John Doe",
+				Email:   "john@example.com",
+				Updated: time.Now().Add(-time.Hour),
+				Status:  entities.StatusActive,
+			}
+		})
+
+		It("should count a user without a status as active", func() {
+			user.Status = ""
+			Expect(user.CurrentStatus()).To(Equal(entities.StatusActive))
+			Expect(user.ChangeStatus(entities.StatusSuspended, "chargeback")).To(Succeed())
+		})
+
+		It("should move along an allowed transition, recording the reason", func() {
+			updated := user.Updated
+
+			Expect(user.ChangeStatus(entities.StatusSuspended, "  chargeback ")).To(Succeed())
+
+			Expect(user.Status).To(Equal(entities.StatusSuspended))
+			Expect(user.StatusReason).To(Equal("chargeback"))
+			Expect(user.Updated).To(BeTemporally(">", updated))
+		})
+
+		It("should refuse a transition the table doesn't allow", func() {
+			user.Status = entities.StatusDeactivated
+
+			err := user.ChangeStatus(entities.StatusSuspended, "chargeback")
+
+			Expect(err).To(MatchError(entities.ErrInvalidStatusTransition))
+			Expect(entities.Violations(err)).To(BeEmpty())
+			var statusErr *entities.Error
+			Expect(errors.As(err, &statusErr)).To(BeTrue())
+			Expect(statusErr.Details).To(Equal(map[string]any{"from": "deactivated", "to": "suspended"}))
+			Expect(user.Status).To(Equal(entities.StatusDeactivated))
+		})
+
+		It("should require a reason", func() {
+			err := user.ChangeStatus(entities.StatusSuspended, " ")
+
+			Expect(err).To(MatchError(entities.ErrStatusReasonRequired))
+			Expect(user.Status).To(Equal(entities.StatusActive))
+		})
+
+		It("should reject an unknown status on validation", func() {
+			user.Status = "banned"
+
+			err := user.Validate()
+
+			Expect(err).To(MatchError(entities.ErrInvalidStatus))
+			Expect(entities.Violations(err)[0].Field).To(Equal("status"))
+		})
+	})
+})
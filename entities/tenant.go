@@ -0,0 +1,46 @@
+package entities
+
+// DefaultTenant is the tenant of calls that name none, and of the users
+// stored before tenants were introduced
+const DefaultTenant = "default"
+
+// MaxTenantIDLength is the maximum length of a tenant ID
+const MaxTenantIDLength = 63
+
+// ValidTenantID reports whether id is a valid tenant ID: up to
+// MaxTenantIDLength lowercase ASCII letters, digits and hyphens, starting
+// and ending with a letter or digit
+func ValidTenantID(id string) bool {
+	if id == "" || len(id) > MaxTenantIDLength || id[0] == '-' || id[len(id)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Tenant returns the tenant of the user. Users stored before tenants were
+// introduced have none and belong to DefaultTenant.
+func (u *User) Tenant() string {
+	if u.TenantID == "" {
+		return DefaultTenant
+	}
+	return u.TenantID
+}
+
+// TenantEmailKey returns the key repositories enforce email uniqueness
+// under: the email key of the user within its tenant
+func (u *User) TenantEmailKey() string {
+	return TenantEmailKey(u.Tenant(), u.EmailKey())
+}
+
+// TenantEmailKey returns the key of the email key emailKey within tenant.
+// Tenant IDs never contain a slash, so keys of different tenants never
+// collide.
+func TenantEmailKey(tenant, emailKey string) string {
+	return tenant + "/" + emailKey
+}
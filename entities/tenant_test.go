@@ -0,0 +1,45 @@
+package entities_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+)
+
+var _ = Describe("Tenants", func() {
+	DescribeTable("tenant IDs",
+		func(id string, valid bool) {
+			Expect(entities.ValidTenantID(id)).To(Equal(valid))
+		},
+		Entry("letters", "acme", true),
+		Entry("digits and hyphens", "acme-2", true),
+		Entry("maximum length", strings.Repeat("a", entities.MaxTenantIDLength), true),
+		Entry("empty", "", false),
+		Entry("too long", strings.Repeat("a", entities.MaxTenantIDLength+1), false),
+		Entry("uppercase", "Acme", false),
+		Entry("leading hyphen", "-acme", false),
+		Entry("trailing hyphen", "acme-", false),
+		Entry("slash", "acme/corp", false),
+	)
+
+	It("should put users without a tenant in the default tenant", func() {
+		user := &entities.User{Email: "John@Example.com"}
+		Expect(user.Tenant()).To(Equal(entities.DefaultTenant))
+		Expect(user.TenantEmailKey()).To(Equal("default/John@example.com"))
+
+		user.TenantID = "acme"
+		Expect(user.TenantEmailKey()).To(Equal("acme/John@example.com"))
+	})
+
+	It("should reject a malformed tenant ID on validation", func() {
+		user := &entities.User{Name: "John Doe", Email: "john@example.com", TenantID: "Acme"}
+
+		err := user.Validate()
+
+		Expect(err).To(MatchError(entities.ErrInvalidTenant))
+		Expect(entities.Violations(err)[0].Field).To(Equal("tenant_id"))
+	})
+})
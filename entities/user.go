@@ -1,25 +1,62 @@
 package entities
 
 import (
+	"strings"
 	"time"
 )
 
-// User represents a user entity
+// User represents a user entity.
+//
+// A soft-deleted user has DeletedAt set. It is hidden from normal reads but
+// keeps its email address reserved, so registering a new user with the same
+// email fails until the deleted user is purged. This guarantees a restore
+// can never collide with a newer account.
+//
+// A user on legal hold is exempt from the retention policy and is never
+// purged. InactivityWarnedAt records when the user was last warned that the
+// account is about to be removed for inactivity.
+//
+// Status moves through the transitions allowed by the status table only,
+// see ChangeStatus. StatusReason tells why the user was last moved.
+//
+// Roles names the roles assigned to the user, sorted. They grant their
+// permissions to the principal acting as the user, see Role.
+//
+// TenantID is the organization the user belongs to. It is set when the user
+// is created and never changes; emails are only unique within a tenant.
 type User struct {
-	ID       int       `json:"id"`
-	Name     string    `json:"name"`
-	Email    string    `json:"email"`
-	Created  time.Time `json:"created"`
-	Updated  time.Time `json:"updated"`
+	ID                 int        `json:"id"`
+	Name               string     `json:"name"`
+	Email              string     `json:"email"`
+	Created            time.Time  `json:"created"`
+	Updated            time.Time  `json:"updated"`
+	Version            int        `json:"version"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"`
+	LegalHold          bool       `json:"legal_hold,omitempty"`
+	InactivityWarnedAt *time.Time `json:"inactivity_warned_at,omitempty"`
+	Status             Status     `json:"status"`
+	StatusReason       string     `json:"status_reason,omitempty"`
+	Roles              []string   `json:"roles,omitempty"`
+	TenantID           string     `json:"tenant_id"`
 }
 
-// Validate validates user data
+// Validate validates user data, returning a validation error that reports
+// every violation found
 func (u *User) Validate() error {
-	if u.Name == "" {
-		return ErrUserNameRequired
+	violations := append(nameViolations(NormalizeName(u.Name)), emailViolations(u.Email)...)
+	if u.Status != "" && !u.Status.Valid() {
+		violations = append(violations, ErrInvalidStatus.WithField("status"))
 	}
-	if u.Email == "" {
-		return ErrUserEmailRequired
+	for _, name := range u.Roles {
+		if _, err := LookupRole(name); err != nil {
+			violations = append(violations, ErrUnknownRole.WithField("roles").WithDetail("role", name))
+		}
+	}
+	if u.TenantID != "" && !ValidTenantID(u.TenantID) {
+		violations = append(violations, ErrInvalidTenant.WithField("tenant_id"))
+	}
+	if len(violations) > 0 {
+		return NewValidationError(violations...)
 	}
 	return nil
 }
@@ -29,22 +66,62 @@ func (u *User) IsValid() bool {
 	return u.Validate() == nil
 }
 
-// UpdateName updates the user's name
+// UpdateName updates the user's name, stored in its normalized form
 func (u *User) UpdateName(name string) error {
-	if name == "" {
-		return ErrUserNameRequired
+	name = NormalizeName(name)
+	if violations := nameViolations(name); len(violations) > 0 {
+		return NewValidationError(violations...)
 	}
 	u.Name = name
 	u.Updated = time.Now()
 	return nil
 }
 
-// UpdateEmail updates the user's email
+// EmailKey returns the canonical form of the user's email, under which
+// repositories enforce uniqueness and look users up
+func (u *User) EmailKey() string {
+	return NormalizeEmail(u.Email)
+}
+
+// UpdateEmail updates the user's email. The address is kept as typed, apart
+// from surrounding whitespace, so it can be displayed back to the user.
 func (u *User) UpdateEmail(email string) error {
-	if email == "" {
-		return ErrUserEmailRequired
+	email = strings.TrimSpace(email)
+	if violations := emailViolations(email); len(violations) > 0 {
+		return NewValidationError(violations...)
 	}
 	u.Email = email
 	u.Updated = time.Now()
 	return nil
+}
+
+// IsDeleted returns true if the user has been soft-deleted
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// MarkDeleted soft-deletes the user
+func (u *User) MarkDeleted(at time.Time) error {
+	if u.IsDeleted() {
+		return ErrUserNotFound
+	}
+	u.DeletedAt = &at
+	u.Updated = at
+	return nil
+}
+
+// WarnedOfInactivity returns true if the user was warned of the removal of
+// the account since it was last updated
+func (u *User) WarnedOfInactivity() bool {
+	return u.InactivityWarnedAt != nil && !u.InactivityWarnedAt.Before(u.Updated)
+}
+
+// Restore undoes a soft delete
+func (u *User) Restore() error {
+	if !u.IsDeleted() {
+		return ErrUserNotDeleted
+	}
+	u.DeletedAt = nil
+	u.Updated = time.Now()
+	return nil
 }
\ No newline at end of file
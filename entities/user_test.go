@@ -36,7 +36,8 @@ var _ = Describe("User", func() {
 
 			It("should return ErrUserNameRequired", func() {
 				err := user.Validate()
-				Expect(err).To(Equal(entities.ErrUserNameRequired))
+				Expect(err).To(MatchError(entities.ErrValidationFailed))
+				Expect(err).To(MatchError(entities.ErrUserNameRequired))
 			})
 		})
 
@@ -47,7 +48,8 @@ var _ = Describe("User", func() {
 
 			It("should return ErrUserEmailRequired", func() {
 				err := user.Validate()
-				Expect(err).To(Equal(entities.ErrUserEmailRequired))
+				Expect(err).To(MatchError(entities.ErrValidationFailed))
+				Expect(err).To(MatchError(entities.ErrUserEmailRequired))
 			})
 		})
 
@@ -57,9 +59,13 @@ var _ = Describe("User", func() {
 				user.Email = ""
 			})
 
-			It("should return ErrUserNameRequired first", func() {
+			It("should report both violations", func() {
 				err := user.Validate()
-				Expect(err).To(Equal(entities.ErrUserNameRequired))
+				Expect(err).To(MatchError("user is invalid: user name is required; user email is required"))
+				Expect(entities.Violations(err)).To(Equal([]*entities.Error{
+					entities.ErrUserNameRequired,
+					entities.ErrUserEmailRequired,
+				}))
 			})
 		})
 	})
@@ -101,11 +107,18 @@ var _ = Describe("User", func() {
 			})
 		})
 
+		Context("when name is not normalized", func() {
+			It("should store its NFC form", func() {
+				Expect(user.UpdateName("Jose\u0301")).To(Succeed())
+				Expect(user.Name).To(Equal("Jos\u00e9"))
+			})
+		})
+
 		Context("when name is empty", func() {
 			It("should return ErrUserNameRequired and not update", func() {
 				originalName := user.Name
 				err := user.UpdateName("")
-				Expect(err).To(Equal(entities.ErrUserNameRequired))
+				Expect(err).To(MatchError(entities.ErrUserNameRequired))
 				Expect(user.Name).To(Equal(originalName))
 			})
 		})
@@ -119,6 +132,29 @@ var _ = Describe("User", func() {
 			time.Sleep(1 * time.Millisecond) // Ensure time difference
 		})
 
+		Context("when email has surrounding whitespace", func() {
+			It("should keep the address as typed without the whitespace", func() {
+				err := user.UpdateEmail("  Jane@Example.com ")
+				Expect(err).To(BeNil())
+				Expect(user.Email).To(Equal("Jane@Example.com"))
+				Expect(user.EmailKey()).To(Equal("Jane@example.com"))
+			})
+		})
+
+		Context("when email is only whitespace", func() {
+			It("should return ErrUserEmailRequired", func() {
+				Expect(user.UpdateEmail("   ")).To(MatchError(entities.ErrUserEmailRequired))
+			})
+		})
+
+		Context("when email is not an address", func() {
+			It("should return ErrInvalidEmail and not update", func() {
+				err := user.UpdateEmail("jane@")
+				Expect(err).To(MatchError(entities.ErrInvalidEmail))
+				Expect(user.Email).To(Equal("john@example.com"))
+			})
+		})
+
 		Context("when email is valid", func() {
 			newEmail := "jane@example.com"
 
@@ -134,12 +170,30 @@ var _ = Describe("User", func() {
 			It("should return ErrUserEmailRequired and not update", func() {
 				originalEmail := user.Email
 				err := user.UpdateEmail("")
-				Expect(err).To(Equal(entities.ErrUserEmailRequired))
+				Expect(err).To(MatchError(entities.ErrUserEmailRequired))
 				Expect(user.Email).To(Equal(originalEmail))
 			})
 		})
 	})
 
+	Describe("WarnedOfInactivity", func() {
+		It("should be false for a user never warned", func() {
+			Expect(user.WarnedOfInactivity()).To(BeFalse())
+		})
+
+		It("should be true for a warning given since the last update", func() {
+			warnedAt := user.Updated.Add(time.Hour)
+			user.InactivityWarnedAt = &warnedAt
+			Expect(user.WarnedOfInactivity()).To(BeTrue())
+		})
+
+		It("should be false once the user was updated after the warning", func() {
+			warnedAt := user.Updated.Add(-time.Hour)
+			user.InactivityWarnedAt = &warnedAt
+			Expect(user.WarnedOfInactivity()).To(BeFalse())
+		})
+	})
+
 	// Table-driven tests for different user scenarios
 	Describe("User validation scenarios", func() {
 		DescribeTable("validation scenarios",
@@ -151,7 +205,7 @@ var _ = Describe("User", func() {
 					Expect(err).To(BeNil())
 					Expect(user.IsValid()).To(BeTrue())
 				} else {
-					Expect(err).To(Equal(expectedError))
+					Expect(err).To(MatchError(expectedError))
 					Expect(user.IsValid()).To(BeFalse())
 				}
 			},
@@ -159,6 +213,8 @@ var _ = Describe("User", func() {
 			Entry("missing name", "", "john@example.com", false, entities.ErrUserNameRequired),
 			Entry("missing email", "John Doe", "", false, entities.ErrUserEmailRequired),
 			Entry("missing both", "", "", false, entities.ErrUserNameRequired),
+			Entry("invalid email", "John Doe", "john.example.com", false, entities.ErrInvalidEmail),
+			Entry("control character in name", "John\x00Doe", "john@example.com", false, entities.ErrInvalidUserName),
 		)
 	})
 })
\ No newline at end of file
@@ -0,0 +1,141 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxNameLength is the maximum length of a user name, in characters after
+// normalization
+const MaxNameLength = 200
+
+// NormalizeName returns the Unicode NFC form of a user name, under which it
+// is checked and stored, so a name typed with combining marks and the same
+// name typed precomposed are the same name
+func NormalizeName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// nameViolations returns every rule the normalized name breaks
+func nameViolations(name string) []*Error {
+	if strings.TrimSpace(name) == "" {
+		return []*Error{ErrUserNameRequired}
+	}
+
+	var violations []*Error
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		violations = append(violations, ErrUserNameTooLong.WithDetail("max_length", MaxNameLength))
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		violations = append(violations, ErrInvalidUserName)
+	}
+	return violations
+}
+
+// emailViolations returns every rule the email breaks
+func emailViolations(email string) []*Error {
+	if strings.TrimSpace(email) == "" {
+		return []*Error{ErrUserEmailRequired}
+	}
+	if !ValidEmail(email) {
+		return []*Error{ErrInvalidEmail}
+	}
+	return nil
+}
+
+// ValidEmail reports whether email is an addr-spec as defined by RFC 5322,
+// without the obsolete syntax, comments or folding whitespace outside of a
+// quoted local part. As allowed by RFC 6532, it may hold UTF-8 characters,
+// but an internationalized domain must also be valid IDNA.
+func ValidEmail(email string) bool {
+	if !utf8.ValidString(email) {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if !isDotAtom(local) && !isQuotedString(local) {
+		return false
+	}
+	if isDomainLiteral(domain) {
+		return true
+	}
+	if !isDotAtom(domain) {
+		return false
+	}
+	_, err := idna.Lookup.ToASCII(domain)
+	return err == nil
+}
+
+// isAtext reports whether r may appear in an atom (RFC 5322 section 3.2.3)
+func isAtext(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case r >= utf8.RuneSelf:
+		return !unicode.IsControl(r) && !unicode.IsSpace(r)
+	}
+	return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
+
+// isDotAtom reports whether s is atoms separated by single dots
+func isDotAtom(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" || strings.IndexFunc(atom, func(r rune) bool { return !isAtext(r) }) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isQuotedString reports whether s is a quoted string (RFC 5322 section
+// 3.2.4)
+func isQuotedString(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+	escaped := false
+	for _, r := range s[1 : len(s)-1] {
+		switch {
+		case escaped:
+			// quoted-pair: a backslash followed by VCHAR or WSP
+			if r < ' ' && r != '\t' || r == 0x7f {
+				return false
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return false
+		case r == ' ' || r == '\t':
+		case r < ' ' || r == 0x7f || r >= utf8.RuneSelf && unicode.IsControl(r):
+			return false
+		}
+	}
+	return !escaped
+}
+
+// isDomainLiteral reports whether s is a domain literal (RFC 5322 section
+// 3.4.1), such as an IP address in brackets
+func isDomainLiteral(s string) bool {
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return false
+	}
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '[' || c == ']' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
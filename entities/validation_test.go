@@ -0,0 +1,88 @@
+package entities_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+)
+
+var _ = Describe("ValidEmail", func() {
+	DescribeTable("addresses",
+		func(email string, valid bool) {
+			Expect(entities.ValidEmail(email)).To(Equal(valid))
+		},
+		Entry("simple", "john@example.com", true),
+		Entry("dotted local part", "john.doe@example.com", true),
+		Entry("special characters", "john+tag!#$%&'*/=?^_`{|}~-@example.com", true),
+		Entry("single label domain", "john@localhost", true),
+		Entry("internationalized", "jürgen@bücher.example", true),
+		Entry("quoted local part", `"john doe"@example.com`, true),
+		Entry("quoted local part with @", `"a@b"@example.com`, true),
+		Entry("quoted pair", `"john\"doe"@example.com`, true),
+		Entry("domain literal", "john@[192.0.2.1]", true),
+		Entry("empty", "", false),
+		Entry("no @", "john.example.com", false),
+		Entry("empty local part", "@example.com", false),
+		Entry("empty domain", "john@", false),
+		Entry("leading dot", ".john@example.com", false),
+		Entry("consecutive dots", "john..doe@example.com", false),
+		Entry("trailing dot in domain", "john@example.com.", false),
+		Entry("space", "john doe@example.com", false),
+		Entry("unquoted @", "a@b@example.com", false),
+		Entry("special in domain", "john@exa(mple).com", false),
+		Entry("invalid IDNA domain", "john@exa_mple.com", false),
+		Entry("unterminated quote", `"john@example.com`, false),
+		Entry("control character in quotes", "\"john\x01\"@example.com", false),
+		Entry("display name", "John <john@example.com>", false),
+		Entry("invalid UTF-8", "j\xffhn@example.com", false),
+	)
+})
+
+var _ = Describe("User name rules", func() {
+	It("should accept a name of the maximum length", func() {
+		user := &entities.User{Name: strings.Repeat("é", entities.MaxNameLength), Email: "john@example.com"}
+		Expect(user.Validate()).To(Succeed())
+	})
+
+	It("should count characters after normalization", func() {
+		user := &entities.User{Name: strings.Repeat("é", entities.MaxNameLength), Email: "john@example.com"}
+		Expect(user.Validate()).To(Succeed())
+	})
+
+	It("should reject a longer name, giving the maximum", func() {
+		user := &entities.User{Name: strings.Repeat("a", entities.MaxNameLength+1), Email: "john@example.com"}
+		violations := entities.Violations(user.Validate())
+		Expect(violations).To(HaveLen(1))
+		Expect(violations[0]).To(MatchError(entities.ErrUserNameTooLong))
+		Expect(violations[0].Details).To(Equal(map[string]any{"max_length": entities.MaxNameLength}))
+	})
+
+	It("should treat a blank name as missing", func() {
+		user := &entities.User{Name: " \t", Email: "john@example.com"}
+		Expect(entities.Violations(user.Validate())).To(Equal([]*entities.Error{entities.ErrUserNameRequired}))
+	})
+
+	It("should report every violation of every field", func() {
+		user := &entities.User{Name: strings.Repeat("a\n", entities.MaxNameLength), Email: "john@"}
+		err := user.Validate()
+		Expect(err).To(MatchError(entities.ErrValidationFailed))
+		codes := []entities.ErrorCode{}
+		for _, violation := range entities.Violations(err) {
+			codes = append(codes, violation.Code)
+		}
+		Expect(codes).To(Equal([]entities.ErrorCode{
+			entities.CodeUserNameTooLong,
+			entities.CodeInvalidUserName,
+			entities.CodeInvalidEmail,
+		}))
+	})
+
+	It("should reject an email in the sealed form of the encrypted store", func() {
+		user := &entities.User{Name: "John Doe", Email: "sealed:0a1b:1:Q2lwaGVy"}
+		Expect(entities.Violations(user.Validate())).To(Equal([]*entities.Error{entities.ErrInvalidEmail}))
+		Expect(user.UpdateEmail("sealed:not an email:<script>")).To(MatchError(entities.ErrInvalidEmail))
+	})
+})
@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// Archive format identification. Version is bumped whenever the payload
+// changes in a way older readers can't handle.
+const (
+	archiveFormat  = "user-backup"
+	archiveVersion = 1
+
+	// maxHeaderSize bounds the header line, so garbage can't make the reader
+	// buffer a whole file looking for a newline
+	maxHeaderSize = 4 << 10
+)
+
+// header is the first line of an archive. It identifies the format and
+// describes the payload that follows it, so the payload can be checked
+// before it is decoded.
+type header struct {
+	Format      string    `json:"format"`
+	Version     int       `json:"version"`
+	Taken       time.Time `json:"taken"`
+	Users       int       `json:"users"`
+	NextID      int       `json:"next_id"`
+	PayloadSize int64     `json:"payload_size"`
+	SHA256      string    `json:"sha256"`
+}
+
+// payload is the snapshot as stored in an archive
+type payload struct {
+	NextID int              `json:"next_id"`
+	Users  []*entities.User `json:"users"`
+}
+
+// encodeArchive returns the archive holding snapshot: a JSON header line
+// followed by the JSON payload it checksums
+func encodeArchive(snapshot *repository.UserSnapshot) ([]byte, error) {
+	body, err := json.Marshal(payload{NextID: snapshot.NextID, Users: snapshot.Users})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+
+	head, err := json.Marshal(header{
+		Format:      archiveFormat,
+		Version:     archiveVersion,
+		Taken:       snapshot.Taken.UTC(),
+		Users:       len(snapshot.Users),
+		NextID:      snapshot.NextID,
+		PayloadSize: int64(len(body)),
+		SHA256:      hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(head) + 1 + len(body))
+	buf.Write(head)
+	buf.WriteByte('\n')
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// readHeader reads and checks the header line of an archive
+func readHeader(r *bufio.Reader) (*header, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("%w: header too long", entities.ErrInvalidBackup)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header", entities.ErrInvalidBackup)
+	}
+
+	var h header
+	if err := json.Unmarshal(line, &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", entities.ErrInvalidBackup, err)
+	}
+	if h.Format != archiveFormat {
+		return nil, fmt.Errorf("%w: not a user backup", entities.ErrInvalidBackup)
+	}
+	if h.Version != archiveVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", entities.ErrInvalidBackup, h.Version)
+	}
+	return &h, nil
+}
+
+// decodeArchive reads an archive, checking its header, size and checksum
+// before decoding the payload
+func decodeArchive(r io.Reader) (*repository.UserSnapshot, error) {
+	buf := bufio.NewReaderSize(r, maxHeaderSize)
+	h, err := readHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(buf, h.PayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) != h.PayloadSize {
+		return nil, fmt.Errorf("%w: payload is %d bytes, expected %d", entities.ErrInvalidBackup, len(body), h.PayloadSize)
+	}
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != h.SHA256 {
+		return nil, fmt.Errorf("%w: checksum mismatch", entities.ErrInvalidBackup)
+	}
+
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", entities.ErrInvalidBackup, err)
+	}
+	if len(p.Users) != h.Users || p.NextID != h.NextID {
+		return nil, fmt.Errorf("%w: payload does not match header", entities.ErrInvalidBackup)
+	}
+
+	return &repository.UserSnapshot{Taken: h.Taken, NextID: p.NextID, Users: p.Users}, nil
+}
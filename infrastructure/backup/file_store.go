@@ -0,0 +1,227 @@
+// Package backup stores snapshots of the user store as archive files
+package backup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// Archive files are named after the time their snapshot was taken, so
+// sorting the names sorts the backups
+const (
+	namePrefix = "users-"
+	nameSuffix = ".backup"
+	nameLayout = "20060102T150405.000000000Z"
+)
+
+// Options configures a FileStore
+type Options struct {
+	// Dir holds the archive files. It is created on the first backup.
+	Dir string
+
+	// Keep is the number of backups kept. Zero keeps every backup.
+	Keep int
+
+	// MaxAge drops backups older than this. Zero keeps backups of any age.
+	// The newest backup is always kept.
+	MaxAge time.Duration
+}
+
+// FileStore keeps backups as archive files in a directory. Every archive
+// starts with a header line holding the format version and the SHA-256
+// checksum of the snapshot that follows it.
+type FileStore struct {
+	opts Options
+}
+
+// NewFileStore creates a backup store configured by opts
+func NewFileStore(opts Options) *FileStore {
+	return &FileStore{opts: opts}
+}
+
+// Save writes snapshot to a new archive file and prunes the backups the
+// retention policy no longer keeps. A failed prune is logged rather than
+// returned, since the backup itself was saved.
+func (s *FileStore) Save(ctx context.Context, snapshot *repository.UserSnapshot) (*repository.BackupInfo, error) {
+	data, err := encodeArchive(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create backup directory: %w", err)
+	}
+
+	name := namePrefix + snapshot.Taken.UTC().Format(nameLayout) + nameSuffix
+	if err := writeFileAtomic(s.opts.Dir, name, data); err != nil {
+		return nil, fmt.Errorf("write backup: %w", err)
+	}
+
+	if err := s.prune(time.Now()); err != nil {
+		log.Printf("Failed to prune user backups: %v", err)
+	}
+
+	return &repository.BackupInfo{
+		Name:  name,
+		Taken: snapshot.Taken.UTC(),
+		Users: len(snapshot.Users),
+		Size:  int64(len(data)),
+	}, nil
+}
+
+// List returns the stored backups, newest first. Users is read from the
+// archive header and left zero if the header can't be read.
+func (s *FileStore) List(ctx context.Context) ([]*repository.BackupInfo, error) {
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*repository.BackupInfo, 0, len(names))
+	for _, name := range names {
+		taken, _ := parseName(name)
+		info := &repository.BackupInfo{Name: name, Taken: taken}
+
+		file, err := os.Open(filepath.Join(s.opts.Dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			// Pruned since the directory was read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if stat, err := file.Stat(); err == nil {
+			info.Size = stat.Size()
+		}
+		if h, err := readHeader(bufio.NewReaderSize(file, maxHeaderSize)); err == nil {
+			info.Users = h.Users
+		}
+		file.Close()
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Load reads and checks the archive called name
+func (s *FileStore) Load(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+	// Only names the store generates are accepted, so a name can't reach
+	// outside the directory
+	if _, ok := parseName(name); !ok {
+		return nil, entities.ErrBackupNotFound
+	}
+
+	file, err := os.Open(filepath.Join(s.opts.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, entities.ErrBackupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return decodeArchive(file)
+}
+
+// prune removes the backups beyond Keep and those older than MaxAge at now,
+// never the newest one
+func (s *FileStore) prune(now time.Time) error {
+	names, err := s.names()
+	if err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		if i == 0 {
+			continue
+		}
+		taken, _ := parseName(name)
+		expired := s.opts.MaxAge > 0 && now.Sub(taken) > s.opts.MaxAge
+		if (s.opts.Keep > 0 && i >= s.opts.Keep) || expired {
+			if err := os.Remove(filepath.Join(s.opts.Dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// names returns the names of the archive files, newest first
+func (s *FileStore) names() ([]string, error) {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if _, ok := parseName(entry.Name()); ok && entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// parseName returns the time encoded in an archive file name and whether
+// name is one
+func parseName(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, namePrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, nameSuffix)
+	if !ok {
+		return time.Time{}, false
+	}
+	taken, err := time.Parse(nameLayout, stamp)
+	if err != nil || taken.Format(nameLayout) != stamp {
+		return time.Time{}, false
+	}
+	return taken, true
+}
+
+// writeFileAtomic writes data to name in dir through a temporary file, so
+// a crash never leaves a partial archive under that name
+func writeFileAtomic(dir, name string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+
+	"agent-orchestration/entities"
+)
+
+// errLookupAborted is returned to the callers waiting for a lookup that
+// panicked
+var errLookupAborted = errors.New("cache: lookup aborted")
+
+// flight is a lookup in progress that other callers can wait for
+type flight struct {
+	done chan struct{}
+	user *entities.User
+	err  error
+}
+
+// flightGroup collapses concurrent lookups of the same key into one
+type flightGroup struct {
+	mutex   sync.Mutex
+	flights map[string]*flight
+}
+
+// do calls fn unless a call for key is already in progress, in which case
+// it waits for that call and returns its result. The user returned is
+// shared between the callers and must not be modified.
+func (g *flightGroup) do(key string, fn func() (*entities.User, error)) (*entities.User, error) {
+	g.mutex.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mutex.Unlock()
+		<-f.done
+		return f.user, f.err
+	}
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f := &flight{done: make(chan struct{}), err: errLookupAborted}
+	g.flights[key] = f
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.flights, key)
+		g.mutex.Unlock()
+		close(f.done)
+	}()
+
+	f.user, f.err = fn()
+	return f.user, f.err
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// entry is a cached lookup result: a user, or the error the lookup failed
+// with for negative entries
+type entry struct {
+	key     string
+	user    *entities.User
+	err     error
+	expires time.Time // zero for entries that never expire
+}
+
+// lru is a fixed size map of entries that drops the least recently used
+// entry when full. It is not safe for concurrent use.
+type lru struct {
+	size    int
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+// newLRU creates an lru holding up to size entries
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// expired reports whether e has expired at now
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// get returns the entry for key and marks it as recently used
+func (c *lru) get(key string) (*entry, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*entry), true
+}
+
+// add stores e, replacing any entry with the same key, and returns the
+// entry evicted to make room for it, if any
+func (c *lru) add(e *entry) *entry {
+	if element, ok := c.entries[e.key]; ok {
+		element.Value = e
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[e.key] = c.order.PushFront(e)
+	if c.order.Len() <= c.size {
+		return nil
+	}
+	oldest := c.order.Back()
+	c.order.Remove(oldest)
+	evicted := oldest.Value.(*entry)
+	delete(c.entries, evicted.key)
+	return evicted
+}
+
+// remove drops the entry for key and returns it, if there was one
+func (c *lru) remove(key string) (*entry, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.Remove(element)
+	delete(c.entries, key)
+	return element.Value.(*entry), true
+}
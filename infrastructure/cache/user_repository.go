@@ -0,0 +1,301 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// Options configures a CachedUserRepository
+type Options struct {
+	// Size is the maximum number of cached lookups, found or not
+	Size int
+
+	// TTL is how long a user is served from the cache. Zero keeps users
+	// until they are evicted or changed.
+	TTL time.Duration
+
+	// NegativeTTL is how long a lookup that found no user is served from the
+	// cache. Zero disables negative caching.
+	NegativeTTL time.Duration
+}
+
+// Stats counts the lookups served by a CachedUserRepository
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// CachedUserRepository is a read-through cache in front of a
+// UserRepository. GetByID and GetByEmail results, including ErrUserNotFound,
+// are kept in a bounded LRU; concurrent misses for the same key share a
+// single backend call. Writes through the cache invalidate the entries they
+// affect, both right away and again once the surrounding transaction
+// commits. Reads inside a transaction bypass the cache, since they may see
+// uncommitted changes.
+//
+// Users are cached by ID whatever their tenant and only returned to reads
+// from it; lookups by email are cached per tenant.
+//
+// Writes made to the backend by anyone else are only picked up when the
+// entries expire.
+type CachedUserRepository struct {
+	repository.UserRepository
+	opts    Options
+	flights flightGroup
+
+	mutex sync.Mutex
+	lru   *lru
+
+	// emailKeys maps user IDs to the email keys cached for them, so an
+	// email change can invalidate the old address
+	emailKeys map[int]map[string]bool
+
+	// generation changes on every invalidation. Lookups that raced with an
+	// invalidation don't store their result, which may be stale.
+	generation uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// NewCachedUserRepository wraps repo with a cache configured by opts
+func NewCachedUserRepository(repo repository.UserRepository, opts Options) *CachedUserRepository {
+	return &CachedUserRepository{
+		UserRepository: repo,
+		opts:           opts,
+		lru:            newLRU(opts.Size),
+		emailKeys:      make(map[int]map[string]bool),
+	}
+}
+
+// Stats returns the lookup counters
+func (r *CachedUserRepository) Stats() Stats {
+	return Stats{
+		Hits:      r.hits.Load(),
+		Misses:    r.misses.Load(),
+		Evictions: r.evictions.Load(),
+	}
+}
+
+// GetByID retrieves a user by ID
+func (r *CachedUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	if repository.InTransaction(ctx) {
+		return r.UserRepository.GetByID(ctx, id)
+	}
+	return r.lookup(ctx, idKey(id), func(ctx context.Context) (*entities.User, error) {
+		return r.UserRepository.GetByID(ctx, id)
+	})
+}
+
+// GetByEmail retrieves a user by email
+func (r *CachedUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	if repository.InTransaction(ctx) {
+		return r.UserRepository.GetByEmail(ctx, email)
+	}
+	key := entities.TenantEmailKey(repository.Tenant(ctx), entities.NormalizeEmail(email))
+	return r.lookup(ctx, emailKey(key), func(ctx context.Context) (*entities.User, error) {
+		return r.UserRepository.GetByEmail(ctx, email)
+	})
+}
+
+// Create creates a user and drops cached misses for its ID and email
+func (r *CachedUserRepository) Create(ctx context.Context, user *entities.User) error {
+	if err := r.UserRepository.Create(ctx, user); err != nil {
+		return err
+	}
+	r.invalidate(ctx, user.ID, user.TenantEmailKey())
+	return nil
+}
+
+// Update updates a user and drops the entries for its ID, its new email
+// and every email it was cached under
+func (r *CachedUserRepository) Update(ctx context.Context, user *entities.User) error {
+	if err := r.UserRepository.Update(ctx, user); err != nil {
+		return err
+	}
+	r.invalidate(ctx, user.ID, user.TenantEmailKey())
+	return nil
+}
+
+// Delete deletes a user and drops its entries
+func (r *CachedUserRepository) Delete(ctx context.Context, id int) error {
+	if err := r.UserRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id, "")
+	return nil
+}
+
+// SnapshotUsers forwards to the wrapped repository
+func (r *CachedUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return nil, entities.ErrBackupsUnsupported
+	}
+	return snapshotter.SnapshotUsers(ctx)
+}
+
+// RestoreUsers restores the wrapped repository and empties the cache
+func (r *CachedUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return entities.ErrBackupsUnsupported
+	}
+	if err := snapshotter.RestoreUsers(ctx, snapshot); err != nil {
+		return err
+	}
+	r.purge()
+	return nil
+}
+
+// lookup serves key from the cache or loads it with load. Soft-deleted
+// users and users of every tenant are cached too, and hidden here unless
+// ctx includes them, so all kinds of reads share the entries.
+func (r *CachedUserRepository) lookup(ctx context.Context, key string, load func(ctx context.Context) (*entities.User, error)) (*entities.User, error) {
+	r.mutex.Lock()
+	e, ok := r.lru.get(key)
+	if ok && e.expired(time.Now()) {
+		r.remove(key)
+		ok = false
+	}
+	generation := r.generation
+	r.mutex.Unlock()
+
+	if ok {
+		r.hits.Add(1)
+	} else {
+		r.misses.Add(1)
+		// Lookups after an invalidation don't join one started before it
+		flightKey := key + "@" + strconv.FormatUint(generation, 10)
+		user, err := r.flights.do(flightKey, func() (*entities.User, error) {
+			user, err := load(repository.IncludeAllTenants(repository.IncludeDeleted(ctx)))
+			r.store(key, user, err, generation)
+			return user, err
+		})
+		e = &entry{user: user, err: err}
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.user.IsDeleted() && !repository.IncludesDeleted(ctx) {
+		return nil, entities.ErrUserNotFound
+	}
+	if !repository.InTenant(ctx, e.user.Tenant()) {
+		return nil, entities.ErrUserNotFound
+	}
+	userCopy := *e.user
+	return &userCopy, nil
+}
+
+// store caches the result of a lookup started at generation, unless the
+// cache was invalidated since
+func (r *CachedUserRepository) store(key string, user *entities.User, err error, generation uint64) {
+	e := &entry{key: key, err: err}
+	ttl := r.opts.TTL
+	switch {
+	case err == nil:
+		userCopy := *user
+		e.user = &userCopy
+	case errors.Is(err, entities.ErrUserNotFound) && r.opts.NegativeTTL > 0:
+		ttl = r.opts.NegativeTTL
+	default:
+		return
+	}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.generation != generation {
+		return
+	}
+	if e.user != nil && key != idKey(e.user.ID) {
+		r.trackEmail(e.user.ID, key)
+	}
+	if evicted := r.lru.add(e); evicted != nil {
+		r.evictions.Add(1)
+		if evicted.user != nil {
+			r.untrackEmail(evicted.user.ID, evicted.key)
+		}
+	}
+}
+
+// invalidate drops the entries of a user now and again after the
+// transaction of ctx commits, since reads in between may cache the state
+// before the change
+func (r *CachedUserRepository) invalidate(ctx context.Context, id int, email string) {
+	r.drop(id, email)
+	repository.AfterCommit(ctx, func() { r.drop(id, email) })
+}
+
+// drop removes the entries for id, the email keys cached for it and email
+func (r *CachedUserRepository) drop(id int, email string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.generation++
+	r.lru.remove(idKey(id))
+	for key := range r.emailKeys[id] {
+		r.lru.remove(key)
+	}
+	delete(r.emailKeys, id)
+	if email != "" {
+		r.remove(emailKey(email))
+	}
+}
+
+// purge drops every entry
+func (r *CachedUserRepository) purge() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.generation++
+	r.lru = newLRU(r.opts.Size)
+	r.emailKeys = make(map[int]map[string]bool)
+}
+
+// remove drops the entry for key. The caller must hold the mutex.
+func (r *CachedUserRepository) remove(key string) {
+	if e, ok := r.lru.remove(key); ok && e.user != nil {
+		r.untrackEmail(e.user.ID, key)
+	}
+}
+
+// trackEmail records that key caches the user with the given ID. The
+// caller must hold the mutex.
+func (r *CachedUserRepository) trackEmail(id int, key string) {
+	if r.emailKeys[id] == nil {
+		r.emailKeys[id] = make(map[string]bool)
+	}
+	r.emailKeys[id][key] = true
+}
+
+// untrackEmail forgets that key caches the user with the given ID. The
+// caller must hold the mutex.
+func (r *CachedUserRepository) untrackEmail(id int, key string) {
+	delete(r.emailKeys[id], key)
+	if len(r.emailKeys[id]) == 0 {
+		delete(r.emailKeys, id)
+	}
+}
+
+// idKey and emailKey return the cache keys of lookups by ID and by email
+func idKey(id int) string {
+	return "id:" + strconv.Itoa(id)
+}
+
+func emailKey(email string) string {
+	return "email:" + email
+}
@@ -0,0 +1,183 @@
+package changefeed
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// DefaultLogSize is the number of changes a Log retains unless configured
+// otherwise
+const DefaultLogSize = 10000
+
+// purged is the version recorded for purged users, so late changes can't
+// bring them back
+const purged = math.MaxInt
+
+// userState is the last change published for a user
+type userState struct {
+	version int
+	deleted bool
+}
+
+// Log retains the latest changes of a user store in a ring buffer and
+// serves them to watchers. Writers never wait for watchers: a watcher that
+// falls behind by more than the size of the log loses its place and is
+// told so by its stream.
+//
+// Sequence numbers of a new log start from the clock in microseconds, so
+// they keep increasing across restarts, and a watcher resuming from a
+// sequence number issued by an earlier process is told it is too far
+// behind rather than handed unrelated changes.
+type Log struct {
+	mutex   sync.Mutex
+	changes []repository.UserChange // indexed by Seq modulo the size
+	first   int64                   // Seq of the oldest retained change
+	next    int64                   // Seq of the next change
+	states  map[int]userState       // user ID -> last change published
+
+	// appended is closed and replaced on every change, waking the watchers
+	appended chan struct{}
+}
+
+// NewLog creates a log retaining the latest size changes
+func NewLog(size int) *Log {
+	if size <= 0 {
+		size = DefaultLogSize
+	}
+	start := time.Now().UnixMicro()
+	return &Log{
+		changes:  make([]repository.UserChange, size),
+		first:    start,
+		next:     start,
+		states:   make(map[int]userState),
+		appended: make(chan struct{}),
+	}
+}
+
+// Publish appends a change of user. Changes published out of order, older
+// than one already published for the same user, are dropped, as a newer
+// record has been delivered already. An update that soft-deletes the user
+// is published as a ChangeDeleted.
+func (l *Log) Publish(operation string, user *entities.User) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	state, seen := l.states[user.ID]
+	if seen && (state.version == purged || (operation != repository.ChangePurged && user.Version <= state.version)) {
+		return
+	}
+	if operation == repository.ChangeUpdated && user.IsDeleted() && !state.deleted {
+		operation = repository.ChangeDeleted
+	}
+
+	if operation == repository.ChangePurged {
+		l.states[user.ID] = userState{version: purged}
+	} else {
+		l.states[user.ID] = userState{version: user.Version, deleted: user.IsDeleted()}
+	}
+
+	stored := *user
+	size := int64(len(l.changes))
+	l.changes[l.next%size] = repository.UserChange{
+		Seq:       l.next,
+		Operation: operation,
+		User:      &stored,
+		Time:      time.Now(),
+	}
+	l.next++
+	if l.next-l.first > size {
+		l.first = l.next - size
+	}
+
+	close(l.appended)
+	l.appended = make(chan struct{})
+}
+
+// Reset drops every retained change after the whole store was replaced.
+// Every watcher is then too far behind and must start over.
+func (l *Log) Reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Skipping a sequence number puts the latest one issued so far out of
+	// reach too
+	l.next++
+	l.first = l.next
+	l.states = make(map[int]userState)
+
+	close(l.appended)
+	l.appended = make(chan struct{})
+}
+
+// Watch returns a stream of the changes published after fromSeq to the
+// users of the tenant of ctx
+func (l *Log) Watch(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if fromSeq == repository.WatchFromLatest {
+		fromSeq = l.next - 1
+	}
+	switch {
+	case fromSeq >= l.next:
+		return nil, entities.ErrInvalidSequence
+	case fromSeq < l.first-1:
+		return nil, entities.ErrWatchTooFarBehind
+	}
+	return &stream{ctx: ctx, log: l, seq: fromSeq}, nil
+}
+
+// after returns a copy of the change following seq. If it isn't published
+// yet, it returns a channel closed once another change is.
+func (l *Log) after(seq int64) (*repository.UserChange, <-chan struct{}, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if seq+1 < l.first {
+		return nil, nil, entities.ErrWatchTooFarBehind
+	}
+	if seq+1 >= l.next {
+		return nil, l.appended, nil
+	}
+
+	change := l.changes[(seq+1)%int64(len(l.changes))]
+	user := *change.User
+	change.User = &user
+	return &change, nil, nil
+}
+
+// stream reads the changes of a Log following seq
+type stream struct {
+	ctx context.Context
+	log *Log
+	seq int64
+}
+
+// Next waits for the next change and returns it
+func (s *stream) Next() (*repository.UserChange, error) {
+	for {
+		change, appended, err := s.log.after(s.seq)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			s.seq = change.Seq
+			// Watchers only see the changes of their own tenant
+			if !repository.InTenant(s.ctx, change.User.Tenant()) {
+				continue
+			}
+			return change, nil
+		}
+
+		select {
+		case <-appended:
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+	}
+}
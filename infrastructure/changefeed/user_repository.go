@@ -0,0 +1,90 @@
+package changefeed
+
+import (
+	"context"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// WatchedUserRepository publishes the writes made through a UserRepository
+// to a Log. Changes are published once the surrounding transaction, if
+// any, commits, so rolled back changes are never watched.
+type WatchedUserRepository struct {
+	repository.UserRepository
+	log *Log
+}
+
+// NewWatchedUserRepository wraps repo so that every successful write is
+// published to log
+func NewWatchedUserRepository(repo repository.UserRepository, log *Log) *WatchedUserRepository {
+	return &WatchedUserRepository{
+		UserRepository: repo,
+		log:            log,
+	}
+}
+
+// Create creates a user and publishes it
+func (r *WatchedUserRepository) Create(ctx context.Context, user *entities.User) error {
+	if err := r.UserRepository.Create(ctx, user); err != nil {
+		return err
+	}
+	r.publish(ctx, repository.ChangeCreated, user)
+	return nil
+}
+
+// Update updates a user and publishes it
+func (r *WatchedUserRepository) Update(ctx context.Context, user *entities.User) error {
+	if err := r.UserRepository.Update(ctx, user); err != nil {
+		return err
+	}
+	r.publish(ctx, repository.ChangeUpdated, user)
+	return nil
+}
+
+// Delete deletes a user for good and publishes its last record
+func (r *WatchedUserRepository) Delete(ctx context.Context, id int) error {
+	user, err := r.UserRepository.GetByID(repository.IncludeDeleted(ctx), id)
+	if err != nil {
+		return err
+	}
+	if err := r.UserRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.publish(ctx, repository.ChangePurged, user)
+	return nil
+}
+
+// Watch returns a stream of the changes published to the log
+func (r *WatchedUserRepository) Watch(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+	return r.log.Watch(ctx, fromSeq)
+}
+
+// SnapshotUsers forwards to the wrapped repository
+func (r *WatchedUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return nil, entities.ErrBackupsUnsupported
+	}
+	return snapshotter.SnapshotUsers(ctx)
+}
+
+// RestoreUsers restores the wrapped repository and resets the log, as the
+// changes it retains no longer lead to the restored users
+func (r *WatchedUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return entities.ErrBackupsUnsupported
+	}
+	if err := snapshotter.RestoreUsers(ctx, snapshot); err != nil {
+		return err
+	}
+	repository.AfterCommit(ctx, r.log.Reset)
+	return nil
+}
+
+// publish publishes a copy of user once the change is committed
+func (r *WatchedUserRepository) publish(ctx context.Context, operation string, user *entities.User) {
+	stored := *user
+	repository.AfterCommit(ctx, func() { r.log.Publish(operation, &stored) })
+}
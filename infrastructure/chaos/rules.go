@@ -0,0 +1,168 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Errors returned by the injector and the calls it fails
+var (
+	// ErrInjected is the error returned by calls failed on purpose
+	ErrInjected = errors.New("injected user store fault")
+
+	// ErrInvalidRule is the error returned for a rule that can't be applied
+	ErrInvalidRule = errors.New("invalid fault injection rule")
+)
+
+// Operations of a UserRepository that rules apply to
+const (
+	OperationCreate     = "create"
+	OperationGetByID    = "get_by_id"
+	OperationGetByEmail = "get_by_email"
+	OperationUpdate     = "update"
+	OperationDelete     = "delete"
+	OperationList       = "list"
+	OperationListPage   = "list_page"
+)
+
+var operations = map[string]bool{
+	OperationCreate:     true,
+	OperationGetByID:    true,
+	OperationGetByEmail: true,
+	OperationUpdate:     true,
+	OperationDelete:     true,
+	OperationList:       true,
+	OperationListPage:   true,
+}
+
+// Faults a rule injects
+const (
+	// FaultError fails the call with ErrInjected without reaching the store
+	FaultError = "error"
+	// FaultDeadline fails the call with context.DeadlineExceeded without
+	// reaching the store, as if the store had not answered in time
+	FaultDeadline = "deadline"
+	// FaultPartial makes the call and keeps its changes, but fails it with
+	// ErrInjected, as if the answer of the store had been lost
+	FaultPartial = "partial"
+)
+
+// Rule selects calls to misbehave and how. For example, failing 10% of the
+// updates of users with IDs over 100 is
+//
+//	Rule{Operation: OperationUpdate, MinID: 101, Rate: 0.1, Fault: FaultError}
+type Rule struct {
+	// Operation is the operation the rule applies to, every one when empty
+	Operation string
+
+	// MinID and MaxID bound the IDs of the users the rule applies to, when
+	// not zero. Calls that aren't about a single known user, creating,
+	// finding by email and listing, only match rules without bounds.
+	MinID int
+	MaxID int
+
+	// Rate is the share of the matching calls affected, above zero and up
+	// to 1 for every call
+	Rate float64
+
+	// Latency delays the affected calls, before any fault
+	Latency time.Duration
+
+	// Fault fails the affected calls, after the latency. Rules without one
+	// only delay calls.
+	Fault string
+}
+
+// validate checks that the rule can be applied
+func (r Rule) validate() error {
+	switch {
+	case r.Operation != "" && !operations[r.Operation]:
+		return fmt.Errorf("unknown operation %q", r.Operation)
+	case r.MinID < 0 || r.MaxID < 0 || (r.MaxID > 0 && r.MinID > r.MaxID):
+		return fmt.Errorf("invalid ID range %d to %d", r.MinID, r.MaxID)
+	case r.Rate <= 0 || r.Rate > 1:
+		return fmt.Errorf("invalid rate %g, it must be above 0 and up to 1", r.Rate)
+	case r.Latency < 0:
+		return fmt.Errorf("invalid latency %s", r.Latency)
+	}
+	switch r.Fault {
+	case "":
+		if r.Latency == 0 {
+			return errors.New("rule has neither a latency nor a fault")
+		}
+	case FaultError, FaultDeadline, FaultPartial:
+	default:
+		return fmt.Errorf("unknown fault %q", r.Fault)
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to a call of operation about
+// the user with ID id, or no single user when id is zero
+func (r Rule) matches(operation string, id int) bool {
+	if r.Operation != "" && r.Operation != operation {
+		return false
+	}
+	if r.MinID == 0 && r.MaxID == 0 {
+		return true
+	}
+	return id > 0 && id >= r.MinID && (r.MaxID == 0 || id <= r.MaxID)
+}
+
+// Injector holds the rules deciding which calls misbehave. They can be
+// replaced at any time, taking effect from the next call.
+type Injector struct {
+	mutex sync.RWMutex
+	rules []Rule
+}
+
+// NewInjector creates an injector without rules, so every call behaves
+func NewInjector() *Injector {
+	return &Injector{}
+}
+
+// Rules returns the rules in force
+func (i *Injector) Rules() []Rule {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	return append([]Rule(nil), i.rules...)
+}
+
+// SetRules replaces the rules in force. Nothing is replaced if one of them
+// is invalid.
+func (i *Injector) SetRules(rules []Rule) error {
+	for n, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("%w: rule %d: %v", ErrInvalidRule, n+1, err)
+		}
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.rules = append([]Rule(nil), rules...)
+	return nil
+}
+
+// plan decides how a call of operation about the user with ID id
+// misbehaves. Every matching rule is drawn separately: the latencies of
+// the ones drawn add up and the first fault drawn applies.
+func (i *Injector) plan(operation string, id int) (time.Duration, string) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	var latency time.Duration
+	fault := ""
+	for _, rule := range i.rules {
+		if !rule.matches(operation, id) || rand.Float64() >= rule.Rate {
+			continue
+		}
+		latency += rule.Latency
+		if fault == "" {
+			fault = rule.Fault
+		}
+	}
+	return latency, fault
+}
@@ -0,0 +1,148 @@
+package chaos
+
+import (
+	"context"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// FaultyUserRepository makes the calls to the UserRepository it wraps slow
+// or fail as the rules of an Injector say, to test how callers cope with a
+// misbehaving store. It is meant for tests only.
+//
+// Backups are forwarded to the wrapped repository untouched.
+type FaultyUserRepository struct {
+	repository.UserRepository
+	injector *Injector
+}
+
+// NewFaultyUserRepository wraps repo so that its calls misbehave as the
+// rules of injector say
+func NewFaultyUserRepository(repo repository.UserRepository, injector *Injector) *FaultyUserRepository {
+	return &FaultyUserRepository{
+		UserRepository: repo,
+		injector:       injector,
+	}
+}
+
+// Create creates a user
+func (r *FaultyUserRepository) Create(ctx context.Context, user *entities.User) error {
+	return r.call(ctx, OperationCreate, 0, func() error {
+		return r.UserRepository.Create(ctx, user)
+	})
+}
+
+// GetByID retrieves a user by ID
+func (r *FaultyUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	var user *entities.User
+	err := r.call(ctx, OperationGetByID, id, func() (err error) {
+		user, err = r.UserRepository.GetByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetByEmail retrieves a user by email
+func (r *FaultyUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	var user *entities.User
+	err := r.call(ctx, OperationGetByEmail, 0, func() (err error) {
+		user, err = r.UserRepository.GetByEmail(ctx, email)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Update updates a user
+func (r *FaultyUserRepository) Update(ctx context.Context, user *entities.User) error {
+	return r.call(ctx, OperationUpdate, user.ID, func() error {
+		return r.UserRepository.Update(ctx, user)
+	})
+}
+
+// Delete deletes a user for good
+func (r *FaultyUserRepository) Delete(ctx context.Context, id int) error {
+	return r.call(ctx, OperationDelete, id, func() error {
+		return r.UserRepository.Delete(ctx, id)
+	})
+}
+
+// List retrieves all users
+func (r *FaultyUserRepository) List(ctx context.Context) ([]*entities.User, error) {
+	var users []*entities.User
+	err := r.call(ctx, OperationList, 0, func() (err error) {
+		users, err = r.UserRepository.List(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ListPage retrieves a page of users
+func (r *FaultyUserRepository) ListPage(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	var page *repository.UserPage
+	err := r.call(ctx, OperationListPage, 0, func() (err error) {
+		page, err = r.UserRepository.ListPage(ctx, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// SnapshotUsers forwards to the wrapped repository
+func (r *FaultyUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return nil, entities.ErrBackupsUnsupported
+	}
+	return snapshotter.SnapshotUsers(ctx)
+}
+
+// RestoreUsers forwards to the wrapped repository
+func (r *FaultyUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return entities.ErrBackupsUnsupported
+	}
+	return snapshotter.RestoreUsers(ctx, snapshot)
+}
+
+// call makes a call of operation about the user with ID id with fn,
+// misbehaving as planned by the injector. A delay ends early with the
+// error of ctx once it is done.
+func (r *FaultyUserRepository) call(ctx context.Context, operation string, id int, fn func() error) error {
+	latency, fault := r.injector.plan(operation, id)
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	switch fault {
+	case FaultError:
+		return ErrInjected
+	case FaultDeadline:
+		return context.DeadlineExceeded
+	case FaultPartial:
+		if err := fn(); err != nil {
+			return err
+		}
+		return ErrInjected
+	}
+	return fn()
+}
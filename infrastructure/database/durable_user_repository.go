@@ -0,0 +1,247 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// DurableOptions configures the persistence mode of InMemoryUserRepository
+type DurableOptions struct {
+	// Dir holds the write-ahead log and snapshot files
+	Dir string
+
+	// SyncWrites fsyncs the log after every change. Without it a crash of
+	// the machine, not just the process, can lose the latest changes.
+	SyncWrites bool
+
+	// SnapshotEvery compacts the log into a snapshot after this many records.
+	// Zero disables count based snapshots.
+	SnapshotEvery int
+
+	// SnapshotInterval compacts the log periodically. Zero disables
+	// periodic snapshots.
+	SnapshotInterval time.Duration
+}
+
+// persistence is the durable state attached to an InMemoryUserRepository
+type persistence struct {
+	opts     DurableOptions
+	wal      *writeAheadLog
+	snapshot chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+// NewDurableInMemoryUserRepository creates an in-memory user repository
+// that appends every change to a write-ahead log in opts.Dir. On startup the
+// latest snapshot and the log written after it are replayed, so users and
+// ID assignment survive restarts. Call Close to stop background snapshots
+// and release the log file.
+func NewDurableInMemoryUserRepository(opts DurableOptions) (*InMemoryUserRepository, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	r := NewInMemoryUserRepository().(*InMemoryUserRepository)
+
+	state, err := readSnapshot(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range state.Users {
+		r.users[user.ID] = user
+		r.indexEmail(user)
+	}
+	if state.NextID > r.nextID {
+		r.nextID = state.NextID
+	}
+
+	seq := state.Seq
+	walPath := filepath.Join(opts.Dir, walFileName)
+	offset, err := replayWriteAheadLog(walPath, func(rec walRecord) error {
+		// Records already folded into the snapshot are left over when the
+		// process stopped between writing the snapshot and resetting the log
+		if rec.Seq <= state.Seq {
+			return nil
+		}
+		r.applyRecord(rec)
+		seq = rec.Seq
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wal, err := openWriteAheadLog(walPath, offset, seq, opts.SyncWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	r.persistence = &persistence{
+		opts:     opts,
+		wal:      wal,
+		snapshot: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	r.persistence.done.Add(1)
+	go r.compactLoop()
+
+	return r, nil
+}
+
+// Snapshot writes the current state to the snapshot file and empties the
+// write-ahead log. It is a no-op when persistence is disabled.
+func (r *InMemoryUserRepository) Snapshot() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.snapshotLocked()
+}
+
+// Close writes a final snapshot and releases the write-ahead log. Calling
+// it again is a no-op.
+func (r *InMemoryUserRepository) Close() error {
+	if r.persistence == nil {
+		return nil
+	}
+
+	var err error
+	r.persistence.stopOnce.Do(func() {
+		close(r.persistence.stop)
+		r.persistence.done.Wait()
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		err = r.snapshotLocked()
+		if closeErr := r.persistence.wal.close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// snapshotLocked compacts the log. The caller must hold the write lock.
+func (r *InMemoryUserRepository) snapshotLocked() error {
+	if r.persistence == nil {
+		return nil
+	}
+	wal := r.persistence.wal
+
+	state := snapshotState{
+		Seq:    wal.seq,
+		NextID: r.nextID,
+		Users:  make([]*entities.User, 0, len(r.users)),
+	}
+	for _, user := range r.users {
+		state.Users = append(state.Users, user)
+	}
+
+	if err := writeSnapshot(r.persistence.opts.Dir, state); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := wal.reset(); err != nil {
+		return fmt.Errorf("reset write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// compactLoop writes snapshots on the configured interval and whenever the
+// log grows past SnapshotEvery records
+func (r *InMemoryUserRepository) compactLoop() {
+	defer r.persistence.done.Done()
+
+	var tick <-chan time.Time
+	if interval := r.persistence.opts.SnapshotInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.persistence.stop:
+			return
+		case <-tick:
+		case <-r.persistence.snapshot:
+		}
+
+		if err := r.Snapshot(); err != nil {
+			log.Printf("Failed to snapshot user repository: %v", err)
+		}
+	}
+}
+
+// logChange appends rec to the write-ahead log before the change is applied
+// in memory. Inside a transaction the record is held back and written with
+// the rest of the transaction on commit. The caller must hold the write lock.
+func (r *InMemoryUserRepository) logChange(ctx context.Context, rec walRecord) error {
+	if r.persistence == nil {
+		return nil
+	}
+
+	if tx := memoryTxFrom(ctx); tx != nil {
+		pending, ok := tx.values[r].(*[]walRecord)
+		if !ok {
+			pending = &[]walRecord{}
+			tx.values[r] = pending
+			tx.addCommit(func() error {
+				return r.appendLog(walRecord{Op: walOpBatch, Batch: *pending, NextID: r.nextID})
+			})
+		}
+		*pending = append(*pending, rec)
+		return nil
+	}
+
+	return r.appendLog(rec)
+}
+
+// appendLog writes rec to the write-ahead log and schedules a snapshot once
+// the log has grown past SnapshotEvery records
+func (r *InMemoryUserRepository) appendLog(rec walRecord) error {
+	if err := r.persistence.wal.append(rec); err != nil {
+		return err
+	}
+
+	if every := r.persistence.opts.SnapshotEvery; every > 0 && r.persistence.wal.records >= every {
+		select {
+		case r.persistence.snapshot <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// applyRecord replays a logged change into memory
+func (r *InMemoryUserRepository) applyRecord(rec walRecord) {
+	switch rec.Op {
+	case walOpCreate, walOpUpdate:
+		if existing, exists := r.users[rec.User.ID]; exists {
+			r.unindexEmail(existing)
+		}
+		stored := *rec.User
+		r.users[stored.ID] = &stored
+		r.indexEmail(&stored)
+	case walOpDelete:
+		if existing, exists := r.users[rec.ID]; exists {
+			delete(r.users, rec.ID)
+			r.unindexEmail(existing)
+		}
+	case walOpBatch:
+		for _, batched := range rec.Batch {
+			r.applyRecord(batched)
+		}
+	}
+
+	if rec.NextID > r.nextID {
+		r.nextID = rec.NextID
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// InMemoryHistoryRepository keeps the change history of users in memory. It
+// takes part in transactions run by InMemoryTransactionManager.
+type InMemoryHistoryRepository struct {
+	entries map[int][]*entities.HistoryEntry
+	nextID  int
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryHistoryRepository creates a new in-memory history repository
+func NewInMemoryHistoryRepository() repository.HistoryRepository {
+	return &InMemoryHistoryRepository{
+		entries: make(map[int][]*entities.HistoryEntry),
+		nextID:  1,
+	}
+}
+
+// Append stores a new entry
+func (r *InMemoryHistoryRepository) Append(ctx context.Context, entry *entities.HistoryEntry) error {
+	defer lockFor(ctx, &r.mutex)()
+
+	entry.ID = r.nextID
+	r.nextID++
+
+	stored := copyHistoryEntry(entry)
+	r.entries[entry.UserID] = append(r.entries[entry.UserID], stored)
+	onRollback(ctx, func() {
+		entries := r.entries[stored.UserID]
+		r.entries[stored.UserID] = entries[:len(entries)-1]
+		r.nextID = stored.ID
+	})
+	return nil
+}
+
+// ListByUser returns a page of a user's history, oldest first
+func (r *InMemoryHistoryRepository) ListByUser(ctx context.Context, userID, offset, limit int) ([]*entities.HistoryEntry, int, error) {
+	defer rlockFor(ctx, &r.mutex)()
+
+	all := make([]*entities.HistoryEntry, 0, len(r.entries[userID]))
+	for _, entry := range r.entries[userID] {
+		if repository.InTenant(ctx, entry.Tenant()) {
+			all = append(all, entry)
+		}
+	}
+	total := len(all)
+
+	page := make([]*entities.HistoryEntry, 0)
+	for i := offset; i < total && len(page) < limit; i++ {
+		page = append(page, copyHistoryEntry(all[i]))
+	}
+	return page, total, nil
+}
+
+// copyHistoryEntry returns a deep copy of entry
+func copyHistoryEntry(entry *entities.HistoryEntry) *entities.HistoryEntry {
+	c := *entry
+	c.Changes = append([]entities.FieldChange(nil), entry.Changes...)
+	if c.Changes == nil {
+		c.Changes = make([]entities.FieldChange, 0)
+	}
+	return &c
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// sortTimeLayout formats times with a fixed width in UTC, so their text
+// order is their time order
+const sortTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// listKey is the position of a user in a sort order: the value of the sort
+// field, then the ID to break ties
+type listKey struct {
+	Value string `json:"v,omitempty"`
+	ID    int    `json:"i"`
+}
+
+// listCursor is the decoded form of a ListOptions cursor. Backward cursors
+// select the users before the key, forward cursors the users after it.
+type listCursor struct {
+	listKey
+	Sort     repository.SortField `json:"s"`
+	Desc     bool                 `json:"d,omitempty"`
+	Backward bool                 `json:"b,omitempty"`
+}
+
+// encode returns the opaque form of c
+func (c listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses the cursor of opts. It returns nil without a cursor
+// and ErrInvalidCursor when the cursor is malformed or was issued for a
+// different sort order.
+func decodeCursor(opts repository.ListOptions) (*listCursor, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, entities.ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, entities.ErrInvalidCursor
+	}
+	if c.Sort != opts.SortBy || c.Desc != opts.Descending {
+		return nil, entities.ErrInvalidCursor
+	}
+	if c.Sort == repository.SortByCreated || c.Sort == repository.SortByUpdated {
+		if _, err := time.Parse(sortTimeLayout, c.Value); err != nil {
+			return nil, entities.ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// checkListOptions validates opts and fills in the default sort field
+func checkListOptions(opts *repository.ListOptions) error {
+	if opts.SortBy == "" {
+		opts.SortBy = repository.SortByID
+	}
+	if !opts.SortBy.Valid() {
+		return entities.ErrInvalidSortField
+	}
+	if opts.Limit <= 0 {
+		return entities.ErrInvalidPagination
+	}
+	if opts.Status != "" && !opts.Status.Valid() {
+		return entities.ErrInvalidStatus
+	}
+	return nil
+}
+
+// sortValue returns the value of the sort field of user as compared by
+// the in-memory repository
+func sortValue(user *entities.User, field repository.SortField) string {
+	switch field {
+	case repository.SortByName:
+		return user.Name
+	case repository.SortByEmail:
+		return user.EmailKey()
+	case repository.SortByCreated:
+		return user.Created.UTC().Format(sortTimeLayout)
+	case repository.SortByUpdated:
+		return user.Updated.UTC().Format(sortTimeLayout)
+	}
+	return ""
+}
+
+// keyOf returns the position of user in the sort order of opts
+func keyOf(user *entities.User, opts repository.ListOptions) listKey {
+	return listKey{Value: sortValue(user, opts.SortBy), ID: user.ID}
+}
+
+// cursorAt returns the cursor continuing from key in the given direction
+func cursorAt(key listKey, opts repository.ListOptions, backward bool) string {
+	return listCursor{listKey: key, Sort: opts.SortBy, Desc: opts.Descending, Backward: backward}.encode()
+}
+
+// emailDomainSuffix returns the suffix the email key of a user in the
+// domain ends with
+func emailDomainSuffix(domain string) string {
+	return entities.NormalizeEmail("@" + strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+}
+
+// matchesListFilters reports whether user passes the filters of opts
+func matchesListFilters(user *entities.User, opts repository.ListOptions) bool {
+	if opts.NamePrefix != "" && !strings.HasPrefix(user.Name, opts.NamePrefix) {
+		return false
+	}
+	if opts.EmailDomain != "" && !strings.HasSuffix(user.EmailKey(), emailDomainSuffix(opts.EmailDomain)) {
+		return false
+	}
+	if opts.Status != "" && user.CurrentStatus() != opts.Status {
+		return false
+	}
+	return inRange(user.Created, opts.CreatedAfter, opts.CreatedBefore) &&
+		inRange(user.Updated, opts.UpdatedAfter, opts.UpdatedBefore)
+}
+
+// inRange reports whether t is in [after, before), ignoring zero bounds
+func inRange(t, after, before time.Time) bool {
+	if !after.IsZero() && t.Before(after) {
+		return false
+	}
+	if !before.IsZero() && !t.Before(before) {
+		return false
+	}
+	return true
+}
+
+// PageUsers selects the page described by opts from users the way the
+// in-memory repository does, cursors included. It is for decorators that
+// can't hand a sort order or filter down to the repository they wrap.
+func PageUsers(users []*entities.User, opts repository.ListOptions) (*repository.UserPage, error) {
+	if err := checkListOptions(&opts); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*entities.User, 0, len(users))
+	for _, user := range users {
+		if matchesListFilters(user, opts) {
+			matched = append(matched, user)
+		}
+	}
+	return pageOf(matched, opts, cursor), nil
+}
+
+// pageOf selects the page described by opts and cursor from users, which
+// must already be filtered
+func pageOf(users []*entities.User, opts repository.ListOptions, cursor *listCursor) *repository.UserPage {
+	// compare orders a user against a key in the requested direction
+	compare := func(user *entities.User, key listKey) int {
+		c := strings.Compare(sortValue(user, opts.SortBy), key.Value)
+		if c == 0 {
+			c = user.ID - key.ID
+		}
+		if opts.Descending {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return compare(users[i], keyOf(users[j], opts)) < 0
+	})
+	// firstAfter and firstNotBefore return the index of the first user after
+	// key, and at or after it
+	firstAfter := func(key listKey) int {
+		return sort.Search(len(users), func(i int) bool { return compare(users[i], key) > 0 })
+	}
+	firstNotBefore := func(key listKey) int {
+		return sort.Search(len(users), func(i int) bool { return compare(users[i], key) >= 0 })
+	}
+
+	start, end := 0, len(users)
+	switch {
+	case cursor == nil:
+	case cursor.Backward:
+		end = firstNotBefore(cursor.listKey)
+	default:
+		start = firstAfter(cursor.listKey)
+	}
+	if end-start > opts.Limit {
+		if cursor != nil && cursor.Backward {
+			start = end - opts.Limit
+		} else {
+			end = start + opts.Limit
+		}
+	}
+
+	page := &repository.UserPage{Users: users[start:end]}
+	switch {
+	case start < end:
+		if start > 0 {
+			page.Prev = cursorAt(keyOf(users[start], opts), opts, true)
+		}
+		if end < len(users) {
+			page.Next = cursorAt(keyOf(users[end-1], opts), opts, false)
+		}
+	case cursor != nil:
+		// An empty page past either end still leads back to the users
+		if cursor.Backward && firstAfter(cursor.listKey) < len(users) {
+			page.Next = cursorAt(cursor.listKey, opts, false)
+		}
+		if !cursor.Backward && firstNotBefore(cursor.listKey) > 0 {
+			page.Prev = cursorAt(cursor.listKey, opts, true)
+		}
+	}
+	return page
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"agent-orchestration/interfaces/repository"
+)
+
+// memoryTxKey binds a memoryTx to a context
+type memoryTxKey struct{}
+
+// InMemoryTransactionManager runs transactions over the in-memory
+// repositories. Every repository a transaction touches is write locked on
+// first use and stays locked until the transaction ends, so other callers
+// never see uncommitted changes, while transactions touching other
+// repositories run alongside. Transactions must take up repositories in the
+// same order, users before history as the use cases do, or they may
+// deadlock. Changes are undone in reverse order on rollback, and write-ahead
+// log records of a durable repository are only written on commit, as a
+// single frame.
+type InMemoryTransactionManager struct{}
+
+// NewInMemoryTransactionManager creates a transaction manager for the
+// in-memory repositories. Use a single manager per process.
+func NewInMemoryTransactionManager() repository.TransactionManager {
+	return &InMemoryTransactionManager{}
+}
+
+// WithinTransaction runs fn in a transaction
+func (m *InMemoryTransactionManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if memoryTxFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	// Hooks run once every lock is released, so they can use the
+	// repositories and start transactions of their own
+	ctx, hooks := repository.WithCommitHooks(ctx)
+	if err := m.run(ctx, fn); err != nil {
+		return err
+	}
+	hooks.Run()
+	return nil
+}
+
+// run calls fn in a new transaction
+func (m *InMemoryTransactionManager) run(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx := &memoryTx{
+		locked: make(map[*sync.RWMutex]bool),
+		values: make(map[any]any),
+	}
+	defer tx.release()
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, tx)); err != nil {
+		tx.rollback()
+		return err
+	}
+	return tx.commit()
+}
+
+// memoryTx is the state of a running in-memory transaction
+type memoryTx struct {
+	locked   map[*sync.RWMutex]bool
+	unlock   []func()
+	undo     []func()
+	onCommit []func() error
+
+	// values holds per-repository state, keyed by the repository
+	values map[any]any
+}
+
+// memoryTxFrom returns the transaction bound to ctx, if any
+func memoryTxFrom(ctx context.Context) *memoryTx {
+	tx, _ := ctx.Value(memoryTxKey{}).(*memoryTx)
+	return tx
+}
+
+// enlist write locks mutex for the rest of the transaction
+func (tx *memoryTx) enlist(mutex *sync.RWMutex) {
+	if tx.locked[mutex] {
+		return
+	}
+	mutex.Lock()
+	tx.locked[mutex] = true
+	tx.unlock = append(tx.unlock, mutex.Unlock)
+}
+
+// addUndo registers a function that reverts a change on rollback
+func (tx *memoryTx) addUndo(fn func()) {
+	tx.undo = append(tx.undo, fn)
+}
+
+// addCommit registers a function that makes the changes durable on commit
+func (tx *memoryTx) addCommit(fn func() error) {
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// commit runs the commit functions, rolling back if one of them fails
+func (tx *memoryTx) commit() error {
+	for _, fn := range tx.onCommit {
+		if err := fn(); err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+	tx.undo = nil
+	return nil
+}
+
+// rollback reverts every change in reverse order
+func (tx *memoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+// release unlocks every enlisted repository
+func (tx *memoryTx) release() {
+	for i := len(tx.unlock) - 1; i >= 0; i-- {
+		tx.unlock[i]()
+	}
+}
+
+// lockFor write locks mutex for a call with ctx and returns the matching
+// unlock. Inside a transaction the lock is held until the transaction ends.
+func lockFor(ctx context.Context, mutex *sync.RWMutex) func() {
+	if tx := memoryTxFrom(ctx); tx != nil {
+		tx.enlist(mutex)
+		return func() {}
+	}
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// rlockFor read locks mutex for a call with ctx and returns the matching
+// unlock. Inside a transaction the write lock is taken instead, so that the
+// transaction's reads stay valid until it ends.
+func rlockFor(ctx context.Context, mutex *sync.RWMutex) func() {
+	if tx := memoryTxFrom(ctx); tx != nil {
+		tx.enlist(mutex)
+		return func() {}
+	}
+	mutex.RLock()
+	return mutex.RUnlock
+}
+
+// onRollback registers undo to run if the transaction bound to ctx rolls
+// back. Outside a transaction it does nothing.
+func onRollback(ctx context.Context, undo func()) {
+	if tx := memoryTxFrom(ctx); tx != nil {
+		tx.addUndo(undo)
+	}
+}
@@ -0,0 +1,429 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var (
+	// ErrMigrationLocked is returned when another process holds the migration lock
+	ErrMigrationLocked = errors.New("migrations are locked by another process")
+
+	// ErrMigrationLockLost is returned when the migration lock was taken over
+	// by another process while migrations ran, so they may have run twice
+	ErrMigrationLockLost = errors.New("migration lock was lost while migrating")
+
+	// ErrUnknownMigration is returned when the database has a migration applied
+	// that this binary does not know about
+	ErrUnknownMigration = errors.New("database has an applied migration unknown to this binary")
+)
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations to a database
+type Migrator struct {
+	db           *sql.DB
+	migrations   []Migration
+	owner        string
+	lockTimeout  time.Duration
+	lockRetry    time.Duration
+	lockStaleAge time.Duration
+	lockRefresh  time.Duration
+}
+
+// NewMigrator creates a migrator for the migrations embedded in the binary
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		db:           db,
+		migrations:   migrations,
+		owner:        fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		lockTimeout:  30 * time.Second,
+		lockRetry:    100 * time.Millisecond,
+		lockStaleAge: 10 * time.Minute,
+		lockRefresh:  time.Minute,
+	}, nil
+}
+
+// LoadMigrations reads migrations named NNNN_description.up.sql and
+// NNNN_description.down.sql from fsys, ordered by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %q: expected NNNN_name.%s.sql", fileName, direction)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %q: invalid version %q", fileName, versionPart)
+		}
+
+		contents, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrations returns the known migrations in order
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Up applies every pending migration and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func() error {
+		current, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(current); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, done := current[migration.Version]; done {
+				continue
+			}
+			if err := m.apply(ctx, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations and returns the ones it reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func() error {
+		current, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(current); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, done := current[migration.Version]; !done {
+				continue
+			}
+			if err := m.revert(ctx, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status reports every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+
+	current, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, applied := current[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: migration,
+			Applied:   applied,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// apply runs an up script and records it in a single transaction
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+		return fmt.Errorf("apply migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		migration.Version, migration.Name, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("record migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// revert runs a down script and removes its record in a single transaction
+func (m *Migrator) revert(ctx context.Context, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("migration %d (%s) cannot be reverted: no down script", migration.Version, migration.Name)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+		return fmt.Errorf("revert migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM schema_migrations WHERE version = ?`, migration.Version,
+	); err != nil {
+		return fmt.Errorf("unrecord migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// appliedVersions returns the applied migration versions and when they were applied
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// checkKnown refuses to touch a database migrated by a newer binary
+func (m *Migrator) checkKnown(applied map[int]time.Time) error {
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+		}
+	}
+	return nil
+}
+
+// ensureTables creates the bookkeeping and lock tables
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT     NOT NULL,
+	applied_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+	id          INTEGER PRIMARY KEY CHECK (id = 1),
+	owner       TEXT     NOT NULL,
+	acquired_at DATETIME NOT NULL
+);
+`)
+	return err
+}
+
+// withLock runs fn while holding the migration lock. The lock is a single
+// row in schema_migrations_lock, so it works across processes sharing the
+// database. A lock older than lockStaleAge is assumed to belong to a crashed
+// process and is taken over, so the lock is refreshed every lockRefresh
+// while fn runs. ErrMigrationLockLost is returned if it was taken over all
+// the same.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+
+	if err := m.acquireLock(ctx); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		m.refreshLock(stop)
+	}()
+
+	err := fn()
+	close(stop)
+	<-refreshed
+
+	if releaseErr := m.releaseLock(); releaseErr != nil {
+		return errors.Join(err, releaseErr)
+	}
+	return err
+}
+
+// acquireLock waits until the lock row can be inserted or lockTimeout elapses
+func (m *Migrator) acquireLock(ctx context.Context) error {
+	deadline := time.Now().Add(m.lockTimeout)
+	for {
+		now := time.Now().UTC()
+		if _, err := m.db.ExecContext(ctx,
+			`DELETE FROM schema_migrations_lock WHERE acquired_at < ?`, now.Add(-m.lockStaleAge),
+		); err != nil {
+			return err
+		}
+
+		result, err := m.db.ExecContext(ctx,
+			`INSERT OR IGNORE INTO schema_migrations_lock (id, owner, acquired_at) VALUES (1, ?, ?)`,
+			m.owner, now,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 1 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return ErrMigrationLocked
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.lockRetry):
+		}
+	}
+}
+
+// refreshLock moves the acquisition time of the lock held by this migrator
+// forward every lockRefresh until stop is closed, so it never looks stale.
+// Failures are left to the next refresh, and to releaseLock to report.
+func (m *Migrator) refreshLock(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.lockRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.db.Exec(`UPDATE schema_migrations_lock SET acquired_at = ? WHERE owner = ?`, time.Now().UTC(), m.owner)
+		}
+	}
+}
+
+// releaseLock removes the lock row, returning ErrMigrationLockLost if this
+// migrator no longer owned it
+func (m *Migrator) releaseLock() error {
+	result, err := m.db.Exec(`DELETE FROM schema_migrations_lock WHERE owner = ?`, m.owner)
+	if err != nil {
+		return fmt.Errorf("releasing migration lock: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("releasing migration lock: %w", err)
+	}
+	if affected == 0 {
+		return ErrMigrationLockLost
+	}
+	return nil
+}
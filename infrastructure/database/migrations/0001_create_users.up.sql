@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS users (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	name    TEXT     NOT NULL,
+	email   TEXT     NOT NULL,
+	created DATETIME NOT NULL,
+	updated DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email);
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at);
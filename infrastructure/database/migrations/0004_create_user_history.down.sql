@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS user_history_user_id_idx;
+DROP TABLE IF EXISTS user_history;
@@ -0,0 +1,11 @@
+-- No foreign key to users: the history must outlive purged users
+CREATE TABLE IF NOT EXISTS user_history (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id    INTEGER  NOT NULL,
+	operation  TEXT     NOT NULL,
+	changes    TEXT     NOT NULL,
+	timestamp  DATETIME NOT NULL,
+	request_id TEXT     NOT NULL DEFAULT '',
+	principal  TEXT     NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS user_history_user_id_idx ON user_history (user_id, id);
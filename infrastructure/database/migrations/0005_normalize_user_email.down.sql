@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_email_normalized_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (email);
+ALTER TABLE users DROP COLUMN email_normalized;
@@ -0,0 +1,17 @@
+-- Canonical email key, see entities.NormalizeEmail. The backfill can only
+-- trim and lowercase the domain, the part after the last "@", which rtrim
+-- finds by stripping every other character from the end; rows are re-keyed
+-- in full when they are next saved. Addresses that already collide keep a
+-- NULL key, so the unique index can be built; find them with
+-- "server email-duplicates".
+ALTER TABLE users ADD COLUMN email_normalized TEXT;
+UPDATE users SET email_normalized = CASE WHEN instr(trim(email), '@') > 0
+    THEN rtrim(trim(email), replace(trim(email), '@', ''))
+        || lower(substr(trim(email), length(rtrim(trim(email), replace(trim(email), '@', ''))) + 1))
+    ELSE trim(email) END
+WHERE id IN (SELECT min(id) FROM users GROUP BY CASE WHEN instr(trim(email), '@') > 0
+    THEN rtrim(trim(email), replace(trim(email), '@', ''))
+        || lower(substr(trim(email), length(rtrim(trim(email), replace(trim(email), '@', ''))) + 1))
+    ELSE trim(email) END);
+DROP INDEX IF EXISTS users_email_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_normalized_idx ON users (email_normalized);
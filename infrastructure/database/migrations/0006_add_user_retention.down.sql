@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN inactivity_warned_at;
+ALTER TABLE users DROP COLUMN legal_hold;
@@ -0,0 +1,4 @@
+-- Legal hold exempts a user from the retention policy; the warning time
+-- records when the user was told the account is about to be removed
+ALTER TABLE users ADD COLUMN legal_hold INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN inactivity_warned_at DATETIME;
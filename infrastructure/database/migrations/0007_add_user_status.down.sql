@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_status_idx;
+ALTER TABLE users DROP COLUMN status_reason;
+ALTER TABLE users DROP COLUMN status;
@@ -0,0 +1,5 @@
+-- Users move through the pending, active, suspended and deactivated
+-- statuses; the ones stored before statuses existed are active
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS users_status_idx ON users (status);
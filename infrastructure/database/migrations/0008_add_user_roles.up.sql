@@ -0,0 +1,3 @@
+-- The roles assigned to a user, comma-separated and sorted; users stored
+-- before roles existed have none
+ALTER TABLE users ADD COLUMN roles TEXT NOT NULL DEFAULT '';
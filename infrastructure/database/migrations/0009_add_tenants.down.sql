@@ -0,0 +1,5 @@
+-- Fails when an email is used in several tenants
+ALTER TABLE user_history DROP COLUMN tenant_id;
+DROP INDEX IF EXISTS users_tenant_email_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_normalized_idx ON users (email_normalized);
+ALTER TABLE users DROP COLUMN tenant_id;
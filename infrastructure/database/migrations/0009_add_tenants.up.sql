@@ -0,0 +1,7 @@
+-- Users and their history belong to a tenant, and emails are only unique
+-- within one. Everything stored before tenants existed belongs to the
+-- default tenant.
+ALTER TABLE users ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+DROP INDEX IF EXISTS users_email_normalized_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_tenant_email_idx ON users (tenant_id, email_normalized);
+ALTER TABLE user_history ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
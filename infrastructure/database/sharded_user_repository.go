@@ -0,0 +1,320 @@
+package database
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// DefaultShardCount is the number of shards used when none is given
+const DefaultShardCount = 32
+
+// ShardedInMemoryUserRepository is an in-memory user repository for write
+// heavy workloads. Users are partitioned into shards by a hash of their ID,
+// each with its own lock, and email uniqueness within tenants is enforced
+// by a separate index that is itself lock striped. Writes to different
+// users rarely wait for each other.
+//
+// Unlike InMemoryUserRepository it neither persists its data nor takes part
+// in transactions; every call is atomic on its own.
+type ShardedInMemoryUserRepository struct {
+	shards   []userShard
+	emails   *emailIndex
+	emailKey repository.EmailKeyFunc
+	nextID   atomic.Int64
+}
+
+// userShard holds the users whose ID hashes to it
+type userShard struct {
+	mutex sync.RWMutex
+	users map[int]*entities.User
+}
+
+// NewShardedInMemoryUserRepository creates a sharded in-memory user
+// repository with the given number of shards, or DefaultShardCount if it is
+// not positive
+func NewShardedInMemoryUserRepository(shards int) repository.UserRepository {
+	if shards <= 0 {
+		shards = DefaultShardCount
+	}
+
+	r := &ShardedInMemoryUserRepository{
+		shards:   make([]userShard, shards),
+		emails:   newEmailIndex(shards),
+		emailKey: entities.NormalizeEmail,
+	}
+	for i := range r.shards {
+		r.shards[i].users = make(map[int]*entities.User)
+	}
+	return r
+}
+
+// shardFor returns the shard holding the user with the given ID
+func (r *ShardedInMemoryUserRepository) shardFor(id int) *userShard {
+	// Fibonacci hashing spreads consecutive IDs over the shards
+	hash := uint64(id) * 0x9E3779B97F4A7C15
+	return &r.shards[(hash>>32)%uint64(len(r.shards))]
+}
+
+// Create creates a new user
+func (r *ShardedInMemoryUserRepository) Create(ctx context.Context, user *entities.User) error {
+	// Store a copy so later changes by the caller don't leak into the store
+	stored := *user
+	stored.TenantID = repository.Tenant(ctx)
+
+	// Claim the email first, so concurrent creates in other shards can't
+	// take it as well
+	key := r.tenantEmailKey(&stored)
+	if !r.emails.reserve(key) {
+		return entities.ErrUserAlreadyExists
+	}
+
+	stored.ID = int(r.nextID.Add(1))
+	stored.Version = 1
+
+	shard := r.shardFor(stored.ID)
+	shard.mutex.Lock()
+	shard.users[stored.ID] = &stored
+	shard.mutex.Unlock()
+	r.emails.assign(key, stored.ID)
+
+	user.ID = stored.ID
+	user.Version = stored.Version
+	user.TenantID = stored.TenantID
+
+	return nil
+}
+
+// GetByID retrieves a user by ID
+func (r *ShardedInMemoryUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	shard := r.shardFor(id)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	user, exists := shard.users[id]
+	if !exists || !visible(ctx, user) {
+		return nil, entities.ErrUserNotFound
+	}
+
+	// Return a copy to prevent external modifications
+	userCopy := *user
+	return &userCopy, nil
+}
+
+// GetByEmail retrieves a user by email
+func (r *ShardedInMemoryUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	key := entities.TenantEmailKey(repository.Tenant(ctx), r.emailKey(email))
+	id, exists := r.emails.lookup(key)
+	if !exists {
+		return nil, entities.ErrUserNotFound
+	}
+
+	user, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user may have changed its email since the index was read
+	if r.tenantEmailKey(user) != key {
+		return nil, entities.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// Update updates an existing user
+func (r *ShardedInMemoryUserRepository) Update(ctx context.Context, user *entities.User) error {
+	shard := r.shardFor(user.ID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	existing, exists := shard.users[user.ID]
+	if !exists || !repository.InTenant(ctx, existing.Tenant()) {
+		return entities.ErrUserNotFound
+	}
+
+	// Reject updates based on a stale read
+	if existing.Version != user.Version {
+		return entities.ErrVersionConflict
+	}
+
+	// Update user with a copy so later changes by the caller don't leak into the store
+	stored := *user
+	stored.TenantID = existing.TenantID
+
+	// Move the email to the new address, claiming it before the old one is
+	// released. The shard lock is always taken before the index locks.
+	oldKey, newKey := r.tenantEmailKey(existing), r.tenantEmailKey(&stored)
+	if newKey != oldKey {
+		if !r.emails.reserve(newKey) {
+			return entities.ErrUserAlreadyExists
+		}
+		r.emails.assign(newKey, user.ID)
+		r.emails.release(oldKey, user.ID)
+	}
+
+	stored.Version = existing.Version + 1
+	shard.users[user.ID] = &stored
+	user.Version = stored.Version
+
+	return nil
+}
+
+// Delete deletes a user by ID
+func (r *ShardedInMemoryUserRepository) Delete(ctx context.Context, id int) error {
+	shard := r.shardFor(id)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	user, exists := shard.users[id]
+	if !exists || !repository.InTenant(ctx, user.Tenant()) {
+		return entities.ErrUserNotFound
+	}
+
+	delete(shard.users, id)
+	r.emails.release(r.tenantEmailKey(user), id)
+
+	return nil
+}
+
+// List retrieves all users, ordered by ID. Shards are read one after the
+// other, so concurrent writes may be partially visible.
+func (r *ShardedInMemoryUserRepository) List(ctx context.Context) ([]*entities.User, error) {
+	users := r.collect(ctx, func(*entities.User) bool { return true })
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+// ListPage retrieves a page of users
+func (r *ShardedInMemoryUserRepository) ListPage(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	if err := checkListOptions(&opts); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	users := r.collect(ctx, func(user *entities.User) bool { return matchesListFilters(user, opts) })
+	return pageOf(users, opts, cursor), nil
+}
+
+// KeyEmailsWith keys emails with key from now on and re-keys the stored
+// users
+func (r *ShardedInMemoryUserRepository) KeyEmailsWith(key repository.EmailKeyFunc) {
+	for i := range r.shards {
+		r.shards[i].mutex.Lock()
+		defer r.shards[i].mutex.Unlock()
+	}
+
+	r.emailKey = key
+	r.emails = newEmailIndex(len(r.shards))
+	for i := range r.shards {
+		for id, user := range r.shards[i].users {
+			if key := r.tenantEmailKey(user); r.emails.reserve(key) {
+				r.emails.assign(key, id)
+			}
+		}
+	}
+}
+
+// tenantEmailKey returns the key of the email of user within its tenant
+func (r *ShardedInMemoryUserRepository) tenantEmailKey(user *entities.User) string {
+	return entities.TenantEmailKey(user.Tenant(), r.emailKey(user.Email))
+}
+
+// collect returns copies of the visible users that match keep
+func (r *ShardedInMemoryUserRepository) collect(ctx context.Context, keep func(*entities.User) bool) []*entities.User {
+	users := make([]*entities.User, 0)
+	for i := range r.shards {
+		shard := &r.shards[i]
+		shard.mutex.RLock()
+		for _, user := range shard.users {
+			if !visible(ctx, user) || !keep(user) {
+				continue
+			}
+
+			// Return copies to prevent external modifications
+			userCopy := *user
+			users = append(users, &userCopy)
+		}
+		shard.mutex.RUnlock()
+	}
+	return users
+}
+
+// emailIndex maps email keys to user IDs. It is split into stripes, each
+// with its own lock, by a hash of the key.
+type emailIndex struct {
+	stripes []emailStripe
+}
+
+// emailStripe holds the email keys that hash to it. An ID of zero marks a
+// key that is reserved for a user being created.
+type emailStripe struct {
+	mutex sync.Mutex
+	ids   map[string]int
+}
+
+// newEmailIndex creates an email index with the given number of stripes
+func newEmailIndex(stripes int) *emailIndex {
+	index := &emailIndex{stripes: make([]emailStripe, stripes)}
+	for i := range index.stripes {
+		index.stripes[i].ids = make(map[string]int)
+	}
+	return index
+}
+
+// stripeFor returns the stripe holding key
+func (x *emailIndex) stripeFor(key string) *emailStripe {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return &x.stripes[hash.Sum32()%uint32(len(x.stripes))]
+}
+
+// reserve claims key and reports whether it was free
+func (x *emailIndex) reserve(key string) bool {
+	stripe := x.stripeFor(key)
+	stripe.mutex.Lock()
+	defer stripe.mutex.Unlock()
+
+	if _, taken := stripe.ids[key]; taken {
+		return false
+	}
+	stripe.ids[key] = 0
+	return true
+}
+
+// assign points a reserved key at the user that claimed it
+func (x *emailIndex) assign(key string, id int) {
+	stripe := x.stripeFor(key)
+	stripe.mutex.Lock()
+	defer stripe.mutex.Unlock()
+
+	stripe.ids[key] = id
+}
+
+// release frees key if it belongs to the user with the given ID
+func (x *emailIndex) release(key string, id int) {
+	stripe := x.stripeFor(key)
+	stripe.mutex.Lock()
+	defer stripe.mutex.Unlock()
+
+	if stripe.ids[key] == id {
+		delete(stripe.ids, key)
+	}
+}
+
+// lookup returns the ID of the user owning key
+func (x *emailIndex) lookup(key string) (int, bool) {
+	stripe := x.stripeFor(key)
+	stripe.mutex.Lock()
+	defer stripe.mutex.Unlock()
+
+	id, exists := stripe.ids[key]
+	return id, exists && id != 0
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// SQLHistoryRepository is a database/sql implementation of HistoryRepository.
+// It takes part in transactions run by a SQLTransactionManager on the same
+// database.
+type SQLHistoryRepository struct {
+	db *sql.DB
+}
+
+// NewSQLHistoryRepository creates a new SQL history repository
+func NewSQLHistoryRepository(db *sql.DB) repository.HistoryRepository {
+	return &SQLHistoryRepository{
+		db: db,
+	}
+}
+
+// Append stores a new entry
+func (r *SQLHistoryRepository) Append(ctx context.Context, entry *entities.HistoryEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("encode history changes: %w", err)
+	}
+
+	result, err := executorFor(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO user_history (user_id, operation, changes, timestamp, request_id, principal, tenant_id)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.UserID, entry.Operation, string(changes), entry.Timestamp, entry.RequestID, entry.Principal, entry.Tenant(),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+
+	return nil
+}
+
+// ListByUser returns a page of a user's history, oldest first
+func (r *SQLHistoryRepository) ListByUser(ctx context.Context, userID, offset, limit int) ([]*entities.HistoryEntry, int, error) {
+	conditions, args := tenantScope(ctx)
+	conditions = append(conditions, "user_id = ?")
+	args = append(args, userID)
+	where := whereClause(conditions)
+
+	var total int
+	err := executorFor(ctx, r.db).QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM user_history`+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := executorFor(ctx, r.db).QueryContext(ctx,
+		`SELECT id, user_id, operation, changes, timestamp, request_id, principal, tenant_id
+		 FROM user_history`+where+` ORDER BY id LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := make([]*entities.HistoryEntry, 0)
+	for rows.Next() {
+		var (
+			entry   entities.HistoryEntry
+			changes string
+		)
+		err := rows.Scan(&entry.ID, &entry.UserID, &entry.Operation, &changes,
+			&entry.Timestamp, &entry.RequestID, &entry.Principal, &entry.TenantID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return nil, 0, fmt.Errorf("decode history changes: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"agent-orchestration/interfaces/repository"
+)
+
+// sqlTxKey binds a boundTx to a context
+type sqlTxKey struct{}
+
+// boundTx is a transaction together with the database it was started on
+type boundTx struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// sqlExecutor is implemented by both *sql.DB and *sql.Tx
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SQLTransactionManager runs transactions over the SQL repositories that
+// share its database
+type SQLTransactionManager struct {
+	db *sql.DB
+}
+
+// NewSQLTransactionManager creates a transaction manager for db
+func NewSQLTransactionManager(db *sql.DB) repository.TransactionManager {
+	return &SQLTransactionManager{
+		db: db,
+	}
+}
+
+// WithinTransaction runs fn in a database transaction
+func (m *SQLTransactionManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if bound, ok := ctx.Value(sqlTxKey{}).(*boundTx); ok && bound.db == m.db {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	ctx, hooks := repository.WithCommitHooks(ctx)
+	if err := fn(context.WithValue(ctx, sqlTxKey{}, &boundTx{db: m.db, tx: tx})); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	hooks.Run()
+	return nil
+}
+
+// executorFor returns the transaction bound to ctx if it was started on db,
+// and db itself otherwise
+func executorFor(ctx context.Context, db *sql.DB) sqlExecutor {
+	if bound, ok := ctx.Value(sqlTxKey{}).(*boundTx); ok && bound.db == db {
+		return bound.tx
+	}
+	return db
+}
@@ -0,0 +1,487 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// userColumns lists the users columns in the order scanUser reads them
+const userColumns = `id, name, email, created, updated, version, deleted_at, legal_hold, inactivity_warned_at, status, status_reason, roles, tenant_id`
+
+// emailKeyExpr is the canonical email of a row. email_normalized is NULL for
+// duplicates stored before emails were normalized, which are keyed the way
+// migration 0005 backfilled the others: trimmed, with the domain lowercased.
+const emailKeyExpr = `COALESCE(email_normalized, CASE WHEN instr(trim(email), '@') > 0
+	THEN rtrim(trim(email), replace(trim(email), '@', ''))
+		|| lower(substr(trim(email), length(rtrim(trim(email), replace(trim(email), '@', ''))) + 1))
+	ELSE trim(email) END)`
+
+// sortColumns maps sort fields to the expressions ListPage orders by.
+// Timestamps are stored in UTC, so their text order is their time order.
+var sortColumns = map[repository.SortField]string{
+	repository.SortByID:      "id",
+	repository.SortByName:    "name",
+	repository.SortByEmail:   emailKeyExpr,
+	repository.SortByCreated: "created",
+	repository.SortByUpdated: "updated",
+}
+
+// SQLUserRepository is a database/sql implementation of UserRepository. It
+// takes part in transactions run by a SQLTransactionManager on the same
+// database.
+type SQLUserRepository struct {
+	db       *sql.DB
+	emailKey repository.EmailKeyFunc
+}
+
+// NewSQLUserRepository creates a new SQL user repository
+func NewSQLUserRepository(db *sql.DB) repository.UserRepository {
+	return &SQLUserRepository{
+		db:       db,
+		emailKey: entities.NormalizeEmail,
+	}
+}
+
+// KeyEmailsWith keys emails with key from now on. The keys of stored rows
+// are kept.
+func (r *SQLUserRepository) KeyEmailsWith(key repository.EmailKeyFunc) {
+	r.emailKey = key
+}
+
+// Create creates a new user
+func (r *SQLUserRepository) Create(ctx context.Context, user *entities.User) error {
+	result, err := executorFor(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO users (name, email, email_normalized, created, updated, version, deleted_at, legal_hold, inactivity_warned_at,
+		 status, status_reason, roles, tenant_id)
+		 VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?)`,
+		user.Name, user.Email, r.emailKey(user.Email), user.Created.UTC(), user.Updated.UTC(), user.DeletedAt,
+		user.LegalHold, user.InactivityWarnedAt, user.CurrentStatus(), user.StatusReason, joinRoles(user.Roles),
+		repository.Tenant(ctx),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return entities.ErrUserAlreadyExists
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	user.ID = int(id)
+	user.Version = 1
+	user.TenantID = repository.Tenant(ctx)
+
+	return nil
+}
+
+// GetByID retrieves a user by ID
+func (r *SQLUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	conditions, args := scope(ctx)
+	conditions = append(conditions, "id = ?")
+	args = append(args, id)
+	row := executorFor(ctx, r.db).QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users`+whereClause(conditions), args...)
+	return scanUser(row)
+}
+
+// GetByEmail retrieves a user by email
+func (r *SQLUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	row := executorFor(ctx, r.db).QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE tenant_id = ? AND email_normalized = ?`+visibleOnly(ctx, "AND"),
+		repository.Tenant(ctx), r.emailKey(email))
+	return scanUser(row)
+}
+
+// Update updates an existing user
+func (r *SQLUserRepository) Update(ctx context.Context, user *entities.User) error {
+	conditions, args := tenantScope(ctx)
+	conditions = append(conditions, "id = ?", "version = ?")
+	args = append(args, user.ID, user.Version)
+	result, err := executorFor(ctx, r.db).ExecContext(ctx,
+		`UPDATE users SET name = ?, email = ?, email_normalized = ?, created = ?, updated = ?, deleted_at = ?,
+		 legal_hold = ?, inactivity_warned_at = ?, status = ?, status_reason = ?, roles = ?,
+		 version = version + 1`+whereClause(conditions),
+		append([]any{user.Name, user.Email, r.emailKey(user.Email), user.Created.UTC(), user.Updated.UTC(), user.DeletedAt,
+			user.LegalHold, user.InactivityWarnedAt, user.CurrentStatus(), user.StatusReason, joinRoles(user.Roles)},
+			args...)...,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return entities.ErrUserAlreadyExists
+		}
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return r.missingOrConflict(ctx, user.ID)
+	}
+	user.Version++
+
+	return nil
+}
+
+// SnapshotUsers reads every user and the ID sequence in one transaction
+func (r *SQLUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	var snapshot *repository.UserSnapshot
+	err := NewSQLTransactionManager(r.db).WithinTransaction(ctx, func(ctx context.Context) error {
+		users, err := r.queryUsers(ctx, `SELECT `+userColumns+` FROM users ORDER BY id`)
+		if err != nil {
+			return err
+		}
+
+		// AUTOINCREMENT keeps the highest ID ever assigned in sqlite_sequence
+		var seq int
+		err = executorFor(ctx, r.db).QueryRowContext(ctx,
+			`SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'users'), 0)`).Scan(&seq)
+		if err != nil {
+			return err
+		}
+
+		snapshot = &repository.UserSnapshot{Taken: time.Now(), NextID: seq + 1, Users: users}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RestoreUsers replaces every user and the ID sequence with snapshot in one
+// transaction
+func (r *SQLUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	return NewSQLTransactionManager(r.db).WithinTransaction(ctx, func(ctx context.Context) error {
+		exec := executorFor(ctx, r.db)
+		if _, err := exec.ExecContext(ctx, `DELETE FROM users`); err != nil {
+			return err
+		}
+
+		keys := make(map[string]bool, len(snapshot.Users))
+		for _, user := range snapshot.Users {
+			// Like the migration that introduced the key, duplicates stored
+			// before emails were normalized keep a NULL key
+			var key any
+			if tenantKey := entities.TenantEmailKey(user.Tenant(), r.emailKey(user.Email)); !keys[tenantKey] {
+				keys[tenantKey] = true
+				key = r.emailKey(user.Email)
+			}
+
+			_, err := exec.ExecContext(ctx,
+				`INSERT INTO users (id, name, email, email_normalized, created, updated, version, deleted_at, legal_hold, inactivity_warned_at,
+				 status, status_reason, roles, tenant_id)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				user.ID, user.Name, user.Email, key, user.Created.UTC(), user.Updated.UTC(), user.Version, user.DeletedAt,
+				user.LegalHold, user.InactivityWarnedAt, user.CurrentStatus(), user.StatusReason, joinRoles(user.Roles),
+				user.Tenant(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := exec.ExecContext(ctx, `DELETE FROM sqlite_sequence WHERE name = 'users'`); err != nil {
+			return err
+		}
+		_, err := exec.ExecContext(ctx, `INSERT INTO sqlite_sequence (name, seq) VALUES ('users', ?)`, snapshot.NextID-1)
+		return err
+	})
+}
+
+// missingOrConflict explains why a versioned update matched no rows
+func (r *SQLUserRepository) missingOrConflict(ctx context.Context, id int) error {
+	conditions, args := tenantScope(ctx)
+	conditions = append(conditions, "id = ?")
+	args = append(args, id)
+
+	var exists bool
+	err := executorFor(ctx, r.db).QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users`+whereClause(conditions)+`)`, args...).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrUserNotFound
+	}
+	return entities.ErrVersionConflict
+}
+
+// Delete deletes a user by ID
+func (r *SQLUserRepository) Delete(ctx context.Context, id int) error {
+	conditions, args := tenantScope(ctx)
+	conditions = append(conditions, "id = ?")
+	args = append(args, id)
+	result, err := executorFor(ctx, r.db).ExecContext(ctx, `DELETE FROM users`+whereClause(conditions), args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return entities.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// List retrieves all users, ordered by ID
+func (r *SQLUserRepository) List(ctx context.Context) ([]*entities.User, error) {
+	conditions, args := scope(ctx)
+	return r.queryUsers(ctx, `SELECT `+userColumns+` FROM users`+whereClause(conditions)+` ORDER BY id`, args...)
+}
+
+// ListPage retrieves a page of users
+func (r *SQLUserRepository) ListPage(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	if err := checkListOptions(&opts); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+	backward := cursor != nil && cursor.Backward
+
+	conditions, args := listFilters(ctx, opts)
+	if cursor != nil {
+		condition, keyArgs := keyCondition(opts, cursor.listKey, backward)
+		conditions = append(conditions, condition)
+		args = append(args, keyArgs...)
+	}
+
+	// Walk backwards from a backward cursor and restore the order afterwards
+	direction := "ASC"
+	if opts.Descending != backward {
+		direction = "DESC"
+	}
+	column := sortColumns[opts.SortBy]
+	query := `SELECT ` + userColumns + ` FROM users` + whereClause(conditions) +
+		` ORDER BY ` + column + ` ` + direction + `, id ` + direction + ` LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	users, err := r.queryUsers(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	more := len(users) > opts.Limit
+	if more {
+		users = users[:opts.Limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	page := &repository.UserPage{Users: users}
+	if backward {
+		if more {
+			page.Prev = cursorAt(keyOf(users[0], opts), opts, true)
+		}
+		anchor := cursor.listKey
+		if len(users) > 0 {
+			anchor = keyOf(users[len(users)-1], opts)
+		}
+		if found, err := r.existsBeyond(ctx, opts, anchor, false); err != nil {
+			return nil, err
+		} else if found {
+			page.Next = cursorAt(anchor, opts, false)
+		}
+		return page, nil
+	}
+
+	if more {
+		page.Next = cursorAt(keyOf(users[len(users)-1], opts), opts, false)
+	}
+	if cursor != nil {
+		anchor := cursor.listKey
+		if len(users) > 0 {
+			anchor = keyOf(users[0], opts)
+		}
+		if found, err := r.existsBeyond(ctx, opts, anchor, true); err != nil {
+			return nil, err
+		} else if found {
+			page.Prev = cursorAt(anchor, opts, true)
+		}
+	}
+	return page, nil
+}
+
+// existsBeyond reports whether a user matching opts sorts before key, or
+// after it
+func (r *SQLUserRepository) existsBeyond(ctx context.Context, opts repository.ListOptions, key listKey, before bool) (bool, error) {
+	conditions, args := listFilters(ctx, opts)
+	condition, keyArgs := keyCondition(opts, key, before)
+	conditions = append(conditions, condition)
+	args = append(args, keyArgs...)
+
+	var exists bool
+	err := executorFor(ctx, r.db).QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users`+whereClause(conditions)+`)`, args...).Scan(&exists)
+	return exists, err
+}
+
+// queryUsers runs a query selecting userColumns
+func (r *SQLUserRepository) queryUsers(ctx context.Context, query string, args ...any) ([]*entities.User, error) {
+	rows, err := executorFor(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*entities.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// listFilters returns the conditions selecting the users ListPage may
+// return, with their arguments
+func listFilters(ctx context.Context, opts repository.ListOptions) ([]string, []any) {
+	conditions, args := scope(ctx)
+	if opts.NamePrefix != "" {
+		conditions = append(conditions, "substr(name, 1, length(?)) = ?")
+		args = append(args, opts.NamePrefix, opts.NamePrefix)
+	}
+	if opts.EmailDomain != "" {
+		suffix := emailDomainSuffix(opts.EmailDomain)
+		conditions = append(conditions, "substr("+emailKeyExpr+", -length(?)) = ?")
+		args = append(args, suffix, suffix)
+	}
+	if opts.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, opts.Status)
+	}
+	for _, bound := range []struct {
+		condition string
+		value     time.Time
+	}{
+		{"created >= ?", opts.CreatedAfter},
+		{"created < ?", opts.CreatedBefore},
+		{"updated >= ?", opts.UpdatedAfter},
+		{"updated < ?", opts.UpdatedBefore},
+	} {
+		if !bound.value.IsZero() {
+			conditions = append(conditions, bound.condition)
+			args = append(args, bound.value.UTC())
+		}
+	}
+	return conditions, args
+}
+
+// keyCondition returns the condition selecting the users that sort before
+// key, or after it, with its arguments
+func keyCondition(opts repository.ListOptions, key listKey, before bool) (string, []any) {
+	op := ">"
+	if opts.Descending != before {
+		op = "<"
+	}
+	if opts.SortBy == repository.SortByID {
+		return "id " + op + " ?", []any{key.ID}
+	}
+
+	var value any = key.Value
+	if opts.SortBy == repository.SortByCreated || opts.SortBy == repository.SortByUpdated {
+		// decodeCursor has already checked the format
+		t, _ := time.Parse(sortTimeLayout, key.Value)
+		value = t.UTC()
+	}
+	column := sortColumns[opts.SortBy]
+	return "(" + column + " " + op + " ? OR (" + column + " = ? AND id " + op + " ?))", []any{value, value, key.ID}
+}
+
+// whereClause joins conditions into a WHERE clause
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// tenantScope returns the condition keeping the rows of the tenant of ctx,
+// with its argument, or none when ctx includes every tenant
+func tenantScope(ctx context.Context) ([]string, []any) {
+	if repository.IncludesAllTenants(ctx) {
+		return nil, nil
+	}
+	return []string{"tenant_id = ?"}, []any{repository.Tenant(ctx)}
+}
+
+// scope returns the conditions selecting the users reads with ctx return,
+// with their arguments
+func scope(ctx context.Context) ([]string, []any) {
+	conditions, args := tenantScope(ctx)
+	if !repository.IncludesDeleted(ctx) {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	return conditions, args
+}
+
+// visibleOnly returns the condition that hides soft-deleted users, joined
+// with keyword, unless ctx asks for them
+func visibleOnly(ctx context.Context, keyword string) string {
+	if repository.IncludesDeleted(ctx) {
+		return ""
+	}
+	return " " + keyword + " deleted_at IS NULL"
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads a single user from a row
+func scanUser(row rowScanner) (*entities.User, error) {
+	var (
+		user      entities.User
+		deletedAt sql.NullTime
+		warnedAt  sql.NullTime
+		roles     string
+	)
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.Updated, &user.Version, &deletedAt,
+		&user.LegalHold, &warnedAt, &user.Status, &user.StatusReason, &roles, &user.TenantID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrUserNotFound
+		}
+		return nil, err
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if warnedAt.Valid {
+		user.InactivityWarnedAt = &warnedAt.Time
+	}
+	if roles != "" {
+		user.Roles = strings.Split(roles, ",")
+	}
+	return &user, nil
+}
+
+// joinRoles returns the roles column holding roles
+func joinRoles(roles []string) string {
+	return strings.Join(roles, ",")
+}
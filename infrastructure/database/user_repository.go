@@ -2,57 +2,79 @@ package database
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"agent-orchestration/entities"
 	"agent-orchestration/interfaces/repository"
 )
 
-// InMemoryUserRepository is an in-memory implementation for testing
+// InMemoryUserRepository is an in-memory implementation for testing.
+// See NewDurableInMemoryUserRepository for a mode that survives restarts.
+// It takes part in transactions run by InMemoryTransactionManager.
 type InMemoryUserRepository struct {
-	users  map[int]*entities.User
-	emails map[string]*entities.User
-	nextID int
-	mutex  sync.RWMutex
+	users       map[int]*entities.User
+	emails      map[string]*entities.User // keyed by tenantEmailKey
+	emailKey    repository.EmailKeyFunc
+	nextID      int
+	mutex       sync.RWMutex
+	persistence *persistence
 }
 
 // NewInMemoryUserRepository creates a new in-memory user repository
 func NewInMemoryUserRepository() repository.UserRepository {
 	return &InMemoryUserRepository{
 		users:  make(map[int]*entities.User),
-		emails: make(map[string]*entities.User),
-		nextID: 1,
+		emails:   make(map[string]*entities.User),
+		emailKey: entities.NormalizeEmail,
+		nextID:   1,
 	}
 }
 
 // Create creates a new user
 func (r *InMemoryUserRepository) Create(ctx context.Context, user *entities.User) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer lockFor(ctx, &r.mutex)()
+	
+	// Store a copy so later changes by the caller don't leak into the store
+	stored := *user
+	stored.TenantID = repository.Tenant(ctx)
 	
-	// Check if email already exists
-	if _, exists := r.emails[user.Email]; exists {
+	// Check if email already exists in the tenant
+	if _, exists := r.emails[r.tenantEmailKey(&stored)]; exists {
 		return entities.ErrUserAlreadyExists
 	}
 	
+	stored.ID = r.nextID
+	stored.Version = 1
+	if err := r.logChange(ctx, walRecord{Op: walOpCreate, User: &stored, NextID: r.nextID + 1}); err != nil {
+		return err
+	}
+	
 	// Assign new ID
 	user.ID = r.nextID
+	user.Version = stored.Version
+	user.TenantID = stored.TenantID
 	r.nextID++
 	
-	// Store user
-	r.users[user.ID] = user
-	r.emails[user.Email] = user
+	r.users[user.ID] = &stored
+	r.emails[r.tenantEmailKey(&stored)] = &stored
+	onRollback(ctx, func() {
+		delete(r.users, stored.ID)
+		r.unindexEmail(&stored)
+		r.nextID = stored.ID
+	})
 	
 	return nil
 }
 
 // GetByID retrieves a user by ID
 func (r *InMemoryUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	defer rlockFor(ctx, &r.mutex)()
 	
 	user, exists := r.users[id]
-	if !exists {
+	if !exists || !visible(ctx, user) {
 		return nil, entities.ErrUserNotFound
 	}
 	
@@ -63,11 +85,10 @@ func (r *InMemoryUserRepository) GetByID(ctx context.Context, id int) (*entities
 
 // GetByEmail retrieves a user by email
 func (r *InMemoryUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	defer rlockFor(ctx, &r.mutex)()
 	
-	user, exists := r.emails[email]
-	if !exists {
+	user, exists := r.emails[entities.TenantEmailKey(repository.Tenant(ctx), r.emailKey(email))]
+	if !exists || !visible(ctx, user) {
 		return nil, entities.ErrUserNotFound
 	}
 	
@@ -78,59 +99,215 @@ func (r *InMemoryUserRepository) GetByEmail(ctx context.Context, email string) (
 
 // Update updates an existing user
 func (r *InMemoryUserRepository) Update(ctx context.Context, user *entities.User) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer lockFor(ctx, &r.mutex)()
 	
 	existing, exists := r.users[user.ID]
-	if !exists {
+	if !exists || !repository.InTenant(ctx, existing.Tenant()) {
 		return entities.ErrUserNotFound
 	}
 	
-	// Remove old email mapping
-	delete(r.emails, existing.Email)
+	// Reject updates based on a stale read
+	if existing.Version != user.Version {
+		return entities.ErrVersionConflict
+	}
+	
+	// Update user with a copy so later changes by the caller don't leak into the store
+	stored := *user
+	stored.TenantID = existing.TenantID
 	
-	// Check if new email already exists (for different user)
-	if emailUser, emailExists := r.emails[user.Email]; emailExists && emailUser.ID != user.ID {
-		// Restore old email mapping on error
-		r.emails[existing.Email] = existing
+	// Check if new email already exists in the tenant (for different user)
+	if emailUser, emailExists := r.emails[r.tenantEmailKey(&stored)]; emailExists && emailUser.ID != user.ID {
 		return entities.ErrUserAlreadyExists
 	}
 	
-	// Update user
-	r.users[user.ID] = user
-	r.emails[user.Email] = user
+	stored.Version = existing.Version + 1
+	if err := r.logChange(ctx, walRecord{Op: walOpUpdate, User: &stored, NextID: r.nextID}); err != nil {
+		return err
+	}
+	user.Version = stored.Version
+	
+	// Move the email mapping
+	wasIndexed := r.unindexEmail(existing)
+	r.users[user.ID] = &stored
+	r.emails[r.tenantEmailKey(&stored)] = &stored
+	onRollback(ctx, func() {
+		r.unindexEmail(&stored)
+		r.users[existing.ID] = existing
+		if wasIndexed {
+			r.emails[r.tenantEmailKey(existing)] = existing
+		}
+	})
 	
 	return nil
 }
 
 // Delete deletes a user by ID
 func (r *InMemoryUserRepository) Delete(ctx context.Context, id int) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	defer lockFor(ctx, &r.mutex)()
 	
 	user, exists := r.users[id]
-	if !exists {
+	if !exists || !repository.InTenant(ctx, user.Tenant()) {
 		return entities.ErrUserNotFound
 	}
 	
+	if err := r.logChange(ctx, walRecord{Op: walOpDelete, ID: id, NextID: r.nextID}); err != nil {
+		return err
+	}
+	
 	// Remove user from both maps
 	delete(r.users, id)
-	delete(r.emails, user.Email)
+	wasIndexed := r.unindexEmail(user)
+	onRollback(ctx, func() {
+		r.users[user.ID] = user
+		if wasIndexed {
+			r.emails[r.tenantEmailKey(user)] = user
+		}
+	})
 	
 	return nil
 }
 
-// List retrieves all users
+// List retrieves all users, ordered by ID
 func (r *InMemoryUserRepository) List(ctx context.Context) ([]*entities.User, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	defer rlockFor(ctx, &r.mutex)()
 	
 	users := make([]*entities.User, 0, len(r.users))
 	for _, user := range r.users {
+		if !visible(ctx, user) {
+			continue
+		}
+		
 		// Return copies to prevent external modifications
 		userCopy := *user
 		users = append(users, &userCopy)
 	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
 	
 	return users, nil
-}
\ No newline at end of file
+}
+
+// ListPage retrieves a page of users
+func (r *InMemoryUserRepository) ListPage(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	if err := checkListOptions(&opts); err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+	
+	defer rlockFor(ctx, &r.mutex)()
+	
+	users := make([]*entities.User, 0, len(r.users))
+	for _, user := range r.users {
+		if !visible(ctx, user) || !matchesListFilters(user, opts) {
+			continue
+		}
+		users = append(users, user)
+	}
+	
+	page := pageOf(users, opts, cursor)
+	
+	// Return copies to prevent external modifications
+	for i, user := range page.Users {
+		userCopy := *user
+		page.Users[i] = &userCopy
+	}
+	
+	return page, nil
+}
+
+// errRestoreInTransaction is returned by RestoreUsers when called inside a
+// transaction, which would have to hold the lock it waits for
+var errRestoreInTransaction = errors.New("database: restore inside a transaction")
+
+// SnapshotUsers returns a copy of every user and the ID sequence
+func (r *InMemoryUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	defer rlockFor(ctx, &r.mutex)()
+	
+	snapshot := &repository.UserSnapshot{
+		Taken:  time.Now(),
+		NextID: r.nextID,
+		Users:  make([]*entities.User, 0, len(r.users)),
+	}
+	for _, user := range r.users {
+		userCopy := *user
+		snapshot.Users = append(snapshot.Users, &userCopy)
+	}
+	sort.Slice(snapshot.Users, func(i, j int) bool { return snapshot.Users[i].ID < snapshot.Users[j].ID })
+	
+	return snapshot, nil
+}
+
+// RestoreUsers replaces every user and the ID sequence with snapshot. A
+// durable repository compacts its log into the restored state right away,
+// so a restart comes back to it.
+func (r *InMemoryUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	if memoryTxFrom(ctx) != nil {
+		return errRestoreInTransaction
+	}
+	
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	
+	users, emails, nextID := r.users, r.emails, r.nextID
+	r.users = make(map[int]*entities.User, len(snapshot.Users))
+	r.emails = make(map[string]*entities.User, len(snapshot.Users))
+	for _, user := range snapshot.Users {
+		stored := *user
+		r.users[stored.ID] = &stored
+		r.indexEmail(&stored)
+	}
+	r.nextID = snapshot.NextID
+	
+	if err := r.snapshotLocked(); err != nil {
+		r.users, r.emails, r.nextID = users, emails, nextID
+		return err
+	}
+	return nil
+}
+
+// KeyEmailsWith keys emails with key from now on and re-keys the stored
+// users
+func (r *InMemoryUserRepository) KeyEmailsWith(key repository.EmailKeyFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.emailKey = key
+	r.emails = make(map[string]*entities.User, len(r.users))
+	for _, user := range r.users {
+		r.indexEmail(user)
+	}
+}
+
+// tenantEmailKey returns the key of the email of user within its tenant
+func (r *InMemoryUserRepository) tenantEmailKey(user *entities.User) string {
+	return entities.TenantEmailKey(user.Tenant(), r.emailKey(user.Email))
+}
+
+// indexEmail maps the email key of user within its tenant to it, unless
+// another user already owns the key. That only happens for duplicates
+// stored before emails were normalized; the lowest ID keeps the key.
+func (r *InMemoryUserRepository) indexEmail(user *entities.User) {
+	key := r.tenantEmailKey(user)
+	if owner, exists := r.emails[key]; exists && owner.ID < user.ID {
+		return
+	}
+	r.emails[key] = user
+}
+
+// unindexEmail removes the email mapping of user, if user owns it, and
+// reports whether it did
+func (r *InMemoryUserRepository) unindexEmail(user *entities.User) bool {
+	key := r.tenantEmailKey(user)
+	if owner, exists := r.emails[key]; exists && owner.ID == user.ID {
+		delete(r.emails, key)
+		return true
+	}
+	return false
+}
+
+// visible reports whether a read with ctx should return user
+func visible(ctx context.Context, user *entities.User) bool {
+	return repository.InTenant(ctx, user.Tenant()) && (!user.IsDeleted() || repository.IncludesDeleted(ctx))
+}
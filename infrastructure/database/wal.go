@@ -0,0 +1,283 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"agent-orchestration/entities"
+)
+
+// Write-ahead log operations
+const (
+	walOpCreate = "create"
+	walOpUpdate = "update"
+	walOpDelete = "delete"
+
+	// walOpBatch holds the records of a committed transaction, so that a
+	// crash never replays part of a transaction
+	walOpBatch = "batch"
+)
+
+const (
+	walFileName      = "users.wal"
+	snapshotFileName = "users.snapshot"
+
+	// frameHeaderSize is the length and checksum prefix of every frame
+	frameHeaderSize = 8
+
+	// maxFrameSize guards against allocating huge buffers for a garbage length
+	maxFrameSize = 64 << 20
+)
+
+var (
+	// ErrCorruptLog is returned when a record in the middle of the log fails
+	// its checksum, which cannot be explained by a crash during a write
+	ErrCorruptLog = errors.New("write-ahead log is corrupt")
+
+	// ErrCorruptSnapshot is returned when the snapshot file fails its checksum
+	ErrCorruptSnapshot = errors.New("snapshot is corrupt")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// walRecord is a single logged change
+type walRecord struct {
+	Seq    uint64         `json:"seq"`
+	Op     string         `json:"op"`
+	User   *entities.User `json:"user,omitempty"`
+	ID     int            `json:"id,omitempty"`
+	NextID int            `json:"next_id"`
+	Batch  []walRecord    `json:"batch,omitempty"`
+}
+
+// snapshotState is the compacted state written to the snapshot file
+type snapshotState struct {
+	Seq    uint64           `json:"seq"`
+	NextID int              `json:"next_id"`
+	Users  []*entities.User `json:"users"`
+}
+
+// writeAheadLog appends checksummed records to a file. Every frame is a
+// big-endian uint32 payload length, a CRC-32C of the payload and the JSON
+// encoded payload itself.
+type writeAheadLog struct {
+	file    *os.File
+	offset  int64
+	sync    bool
+	seq     uint64
+	records int
+}
+
+// openWriteAheadLog opens the log at path for appending, positioned at offset
+func openWriteAheadLog(path string, offset int64, seq uint64, sync bool) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &writeAheadLog{
+		file:   file,
+		offset: offset,
+		sync:   sync,
+		seq:    seq,
+	}, nil
+}
+
+// append writes rec to the log. On failure the file is truncated back to the
+// last complete record, so a failed write never leaves a torn record behind.
+func (w *writeAheadLog) append(rec walRecord) error {
+	rec.Seq = w.seq + 1
+
+	frame, err := encodeFrame(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.file.Write(frame); err != nil {
+		w.rewind()
+		return fmt.Errorf("append to write-ahead log: %w", err)
+	}
+	if w.sync {
+		if err := w.file.Sync(); err != nil {
+			w.rewind()
+			return fmt.Errorf("sync write-ahead log: %w", err)
+		}
+	}
+
+	w.offset += int64(len(frame))
+	w.seq = rec.Seq
+	w.records++
+	return nil
+}
+
+// rewind drops anything written after the last complete record
+func (w *writeAheadLog) rewind() {
+	w.file.Truncate(w.offset)
+	w.file.Seek(w.offset, io.SeekStart)
+}
+
+// reset empties the log after its records were folded into a snapshot
+func (w *writeAheadLog) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.offset = 0
+	w.records = 0
+	return w.file.Sync()
+}
+
+// close closes the log file
+func (w *writeAheadLog) close() error {
+	return w.file.Close()
+}
+
+// replayWriteAheadLog calls apply for every complete record in the log at
+// path and returns the offset just past the last valid record. A torn final
+// record, left by a crash in the middle of a write, is truncated away.
+func replayWriteAheadLog(path string, apply func(walRecord) error) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var offset int64
+	for offset < int64(len(data)) {
+		payload, size, ok := decodeFrame(data[offset:])
+		if !ok {
+			// Only the last frame may be damaged; anything after it means the
+			// log was corrupted some other way.
+			if offset+size < int64(len(data)) {
+				return 0, fmt.Errorf("%w at offset %d", ErrCorruptLog, offset)
+			}
+			if err := os.Truncate(path, offset); err != nil {
+				return 0, fmt.Errorf("truncate torn write-ahead log record: %w", err)
+			}
+			break
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return 0, fmt.Errorf("%w at offset %d: %v", ErrCorruptLog, offset, err)
+		}
+		if err := apply(rec); err != nil {
+			return 0, err
+		}
+		offset += size
+	}
+
+	return offset, nil
+}
+
+// writeSnapshot atomically replaces the snapshot in dir with state
+func writeSnapshot(dir string, state snapshotState) error {
+	frame, err := encodeFrame(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, snapshotFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(frame); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, snapshotFileName)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// readSnapshot loads the snapshot in dir, returning an empty state if none exists
+func readSnapshot(dir string) (snapshotState, error) {
+	var state snapshotState
+
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	payload, size, ok := decodeFrame(data)
+	if !ok || size != int64(len(data)) {
+		return state, ErrCorruptSnapshot
+	}
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return state, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	return state, nil
+}
+
+// encodeFrame serializes v into a length and checksum prefixed frame
+func encodeFrame(v any) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[frameHeaderSize:], payload)
+	return frame, nil
+}
+
+// decodeFrame reads the frame at the start of data. It returns the payload,
+// the size the frame claims to occupy and whether the frame is complete and
+// matches its checksum.
+func decodeFrame(data []byte) ([]byte, int64, bool) {
+	if len(data) < frameHeaderSize {
+		return nil, int64(len(data)), false
+	}
+
+	length := binary.BigEndian.Uint32(data[0:4])
+	size := int64(frameHeaderSize) + int64(length)
+	if length > maxFrameSize || size > int64(len(data)) {
+		return nil, int64(len(data)), false
+	}
+
+	payload := data[frameHeaderSize:size]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, size, false
+	}
+	return payload, size, true
+}
+
+// syncDir flushes directory entries so a rename survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
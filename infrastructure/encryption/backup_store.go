@@ -0,0 +1,62 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// EncryptedBackupStore keeps user snapshots in the BackupStore it wraps with
+// their emails sealed by a Keyring, so backups of an
+// EncryptedUserRepository don't hold plain addresses either. Backups saved
+// before encryption was enabled still load.
+type EncryptedBackupStore struct {
+	repository.BackupStore
+	keyring *Keyring
+}
+
+// NewEncryptedBackupStore wraps store so that the emails in its backups are
+// sealed with keyring
+func NewEncryptedBackupStore(store repository.BackupStore, keyring *Keyring) *EncryptedBackupStore {
+	return &EncryptedBackupStore{
+		BackupStore: store,
+		keyring:     keyring,
+	}
+}
+
+// Save seals the emails of snapshot and stores it as a new backup
+func (s *EncryptedBackupStore) Save(ctx context.Context, snapshot *repository.UserSnapshot) (*repository.BackupInfo, error) {
+	sealed := *snapshot
+	sealed.Users = make([]*entities.User, len(snapshot.Users))
+	for i, user := range snapshot.Users {
+		email, err := s.keyring.Seal(user.Email)
+		if err != nil {
+			return nil, err
+		}
+		sealedUser := *user
+		sealedUser.Email = email
+		sealed.Users[i] = &sealedUser
+	}
+	return s.BackupStore.Save(ctx, &sealed)
+}
+
+// Load reads the backup called name with the emails of its users opened
+func (s *EncryptedBackupStore) Load(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+	snapshot, err := s.BackupStore.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range snapshot.Users {
+		if !isSealed(user.Email) {
+			continue
+		}
+		email, _, err := s.keyring.Open(user.Email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: open email of user %d: %v", entities.ErrInvalidBackup, user.ID, err)
+		}
+		user.Email = email
+	}
+	return snapshot, nil
+}
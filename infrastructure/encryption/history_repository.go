@@ -0,0 +1,102 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// emailField is the field of a FieldChange recording an email change
+const emailField = "email"
+
+// EncryptedHistoryRepository stores history entries in the
+// HistoryRepository it wraps with the email addresses of their changes
+// sealed by a Keyring.
+//
+// Entries are never rewritten, so a Reencryptor leaves them sealed with the
+// key they were written with. Keep retired keys in the keyring for as long
+// as the history they sealed is kept.
+type EncryptedHistoryRepository struct {
+	repository.HistoryRepository
+	keyring *Keyring
+}
+
+// NewEncryptedHistoryRepository wraps history so that the emails in its
+// entries are sealed with keyring
+func NewEncryptedHistoryRepository(history repository.HistoryRepository, keyring *Keyring) *EncryptedHistoryRepository {
+	return &EncryptedHistoryRepository{
+		HistoryRepository: history,
+		keyring:           keyring,
+	}
+}
+
+// Append seals the email changes of entry and stores it
+func (r *EncryptedHistoryRepository) Append(ctx context.Context, entry *entities.HistoryEntry) error {
+	sealed := *entry
+	sealed.Changes = make([]entities.FieldChange, len(entry.Changes))
+	for i, change := range entry.Changes {
+		if change.Field == emailField {
+			var err error
+			if change.Before, err = r.sealValue(change.Before); err != nil {
+				return err
+			}
+			if change.After, err = r.sealValue(change.After); err != nil {
+				return err
+			}
+		}
+		sealed.Changes[i] = change
+	}
+
+	if err := r.HistoryRepository.Append(ctx, &sealed); err != nil {
+		return err
+	}
+	changes := entry.Changes
+	*entry = sealed
+	entry.Changes = changes
+	return nil
+}
+
+// ListByUser returns a page of the entries of a user with their emails
+// opened
+func (r *EncryptedHistoryRepository) ListByUser(ctx context.Context, userID, offset, limit int) ([]*entities.HistoryEntry, int, error) {
+	entries, total, err := r.HistoryRepository.ListByUser(ctx, userID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, entry := range entries {
+		for i := range entry.Changes {
+			change := &entry.Changes[i]
+			if change.Field != emailField {
+				continue
+			}
+			if change.Before, err = r.openValue(change.Before); err != nil {
+				return nil, 0, fmt.Errorf("open history entry %d: %w", entry.ID, err)
+			}
+			if change.After, err = r.openValue(change.After); err != nil {
+				return nil, 0, fmt.Errorf("open history entry %d: %w", entry.ID, err)
+			}
+		}
+	}
+	return entries, total, nil
+}
+
+// sealValue seals an email value, leaving an unset one empty
+func (r *EncryptedHistoryRepository) sealValue(email string) (string, error) {
+	if email == "" {
+		return "", nil
+	}
+	return r.keyring.Seal(email)
+}
+
+// openValue opens a sealed email value, leaving values recorded before
+// encryption was enabled as they are
+func (r *EncryptedHistoryRepository) openValue(value string) (string, error) {
+	if !isSealed(value) {
+		return value, nil
+	}
+	email, _, err := r.keyring.Open(value)
+	return email, err
+}
@@ -0,0 +1,321 @@
+// Package encryption seals user email addresses at rest with keys kept in a
+// local keyring file
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// Keyring file format. Keys are AES-256 keys, stored base64 encoded.
+const (
+	keyringVersion = 1
+	keySize        = 32
+)
+
+// SealedPrefix starts every address sealed by a Keyring
+const SealedPrefix = "sealed:"
+
+// Errors returned when opening sealed addresses
+var (
+	ErrUnknownKey = errors.New("encryption: sealed with a key missing from the keyring")
+	ErrMalformed  = errors.New("encryption: malformed sealed value")
+)
+
+// keyringFile is the keyring as stored on disk
+type keyringFile struct {
+	Version  int          `json:"version"`
+	Primary  int          `json:"primary"`
+	IndexKey []byte       `json:"index_key"`
+	Keys     []keyringKey `json:"keys"`
+}
+
+// keyringKey is one encryption key of a keyringFile
+type keyringKey struct {
+	ID      int       `json:"id"`
+	Key     []byte    `json:"key"`
+	Created time.Time `json:"created"`
+}
+
+// Keyring holds the keys that seal email addresses. Addresses are sealed
+// with AES-GCM under the primary key; the other keys are kept to open the
+// addresses sealed before a rotation. The index key computes the blind
+// index of an address and never rotates, since a new one would change the
+// email key of every user.
+//
+// A Keyring is safe for concurrent use, except for Rotate.
+type Keyring struct {
+	file  keyringFile
+	aeads map[int]cipher.AEAD
+}
+
+// NewKeyring generates a keyring with a new index key and a first primary
+// key
+func NewKeyring() (*Keyring, error) {
+	indexKey, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{
+		file:  keyringFile{Version: keyringVersion, IndexKey: indexKey},
+		aeads: make(map[int]cipher.AEAD),
+	}
+	if _, err := k.Rotate(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// LoadKeyring reads the keyring file at path
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	k, err := newKeyring(file)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	return k, nil
+}
+
+// newKeyring checks file and prepares its keys
+func newKeyring(file keyringFile) (*Keyring, error) {
+	if file.Version != keyringVersion {
+		return nil, fmt.Errorf("unsupported version %d", file.Version)
+	}
+	if len(file.IndexKey) != keySize {
+		return nil, fmt.Errorf("index key is %d bytes, expected %d", len(file.IndexKey), keySize)
+	}
+
+	k := &Keyring{file: file, aeads: make(map[int]cipher.AEAD, len(file.Keys))}
+	for _, key := range file.Keys {
+		if key.ID <= 0 {
+			return nil, fmt.Errorf("invalid key ID %d", key.ID)
+		}
+		if _, exists := k.aeads[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key ID %d", key.ID)
+		}
+		aead, err := newAEAD(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", key.ID, err)
+		}
+		k.aeads[key.ID] = aead
+	}
+	if _, ok := k.aeads[file.Primary]; !ok {
+		return nil, fmt.Errorf("primary key %d not found", file.Primary)
+	}
+	return k, nil
+}
+
+// Primary returns the ID of the key new addresses are sealed with
+func (k *Keyring) Primary() int {
+	return k.file.Primary
+}
+
+// Rotate adds a new key and makes it the primary key. The previous keys are
+// kept, so addresses sealed with them can still be opened. It returns the
+// ID of the new key.
+func (k *Keyring) Rotate() (int, error) {
+	key, err := randomKey()
+	if err != nil {
+		return 0, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return 0, err
+	}
+
+	id := 1
+	for _, existing := range k.file.Keys {
+		if existing.ID >= id {
+			id = existing.ID + 1
+		}
+	}
+	k.file.Keys = append(k.file.Keys, keyringKey{ID: id, Key: key, Created: time.Now().UTC()})
+	k.file.Primary = id
+	k.aeads[id] = aead
+	return id, nil
+}
+
+// Save writes the keyring to path, readable by its owner only
+func (k *Keyring) Save(path string) error {
+	data, err := json.MarshalIndent(k.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0o600)
+}
+
+// Index returns the blind index of an address: a keyed hash of its
+// canonical form, so addresses that differ only in form share an index
+func (k *Keyring) Index(email string) string {
+	mac := hmac.New(sha256.New, k.file.IndexKey)
+	mac.Write([]byte(entities.NormalizeEmail(email)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Seal encrypts email with the primary key. The result has the form
+//
+//	sealed:<index>:<key ID>:<nonce and ciphertext>
+//
+// and its email key is the blind index of the address. The index and key
+// ID are authenticated along with the ciphertext, so a sealed address can't
+// be moved under another index.
+func (k *Keyring) Seal(email string) (string, error) {
+	header := SealedPrefix + k.Index(email) + ":" + strconv.Itoa(k.file.Primary)
+	aead := k.aeads[k.file.Primary]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(email)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(email), []byte(header))
+	return header + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts an address returned by Seal. It also returns the ID of the
+// key the address was sealed with.
+func (k *Keyring) Open(sealed string) (string, int, error) {
+	header, keyID, ciphertext, err := parseSealed(sealed)
+	if err != nil {
+		return "", 0, err
+	}
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return "", 0, fmt.Errorf("%w: key %d", ErrUnknownKey, keyID)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return "", 0, ErrMalformed
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	email, err := aead.Open(nil, nonce, ciphertext, []byte(header))
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return string(email), keyID, nil
+}
+
+// EmailKey returns the key the repository wrapped by an
+// EncryptedUserRepository keeps email under. A sealed address, like the
+// key itself, has the key "sealed:<index>", so the repository enforces
+// uniqueness and looks users up by blind index without ever seeing the
+// address. An address stored before encryption was enabled keeps its
+// canonical form.
+func EmailKey(email string) string {
+	if rest, ok := strings.CutPrefix(email, SealedPrefix); ok {
+		if index, _, _ := strings.Cut(rest, ":"); index != "" {
+			return SealedPrefix + index
+		}
+	}
+	return entities.NormalizeEmail(email)
+}
+
+// isSealed reports whether value is a sealed address rather than one
+// stored before encryption was enabled
+func isSealed(value string) bool {
+	return strings.HasPrefix(value, SealedPrefix)
+}
+
+// sealedKeyID returns the ID of the key sealed was sealed with, without
+// opening it
+func sealedKeyID(sealed string) (int, bool) {
+	_, keyID, _, err := parseSealed(sealed)
+	return keyID, err == nil
+}
+
+// parseSealed splits a sealed address into its authenticated header, key
+// ID and ciphertext
+func parseSealed(sealed string) (string, int, []byte, error) {
+	rest, ok := strings.CutPrefix(sealed, SealedPrefix)
+	if !ok {
+		return "", 0, nil, ErrMalformed
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", 0, nil, ErrMalformed
+	}
+	keyID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, nil, ErrMalformed
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", 0, nil, ErrMalformed
+	}
+	header := sealed[:len(sealed)-len(parts[2])-1]
+	return header, keyID, ciphertext, nil
+}
+
+// newAEAD returns AES-GCM with key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key is %d bytes, expected %d", len(key), keySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// randomKey returns a new random key
+func randomKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary
+// file, so a crash leaves either the old or the new content
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
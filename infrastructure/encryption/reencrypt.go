@@ -0,0 +1,195 @@
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// DefaultReencryptBatchSize is the number of users a Reencryptor reads at
+// a time unless configured otherwise
+const DefaultReencryptBatchSize = 100
+
+// ReencryptOptions configures a Reencryptor
+type ReencryptOptions struct {
+	// Checkpoint is the file recording how far an unfinished pass got. It
+	// is removed when a pass completes. Without one every pass starts from
+	// the first user.
+	Checkpoint string
+
+	// BatchSize is the number of users read at a time
+	BatchSize int
+}
+
+// ReencryptReport counts the users seen by a pass
+type ReencryptReport struct {
+	// Resumed is set when the pass continued an interrupted one
+	Resumed bool
+
+	Checked  int
+	Resealed int
+
+	// Skipped counts users whose email couldn't be sealed because another
+	// user stored before encryption has the same address
+	Skipped int
+}
+
+// checkpoint is the progress of a pass as saved between batches
+type checkpoint struct {
+	Key    int    `json:"key"`
+	Cursor string `json:"cursor"`
+}
+
+// Reencryptor seals every stored email with the primary key of a keyring,
+// the emails stored before encryption was enabled included. Run it after
+// a rotation, so the retired keys are only needed for the history.
+type Reencryptor struct {
+	encrypted *EncryptedUserRepository
+	users     repository.UserRepository
+	opts      ReencryptOptions
+}
+
+// NewReencryptor creates a Reencryptor for the users stored by encrypted.
+// Users are rewritten through users, which must end in encrypted, so any
+// decorator in front of it sees the writes.
+func NewReencryptor(encrypted *EncryptedUserRepository, users repository.UserRepository, opts ReencryptOptions) *Reencryptor {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultReencryptBatchSize
+	}
+	return &Reencryptor{
+		encrypted: encrypted,
+		users:     users,
+		opts:      opts,
+	}
+}
+
+// Run makes a pass over every user, soft-deleted ones included, and
+// rewrites those whose email isn't sealed with the primary key yet. The
+// position is saved to the checkpoint after every batch, so a pass stopped
+// by a crash or a cancelled context resumes where it left off. A pass
+// started under another primary key starts over.
+func (r *Reencryptor) Run(ctx context.Context) (*ReencryptReport, error) {
+	primary := r.encrypted.keyring.Primary()
+	report := &ReencryptReport{}
+
+	saved, err := r.loadCheckpoint()
+	if err != nil {
+		return report, err
+	}
+	cursor := ""
+	if saved != nil && saved.Key == primary {
+		cursor, report.Resumed = saved.Cursor, true
+	}
+
+	// Every user is resealed, whatever its tenant
+	ctx = repository.IncludeAllTenants(repository.IncludeDeleted(ctx))
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		page, err := r.encrypted.UserRepository.ListPage(ctx, repository.ListOptions{
+			Limit:  r.opts.BatchSize,
+			SortBy: repository.SortByID,
+			Cursor: cursor,
+		})
+		if errors.Is(err, entities.ErrInvalidCursor) {
+			// The checkpoint came from another store
+			cursor, report.Resumed = "", false
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		for _, stored := range page.Users {
+			report.Checked++
+			if keyID, ok := sealedKeyID(stored.Email); ok && keyID == primary {
+				continue
+			}
+
+			err := r.reseal(ctx, stored)
+			switch {
+			case err == nil:
+				report.Resealed++
+			case errors.Is(err, entities.ErrVersionConflict), errors.Is(err, entities.ErrUserNotFound):
+				// Changed since it was read, and so sealed with the
+				// primary key already, or gone
+			case errors.Is(err, entities.ErrUserAlreadyExists):
+				log.Printf("Skipped sealing the email of user %d: the address is stored twice", stored.ID)
+				report.Skipped++
+			default:
+				return report, fmt.Errorf("reseal user %d: %w", stored.ID, err)
+			}
+		}
+
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+		if err := r.saveCheckpoint(checkpoint{Key: primary, Cursor: cursor}); err != nil {
+			return report, err
+		}
+	}
+
+	if r.opts.Checkpoint != "" {
+		if err := os.Remove(r.opts.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// reseal rewrites stored, as read from the wrapped repository, with its
+// email opened, so that writing it seals it with the primary key
+func (r *Reencryptor) reseal(ctx context.Context, stored *entities.User) error {
+	user := *stored
+	if isSealed(user.Email) {
+		email, _, err := r.encrypted.keyring.Open(user.Email)
+		if err != nil {
+			return err
+		}
+		user.Email = email
+	}
+	return r.users.Update(ctx, &user)
+}
+
+// loadCheckpoint reads the checkpoint, returning nil if there is none
+func (r *Reencryptor) loadCheckpoint() (*checkpoint, error) {
+	if r.opts.Checkpoint == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(r.opts.Checkpoint)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		// A damaged checkpoint only costs a pass from the start
+		log.Printf("Ignoring damaged re-encryption checkpoint %s: %v", r.opts.Checkpoint, err)
+		return nil, nil
+	}
+	return &saved, nil
+}
+
+// saveCheckpoint records the progress of the pass
+func (r *Reencryptor) saveCheckpoint(saved checkpoint) error {
+	if r.opts.Checkpoint == "" {
+		return nil
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.opts.Checkpoint, data, 0o600)
+}
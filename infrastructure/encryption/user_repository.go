@@ -0,0 +1,200 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+)
+
+// EncryptedUserRepository stores users in the UserRepository it wraps with
+// their email sealed by a Keyring. The wrapped repository only ever sees
+// sealed addresses, which it keys by their blind index, see EmailKey, so it
+// keeps enforcing uniqueness and GetByEmail keeps finding users by exact
+// address.
+//
+// Users stored before encryption was enabled keep their plain address until
+// a Reencryptor seals it. They are still found by email meanwhile.
+//
+// Listing sorted by email or filtered by email domain can't be handed to
+// the wrapped repository, so those pages are selected from every user.
+type EncryptedUserRepository struct {
+	repository.UserRepository
+	keyring *Keyring
+}
+
+// NewEncryptedUserRepository wraps repo so that emails are sealed with
+// keyring. repo must implement repository.EmailKeyer, as the repositories
+// of package database do, or every sealed address is a key of its own and
+// emails are no longer unique.
+func NewEncryptedUserRepository(repo repository.UserRepository, keyring *Keyring) *EncryptedUserRepository {
+	if keyer, ok := repo.(repository.EmailKeyer); ok {
+		keyer.KeyEmailsWith(EmailKey)
+	}
+	return &EncryptedUserRepository{
+		UserRepository: repo,
+		keyring:        keyring,
+	}
+}
+
+// Create seals the email of user and creates it
+func (r *EncryptedUserRepository) Create(ctx context.Context, user *entities.User) error {
+	sealed, err := r.seal(user)
+	if err != nil {
+		return err
+	}
+	if err := r.UserRepository.Create(ctx, sealed); err != nil {
+		return err
+	}
+	r.writeBack(user, sealed)
+	return nil
+}
+
+// GetByID retrieves a user by ID
+func (r *EncryptedUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	user, err := r.UserRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.open(user)
+}
+
+// GetByEmail retrieves a user by the blind index of email, falling back to
+// the plain address for users stored before encryption was enabled
+func (r *EncryptedUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	user, err := r.UserRepository.GetByEmail(ctx, SealedPrefix+r.keyring.Index(email))
+	if errors.Is(err, entities.ErrUserNotFound) && !isSealed(strings.TrimSpace(email)) {
+		user, err = r.UserRepository.GetByEmail(ctx, email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.open(user)
+}
+
+// Update seals the email of user and updates it
+func (r *EncryptedUserRepository) Update(ctx context.Context, user *entities.User) error {
+	sealed, err := r.seal(user)
+	if err != nil {
+		return err
+	}
+	if err := r.UserRepository.Update(ctx, sealed); err != nil {
+		return err
+	}
+	r.writeBack(user, sealed)
+	return nil
+}
+
+// List retrieves all users, ordered by ID
+func (r *EncryptedUserRepository) List(ctx context.Context) ([]*entities.User, error) {
+	users, err := r.UserRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.openAll(users)
+}
+
+// ListPage retrieves a page of users
+func (r *EncryptedUserRepository) ListPage(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	if opts.SortBy == repository.SortByEmail || opts.EmailDomain != "" {
+		users, err := r.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return database.PageUsers(users, opts)
+	}
+
+	page, err := r.UserRepository.ListPage(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if page.Users, err = r.openAll(page.Users); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// SnapshotUsers returns a snapshot of the wrapped repository with the
+// emails opened, so it can be checked like any other. Save it to an
+// EncryptedBackupStore to keep the backup sealed.
+func (r *EncryptedUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return nil, entities.ErrBackupsUnsupported
+	}
+	snapshot, err := snapshotter.SnapshotUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Users, err = r.openAll(snapshot.Users); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RestoreUsers seals the emails of a snapshot taken by SnapshotUsers and
+// restores it to the wrapped repository. Users stored before encryption was
+// enabled come back sealed.
+func (r *EncryptedUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return entities.ErrBackupsUnsupported
+	}
+	sealed := *snapshot
+	sealed.Users = make([]*entities.User, len(snapshot.Users))
+	for i, user := range snapshot.Users {
+		var err error
+		if sealed.Users[i], err = r.seal(user); err != nil {
+			return err
+		}
+	}
+	return snapshotter.RestoreUsers(ctx, &sealed)
+}
+
+// seal returns a copy of user with its email sealed
+func (r *EncryptedUserRepository) seal(user *entities.User) (*entities.User, error) {
+	sealed := *user
+	email, err := r.keyring.Seal(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	sealed.Email = email
+	return &sealed, nil
+}
+
+// writeBack copies the fields the wrapped repository set on sealed back to
+// user, leaving its plain email
+func (r *EncryptedUserRepository) writeBack(user, sealed *entities.User) {
+	email := user.Email
+	*user = *sealed
+	user.Email = email
+}
+
+// open replaces the sealed email of user, which the wrapped repository
+// returned as a copy, with the plain address
+func (r *EncryptedUserRepository) open(user *entities.User) (*entities.User, error) {
+	if !isSealed(user.Email) {
+		// Stored before encryption was enabled
+		return user, nil
+	}
+	email, _, err := r.keyring.Open(user.Email)
+	if err != nil {
+		return nil, fmt.Errorf("open email of user %d: %w", user.ID, err)
+	}
+	user.Email = email
+	return user, nil
+}
+
+// openAll opens the emails of users in place
+func (r *EncryptedUserRepository) openAll(users []*entities.User) ([]*entities.User, error) {
+	for _, user := range users {
+		if _, err := r.open(user); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
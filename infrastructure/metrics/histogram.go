@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the latency buckets used unless
+// configured otherwise
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Bucket counts the observations of a histogram no longer than UpperBound
+// and longer than the bound of the previous bucket. The last bucket has no
+// upper bound and is labelled "+Inf".
+type Bucket struct {
+	UpperBound string `json:"le"`
+	Count      uint64 `json:"count"`
+}
+
+// Histogram is a snapshot of the latencies observed by an operation
+type Histogram struct {
+	Buckets    []Bucket `json:"buckets"`
+	Count      uint64   `json:"count"`
+	SumSeconds float64  `json:"sum_seconds"`
+}
+
+// histogram counts latencies in fixed buckets without locking
+type histogram struct {
+	bounds []time.Duration
+	counts []atomic.Uint64 // one per bound, plus one past the last
+	sum    atomic.Int64    // nanoseconds
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]atomic.Uint64, len(bounds)+1),
+	}
+}
+
+// observe counts a latency of d
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// snapshot returns the counts observed so far. Observations made while it
+// is taken may be missing from some of them.
+func (h *histogram) snapshot() Histogram {
+	snapshot := Histogram{Buckets: make([]Bucket, len(h.counts))}
+	for i := range h.counts {
+		bound := "+Inf"
+		if i < len(h.bounds) {
+			bound = h.bounds[i].String()
+		}
+		count := h.counts[i].Load()
+		snapshot.Buckets[i] = Bucket{UpperBound: bound, Count: count}
+		snapshot.Count += count
+	}
+	snapshot.SumSeconds = time.Duration(h.sum.Load()).Seconds()
+	return snapshot
+}
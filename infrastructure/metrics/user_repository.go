@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/requestctx"
+)
+
+// Operations of a UserRepository, as named in its Stats
+const (
+	OperationCreate        = "create"
+	OperationGetByID       = "get_by_id"
+	OperationGetByEmail    = "get_by_email"
+	OperationUpdate        = "update"
+	OperationDelete        = "delete"
+	OperationList          = "list"
+	OperationListPage      = "list_page"
+	OperationSnapshotUsers = "snapshot_users"
+	OperationRestoreUsers  = "restore_users"
+)
+
+var operations = []string{
+	OperationCreate,
+	OperationGetByID,
+	OperationGetByEmail,
+	OperationUpdate,
+	OperationDelete,
+	OperationList,
+	OperationListPage,
+	OperationSnapshotUsers,
+	OperationRestoreUsers,
+}
+
+// Options configures an InstrumentedUserRepository
+type Options struct {
+	// SlowThreshold is the latency from which operations are logged, along
+	// with the ID of the request they served. Zero disables the log.
+	SlowThreshold time.Duration
+
+	// Buckets are the ascending upper bounds of the latency histograms,
+	// DefaultBuckets when empty
+	Buckets []time.Duration
+}
+
+// ErrorCounts counts the failed calls of an operation by kind of error
+type ErrorCounts struct {
+	NotFound uint64 `json:"not_found"`
+	Conflict uint64 `json:"conflict"`
+	Other    uint64 `json:"other"`
+}
+
+// OperationStats describes the calls of an operation. Calls counts the
+// completed ones, successful or not, and InFlight the ones still running.
+type OperationStats struct {
+	Calls    uint64      `json:"calls"`
+	InFlight int64       `json:"in_flight"`
+	Errors   ErrorCounts `json:"errors"`
+	Latency  Histogram   `json:"latency"`
+}
+
+// Stats describes the calls of every operation, by operation name
+type Stats map[string]OperationStats
+
+// operation holds the counters of one operation
+type operation struct {
+	calls    atomic.Uint64
+	inFlight atomic.Int64
+	notFound atomic.Uint64
+	conflict atomic.Uint64
+	other    atomic.Uint64
+	latency  *histogram
+}
+
+// InstrumentedUserRepository measures the calls made to the UserRepository
+// it wraps, whatever its backend: their latency, their errors and how many
+// are running. Calls slower than the configured threshold are logged.
+type InstrumentedUserRepository struct {
+	repository.UserRepository
+	opts       Options
+	operations map[string]*operation // read only once created
+}
+
+// NewInstrumentedUserRepository wraps repo so that its calls are measured
+func NewInstrumentedUserRepository(repo repository.UserRepository, opts Options) *InstrumentedUserRepository {
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = DefaultBuckets
+	}
+	r := &InstrumentedUserRepository{
+		UserRepository: repo,
+		opts:           opts,
+		operations:     make(map[string]*operation, len(operations)),
+	}
+	for _, name := range operations {
+		r.operations[name] = &operation{latency: newHistogram(opts.Buckets)}
+	}
+	return r
+}
+
+// Stats returns the counters of every operation
+func (r *InstrumentedUserRepository) Stats() Stats {
+	stats := make(Stats, len(r.operations))
+	for name, op := range r.operations {
+		stats[name] = OperationStats{
+			Calls:    op.calls.Load(),
+			InFlight: op.inFlight.Load(),
+			Errors: ErrorCounts{
+				NotFound: op.notFound.Load(),
+				Conflict: op.conflict.Load(),
+				Other:    op.other.Load(),
+			},
+			Latency: op.latency.snapshot(),
+		}
+	}
+	return stats
+}
+
+// Create creates a user
+func (r *InstrumentedUserRepository) Create(ctx context.Context, user *entities.User) error {
+	return r.measure(ctx, OperationCreate, func() error {
+		return r.UserRepository.Create(ctx, user)
+	})
+}
+
+// GetByID retrieves a user by ID
+func (r *InstrumentedUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	var user *entities.User
+	err := r.measure(ctx, OperationGetByID, func() (err error) {
+		user, err = r.UserRepository.GetByID(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+// GetByEmail retrieves a user by email
+func (r *InstrumentedUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	var user *entities.User
+	err := r.measure(ctx, OperationGetByEmail, func() (err error) {
+		user, err = r.UserRepository.GetByEmail(ctx, email)
+		return err
+	})
+	return user, err
+}
+
+// Update updates a user
+func (r *InstrumentedUserRepository) Update(ctx context.Context, user *entities.User) error {
+	return r.measure(ctx, OperationUpdate, func() error {
+		return r.UserRepository.Update(ctx, user)
+	})
+}
+
+// Delete deletes a user for good
+func (r *InstrumentedUserRepository) Delete(ctx context.Context, id int) error {
+	return r.measure(ctx, OperationDelete, func() error {
+		return r.UserRepository.Delete(ctx, id)
+	})
+}
+
+// List retrieves all users
+func (r *InstrumentedUserRepository) List(ctx context.Context) ([]*entities.User, error) {
+	var users []*entities.User
+	err := r.measure(ctx, OperationList, func() (err error) {
+		users, err = r.UserRepository.List(ctx)
+		return err
+	})
+	return users, err
+}
+
+// ListPage retrieves a page of users
+func (r *InstrumentedUserRepository) ListPage(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	var page *repository.UserPage
+	err := r.measure(ctx, OperationListPage, func() (err error) {
+		page, err = r.UserRepository.ListPage(ctx, opts)
+		return err
+	})
+	return page, err
+}
+
+// SnapshotUsers forwards to the wrapped repository
+func (r *InstrumentedUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return nil, entities.ErrBackupsUnsupported
+	}
+	var snapshot *repository.UserSnapshot
+	err := r.measure(ctx, OperationSnapshotUsers, func() (err error) {
+		snapshot, err = snapshotter.SnapshotUsers(ctx)
+		return err
+	})
+	return snapshot, err
+}
+
+// RestoreUsers forwards to the wrapped repository
+func (r *InstrumentedUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return entities.ErrBackupsUnsupported
+	}
+	return r.measure(ctx, OperationRestoreUsers, func() error {
+		return snapshotter.RestoreUsers(ctx, snapshot)
+	})
+}
+
+// Watch forwards to the wrapped repository. Streams live as long as their
+// watchers, so they aren't measured.
+func (r *InstrumentedUserRepository) Watch(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+	watcher, ok := r.UserRepository.(repository.UserWatcher)
+	if !ok {
+		return nil, entities.ErrWatchUnsupported
+	}
+	return watcher.Watch(ctx, fromSeq)
+}
+
+// measure calls fn, counting it as a call of the operation called name
+func (r *InstrumentedUserRepository) measure(ctx context.Context, name string, fn func() error) error {
+	op := r.operations[name]
+	op.inFlight.Add(1)
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	op.inFlight.Add(-1)
+
+	op.calls.Add(1)
+	op.latency.observe(elapsed)
+	switch {
+	case err == nil:
+	case errors.Is(err, entities.ErrUserNotFound):
+		op.notFound.Add(1)
+	case errors.Is(err, entities.ErrUserAlreadyExists), errors.Is(err, entities.ErrVersionConflict):
+		op.conflict.Add(1)
+	default:
+		op.other.Add(1)
+	}
+
+	if r.opts.SlowThreshold > 0 && elapsed >= r.opts.SlowThreshold {
+		requestID := requestctx.RequestID(ctx)
+		if requestID == "" {
+			requestID = "-"
+		}
+		log.Printf("Slow user store %s took %s (request %s)", name, elapsed, requestID)
+	}
+	return err
+}
@@ -0,0 +1,19 @@
+package notification
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// LogNotifier only logs the warnings of inactive users. It stands in for a
+// webhook where users are told some other way, or not at all.
+type LogNotifier struct{}
+
+// WarnInactive logs the warning of user
+func (LogNotifier) WarnInactive(ctx context.Context, user *entities.User, deleteAfter time.Time) error {
+	log.Printf("User %d is inactive and will be deleted after %s", user.ID, deleteAfter.UTC().Format(time.RFC3339))
+	return nil
+}
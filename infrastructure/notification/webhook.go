@@ -0,0 +1,84 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// EventUserInactive is the event posted for a user about to be removed by
+// the retention policy
+const EventUserInactive = "user.inactive"
+
+// DefaultWebhookTimeout bounds a webhook call unless configured otherwise
+const DefaultWebhookTimeout = 10 * time.Second
+
+// InactiveEvent is the body posted to the webhook. The receiver is expected
+// to tell the user, typically by mail.
+type InactiveEvent struct {
+	Event       string    `json:"event"`
+	UserID      int       `json:"user_id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	LastActive  time.Time `json:"last_active"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// WebhookNotifier warns inactive users by posting an InactiveEvent to a
+// webhook. Any response but a 2xx is a failed warning, which the retention
+// policy sends again on its next run.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a WebhookNotifier posting to url, giving up on
+// a call after timeout
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// WarnInactive posts the warning of user to the webhook
+func (n *WebhookNotifier) WarnInactive(ctx context.Context, user *entities.User, deleteAfter time.Time) error {
+	body, err := json.Marshal(InactiveEvent{
+		Event:       EventUserInactive,
+		UserID:      user.ID,
+		Name:        user.Name,
+		Email:       user.Email,
+		LastActive:  user.Updated.UTC(),
+		DeleteAfter: deleteAfter.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a unit of background work. The context is cancelled when the
+// scheduler stops.
+type Job func(ctx context.Context) error
+
+// Periodic runs a job on a fixed interval in a background goroutine
+type Periodic struct {
+	name     string
+	interval time.Duration
+	job      Job
+
+	cancel   context.CancelFunc
+	done     sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewPeriodic creates a scheduler that runs job every interval once started.
+// name identifies the job in log messages.
+func NewPeriodic(name string, interval time.Duration, job Job) *Periodic {
+	return &Periodic{
+		name:     name,
+		interval: interval,
+		job:      job,
+	}
+}
+
+// Start runs the job immediately and then on every interval until Stop is
+// called. Errors are logged and do not stop the schedule.
+func (p *Periodic) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := p.job(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Scheduled job %s failed: %v", p.name, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop cancels a running job and waits for the scheduler to exit. Calling
+// it again, or on a scheduler that was never started, is a no-op.
+func (p *Periodic) Stop() {
+	p.stopOnce.Do(func() {
+		if p.cancel != nil {
+			p.cancel()
+		}
+		p.done.Wait()
+	})
+}
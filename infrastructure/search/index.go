@@ -0,0 +1,293 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// Weights of the fields a term can come from
+const (
+	nameWeight    = 1.0
+	emailWeight   = 0.8 // a word of the email address
+	addressWeight = 1.5 // the whole canonical email address
+)
+
+// Match qualities of a query term against an indexed term
+const (
+	exactQuality  = 1.0
+	prefixQuality = 0.8 // scaled by how much of the indexed term is given
+	fuzzyQuality  = 0.6 // divided by the edit distance plus one
+)
+
+// removed is the version recorded for permanently deleted users, so late
+// updates can't bring them back
+const removed = math.MaxInt
+
+// Index is an in-memory inverted index over the names and emails of users.
+// A query matches a user when every query term matches one of the user's
+// terms exactly, as a prefix or within a small edit distance. Hits are
+// ranked by the sum of their best matches, weighted by field.
+//
+// Prefix lookups use the sorted vocabulary; typo tolerance scans it, so
+// queries get slower as the number of distinct terms grows.
+//
+// The index holds the users of every tenant; searches only return the hits
+// of the tenant of their context.
+type Index struct {
+	mutex      sync.RWMutex
+	postings   map[string]map[int]float64 // term -> user ID -> field weight
+	terms      map[int][]string           // user ID -> its terms
+	versions   map[int]int                // user ID -> version last indexed
+	tenants    map[int]string             // user ID -> its tenant
+	vocabulary []string                   // sorted terms
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[int]float64),
+		terms:    make(map[int][]string),
+		versions: make(map[int]int),
+		tenants:  make(map[int]string),
+	}
+}
+
+// Rebuild replaces the contents of the index with the users of every tenant
+// in repo
+func (i *Index) Rebuild(ctx context.Context, repo repository.UserRepository) error {
+	users, err := repo.List(repository.IncludeAllTenants(ctx))
+	if err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.postings = make(map[string]map[int]float64)
+	i.terms = make(map[int][]string)
+	i.versions = make(map[int]int)
+	i.tenants = make(map[int]string)
+	i.vocabulary = nil
+	for _, user := range users {
+		i.put(user)
+	}
+	return nil
+}
+
+// Put indexes the current state of user. Soft-deleted users are removed
+// from the index, and states older than the one indexed are ignored, so
+// changes applied out of order leave the latest one in place.
+func (i *Index) Put(user *entities.User) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.put(user)
+}
+
+// Remove drops a permanently deleted user from the index
+func (i *Index) Remove(id int) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.removeTerms(id)
+	i.versions[id] = removed
+}
+
+// Search finds users matching query
+func (i *Index) Search(ctx context.Context, query string, offset, limit int) ([]repository.SearchHit, int, error) {
+	if offset < 0 || limit < 0 {
+		return nil, 0, entities.ErrInvalidPagination
+	}
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	var scores map[int]float64
+	for _, term := range queryTerms(query) {
+		matches := i.match(term)
+		if scores == nil {
+			scores = matches
+		} else {
+			for id := range scores {
+				if score, ok := matches[id]; ok {
+					scores[id] += score
+				} else {
+					delete(scores, id)
+				}
+			}
+		}
+		if len(scores) == 0 {
+			break
+		}
+	}
+
+	hits := make([]repository.SearchHit, 0, len(scores))
+	for id, score := range scores {
+		if !repository.InTenant(ctx, i.tenants[id]) {
+			continue
+		}
+		hits = append(hits, repository.SearchHit{UserID: id, Score: score})
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].UserID < hits[b].UserID
+	})
+
+	total := len(hits)
+	if offset > total {
+		offset = total
+	}
+	end := min(offset+limit, total)
+	return hits[offset:end], total, nil
+}
+
+// put indexes user. The caller must hold the write lock.
+func (i *Index) put(user *entities.User) {
+	if version, seen := i.versions[user.ID]; seen && user.Version < version {
+		return
+	}
+	i.versions[user.ID] = user.Version
+
+	i.removeTerms(user.ID)
+	if user.IsDeleted() {
+		return
+	}
+	i.tenants[user.ID] = user.Tenant()
+
+	terms := documentTerms(user)
+	for term, weight := range terms {
+		posting, exists := i.postings[term]
+		if !exists {
+			posting = make(map[int]float64)
+			i.postings[term] = posting
+			i.addToVocabulary(term)
+		}
+		posting[user.ID] = weight
+		i.terms[user.ID] = append(i.terms[user.ID], term)
+	}
+}
+
+// removeTerms drops the terms of a user. The caller must hold the write
+// lock.
+func (i *Index) removeTerms(id int) {
+	for _, term := range i.terms[id] {
+		posting := i.postings[term]
+		delete(posting, id)
+		if len(posting) == 0 {
+			delete(i.postings, term)
+			i.removeFromVocabulary(term)
+		}
+	}
+	delete(i.terms, id)
+	delete(i.tenants, id)
+}
+
+// addToVocabulary inserts a new term into the sorted vocabulary
+func (i *Index) addToVocabulary(term string) {
+	at := sort.SearchStrings(i.vocabulary, term)
+	i.vocabulary = append(i.vocabulary, "")
+	copy(i.vocabulary[at+1:], i.vocabulary[at:])
+	i.vocabulary[at] = term
+}
+
+// removeFromVocabulary deletes a term from the sorted vocabulary
+func (i *Index) removeFromVocabulary(term string) {
+	at := sort.SearchStrings(i.vocabulary, term)
+	if at < len(i.vocabulary) && i.vocabulary[at] == term {
+		i.vocabulary = append(i.vocabulary[:at], i.vocabulary[at+1:]...)
+	}
+}
+
+// match returns the best score of every user with a term matching the
+// query term. The caller must hold the read lock.
+func (i *Index) match(term string) map[int]float64 {
+	matches := make(map[int]float64)
+	add := func(indexed string, quality float64) {
+		for id, weight := range i.postings[indexed] {
+			if score := quality * weight; score > matches[id] {
+				matches[id] = score
+			}
+		}
+	}
+
+	// Exact and prefix matches are adjacent in the sorted vocabulary
+	for at := sort.SearchStrings(i.vocabulary, term); at < len(i.vocabulary) && strings.HasPrefix(i.vocabulary[at], term); at++ {
+		indexed := i.vocabulary[at]
+		if indexed == term {
+			add(indexed, exactQuality)
+		} else {
+			add(indexed, prefixQuality*float64(len(term))/float64(len(indexed)))
+		}
+	}
+
+	runes := []rune(term)
+	edits := maxEdits(len(runes))
+	if edits == 0 {
+		return matches
+	}
+	for _, indexed := range i.vocabulary {
+		if diff := utf8.RuneCountInString(indexed) - len(runes); diff > edits || -diff > edits {
+			continue
+		}
+		if distance := editDistance(runes, []rune(indexed), edits); distance > 0 && distance <= edits {
+			add(indexed, fuzzyQuality/float64(distance+1))
+		}
+	}
+	return matches
+}
+
+// documentTerms returns the terms of user with the weight of the best
+// field each appears in
+func documentTerms(user *entities.User) map[string]float64 {
+	terms := make(map[string]float64)
+	add := func(term string, weight float64) {
+		if weight > terms[term] {
+			terms[term] = weight
+		}
+	}
+
+	for _, word := range words(user.Name) {
+		add(word, nameWeight)
+	}
+	// Unlike uniqueness, search ignores the case of the local part too
+	address := strings.ToLower(user.EmailKey())
+	add(address, addressWeight)
+	for _, word := range words(address) {
+		add(word, emailWeight)
+	}
+	return terms
+}
+
+// queryTerms splits a query into distinct terms. A word that looks like an
+// email address is kept whole, so it can match an address with a typo in it
+// or be completed as a prefix.
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for _, word := range strings.Fields(query) {
+		if strings.Index(word, "@") > 0 {
+			add(strings.ToLower(entities.NormalizeEmail(word)))
+			continue
+		}
+		for _, term := range words(word) {
+			add(term)
+		}
+	}
+	return terms
+}
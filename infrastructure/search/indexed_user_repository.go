@@ -0,0 +1,81 @@
+package search
+
+import (
+	"context"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// IndexedUserRepository keeps an Index in sync with the writes made through
+// a UserRepository. Index updates wait for the surrounding transaction, if
+// any, to commit, so rolled back changes are never searchable.
+type IndexedUserRepository struct {
+	repository.UserRepository
+	index *Index
+}
+
+// NewIndexedUserRepository wraps repo so that every successful write is
+// applied to index. Fill the index from the existing users with
+// Index.Rebuild before serving searches.
+func NewIndexedUserRepository(repo repository.UserRepository, index *Index) repository.UserRepository {
+	return &IndexedUserRepository{
+		UserRepository: repo,
+		index:          index,
+	}
+}
+
+// Create creates a user and indexes it
+func (r *IndexedUserRepository) Create(ctx context.Context, user *entities.User) error {
+	if err := r.UserRepository.Create(ctx, user); err != nil {
+		return err
+	}
+	r.put(ctx, user)
+	return nil
+}
+
+// Update updates a user and reindexes it
+func (r *IndexedUserRepository) Update(ctx context.Context, user *entities.User) error {
+	if err := r.UserRepository.Update(ctx, user); err != nil {
+		return err
+	}
+	r.put(ctx, user)
+	return nil
+}
+
+// Delete deletes a user and drops it from the index
+func (r *IndexedUserRepository) Delete(ctx context.Context, id int) error {
+	if err := r.UserRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	repository.AfterCommit(ctx, func() { r.index.Remove(id) })
+	return nil
+}
+
+// SnapshotUsers forwards to the wrapped repository
+func (r *IndexedUserRepository) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return nil, entities.ErrBackupsUnsupported
+	}
+	return snapshotter.SnapshotUsers(ctx)
+}
+
+// RestoreUsers restores the wrapped repository and rebuilds the index from
+// the restored users
+func (r *IndexedUserRepository) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	snapshotter, ok := r.UserRepository.(repository.UserSnapshotter)
+	if !ok {
+		return entities.ErrBackupsUnsupported
+	}
+	if err := snapshotter.RestoreUsers(ctx, snapshot); err != nil {
+		return err
+	}
+	return r.index.Rebuild(ctx, r.UserRepository)
+}
+
+// put indexes a copy of user once the change is committed
+func (r *IndexedUserRepository) put(ctx context.Context, user *entities.User) {
+	stored := *user
+	repository.AfterCommit(ctx, func() { r.index.Put(&stored) })
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"agent-orchestration/interfaces/repository"
+)
+
+// BackupResponse describes a stored backup
+type BackupResponse struct {
+	Name  string    `json:"name"`
+	Taken time.Time `json:"taken"`
+	Users int       `json:"users"`
+	Size  int64     `json:"size"`
+}
+
+// ListBackupsResponse lists the stored backups, newest first
+type ListBackupsResponse struct {
+	Backups []BackupResponse `json:"backups"`
+}
+
+// RestoreResponse describes a restored backup
+type RestoreResponse struct {
+	Name   string    `json:"name"`
+	Taken  time.Time `json:"taken"`
+	Users  int       `json:"users"`
+	NextID int       `json:"next_id"`
+}
+
+// CreateBackup handles POST /admin/backups
+func (h *UserHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	info, err := h.userUseCase.BackupUsers(r.Context())
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to back up users")
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, backupResponse(info))
+}
+
+// ListBackups handles GET /admin/backups
+func (h *UserHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.userUseCase.ListBackups(r.Context())
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to list backups")
+		return
+	}
+
+	response := ListBackupsResponse{Backups: make([]BackupResponse, 0, len(infos))}
+	for _, info := range infos {
+		response.Backups = append(response.Backups, backupResponse(info))
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// RestoreBackup handles POST /admin/backups/{name}/restore
+func (h *UserHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	snapshot, err := h.userUseCase.RestoreUsers(r.Context(), name)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to restore backup")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, RestoreResponse{
+		Name:   name,
+		Taken:  snapshot.Taken,
+		Users:  len(snapshot.Users),
+		NextID: snapshot.NextID,
+	})
+}
+
+// backupResponse converts a BackupInfo to its response
+func backupResponse(info *repository.BackupInfo) BackupResponse {
+	return BackupResponse{
+		Name:  info.Name,
+		Taken: info.Taken,
+		Users: info.Users,
+		Size:  info.Size,
+	}
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"agent-orchestration/infrastructure/chaos"
+)
+
+// ChaosRule represents a fault injection rule, with the latency written as
+// a duration such as "250ms"
+type ChaosRule struct {
+	Operation string  `json:"operation,omitempty"`
+	MinID     int     `json:"min_id,omitempty"`
+	MaxID     int     `json:"max_id,omitempty"`
+	Rate      float64 `json:"rate"`
+	Latency   string  `json:"latency,omitempty"`
+	Fault     string  `json:"fault,omitempty"`
+}
+
+// ChaosRulesRequest represents the request to replace the fault injection
+// rules
+type ChaosRulesRequest struct {
+	Rules []ChaosRule `json:"rules"`
+}
+
+// ChaosRulesResponse lists the fault injection rules in force
+type ChaosRulesResponse struct {
+	Rules []ChaosRule `json:"rules"`
+}
+
+// WithFaultInjector serves the rules of injector on the chaos endpoints,
+// which are only mounted when fault injection is enabled, for tests
+func WithFaultInjector(injector *chaos.Injector) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.injector = injector
+	}
+}
+
+// ChaosRules handles GET /admin/chaos
+func (h *UserHandler) ChaosRules(w http.ResponseWriter, r *http.Request) {
+	h.writeChaosRules(w)
+}
+
+// SetChaosRules handles PUT /admin/chaos
+func (h *UserHandler) SetChaosRules(w http.ResponseWriter, r *http.Request) {
+	var req ChaosRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	rules, err := decodeChaosRules(req.Rules)
+	if err == nil {
+		err = h.injector.SetRules(rules)
+	}
+	if errors.Is(err, chaos.ErrInvalidRule) {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to set fault injection rules")
+		return
+	}
+
+	h.writeChaosRules(w)
+}
+
+// ClearChaosRules handles DELETE /admin/chaos
+func (h *UserHandler) ClearChaosRules(w http.ResponseWriter, r *http.Request) {
+	if err := h.injector.SetRules(nil); err != nil {
+		h.writeUseCaseError(w, err, "failed to clear fault injection rules")
+		return
+	}
+
+	h.writeChaosRules(w)
+}
+
+// writeChaosRules writes the rules in force
+func (h *UserHandler) writeChaosRules(w http.ResponseWriter) {
+	response := ChaosRulesResponse{Rules: make([]ChaosRule, 0)}
+	for _, rule := range h.injector.Rules() {
+		encoded := ChaosRule{
+			Operation: rule.Operation,
+			MinID:     rule.MinID,
+			MaxID:     rule.MaxID,
+			Rate:      rule.Rate,
+			Fault:     rule.Fault,
+		}
+		if rule.Latency > 0 {
+			encoded.Latency = rule.Latency.String()
+		}
+		response.Rules = append(response.Rules, encoded)
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// decodeChaosRules converts rules from their JSON form
+func decodeChaosRules(encoded []ChaosRule) ([]chaos.Rule, error) {
+	rules := make([]chaos.Rule, 0, len(encoded))
+	for n, rule := range encoded {
+		var latency time.Duration
+		if rule.Latency != "" {
+			var err error
+			if latency, err = time.ParseDuration(rule.Latency); err != nil {
+				return nil, fmt.Errorf("%w: rule %d: invalid latency %q", chaos.ErrInvalidRule, n+1, rule.Latency)
+			}
+		}
+		rules = append(rules, chaos.Rule{
+			Operation: rule.Operation,
+			MinID:     rule.MinID,
+			MaxID:     rule.MaxID,
+			Rate:      rule.Rate,
+			Latency:   latency,
+			Fault:     rule.Fault,
+		})
+	}
+	return rules, nil
+}
@@ -0,0 +1,95 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/infrastructure/chaos"
+	httphandler "agent-orchestration/interfaces/http"
+	"agent-orchestration/internal/mocks"
+	"agent-orchestration/use_cases"
+)
+
+var _ = Describe("Chaos endpoints", func() {
+	var (
+		injector *chaos.Injector
+		router   *chi.Mux
+	)
+
+	BeforeEach(func() {
+		injector = chaos.NewInjector()
+		userUseCase := use_cases.NewUserUseCase(&mocks.UserRepositoryMock{})
+		handler := httphandler.NewUserHandler(userUseCase, httphandler.WithFaultInjector(injector))
+
+		router = chi.NewRouter()
+		router.Get("/admin/chaos", handler.ChaosRules)
+		router.Put("/admin/chaos", handler.SetChaosRules)
+		router.Delete("/admin/chaos", handler.ClearChaosRules)
+	})
+
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/chaos", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	It("should return no rules rather than null", func() {
+		w := serve("GET", "")
+
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(w.Body.String()).To(MatchJSON(`{"rules": []}`))
+	})
+
+	It("should replace the rules, reading latencies as durations", func() {
+		w := serve("PUT", `{"rules": [{"operation": "update", "min_id": 2, "rate": 0.5, "latency": "250ms", "fault": "error"}]}`)
+
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(injector.Rules()).To(Equal([]chaos.Rule{
+			{Operation: chaos.OperationUpdate, MinID: 2, Rate: 0.5, Latency: 250 * time.Millisecond, Fault: chaos.FaultError},
+		}))
+		var response httphandler.ChaosRulesResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Rules).To(Equal([]httphandler.ChaosRule{
+			{Operation: "update", MinID: 2, Rate: 0.5, Latency: "250ms", Fault: "error"},
+		}))
+	})
+
+	DescribeTable("should reject invalid rules, keeping the ones in force",
+		func(body, message string) {
+			kept := chaos.Rule{Rate: 1, Fault: chaos.FaultError}
+			Expect(injector.SetRules([]chaos.Rule{kept})).To(Succeed())
+
+			w := serve("PUT", body)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			var response httphandler.ErrorResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Error).To(Equal(message))
+			Expect(injector.Rules()).To(Equal([]chaos.Rule{kept}))
+		},
+		Entry("malformed latency", `{"rules": [{"rate": 1, "fault": "error"}, {"rate": 1, "latency": "soon"}]}`,
+			`invalid fault injection rule: rule 2: invalid latency "soon"`),
+		Entry("refused by the injector", `{"rules": [{"rate": 1, "fault": "boom"}]}`,
+			`invalid fault injection rule: rule 1: unknown fault "boom"`),
+		Entry("invalid request body", `{"rules":`, "invalid request body"),
+	)
+
+	It("should remove every rule", func() {
+		Expect(injector.SetRules([]chaos.Rule{{Rate: 1, Fault: chaos.FaultError}})).To(Succeed())
+
+		w := serve("DELETE", "")
+
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"rules": []}`))
+		Expect(injector.Rules()).To(BeEmpty())
+	})
+})
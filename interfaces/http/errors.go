@@ -0,0 +1,103 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"agent-orchestration/entities"
+)
+
+// statusByCode maps the codes of domain errors to response statuses. Codes
+// missing from it are internal errors.
+var statusByCode = map[entities.ErrorCode]int{
+	entities.CodeUserNotFound:        http.StatusNotFound,
+	entities.CodeValidationFailed:    http.StatusUnprocessableEntity,
+	entities.CodeUserNameRequired:    http.StatusUnprocessableEntity,
+	entities.CodeUserEmailRequired:   http.StatusUnprocessableEntity,
+	entities.CodeUserNameTooLong:     http.StatusUnprocessableEntity,
+	entities.CodeInvalidUserName:     http.StatusUnprocessableEntity,
+	entities.CodeInvalidEmail:        http.StatusUnprocessableEntity,
+	entities.CodeUserAlreadyExists:   http.StatusConflict,
+	entities.CodeVersionConflict:     http.StatusConflict,
+	entities.CodePreconditionFailed:  http.StatusPreconditionFailed,
+	entities.CodeUserNotDeleted:      http.StatusConflict,
+	entities.CodeInvalidID:           http.StatusBadRequest,
+	entities.CodeInvalidPagination:   http.StatusBadRequest,
+	entities.CodeInvalidCursor:       http.StatusBadRequest,
+	entities.CodeInvalidSortField:    http.StatusBadRequest,
+	entities.CodeSearchQueryRequired: http.StatusBadRequest,
+	entities.CodeInvalidParameter:    http.StatusBadRequest,
+	entities.CodeInvalidRecord:       http.StatusBadRequest,
+	entities.CodeBackupNotFound:      http.StatusNotFound,
+	entities.CodeInvalidBackup:       http.StatusUnprocessableEntity,
+	entities.CodeBackupsUnsupported:  http.StatusNotImplemented,
+	entities.CodeRetentionDisabled:   http.StatusNotImplemented,
+	entities.CodeWatchTooFarBehind:   http.StatusGone,
+	entities.CodeInvalidSequence:     http.StatusBadRequest,
+	entities.CodeWatchUnsupported:    http.StatusNotImplemented,
+
+	entities.CodeInvalidStatus:           http.StatusBadRequest,
+	entities.CodeInvalidStatusTransition: http.StatusConflict,
+	entities.CodeStatusReasonRequired:    http.StatusUnprocessableEntity,
+
+	entities.CodeForbidden:   http.StatusForbidden,
+	entities.CodeUnknownRole: http.StatusNotFound,
+
+	entities.CodeInvalidTenant: http.StatusBadRequest,
+}
+
+// ErrorResponse represents an error. Code, Field and Details are only set
+// for domain errors, and Violations only for validation errors, which list
+// every invalid field so a form can highlight them all at once.
+type ErrorResponse struct {
+	Error      string              `json:"error"`
+	Code       string              `json:"code,omitempty"`
+	Field      string              `json:"field,omitempty"`
+	Details    map[string]any      `json:"details,omitempty"`
+	Violations []ViolationResponse `json:"violations,omitempty"`
+}
+
+// ViolationResponse represents a rule broken by a field
+type ViolationResponse struct {
+	Field   string         `json:"field"`
+	Code    string         `json:"code"`
+	Error   string         `json:"error"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// errorResponse translates err into a response status and body. Domain
+// errors, wrapped or not, get the status of their code and are described
+// in full. Any other error is internal and only described by message, so
+// nothing is leaked about the failure.
+func errorResponse(err error, message string) (int, ErrorResponse) {
+	var domainErr *entities.Error
+	if !errors.As(err, &domainErr) {
+		return http.StatusInternalServerError, ErrorResponse{Error: message}
+	}
+	status, ok := statusByCode[domainErr.Code]
+	if !ok {
+		return http.StatusInternalServerError, ErrorResponse{Error: message}
+	}
+	response := ErrorResponse{
+		Error:   err.Error(),
+		Code:    string(domainErr.Code),
+		Field:   domainErr.Field,
+		Details: domainErr.Details,
+	}
+	for _, violation := range domainErr.Violations {
+		response.Violations = append(response.Violations, ViolationResponse{
+			Field:   violation.Field,
+			Code:    string(violation.Code),
+			Error:   violation.Error(),
+			Details: violation.Details,
+		})
+	}
+	return status, response
+}
+
+// writeUseCaseError writes the response translating an error returned by a
+// use case. message describes internal errors.
+func (h *UserHandler) writeUseCaseError(w http.ResponseWriter, err error, message string) {
+	status, response := errorResponse(err, message)
+	h.writeJSON(w, status, response)
+}
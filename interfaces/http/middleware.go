@@ -0,0 +1,49 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/requestctx"
+)
+
+// PrincipalHeader names the acting principal of a request. It is expected
+// to be set by an authenticating proxy in front of the server.
+const PrincipalHeader = "X-Principal"
+
+// TenantHeader names the tenant a request acts in. Like the principal, it
+// is expected to be set by the authenticating proxy from the credential of
+// the caller. Requests without one act in entities.DefaultTenant.
+const TenantHeader = "X-Tenant-ID"
+
+// RequestContext copies the request ID assigned by chi's RequestID
+// middleware and the acting principal into the request context, where the
+// use cases can read them through requestctx, and scopes the repositories
+// to the tenant of the request. A malformed tenant ID is rejected with 400.
+// It must be installed after middleware.RequestID.
+func RequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if requestID := middleware.GetReqID(ctx); requestID != "" {
+			ctx = requestctx.WithRequestID(ctx, requestID)
+		}
+		if principal := r.Header.Get(PrincipalHeader); principal != "" {
+			ctx = requestctx.WithPrincipal(ctx, principal)
+		}
+		if tenant := r.Header.Get(TenantHeader); tenant != "" {
+			if !entities.ValidTenantID(tenant) {
+				status, response := errorResponse(entities.ErrInvalidTenant.WithDetail("tenant", tenant), "invalid tenant ID")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+			ctx = repository.WithTenant(ctx, tenant)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,80 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	httphandler "agent-orchestration/interfaces/http"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/requestctx"
+)
+
+var _ = Describe("RequestContext", func() {
+	var (
+		router    *chi.Mux
+		requestID string
+		principal string
+		tenant    string
+	)
+
+	BeforeEach(func() {
+		requestID, principal, tenant = "", "", ""
+
+		router = chi.NewRouter()
+		router.Use(middleware.RequestID)
+		router.Use(httphandler.RequestContext)
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			requestID = requestctx.RequestID(r.Context())
+			principal = requestctx.Principal(r.Context())
+			tenant = repository.Tenant(r.Context())
+		})
+	})
+
+	It("should carry the request ID and principal into the context", func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(middleware.RequestIDHeader, "req-123")
+		req.Header.Set(httphandler.PrincipalHeader, "admin")
+
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		Expect(requestID).To(Equal("req-123"))
+		Expect(principal).To(Equal("admin"))
+	})
+
+	It("should leave the principal empty for anonymous requests", func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		Expect(requestID).NotTo(BeEmpty())
+		Expect(principal).To(BeEmpty())
+		Expect(tenant).To(Equal("default"))
+	})
+
+	It("should scope the request to its tenant", func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(httphandler.TenantHeader, "acme")
+
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		Expect(tenant).To(Equal("acme"))
+	})
+
+	It("should reject a malformed tenant ID", func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(httphandler.TenantHeader, "Acme/Corp")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		var response map[string]any
+		Expect(json.Unmarshal(rec.Body.Bytes(), &response)).To(Succeed())
+		Expect(response["code"]).To(Equal("invalid_tenant"))
+		Expect(requestID).To(BeEmpty())
+	})
+})
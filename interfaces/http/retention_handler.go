@@ -0,0 +1,105 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RetentionRow represents the outcome of the retention policy for one
+// inactive user
+type RetentionRow struct {
+	ID          int        `json:"id"`
+	Email       string     `json:"email"`
+	Updated     time.Time  `json:"updated"`
+	Status      string     `json:"status"`
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// RetentionResponse represents the report of a retention run
+type RetentionResponse struct {
+	DryRun  bool           `json:"dry_run"`
+	Cutoff  time.Time      `json:"cutoff"`
+	Warned  int            `json:"warned"`
+	Pending int            `json:"pending"`
+	Deleted int            `json:"deleted"`
+	Held    int            `json:"held"`
+	Failed  int            `json:"failed"`
+	Users   []RetentionRow `json:"users"`
+}
+
+// RetentionReport handles GET /admin/retention, reporting what enforcing
+// the retention policy now would do without doing it
+func (h *UserHandler) RetentionReport(w http.ResponseWriter, r *http.Request) {
+	h.enforceRetention(w, r, true)
+}
+
+// EnforceRetention handles POST /admin/retention, enforcing the retention
+// policy now rather than at the next scheduled run
+func (h *UserHandler) EnforceRetention(w http.ResponseWriter, r *http.Request) {
+	h.enforceRetention(w, r, false)
+}
+
+// enforceRetention runs the retention policy and writes its report
+func (h *UserHandler) enforceRetention(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	report, err := h.userUseCase.EnforceRetention(r.Context(), dryRun)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to enforce the retention policy")
+		return
+	}
+
+	response := RetentionResponse{
+		DryRun:  report.DryRun,
+		Cutoff:  report.Cutoff,
+		Warned:  report.Warned,
+		Pending: report.Pending,
+		Deleted: report.Deleted,
+		Held:    report.Held,
+		Failed:  report.Failed,
+		Users:   make([]RetentionRow, 0, len(report.Rows)),
+	}
+	for _, row := range report.Rows {
+		result := RetentionRow{ID: row.ID, Email: row.Email, Updated: row.Updated, Status: row.Status}
+		if !row.DeleteAfter.IsZero() {
+			deleteAfter := row.DeleteAfter
+			result.DeleteAfter = &deleteAfter
+		}
+		if row.Err != nil {
+			result.Error = row.Err.Error()
+		}
+		response.Users = append(response.Users, result)
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// PlaceLegalHold handles PUT /users/{id}/legal-hold
+func (h *UserHandler) PlaceLegalHold(w http.ResponseWriter, r *http.Request) {
+	h.setLegalHold(w, r, true)
+}
+
+// ReleaseLegalHold handles DELETE /users/{id}/legal-hold
+func (h *UserHandler) ReleaseLegalHold(w http.ResponseWriter, r *http.Request) {
+	h.setLegalHold(w, r, false)
+}
+
+// setLegalHold places a user on legal hold or releases it and writes the
+// user. Soft-deleted users can be held too.
+func (h *UserHandler) setLegalHold(w http.ResponseWriter, r *http.Request, hold bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	user, err := h.userUseCase.SetLegalHold(r.Context(), id, hold)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to set legal hold")
+		return
+	}
+
+	w.Header().Set("ETag", etag(user))
+	h.writeJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListRoles handles GET /roles
+func (h *UserHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.userUseCase.ListRoles(r.Context())
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to list roles")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, roles)
+}
+
+// AssignRole handles PUT /users/{id}/roles/{role}
+func (h *UserHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRoles(w, r, true)
+}
+
+// RevokeRole handles DELETE /users/{id}/roles/{role}
+func (h *UserHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRoles(w, r, false)
+}
+
+// changeRoles assigns or revokes the role named in the path and writes the
+// user. An unknown role is not found.
+func (h *UserHandler) changeRoles(w http.ResponseWriter, r *http.Request, assign bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	role := chi.URLParam(r, "role")
+
+	update := h.userUseCase.RevokeRole
+	if assign {
+		update = h.userUseCase.AssignRole
+	}
+	user, err := update(r.Context(), id, role)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to change user roles")
+		return
+	}
+
+	w.Header().Set("ETag", etag(user))
+	h.writeJSON(w, http.StatusOK, user)
+}
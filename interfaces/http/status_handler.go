@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"agent-orchestration/entities"
+)
+
+// StatusChangeRequest represents the request to change the status of a user
+type StatusChangeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ActivateUser handles POST /users/{id}/activate
+func (h *UserHandler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, entities.StatusActive)
+}
+
+// SuspendUser handles POST /users/{id}/suspend
+func (h *UserHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, entities.StatusSuspended)
+}
+
+// DeactivateUser handles POST /users/{id}/deactivate
+func (h *UserHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, entities.StatusDeactivated)
+}
+
+// changeStatus moves a user to status for the reason in the request body
+// and writes the user. A move the status table doesn't allow is a
+// conflict.
+func (h *UserHandler) changeStatus(w http.ResponseWriter, r *http.Request, status entities.Status) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	// An empty body is a change without a reason, which the use case rejects
+	var req StatusChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var user *entities.User
+	switch status {
+	case entities.StatusActive:
+		user, err = h.userUseCase.ActivateUser(r.Context(), id, req.Reason)
+	case entities.StatusSuspended:
+		user, err = h.userUseCase.SuspendUser(r.Context(), id, req.Reason)
+	case entities.StatusDeactivated:
+		user, err = h.userUseCase.DeactivateUser(r.Context(), id, req.Reason)
+	}
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to change user status")
+		return
+	}
+
+	w.Header().Set("ETag", etag(user))
+	h.writeJSON(w, http.StatusOK, user)
+}
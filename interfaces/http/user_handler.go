@@ -1,26 +1,42 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	
 	"github.com/go-chi/chi/v5"
 	
 	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/chaos"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/interfaces/userio"
 	"agent-orchestration/use_cases"
 )
 
 // UserHandler handles HTTP requests for users
 type UserHandler struct {
 	userUseCase *use_cases.UserUseCase
+	injector    *chaos.Injector
 }
 
+// UserHandlerOption configures optional features of a UserHandler
+type UserHandlerOption func(*UserHandler)
+
 // NewUserHandler creates a new UserHandler
-func NewUserHandler(userUseCase *use_cases.UserUseCase) *UserHandler {
-	return &UserHandler{
+func NewUserHandler(userUseCase *use_cases.UserUseCase, opts ...UserHandlerOption) *UserHandler {
+	h := &UserHandler{
 		userUseCase: userUseCase,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // CreateUserRequest represents the request body for creating a user
@@ -45,17 +61,11 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	
 	user, err := h.userUseCase.CreateUser(r.Context(), req.Name, req.Email)
 	if err != nil {
-		switch err {
-		case entities.ErrUserAlreadyExists:
-			h.writeError(w, http.StatusConflict, err.Error())
-		case entities.ErrUserNameRequired, entities.ErrUserEmailRequired:
-			h.writeError(w, http.StatusBadRequest, err.Error())
-		default:
-			h.writeError(w, http.StatusInternalServerError, "failed to create user")
-		}
+		h.writeUseCaseError(w, err, "failed to create user")
 		return
 	}
 	
+	w.Header().Set("ETag", etag(user))
 	h.writeJSON(w, http.StatusCreated, user)
 }
 
@@ -67,19 +77,13 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	user, err := h.userUseCase.GetUserByID(r.Context(), id)
+	user, err := h.userUseCase.GetUserByID(readContext(r), id)
 	if err != nil {
-		switch err {
-		case entities.ErrUserNotFound:
-			h.writeError(w, http.StatusNotFound, err.Error())
-		case entities.ErrInvalidID:
-			h.writeError(w, http.StatusBadRequest, err.Error())
-		default:
-			h.writeError(w, http.StatusInternalServerError, "failed to get user")
-		}
+		h.writeUseCaseError(w, err, "failed to get user")
 		return
 	}
 	
+	w.Header().Set("ETag", etag(user))
 	h.writeJSON(w, http.StatusOK, user)
 }
 
@@ -97,19 +101,19 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	user, err := h.userUseCase.UpdateUser(r.Context(), id, req.Name, req.Email)
+	expectedVersion, ok := parseIfMatch(r.Header.Get("If-Match"))
+	if !ok {
+		h.writeUseCaseError(w, entities.ErrPreconditionFailed, "failed to update user")
+		return
+	}
+	
+	user, err := h.userUseCase.UpdateUserIfMatch(r.Context(), id, expectedVersion, req.Name, req.Email)
 	if err != nil {
-		switch err {
-		case entities.ErrUserNotFound:
-			h.writeError(w, http.StatusNotFound, err.Error())
-		case entities.ErrInvalidID, entities.ErrUserNameRequired, entities.ErrUserEmailRequired:
-			h.writeError(w, http.StatusBadRequest, err.Error())
-		default:
-			h.writeError(w, http.StatusInternalServerError, "failed to update user")
-		}
+		h.writeUseCaseError(w, err, "failed to update user")
 		return
 	}
 	
+	w.Header().Set("ETag", etag(user))
 	h.writeJSON(w, http.StatusOK, user)
 }
 
@@ -123,29 +127,356 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	
 	err = h.userUseCase.DeleteUser(r.Context(), id)
 	if err != nil {
-		switch err {
-		case entities.ErrUserNotFound:
-			h.writeError(w, http.StatusNotFound, err.Error())
-		case entities.ErrInvalidID:
-			h.writeError(w, http.StatusBadRequest, err.Error())
-		default:
-			h.writeError(w, http.StatusInternalServerError, "failed to delete user")
-		}
+		h.writeUseCaseError(w, err, "failed to delete user")
 		return
 	}
 	
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListUsers handles GET /users
+// RestoreUser handles POST /users/{id}/restore
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	
+	user, err := h.userUseCase.RestoreUser(r.Context(), id)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to restore user")
+		return
+	}
+	
+	w.Header().Set("ETag", etag(user))
+	h.writeJSON(w, http.StatusOK, user)
+}
+
+// HistoryResponse represents a page of a user's change history
+type HistoryResponse struct {
+	Entries []*entities.HistoryEntry `json:"entries"`
+	Total   int                      `json:"total"`
+	Offset  int                      `json:"offset"`
+}
+
+// GetUserHistory handles GET /users/{id}/history
+func (h *UserHandler) GetUserHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	
+	offset, err := queryInt(r, "offset")
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	
+	entries, total, err := h.userUseCase.GetUserHistory(r.Context(), id, offset, limit)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to get user history")
+		return
+	}
+	
+	h.writeJSON(w, http.StatusOK, HistoryResponse{
+		Entries: entries,
+		Total:   total,
+		Offset:  offset,
+	})
+}
+
+// SearchResult represents a user found by a search
+type SearchResult struct {
+	User  *entities.User `json:"user"`
+	Score float64        `json:"score"`
+}
+
+// SearchResponse represents a page of search results, best match first
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+}
+
+// SearchUsers handles GET /users/search?q=
+func (h *UserHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	offset, err := queryInt(r, "offset")
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	
+	results, total, err := h.userUseCase.SearchUsers(r.Context(), r.URL.Query().Get("q"), offset, limit)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to search users")
+		return
+	}
+	
+	response := SearchResponse{
+		Results: make([]SearchResult, 0, len(results)),
+		Total:   total,
+		Offset:  offset,
+	}
+	for _, result := range results {
+		response.Results = append(response.Results, SearchResult{User: result.User, Score: result.Score})
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// ListUsersResponse represents a page of users. Next and Prev are cursors
+// for the following and preceding pages, omitted when there is none.
+type ListUsersResponse struct {
+	Users []*entities.User `json:"users"`
+	Next  string           `json:"next,omitempty"`
+	Prev  string           `json:"prev,omitempty"`
+}
+
+// ListUsers handles GET /users. The page is selected by the limit, cursor,
+// sort, order (asc or desc), name_prefix, email_domain, status and
+// created_after, created_before, updated_after and updated_before
+// (RFC 3339) query parameters.
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.userUseCase.ListUsers(r.Context())
+	opts, err := parseListOptions(r)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to list users")
+		return
+	}
+	
+	page, err := h.userUseCase.ListUsers(readContext(r), opts)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to list users")
+		return
+	}
+	
+	h.writeJSON(w, http.StatusOK, ListUsersResponse{
+		Users: page.Users,
+		Next:  page.Next,
+		Prev:  page.Prev,
+	})
+}
+
+// ExportUsers handles GET /users/export?format=jsonl|csv. Every user is
+// streamed, soft-deleted ones too when include_deleted is true.
+func (h *UserHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	format, err := parseFormat(r)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to export users")
+		return
+	}
+	
+	out := &sentWriter{w: w}
+	writer := userio.NewWriter(out, format)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, format))
+	
+	err = h.userUseCase.ExportUsers(readContext(r), writer.Write)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		return
+	}
+	
+	if !out.sent {
+		w.Header().Del("Content-Disposition")
+		h.writeUseCaseError(w, err, "failed to export users")
+		return
+	}
+	// Part of the export was sent already, so the status can't be changed.
+	// Abort the response instead of letting it look complete.
+	log.Printf("Exporting users failed: %v", err)
+	panic(http.ErrAbortHandler)
+}
+
+// sentWriter records whether anything was written through it
+type sentWriter struct {
+	w    http.ResponseWriter
+	sent bool
+}
+
+func (s *sentWriter) Write(p []byte) (int, error) {
+	s.sent = true
+	return s.w.Write(p)
+}
+
+// ImportRow represents the outcome of importing one row
+type ImportRow struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	ID     int    `json:"id,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportResponse represents the report of an import
+type ImportResponse struct {
+	DryRun     bool        `json:"dry_run"`
+	Created    int         `json:"created"`
+	Duplicates int         `json:"duplicates"`
+	Invalid    int         `json:"invalid"`
+	Rows       []ImportRow `json:"rows"`
+}
+
+// ImportUsers handles POST /users/import?format=jsonl|csv. With dry_run=true
+// the rows are checked but no user is created.
+func (h *UserHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	format, err := parseFormat(r)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to import users")
+		return
+	}
+	
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			h.writeUseCaseError(w, entities.ErrInvalidParameter.WithField("dry_run"), "failed to import users")
+			return
+		}
+	}
+	
+	reader, err := userio.NewReader(r.Body, format)
 	if err != nil {
-		h.writeError(w, http.StatusInternalServerError, "failed to list users")
+		h.writeUseCaseError(w, entities.ErrInvalidRecord.Wrap(err), "failed to import users")
 		return
 	}
 	
-	h.writeJSON(w, http.StatusOK, users)
+	report, err := h.userUseCase.ImportUsers(r.Context(), reader, dryRun)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to import users")
+		return
+	}
+	
+	response := ImportResponse{
+		DryRun:     report.DryRun,
+		Created:    report.Created,
+		Duplicates: report.Duplicates,
+		Invalid:    report.Invalid,
+		Rows:       make([]ImportRow, 0, len(report.Rows)),
+	}
+	for _, row := range report.Rows {
+		result := ImportRow{Line: row.Line, Status: row.Status, ID: row.ID, Email: row.Email}
+		if row.Err != nil {
+			result.Error = row.Err.Error()
+		}
+		response.Rows = append(response.Rows, result)
+	}
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// parseFormat reads the format query parameter of an export or import
+func parseFormat(r *http.Request) (userio.Format, error) {
+	format, err := userio.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		return "", entities.ErrInvalidParameter.WithField("format").Wrap(err)
+	}
+	return format, nil
+}
+
+// parseListOptions reads the ListUsers query parameters
+func parseListOptions(r *http.Request) (repository.ListOptions, error) {
+	query := r.URL.Query()
+	opts := repository.ListOptions{
+		Cursor:      query.Get("cursor"),
+		SortBy:      repository.SortField(query.Get("sort")),
+		NamePrefix:  query.Get("name_prefix"),
+		EmailDomain: query.Get("email_domain"),
+		Status:      entities.Status(query.Get("status")),
+	}
+	
+	limit, err := queryInt(r, "limit")
+	if err != nil {
+		return opts, entities.ErrInvalidPagination.WithField("limit")
+	}
+	opts.Limit = limit
+	
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, entities.ErrInvalidParameter.WithField("order")
+	}
+	
+	for _, bound := range []struct {
+		key   string
+		value *time.Time
+	}{
+		{"created_after", &opts.CreatedAfter},
+		{"created_before", &opts.CreatedBefore},
+		{"updated_after", &opts.UpdatedAfter},
+		{"updated_before", &opts.UpdatedBefore},
+	} {
+		value := query.Get(bound.key)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return opts, entities.ErrInvalidParameter.WithField(bound.key)
+		}
+		*bound.value = t
+	}
+	
+	return opts, nil
+}
+
+// readContext returns the context for a read request. Soft-deleted users
+// are included when the include_deleted query parameter is true.
+func readContext(r *http.Request) context.Context {
+	if include, _ := strconv.ParseBool(r.URL.Query().Get("include_deleted")); include {
+		return repository.IncludeDeleted(r.Context())
+	}
+	return r.Context()
+}
+
+// queryInt returns the integer query parameter key, or 0 if it is absent
+func queryInt(r *http.Request, key string) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// etag returns the entity tag of a user, derived from its version
+func etag(user *entities.User) string {
+	return fmt.Sprintf(`"%d"`, user.Version)
+}
+
+// parseIfMatch returns the version required by an If-Match header. An absent
+// header or "*" requires no particular version. Weak or malformed tags can
+// never match, since If-Match uses strong comparison.
+func parseIfMatch(header string) (int, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return use_cases.AnyVersion, true
+	}
+	
+	tag, ok := strings.CutPrefix(header, `"`)
+	if !ok {
+		return 0, false
+	}
+	tag, ok = strings.CutSuffix(tag, `"`)
+	if !ok {
+		return 0, false
+	}
+	
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
 }
 
 // writeJSON writes JSON response
@@ -159,5 +490,5 @@ func (h *UserHandler) writeJSON(w http.ResponseWriter, status int, data interfac
 func (h *UserHandler) writeError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
 }
\ No newline at end of file
@@ -16,6 +16,7 @@ import (
 
 	"agent-orchestration/entities"
 	httphandler "agent-orchestration/interfaces/http"
+	"agent-orchestration/interfaces/repository"
 	"agent-orchestration/internal/mocks"
 	"agent-orchestration/use_cases"
 )
@@ -39,6 +40,11 @@ var _ = Describe("UserHandler", func() {
 		router.Get("/users/{id}", handler.GetUser)
 		router.Put("/users/{id}", handler.UpdateUser)
 		router.Delete("/users/{id}", handler.DeleteUser)
+		router.Post("/users/{id}/restore", handler.RestoreUser)
+		router.Get("/users/{id}/history", handler.GetUserHistory)
+		router.Post("/users/{id}/activate", handler.ActivateUser)
+		router.Post("/users/{id}/suspend", handler.SuspendUser)
+		router.Post("/users/{id}/deactivate", handler.DeactivateUser)
 		router.Get("/users", handler.ListUsers)
 	})
 
@@ -151,13 +157,33 @@ var _ = Describe("UserHandler", func() {
 
 					Expect(w.Code).To(Equal(expectedStatus))
 					
-					var response map[string]string
+					var response httphandler.ErrorResponse
 					json.Unmarshal(w.Body.Bytes(), &response)
-					Expect(response["error"]).To(Equal(expectedError))
+					Expect(response.Error).To(Equal(expectedError))
 				},
-				Entry("empty name", "", "john@example.com", http.StatusBadRequest, entities.ErrUserNameRequired.Error()),
-				Entry("empty email", "John Doe", "", http.StatusBadRequest, entities.ErrUserEmailRequired.Error()),
+				Entry("empty name", "", "john@example.com", http.StatusUnprocessableEntity, "user is invalid: user name is required"),
+				Entry("empty email", "John Doe", "", http.StatusUnprocessableEntity, "user is invalid: user email is required"),
+				Entry("email in sealed form", "John Doe", "sealed:not an email:<script>", http.StatusUnprocessableEntity, "user is invalid: user email is not a valid address"),
 			)
+
+			It("should report every invalid field at once", func() {
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: strings.Repeat("x", entities.MaxNameLength+1), Email: "john@"})
+				req := httptest.NewRequest("POST", "/users", bytes.NewReader(body))
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(w.Body.String()).To(MatchJSON(`{
+					"error": "user is invalid: user name is too long; user email is not a valid address",
+					"code": "validation_failed",
+					"violations": [
+						{"field": "name", "code": "user_name_too_long", "error": "user name is too long", "details": {"max_length": 200}},
+						{"field": "email", "code": "invalid_email", "error": "user email is not a valid address"}
+					]
+				}`))
+				Expect(mockRepo.CreateCalls()).To(BeEmpty())
+			})
 		})
 	})
 
@@ -195,6 +221,17 @@ var _ = Describe("UserHandler", func() {
 				Expect(user.Name).To(Equal(expectedUser.Name))
 				Expect(user.Email).To(Equal(expectedUser.Email))
 			})
+
+			It("should return the version as ETag", func() {
+				expectedUser.Version = 3
+				req := httptest.NewRequest("GET", "/users/1", nil)
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("ETag")).To(Equal(`"3"`))
+			})
 		})
 
 		Context("when user not found", func() {
@@ -244,9 +281,22 @@ var _ = Describe("UserHandler", func() {
 				Email:   "john@example.com",
 				Created: time.Now().Add(-24 * time.Hour),
 				Updated: time.Now().Add(-1 * time.Hour),
+				Version: 2,
 			}
 		})
 
+		sendUpdate := func(ifMatch string) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(httphandler.UpdateUserRequest{Name: "Jane Doe"})
+			req := httptest.NewRequest("PUT", "/users/1", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
 		Context("when update is successful", func() {
 			BeforeEach(func() {
 				mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
@@ -283,6 +333,59 @@ var _ = Describe("UserHandler", func() {
 				Expect(user.Email).To(Equal(requestBody.Email))
 				Expect(user.Updated).To(BeTemporally(">", existingUser.Updated))
 			})
+
+			It("should return the new version as ETag", func() {
+				mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+					user.Version++
+					return nil
+				}
+
+				w := sendUpdate("")
+
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("ETag")).To(Equal(`"3"`))
+			})
+
+			DescribeTable("If-Match preconditions",
+				func(ifMatch string, expectedStatus int) {
+					w := sendUpdate(ifMatch)
+
+					Expect(w.Code).To(Equal(expectedStatus))
+					if expectedStatus == http.StatusPreconditionFailed {
+						var response map[string]string
+						json.Unmarshal(w.Body.Bytes(), &response)
+						Expect(response["error"]).To(Equal(entities.ErrPreconditionFailed.Error()))
+						Expect(mockRepo.UpdateCalls()).To(HaveLen(0))
+					}
+				},
+				Entry("current version", `"2"`, http.StatusOK),
+				Entry("any version", "*", http.StatusOK),
+				Entry("stale version", `"1"`, http.StatusPreconditionFailed),
+				Entry("weak tag", `W/"2"`, http.StatusPreconditionFailed),
+				Entry("malformed tag", "2", http.StatusPreconditionFailed),
+			)
+		})
+
+		Context("when another update wins the race", func() {
+			BeforeEach(func() {
+				mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+					user := *existingUser
+					return &user, nil
+				}
+				mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+					return entities.ErrVersionConflict
+				}
+			})
+
+			It("should return 409 Conflict", func() {
+				w := sendUpdate(`"2"`)
+
+				Expect(w.Code).To(Equal(http.StatusConflict))
+
+				var response map[string]string
+				json.Unmarshal(w.Body.Bytes(), &response)
+				Expect(response["error"]).To(Equal(entities.ErrVersionConflict.Error()))
+			})
 		})
 
 		Context("when user not found", func() {
@@ -327,7 +430,7 @@ var _ = Describe("UserHandler", func() {
 					}
 					return nil, entities.ErrUserNotFound
 				}
-				mockRepo.DeleteFunc = func(ctx context.Context, id int) error {
+				mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
 					return nil
 				}
 			})
@@ -372,6 +475,167 @@ var _ = Describe("UserHandler", func() {
 		})
 	})
 
+	Describe("RestoreUser", func() {
+		BeforeEach(func() {
+			deletedAt := time.Now()
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				switch id {
+				case 1:
+					return &entities.User{ID: 1, Name: "John", Version: 2, DeletedAt: &deletedAt}, nil
+				case 2:
+					return &entities.User{ID: 2, Name: "Jane", Version: 1}, nil
+				}
+				return nil, entities.ErrUserNotFound
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				user.Version++
+				return nil
+			}
+		})
+
+		It("should restore a deleted user and return 200", func() {
+			req := httptest.NewRequest("POST", "/users/1/restore", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("ETag")).To(Equal(`"3"`))
+
+			var response entities.User
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.DeletedAt).To(BeNil())
+		})
+
+		It("should return 409 Conflict for a user that is not deleted", func() {
+			req := httptest.NewRequest("POST", "/users/2/restore", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusConflict))
+		})
+
+		It("should return 404 Not Found for an unknown user", func() {
+			req := httptest.NewRequest("POST", "/users/999/restore", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("GetUserHistory", func() {
+		var historyRepo *mocks.HistoryRepositoryMock
+
+		BeforeEach(func() {
+			historyRepo = &mocks.HistoryRepositoryMock{
+				ListByUserFunc: func(ctx context.Context, userID, offset, limit int) ([]*entities.HistoryEntry, int, error) {
+					return []*entities.HistoryEntry{
+						{ID: 3, UserID: userID, Operation: entities.OperationUpdated},
+					}, 5, nil
+				},
+			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithHistory(historyRepo))
+			handler = httphandler.NewUserHandler(userUseCase)
+			router = chi.NewRouter()
+			router.Get("/users/{id}/history", handler.GetUserHistory)
+		})
+
+		It("should return a page of history with 200", func() {
+			req := httptest.NewRequest("GET", "/users/1/history?offset=2&limit=1", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.HistoryResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Total).To(Equal(5))
+			Expect(response.Offset).To(Equal(2))
+			Expect(response.Entries).To(HaveLen(1))
+			Expect(response.Entries[0].Operation).To(Equal(entities.OperationUpdated))
+
+			call := historyRepo.ListByUserCalls()[0]
+			Expect(call.UserID).To(Equal(1))
+			Expect(call.Offset).To(Equal(2))
+			Expect(call.Limit).To(Equal(1))
+		})
+
+		DescribeTable("invalid parameters return 400",
+			func(url string) {
+				req := httptest.NewRequest("GET", url, nil)
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			},
+			Entry("invalid ID", "/users/abc/history"),
+			Entry("invalid offset", "/users/1/history?offset=x"),
+			Entry("negative limit", "/users/1/history?limit=-1"),
+		)
+	})
+
+	Describe("SearchUsers", func() {
+		var searchIndex *mocks.UserSearchIndexMock
+
+		BeforeEach(func() {
+			searchIndex = &mocks.UserSearchIndexMock{
+				SearchFunc: func(ctx context.Context, query string, offset, limit int) ([]repository.SearchHit, int, error) {
+					return []repository.SearchHit{{UserID: 7, Score: 2}}, 4, nil
+				},
+			}
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: "John Doe", Email: "john@example.com"}, nil
+			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithSearch(searchIndex))
+			handler = httphandler.NewUserHandler(userUseCase)
+			router = chi.NewRouter()
+			router.Get("/users/search", handler.SearchUsers)
+		})
+
+		It("should return a page of ranked results with 200", func() {
+			req := httptest.NewRequest("GET", "/users/search?q=jon&offset=3&limit=1", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.SearchResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Total).To(Equal(4))
+			Expect(response.Offset).To(Equal(3))
+			Expect(response.Results).To(HaveLen(1))
+			Expect(response.Results[0].User.ID).To(Equal(7))
+			Expect(response.Results[0].Score).To(Equal(2.0))
+
+			call := searchIndex.SearchCalls()[0]
+			Expect(call.Query).To(Equal("jon"))
+			Expect(call.Offset).To(Equal(3))
+			Expect(call.Limit).To(Equal(1))
+		})
+
+		DescribeTable("invalid parameters return 400",
+			func(url string) {
+				req := httptest.NewRequest("GET", url, nil)
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(searchIndex.SearchCalls()).To(BeEmpty())
+			},
+			Entry("missing query", "/users/search"),
+			Entry("blank query", "/users/search?q=+"),
+			Entry("invalid offset", "/users/search?q=jon&offset=x"),
+			Entry("negative limit", "/users/search?q=jon&limit=-1"),
+		)
+	})
+
 	Describe("ListUsers", func() {
 		Context("when users exist", func() {
 			expectedUsers := []*entities.User{
@@ -380,12 +644,12 @@ var _ = Describe("UserHandler", func() {
 			}
 
 			BeforeEach(func() {
-				mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
-					return expectedUsers, nil
+				mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+					return &repository.UserPage{Users: expectedUsers, Next: "next-cursor"}, nil
 				}
 			})
 
-			It("should return users with 200", func() {
+			It("should return the page with 200", func() {
 				req := httptest.NewRequest("GET", "/users", nil)
 				w := httptest.NewRecorder()
 
@@ -393,19 +657,43 @@ var _ = Describe("UserHandler", func() {
 
 				Expect(w.Code).To(Equal(http.StatusOK))
 				
-				var users []*entities.User
-				err := json.Unmarshal(w.Body.Bytes(), &users)
+				var response httphandler.ListUsersResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
 				Expect(err).To(BeNil())
-				Expect(users).To(HaveLen(2))
-				Expect(users[0].Name).To(Equal("User 1"))
-				Expect(users[1].Name).To(Equal("User 2"))
+				Expect(response.Users).To(HaveLen(2))
+				Expect(response.Users[0].Name).To(Equal("User 1"))
+				Expect(response.Users[1].Name).To(Equal("User 2"))
+				Expect(response.Next).To(Equal("next-cursor"))
+				Expect(response.Prev).To(BeEmpty())
+			})
+
+			It("should pass the query parameters on", func() {
+				req := httptest.NewRequest("GET", "/users?limit=5&cursor=abc&sort=created&order=desc"+
+					"&name_prefix=Jo&email_domain=example.com&status=suspended&created_after=2024-01-01T00:00:00Z&updated_before=2024-02-01T00:00:00Z", nil)
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(mockRepo.ListPageCalls()).To(HaveLen(1))
+				opts := mockRepo.ListPageCalls()[0].Opts
+				Expect(opts.Limit).To(Equal(5))
+				Expect(opts.Cursor).To(Equal("abc"))
+				Expect(opts.SortBy).To(Equal(repository.SortByCreated))
+				Expect(opts.Descending).To(BeTrue())
+				Expect(opts.NamePrefix).To(Equal("Jo"))
+				Expect(opts.EmailDomain).To(Equal("example.com"))
+				Expect(opts.Status).To(Equal(entities.StatusSuspended))
+				Expect(opts.CreatedAfter).To(Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+				Expect(opts.CreatedBefore.IsZero()).To(BeTrue())
+				Expect(opts.UpdatedBefore).To(Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
 			})
 		})
 
 		Context("when no users exist", func() {
 			BeforeEach(func() {
-				mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
-					return []*entities.User{}, nil
+				mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+					return &repository.UserPage{Users: []*entities.User{}}, nil
 				}
 			})
 
@@ -417,16 +705,53 @@ var _ = Describe("UserHandler", func() {
 
 				Expect(w.Code).To(Equal(http.StatusOK))
 				
-				var users []*entities.User
-				err := json.Unmarshal(w.Body.Bytes(), &users)
+				var response map[string]json.RawMessage
+				err := json.Unmarshal(w.Body.Bytes(), &response)
 				Expect(err).To(BeNil())
-				Expect(users).To(BeEmpty())
+				Expect(string(response["users"])).To(Equal("[]"))
+				Expect(response).NotTo(HaveKey("next"))
+				Expect(response).NotTo(HaveKey("prev"))
+			})
+		})
+
+		Context("when query parameters are invalid", func() {
+			DescribeTable("should return 400 Bad Request",
+				func(query string, code entities.ErrorCode, field string) {
+					req := httptest.NewRequest("GET", "/users?"+query, nil)
+					w := httptest.NewRecorder()
+
+					router.ServeHTTP(w, req)
+
+					Expect(w.Code).To(Equal(http.StatusBadRequest))
+					
+					var response httphandler.ErrorResponse
+					Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+					Expect(response.Code).To(Equal(string(code)))
+					Expect(response.Field).To(Equal(field))
+				},
+				Entry("limit", "limit=many", entities.CodeInvalidPagination, "limit"),
+				Entry("negative limit", "limit=-1", entities.CodeInvalidPagination, ""),
+				Entry("order", "order=sideways", entities.CodeInvalidParameter, "order"),
+				Entry("sort field", "sort=password", entities.CodeInvalidSortField, ""),
+				Entry("time range", "created_after=yesterday", entities.CodeInvalidParameter, "created_after"),
+			)
+
+			It("should return 400 for a cursor the repository rejects", func() {
+				mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+					return nil, entities.ErrInvalidCursor
+				}
+				req := httptest.NewRequest("GET", "/users?cursor=bogus", nil)
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
 			})
 		})
 
 		Context("when repository fails", func() {
 			BeforeEach(func() {
-				mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
+				mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
 					return nil, fmt.Errorf("database error")
 				}
 			})
@@ -445,4 +770,775 @@ var _ = Describe("UserHandler", func() {
 			})
 		})
 	})
-})
\ No newline at end of file
+
+	Describe("ExportUsers", func() {
+		BeforeEach(func() {
+			router.Get("/users/export", handler.ExportUsers)
+			mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+				return &repository.UserPage{Users: []*entities.User{
+					{ID: 1, Name: "John Doe", Email: "john@example.com"},
+					{ID: 2, Name: "Jane Doe", Email: "jane@example.com"},
+				}}, nil
+			}
+		})
+
+		It("should stream JSON Lines by default", func() {
+			req := httptest.NewRequest("GET", "/users/export", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+			Expect(lines).To(HaveLen(2))
+			var user entities.User
+			Expect(json.Unmarshal([]byte(lines[1]), &user)).To(Succeed())
+			Expect(user.Email).To(Equal("jane@example.com"))
+		})
+
+		It("should stream CSV", func() {
+			req := httptest.NewRequest("GET", "/users/export?format=csv&include_deleted=true", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(Equal("text/csv; charset=utf-8"))
+			Expect(w.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="users.csv"`))
+			Expect(w.Body.String()).To(HavePrefix("id,name,email,created,updated,deleted_at\n1,John Doe,john@example.com,"))
+			Expect(repository.IncludesDeleted(mockRepo.ListPageCalls()[0].Ctx)).To(BeTrue())
+		})
+
+		It("should return 400 for an unknown format", func() {
+			req := httptest.NewRequest("GET", "/users/export?format=xml", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			var response httphandler.ErrorResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Code).To(Equal(string(entities.CodeInvalidParameter)))
+			Expect(response.Field).To(Equal("format"))
+			Expect(mockRepo.ListPageCalls()).To(BeEmpty())
+		})
+
+		It("should return 500 when the repository fails before anything is sent", func() {
+			mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+				return nil, fmt.Errorf("database error")
+			}
+			req := httptest.NewRequest("GET", "/users/export", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+		})
+	})
+
+	Describe("ImportUsers", func() {
+		BeforeEach(func() {
+			router.Post("/users/import", handler.ImportUsers)
+			mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+				if email == "jane@example.com" {
+					return &entities.User{ID: 9, Email: email}, nil
+				}
+				return nil, entities.ErrUserNotFound
+			}
+			mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+				user.ID = 5
+				return nil
+			}
+		})
+
+		It("should import the rows and report each of them", func() {
+			body := "name,email\nJohn Doe,john@example.com\nJane Doe,jane@example.com\n,nobody@example.com\n"
+			req := httptest.NewRequest("POST", "/users/import?format=csv", strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.ImportResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.DryRun).To(BeFalse())
+			Expect(response.Created).To(Equal(1))
+			Expect(response.Duplicates).To(Equal(1))
+			Expect(response.Invalid).To(Equal(1))
+			Expect(response.Rows).To(Equal([]httphandler.ImportRow{
+				{Line: 2, Status: use_cases.ImportCreated, ID: 5, Email: "john@example.com"},
+				{Line: 3, Status: use_cases.ImportDuplicate, Email: "jane@example.com", Error: entities.ErrUserAlreadyExists.Error()},
+				{Line: 4, Status: use_cases.ImportInvalid, Email: "nobody@example.com", Error: "user is invalid: user name is required"},
+			}))
+			Expect(mockRepo.CreateCalls()).To(HaveLen(1))
+		})
+
+		It("should create nothing on a dry run", func() {
+			body := `{"name":"John Doe","email":"john@example.com"}` + "\n"
+			req := httptest.NewRequest("POST", "/users/import?dry_run=true", strings.NewReader(body))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.ImportResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.DryRun).To(BeTrue())
+			Expect(response.Created).To(Equal(1))
+			Expect(mockRepo.CreateCalls()).To(BeEmpty())
+		})
+
+		DescribeTable("invalid requests return 400",
+			func(url, body string, expected httphandler.ErrorResponse) {
+				req := httptest.NewRequest("POST", url, strings.NewReader(body))
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				var response httphandler.ErrorResponse
+				Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+				Expect(response).To(Equal(expected))
+				Expect(mockRepo.CreateCalls()).To(BeEmpty())
+			},
+			Entry("unknown format", "/users/import?format=xml", "", httphandler.ErrorResponse{
+				Error: "invalid query parameter: unknown format",
+				Code:  string(entities.CodeInvalidParameter),
+				Field: "format",
+			}),
+			Entry("invalid dry run", "/users/import?dry_run=maybe", "", httphandler.ErrorResponse{
+				Error: "invalid query parameter",
+				Code:  string(entities.CodeInvalidParameter),
+				Field: "dry_run",
+			}),
+			Entry("missing CSV column", "/users/import?format=csv", "name\nJohn Doe\n", httphandler.ErrorResponse{
+				Error: `invalid record: invalid CSV header: missing column "email"`,
+				Code:  string(entities.CodeInvalidRecord),
+			}),
+		)
+	})
+	Describe("Backups", func() {
+		var (
+			snapshotter *mocks.UserSnapshotterMock
+			backups     *mocks.BackupStoreMock
+			taken       time.Time
+		)
+
+		BeforeEach(func() {
+			taken = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			snapshotter = &mocks.UserSnapshotterMock{
+				SnapshotUsersFunc: func(ctx context.Context) (*repository.UserSnapshot, error) {
+					return &repository.UserSnapshot{Taken: taken, NextID: 1}, nil
+				},
+				RestoreUsersFunc: func(ctx context.Context, snapshot *repository.UserSnapshot) error {
+					return nil
+				},
+			}
+			backups = &mocks.BackupStoreMock{
+				SaveFunc: func(ctx context.Context, snapshot *repository.UserSnapshot) (*repository.BackupInfo, error) {
+					return &repository.BackupInfo{Name: "users-1.backup", Taken: snapshot.Taken, Size: 120}, nil
+				},
+				ListFunc: func(ctx context.Context) ([]*repository.BackupInfo, error) {
+					return []*repository.BackupInfo{
+						{Name: "users-2.backup", Taken: taken, Users: 3, Size: 300},
+						{Name: "users-1.backup", Taken: taken.Add(-time.Hour), Users: 2, Size: 200},
+					}, nil
+				},
+				LoadFunc: func(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+					return &repository.UserSnapshot{
+						Taken:  taken,
+						NextID: 8,
+						Users:  []*entities.User{{ID: 7, Name: "John Doe", Email: "john@example.com", Version: 1}},
+					}, nil
+				},
+			}
+			repo := struct {
+				*mocks.UserRepositoryMock
+				*mocks.UserSnapshotterMock
+			}{mockRepo, snapshotter}
+			userUseCase = use_cases.NewUserUseCase(repo, use_cases.WithBackups(backups))
+			handler = httphandler.NewUserHandler(userUseCase)
+			router = chi.NewRouter()
+			router.Post("/admin/backups", handler.CreateBackup)
+			router.Get("/admin/backups", handler.ListBackups)
+			router.Post("/admin/backups/{name}/restore", handler.RestoreBackup)
+		})
+
+		It("should create a backup with 201", func() {
+			req := httptest.NewRequest("POST", "/admin/backups", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusCreated))
+
+			var response httphandler.BackupResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Name).To(Equal("users-1.backup"))
+			Expect(response.Taken).To(Equal(taken))
+			Expect(response.Size).To(Equal(int64(120)))
+		})
+
+		It("should list the backups with 200", func() {
+			req := httptest.NewRequest("GET", "/admin/backups", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.ListBackupsResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Backups).To(HaveLen(2))
+			Expect(response.Backups[0].Name).To(Equal("users-2.backup"))
+			Expect(response.Backups[1].Users).To(Equal(2))
+		})
+
+		It("should restore a backup with 200", func() {
+			req := httptest.NewRequest("POST", "/admin/backups/users-1.backup/restore", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.RestoreResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response).To(Equal(httphandler.RestoreResponse{Name: "users-1.backup", Taken: taken, Users: 1, NextID: 8}))
+			Expect(backups.LoadCalls()[0].Name).To(Equal("users-1.backup"))
+			Expect(snapshotter.RestoreUsersCalls()).To(HaveLen(1))
+		})
+
+		DescribeTable("restore errors",
+			func(err error, code int) {
+				backups.LoadFunc = func(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+					return nil, err
+				}
+				req := httptest.NewRequest("POST", "/admin/backups/users-1.backup/restore", nil)
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				Expect(w.Code).To(Equal(code))
+				Expect(snapshotter.RestoreUsersCalls()).To(BeEmpty())
+			},
+			Entry("missing backup returns 404", entities.ErrBackupNotFound, http.StatusNotFound),
+			Entry("invalid backup returns 422", fmt.Errorf("%w: checksum mismatch", entities.ErrInvalidBackup), http.StatusUnprocessableEntity),
+			Entry("other errors return 500", fmt.Errorf("disk error"), http.StatusInternalServerError),
+		)
+
+		It("should return 501 when backups are unsupported", func() {
+			handler = httphandler.NewUserHandler(use_cases.NewUserUseCase(mockRepo))
+			router = chi.NewRouter()
+			router.Post("/admin/backups", handler.CreateBackup)
+
+			req := httptest.NewRequest("POST", "/admin/backups", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusNotImplemented))
+		})
+	})
+
+	Describe("Retention", func() {
+		var (
+			notifier *mocks.RetentionNotifierMock
+			updated  time.Time
+		)
+
+		BeforeEach(func() {
+			updated = time.Now().AddDate(-2, 0, 0).UTC()
+			notifier = &mocks.RetentionNotifierMock{
+				WarnInactiveFunc: func(ctx context.Context, user *entities.User, deleteAfter time.Time) error {
+					return nil
+				},
+			}
+			mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+				return &repository.UserPage{Users: []*entities.User{
+					{ID: 1, Email: "john@example.com", Updated: updated, Version: 1},
+					{ID: 2, Email: "jane@example.com", Updated: updated, LegalHold: true, Version: 1},
+				}}, nil
+			}
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: "John Doe", Email: "john@example.com", Updated: updated, Version: 1}, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				return nil
+			}
+			policy := use_cases.RetentionPolicy{Months: 12, Warning: 24 * time.Hour}
+			handler = httphandler.NewUserHandler(use_cases.NewUserUseCase(mockRepo, use_cases.WithRetention(policy, notifier)))
+			router = chi.NewRouter()
+			router.Get("/admin/retention", handler.RetentionReport)
+			router.Post("/admin/retention", handler.EnforceRetention)
+			router.Put("/users/{id}/legal-hold", handler.PlaceLegalHold)
+			router.Delete("/users/{id}/legal-hold", handler.ReleaseLegalHold)
+		})
+
+		It("should report a dry run with 200", func() {
+			req := httptest.NewRequest("GET", "/admin/retention", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.RetentionResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.DryRun).To(BeTrue())
+			Expect(response.Warned).To(Equal(1))
+			Expect(response.Held).To(Equal(1))
+			Expect(response.Users).To(HaveLen(2))
+			Expect(response.Users[0].Status).To(Equal(use_cases.RetentionWarned))
+			Expect(response.Users[0].DeleteAfter).NotTo(BeNil())
+			Expect(response.Users[1].DeleteAfter).To(BeNil())
+			Expect(notifier.WarnInactiveCalls()).To(BeEmpty())
+		})
+
+		It("should enforce the policy with 200", func() {
+			req := httptest.NewRequest("POST", "/admin/retention", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var response httphandler.RetentionResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.DryRun).To(BeFalse())
+			Expect(response.Warned).To(Equal(1))
+			Expect(notifier.WarnInactiveCalls()).To(HaveLen(1))
+		})
+
+		It("should return 501 without a retention policy", func() {
+			handler = httphandler.NewUserHandler(use_cases.NewUserUseCase(mockRepo))
+			router = chi.NewRouter()
+			router.Get("/admin/retention", handler.RetentionReport)
+
+			req := httptest.NewRequest("GET", "/admin/retention", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusNotImplemented))
+		})
+
+		It("should place and release a legal hold with 200", func() {
+			req := httptest.NewRequest("PUT", "/users/1/legal-hold", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var user entities.User
+			Expect(json.Unmarshal(w.Body.Bytes(), &user)).To(Succeed())
+			Expect(user.LegalHold).To(BeTrue())
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: "John Doe", LegalHold: true, Version: 2}, nil
+			}
+			req = httptest.NewRequest("DELETE", "/users/1/legal-hold", nil)
+			w = httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var released entities.User
+			Expect(json.Unmarshal(w.Body.Bytes(), &released)).To(Succeed())
+			Expect(released.LegalHold).To(BeFalse())
+		})
+
+		It("should return 404 for a legal hold on a missing user", func() {
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return nil, entities.ErrUserNotFound
+			}
+			req := httptest.NewRequest("PUT", "/users/9/legal-hold", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("Status changes", func() {
+		var status entities.Status
+
+		BeforeEach(func() {
+			status = entities.StatusActive
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: "John Doe", Email: "john@example.com", Status: status, Version: 1}, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				user.Version++
+				return nil
+			}
+		})
+
+		changeStatus := func(action, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/users/1/"+action, strings.NewReader(body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should suspend, activate and deactivate a user with 200", func() {
+			for _, step := range []struct {
+				action string
+				to     entities.Status
+			}{
+				{"suspend", entities.StatusSuspended},
+				{"activate", entities.StatusActive},
+				{"deactivate", entities.StatusDeactivated},
+			} {
+				w := changeStatus(step.action, `{"reason": "support ticket 42"}`)
+
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Header().Get("ETag")).To(Equal(`"2"`))
+				var user entities.User
+				Expect(json.Unmarshal(w.Body.Bytes(), &user)).To(Succeed())
+				Expect(user.Status).To(Equal(step.to))
+				Expect(user.StatusReason).To(Equal("support ticket 42"))
+				status = step.to
+			}
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(3))
+		})
+
+		It("should return 409 for a transition the status table doesn't allow", func() {
+			status = entities.StatusDeactivated
+
+			w := changeStatus("suspend", `{"reason": "chargeback"}`)
+
+			Expect(w.Code).To(Equal(http.StatusConflict))
+			Expect(w.Body.String()).To(MatchJSON(`{
+				"error": "user status transition is not allowed",
+				"code": "invalid_status_transition",
+				"details": {"from": "deactivated", "to": "suspended"}
+			}`))
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+		})
+
+		It("should return 422 without a reason", func() {
+			w := changeStatus("suspend", "")
+
+			Expect(w.Code).To(Equal(http.StatusUnprocessableEntity))
+			var response httphandler.ErrorResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Violations).To(Equal([]httphandler.ViolationResponse{
+				{Field: "reason", Code: "status_reason_required", Error: "status change reason is required"},
+			}))
+		})
+
+		It("should return 400 for a malformed body", func() {
+			w := changeStatus("suspend", `{"reason":`)
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should return 400 for an unknown status filter", func() {
+			req := httptest.NewRequest("GET", "/users?status=banned", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(w.Body.String()).To(ContainSubstring(`"code":"invalid_status"`))
+		})
+	})
+
+	Describe("Roles", func() {
+		BeforeEach(func() {
+			users := map[string]*entities.User{
+				"ada@example.com": {ID: 1, Email: "ada@example.com", Status: entities.StatusActive, Roles: []string{entities.RoleAdmin}},
+				"sam@example.com": {ID: 2, Email: "sam@example.com", Status: entities.StatusActive, Roles: []string{entities.RoleSupport}},
+			}
+			mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+				user, ok := users[email]
+				if !ok {
+					return nil, entities.ErrUserNotFound
+				}
+				copied := *user
+				return &copied, nil
+			}
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: "John Doe", Email: "john@example.com", Version: 1}, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				user.Version++
+				return nil
+			}
+			handler = httphandler.NewUserHandler(use_cases.NewUserUseCase(mockRepo, use_cases.WithAccessControl()))
+
+			router = chi.NewRouter()
+			router.Use(httphandler.RequestContext)
+			router.Get("/roles", handler.ListRoles)
+			router.Get("/users/export", handler.ExportUsers)
+			router.Delete("/users/{id}", handler.DeleteUser)
+			router.Put("/users/{id}/roles/{role}", handler.AssignRole)
+			router.Delete("/users/{id}/roles/{role}", handler.RevokeRole)
+		})
+
+		serve := func(method, path, principal string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			req.Header.Set(httphandler.PrincipalHeader, principal)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should list the roles", func() {
+			w := serve("GET", "/roles", "sam@example.com")
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			var roles []entities.Role
+			Expect(json.Unmarshal(w.Body.Bytes(), &roles)).To(Succeed())
+			Expect(roles).To(HaveLen(3))
+			Expect(roles[2].Name).To(Equal(entities.RoleSupport))
+			Expect(roles[2].Permissions).To(Equal([]entities.Permission{
+				entities.PermissionReadUsers,
+				entities.PermissionSuspendUsers,
+			}))
+		})
+
+		It("should assign and revoke a role with 200", func() {
+			w := serve("PUT", "/users/3/roles/support", "ada@example.com")
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("ETag")).To(Equal(`"2"`))
+			var user entities.User
+			Expect(json.Unmarshal(w.Body.Bytes(), &user)).To(Succeed())
+			Expect(user.Roles).To(Equal([]string{entities.RoleSupport}))
+
+			w = serve("DELETE", "/users/3/roles/support", "ada@example.com")
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+		})
+
+		It("should return 404 for an unknown role", func() {
+			w := serve("PUT", "/users/3/roles/root", "ada@example.com")
+
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "unknown role", "code": "unknown_role", "details": {"role": "root"}}`))
+		})
+
+		It("should return 403 when the principal lacks the permission", func() {
+			w := serve("DELETE", "/users/3", "sam@example.com")
+
+			Expect(w.Code).To(Equal(http.StatusForbidden))
+			Expect(w.Body.String()).To(MatchJSON(`{
+				"error": "principal is not allowed to do this",
+				"code": "forbidden",
+				"details": {"permission": "users:delete"}
+			}`))
+			Expect(mockRepo.DeleteCalls()).To(BeEmpty())
+		})
+
+		It("should return 403 for an export the principal isn't allowed", func() {
+			w := serve("GET", "/users/export", "stranger@example.com")
+
+			Expect(w.Code).To(Equal(http.StatusForbidden))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(w.Header().Get("Content-Disposition")).To(BeEmpty())
+			Expect(w.Body.String()).To(MatchJSON(`{
+				"error": "principal is not allowed to do this",
+				"code": "forbidden",
+				"details": {"permission": "users:read"}
+			}`))
+		})
+
+		It("should return 403 for roles assigned by a principal that can't manage them", func() {
+			w := serve("PUT", "/users/2/roles/admin", "sam@example.com")
+
+			Expect(w.Code).To(Equal(http.StatusForbidden))
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+		})
+	})
+
+	Describe("Errors", func() {
+		It("should map wrapped domain errors to their status and code", func() {
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return nil, fmt.Errorf("reading user %d: %w", id, entities.ErrUserNotFound)
+			}
+			req := httptest.NewRequest("GET", "/users/7", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "reading user 7: user not found", "code": "user_not_found"}`))
+		})
+
+		It("should describe the field and details of a domain error", func() {
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return nil, entities.ErrUserAlreadyExists.WithDetail("existing_id", 3)
+			}
+			req := httptest.NewRequest("GET", "/users/7", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusConflict))
+			var response httphandler.ErrorResponse
+			Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Code).To(Equal("user_already_exists"))
+			Expect(response.Field).To(Equal("email"))
+			Expect(response.Details).To(Equal(map[string]any{"existing_id": float64(3)}))
+		})
+
+		It("should not describe internal errors", func() {
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return nil, fmt.Errorf("connection to 10.0.0.1 refused")
+			}
+			req := httptest.NewRequest("GET", "/users/7", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "failed to get user"}`))
+		})
+
+		It("should report a missing If-Match version as a failed precondition", func() {
+			req := httptest.NewRequest("PUT", "/users/7", strings.NewReader(`{"name": "John"}`))
+			req.Header.Set("If-Match", `"nope"`)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusPreconditionFailed))
+			Expect(w.Body.String()).To(MatchJSON(`{"error": "user version does not match", "code": "precondition_failed"}`))
+		})
+	})
+
+	Describe("WatchUsers", func() {
+		var (
+			watcher *mocks.UserWatcherMock
+			stream  *sliceStream
+			at      time.Time
+		)
+
+		BeforeEach(func() {
+			at = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			stream = &sliceStream{
+				changes: []*repository.UserChange{
+					{Seq: 11, Operation: repository.ChangeCreated, User: &entities.User{ID: 1, Name: "John Doe", Version: 1}, Time: at},
+					{Seq: 12, Operation: repository.ChangeDeleted, User: &entities.User{ID: 1, Name: "John Doe", Version: 2}, Time: at},
+				},
+				err: context.Canceled,
+			}
+			watcher = &mocks.UserWatcherMock{
+				WatchFunc: func(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+					return stream, nil
+				},
+			}
+			repo := struct {
+				*mocks.UserRepositoryMock
+				*mocks.UserWatcherMock
+			}{mockRepo, watcher}
+			userUseCase = use_cases.NewUserUseCase(repo)
+			handler = httphandler.NewUserHandler(userUseCase)
+			router = chi.NewRouter()
+			router.Get("/users/changes", handler.WatchUsers)
+		})
+
+		// watch requests the change stream. A stream ending with
+		// context.Canceled ends as if the client went away.
+		watch := func(url string) *httptest.ResponseRecorder {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if stream.err == context.Canceled {
+				stream.end = cancel
+			}
+			req := httptest.NewRequest("GET", url, nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should stream the changes as newline delimited JSON", func() {
+			w := watch("/users/changes?from=10")
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+			Expect(w.Flushed).To(BeTrue())
+			Expect(watcher.WatchCalls()[0].FromSeq).To(Equal(int64(10)))
+
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+			Expect(lines).To(HaveLen(2))
+			var change httphandler.ChangeResponse
+			Expect(json.Unmarshal([]byte(lines[1]), &change)).To(Succeed())
+			Expect(change.Seq).To(Equal(int64(12)))
+			Expect(change.Operation).To(Equal(repository.ChangeDeleted))
+			Expect(change.User.Version).To(Equal(2))
+			Expect(change.Time).To(Equal(at))
+		})
+
+		It("should stream from the latest change without from", func() {
+			watch("/users/changes")
+
+			Expect(watcher.WatchCalls()[0].FromSeq).To(Equal(repository.WatchFromLatest))
+		})
+
+		It("should end the stream with the error of a watcher falling behind", func() {
+			stream.err = entities.ErrWatchTooFarBehind
+
+			w := watch("/users/changes?from=10")
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[2]).To(MatchJSON(`{"error": "watcher is too far behind the change stream", "code": "watch_too_far_behind"}`))
+		})
+
+		DescribeTable("should map watch errors to statuses",
+			func(err error, status int) {
+				watcher.WatchFunc = func(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+					return nil, err
+				}
+
+				w := watch("/users/changes?from=10")
+
+				Expect(w.Code).To(Equal(status))
+			},
+			Entry("too far behind", entities.ErrWatchTooFarBehind, http.StatusGone),
+			Entry("a sequence number not issued", entities.ErrInvalidSequence, http.StatusBadRequest),
+			Entry("unsupported", entities.ErrWatchUnsupported, http.StatusNotImplemented),
+		)
+
+		DescribeTable("should reject an invalid from with 400",
+			func(from string) {
+				w := watch("/users/changes?from=" + from)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(watcher.WatchCalls()).To(BeEmpty())
+			},
+			Entry("not a number", "latest"),
+			Entry("negative", "-1"),
+		)
+	})
+})
+
+// sliceStream is a UserChangeStream returning changes, then err. It calls
+// end, if set, before returning err.
+type sliceStream struct {
+	changes []*repository.UserChange
+	err     error
+	end     func()
+}
+
+func (s *sliceStream) Next() (*repository.UserChange, error) {
+	if len(s.changes) == 0 {
+		if s.end != nil {
+			s.end()
+		}
+		return nil, s.err
+	}
+	change := s.changes[0]
+	s.changes = s.changes[1:]
+	return change, nil
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// ChangeResponse represents a change of a user, one per line of the change
+// stream
+type ChangeResponse struct {
+	Seq       int64          `json:"seq"`
+	Operation string         `json:"operation"`
+	User      *entities.User `json:"user"`
+	Time      time.Time      `json:"time"`
+}
+
+// WatchUsers handles GET /users/changes, streaming the user changes
+// committed after the from query parameter as newline delimited JSON until
+// the client goes away. Without from, only the changes committed from now
+// on are streamed. A client resumes by passing the seq of the last change
+// it received.
+//
+// A stream that falls too far behind ends with a line holding the error;
+// the client has to reload the users and watch again from the latest
+// change.
+func (h *UserHandler) WatchUsers(w http.ResponseWriter, r *http.Request) {
+	fromSeq := repository.WatchFromLatest
+	if from := r.URL.Query().Get("from"); from != "" {
+		seq, err := strconv.ParseInt(from, 10, 64)
+		if err != nil || seq < 0 {
+			h.writeError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+		fromSeq = seq
+	}
+
+	stream, err := h.userUseCase.WatchUsers(r.Context(), fromSeq)
+	if err != nil {
+		h.writeUseCaseError(w, err, "failed to watch users")
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	controller.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		change, err := stream.Next()
+		if err != nil {
+			if r.Context().Err() == nil {
+				log.Printf("Watching users ended: %v", err)
+				_, response := errorResponse(err, "failed to watch users")
+				encoder.Encode(response)
+			}
+			return
+		}
+		response := ChangeResponse{
+			Seq:       change.Seq,
+			Operation: change.Operation,
+			User:      change.User,
+			Time:      change.Time,
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
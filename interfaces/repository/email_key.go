@@ -0,0 +1,18 @@
+package repository
+
+// EmailKeyFunc returns the key a user repository keeps an email under,
+// enforcing uniqueness and answering GetByEmail with it. Repositories use
+// entities.NormalizeEmail unless told otherwise.
+type EmailKeyFunc func(email string) string
+
+// EmailKeyer is implemented by user repositories whose email keys can be
+// supplied by a repository wrapping them, for emails it stores in a form of
+// its own, such as encrypted
+type EmailKeyer interface {
+	// KeyEmailsWith makes the repository key emails with key from now on.
+	// Repositories indexing users in memory re-key the ones they hold,
+	// while keys stored along with users are kept, so key must give plain
+	// addresses their canonical form. It must be called before the
+	// repository is shared.
+	KeyEmailsWith(key EmailKeyFunc)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"agent-orchestration/entities"
+)
+
+// HistoryRepository stores the change history of users. Entries are never
+// removed, so the history of a user outlives the user itself.
+type HistoryRepository interface {
+	// Append stores a new entry, assigning its ID
+	Append(ctx context.Context, entry *entities.HistoryEntry) error
+
+	// ListByUser returns up to limit entries of a user, oldest first,
+	// skipping the first offset entries, along with the total number of
+	// entries the user has. Only entries of the tenant of ctx are listed.
+	ListByUser(ctx context.Context, userID, offset, limit int) ([]*entities.HistoryEntry, int, error)
+}
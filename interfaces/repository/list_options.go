@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// SortField is a user field ListPage can sort by
+type SortField string
+
+// Sort fields supported by ListPage
+const (
+	SortByID      SortField = "id"
+	SortByName    SortField = "name"
+	SortByEmail   SortField = "email"
+	SortByCreated SortField = "created"
+	SortByUpdated SortField = "updated"
+)
+
+// Valid reports whether f is a supported sort field
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByID, SortByName, SortByEmail, SortByCreated, SortByUpdated:
+		return true
+	}
+	return false
+}
+
+// ListOptions selects a page of users. The zero value of every filter
+// disables it.
+type ListOptions struct {
+	// Limit is the maximum number of users on the page and must be positive
+	Limit int
+
+	// Cursor continues from the Next or Prev cursor of an earlier page. It
+	// must be used with the same sort order that produced it.
+	Cursor string
+
+	// SortBy orders the users, ties are broken by ID. Defaults to SortByID.
+	SortBy     SortField
+	Descending bool
+
+	// NamePrefix keeps users whose name starts with it, case-sensitively
+	NamePrefix string
+
+	// EmailDomain keeps users whose email address is in the domain. It is
+	// compared in canonical form, see entities.NormalizeEmail.
+	EmailDomain string
+
+	// Status keeps users at the status, see entities.User.CurrentStatus
+	Status entities.Status
+
+	// The ranges include their After bound and exclude their Before bound
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+}
+
+// UserPage is a page of users returned by ListPage
+type UserPage struct {
+	Users []*entities.User
+
+	// Next and Prev are cursors for the following and preceding pages. They
+	// are empty when there is no such page.
+	Next string
+	Prev string
+}
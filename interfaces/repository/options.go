@@ -0,0 +1,18 @@
+package repository
+
+import "context"
+
+// includeDeletedKey marks a context whose reads should see soft-deleted users
+type includeDeletedKey struct{}
+
+// IncludeDeleted returns a context in which GetByID, GetByEmail and List
+// also return soft-deleted users
+func IncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+// IncludesDeleted reports whether reads with ctx should return soft-deleted users
+func IncludesDeleted(ctx context.Context) bool {
+	include, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return include
+}
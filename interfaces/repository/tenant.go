@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"agent-orchestration/entities"
+)
+
+// tenantKey binds the tenant repository calls act in to a context
+type tenantKey struct{}
+
+// WithTenant returns a context in which repository calls act in tenant:
+// they only reach its users, and create users in it
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant calls with ctx act in, or
+// entities.DefaultTenant when it names none
+func Tenant(ctx context.Context) string {
+	if tenant, _ := ctx.Value(tenantKey{}).(string); tenant != "" {
+		return tenant
+	}
+	return entities.DefaultTenant
+}
+
+// includeAllTenantsKey marks a context whose calls reach every tenant
+type includeAllTenantsKey struct{}
+
+// IncludeAllTenants returns a context in which calls reach the users of
+// every tenant, for jobs that work on the whole store. Emails are only
+// unique within a tenant, so GetByEmail and Create still act in the tenant
+// of ctx.
+func IncludeAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeAllTenantsKey{}, true)
+}
+
+// IncludesAllTenants reports whether calls with ctx reach every tenant
+func IncludesAllTenants(ctx context.Context) bool {
+	include, _ := ctx.Value(includeAllTenantsKey{}).(bool)
+	return include
+}
+
+// InTenant reports whether calls with ctx reach the data of tenant
+func InTenant(ctx context.Context, tenant string) bool {
+	return IncludesAllTenants(ctx) || tenant == Tenant(ctx)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import "context"
+
+// TransactionManager runs several repository calls as one atomic unit
+type TransactionManager interface {
+	// WithinTransaction calls fn with a context bound to a new transaction.
+	// Repository calls made with that context take part in the transaction,
+	// which commits when fn returns nil and rolls back when fn returns an
+	// error or panics. A panic is re-raised after the rollback. Calling
+	// WithinTransaction with a context that is already bound to a
+	// transaction joins it instead of starting a new one.
+	//
+	// The context must not be used after fn returns or from several
+	// goroutines at once.
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// commitHooksKey binds the CommitHooks of a transaction to a context
+type commitHooksKey struct{}
+
+// CommitHooks collects the functions registered with AfterCommit while a
+// transaction runs
+type CommitHooks struct {
+	hooks []func()
+}
+
+// WithCommitHooks returns a context that collects AfterCommit functions in
+// the returned CommitHooks. TransactionManager implementations call it when
+// they start a transaction and call Run once it has committed.
+func WithCommitHooks(ctx context.Context) (context.Context, *CommitHooks) {
+	hooks := &CommitHooks{}
+	return context.WithValue(ctx, commitHooksKey{}, hooks), hooks
+}
+
+// Run calls the collected functions in the order they were registered
+func (h *CommitHooks) Run() {
+	for _, hook := range h.hooks {
+		hook()
+	}
+	h.hooks = nil
+}
+
+// InTransaction reports whether ctx is bound to a transaction. Reads made
+// with such a context may see uncommitted changes.
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(commitHooksKey{}).(*CommitHooks)
+	return ok
+}
+
+// AfterCommit calls fn once the transaction ctx is bound to has committed,
+// or right away when ctx is not bound to a transaction. fn is dropped when
+// the transaction rolls back. Use it for side effects outside the store,
+// such as updating caches or indexes, that must only see committed changes.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(commitHooksKey{}).(*CommitHooks); ok {
+		hooks.hooks = append(hooks.hooks, fn)
+		return
+	}
+	fn()
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// UserSnapshot is the complete state of a user store at one point in time
+type UserSnapshot struct {
+	Taken time.Time
+
+	// NextID is the ID the store assigns to the next user it creates
+	NextID int
+
+	// Users holds every user, soft-deleted ones included, ordered by ID
+	Users []*entities.User
+}
+
+// UserSnapshotter is implemented by user repositories that can copy and
+// replace their whole state at once
+type UserSnapshotter interface {
+	// SnapshotUsers returns a consistent copy of the store, taken while no
+	// write is in progress
+	SnapshotUsers(ctx context.Context) (*UserSnapshot, error)
+
+	// RestoreUsers replaces every user in the store, and its ID sequence,
+	// with snapshot. It must not be called inside a transaction.
+	RestoreUsers(ctx context.Context, snapshot *UserSnapshot) error
+}
+
+// BackupInfo describes a stored backup
+type BackupInfo struct {
+	Name  string
+	Taken time.Time
+	Users int
+	Size  int64
+}
+
+// BackupStore keeps snapshots of the user store
+type BackupStore interface {
+	// Save stores snapshot as a new backup, then drops the backups the
+	// retention policy no longer keeps
+	Save(ctx context.Context, snapshot *UserSnapshot) (*BackupInfo, error)
+
+	// List returns the stored backups, newest first
+	List(ctx context.Context) ([]*BackupInfo, error)
+
+	// Load reads the backup called name, checking its format version and
+	// checksum. It returns entities.ErrBackupNotFound if there is no such
+	// backup and an error wrapping entities.ErrInvalidBackup if it can't be
+	// trusted.
+	Load(ctx context.Context, name string) (*UserSnapshot, error)
+}
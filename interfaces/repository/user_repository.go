@@ -6,9 +6,23 @@ import (
 	"agent-orchestration/entities"
 )
 
-// UserRepository defines the interface for user data operations
+// UserRepository defines the interface for user data operations.
+//
+// Emails are unique within a tenant and looked up by their canonical form,
+// User.EmailKey, or the key a wrapping repository supplies through
+// EmailKeyer, while the address is stored as typed.
+//
+// Every call acts in the tenant of its context, see WithTenant. Users of
+// other tenants are never returned, updated or deleted; to the caller they
+// don't exist. Create stores the user in the tenant of the context and
+// writes it back to user.TenantID, and Update keeps the stored tenant.
+//
+// Soft-deleted users are stored like any other user, with DeletedAt set
+// through Update. GetByID, GetByEmail and List hide them unless the context
+// comes from IncludeDeleted. Their emails stay reserved until Delete
+// removes them for good.
 type UserRepository interface {
-	// Create creates a new user
+	// Create creates a new user, assigning its ID and setting its Version to 1
 	Create(ctx context.Context, user *entities.User) error
 	
 	// GetByID retrieves a user by ID
@@ -17,12 +31,18 @@ type UserRepository interface {
 	// GetByEmail retrieves a user by email
 	GetByEmail(ctx context.Context, email string) (*entities.User, error)
 	
-	// Update updates an existing user
+	// Update updates an existing user. user.Version must equal the stored
+	// version, otherwise ErrVersionConflict is returned. On success the stored
+	// version is incremented and written back to user.Version.
 	Update(ctx context.Context, user *entities.User) error
 	
-	// Delete deletes a user by ID
+	// Delete permanently deletes a user by ID
 	Delete(ctx context.Context, id int) error
 	
-	// List retrieves all users
+	// List retrieves all users, ordered by ID
 	List(ctx context.Context) ([]*entities.User, error)
+	
+	// ListPage retrieves the page of users selected by opts. It returns
+	// ErrInvalidCursor for a cursor it did not issue for the same sort order.
+	ListPage(ctx context.Context, opts ListOptions) (*UserPage, error)
 }
\ No newline at end of file
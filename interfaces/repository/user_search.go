@@ -0,0 +1,19 @@
+package repository
+
+import "context"
+
+// SearchHit is a user matching a search query
+type SearchHit struct {
+	UserID int
+
+	// Score ranks hits against each other; higher is more relevant
+	Score float64
+}
+
+// UserSearchIndex finds users by free text over their names and emails
+type UserSearchIndex interface {
+	// Search returns the hits for query from offset on, at most limit of
+	// them, best first, along with the total number of hits. Soft-deleted
+	// users and users of other tenants than that of ctx are never returned.
+	Search(ctx context.Context, query string, offset, limit int) ([]SearchHit, int, error)
+}
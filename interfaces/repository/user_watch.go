@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// Operations of a UserChange
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted" // soft-deleted
+	ChangePurged  = "purged"  // deleted for good
+)
+
+// WatchFromLatest starts a watch after the latest change, delivering only
+// the changes committed from then on
+const WatchFromLatest int64 = -1
+
+// UserChange is a committed change of a user, carrying the full record as
+// it was after the change. A purged user carries its last record.
+type UserChange struct {
+	// Seq orders the changes of a store. It increases with every change,
+	// though not necessarily by one.
+	Seq       int64
+	Operation string
+	User      *entities.User
+	Time      time.Time
+}
+
+// UserChangeStream delivers the changes of a store in order, one at a time
+type UserChangeStream interface {
+	// Next waits for the change following the last one returned and returns
+	// it. It returns entities.ErrWatchTooFarBehind once a change the stream
+	// still had to deliver is no longer retained, and the error of the
+	// watch context once it is done.
+	Next() (*UserChange, error)
+}
+
+// UserWatcher is implemented by user repositories that publish their
+// committed changes. Changes rolled back are never published.
+type UserWatcher interface {
+	// Watch returns a stream of the changes committed after fromSeq, or
+	// after the latest one with WatchFromLatest, which ends when ctx is
+	// done. Resume a stream from the Seq of the last change received. It
+	// returns entities.ErrWatchTooFarBehind when changes after fromSeq are
+	// no longer retained, after which the consumer must start over from
+	// the current users, and entities.ErrInvalidSequence for a sequence
+	// number that wasn't issued yet. Only the changes of users of the
+	// tenant of ctx are delivered.
+	Watch(ctx context.Context, fromSeq int64) (UserChangeStream, error)
+}
@@ -0,0 +1,169 @@
+package userio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// csvColumns is the header written by the CSV writer. The reader needs the
+// name and email columns only, in any order.
+var csvColumns = []string{"id", "name", "email", "created", "updated", "deleted_at"}
+
+// csvWriter writes a header row followed by one row per user. Times are
+// written in RFC 3339, and deleted_at is empty for users that aren't
+// deleted.
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(user *entities.User) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	deletedAt := ""
+	if user.DeletedAt != nil {
+		deletedAt = user.DeletedAt.Format(time.RFC3339Nano)
+	}
+	return w.w.Write([]string{
+		strconv.Itoa(user.ID),
+		user.Name,
+		user.Email,
+		user.Created.Format(time.RFC3339Nano),
+		user.Updated.Format(time.RFC3339Nano),
+		deletedAt,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.w.Write(csvColumns)
+}
+
+// csvReader reads the rows after the header. Unknown columns are ignored
+// and empty times are left zero.
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+	width   int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing CSV header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// Spreadsheets like to start their files with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, exists := columns[name]; exists {
+			return nil, fmt.Errorf("invalid CSV header: duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, exists := columns[required]; !exists {
+			return nil, fmt.Errorf("invalid CSV header: missing column %q", required)
+		}
+	}
+
+	return &csvReader{r: cr, columns: columns, width: len(header)}, nil
+}
+
+func (r *csvReader) Read() (*entities.User, int, error) {
+	record, err := r.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, parseErr.StartLine, fmt.Errorf("%w: %v", entities.ErrInvalidRecord, parseErr.Err)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	line, _ := r.r.FieldPos(0)
+	if len(record) != r.width {
+		return nil, line, fmt.Errorf("%w: expected %d fields, got %d", entities.ErrInvalidRecord, r.width, len(record))
+	}
+
+	user := &entities.User{
+		Name:  r.field(record, "name"),
+		Email: r.field(record, "email"),
+	}
+	for _, column := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"created", &user.Created},
+		{"updated", &user.Updated},
+	} {
+		t, err := r.time(record, column.name)
+		if err != nil {
+			return nil, line, err
+		}
+		if t != nil {
+			*column.value = *t
+		}
+	}
+	if user.DeletedAt, err = r.time(record, "deleted_at"); err != nil {
+		return nil, line, err
+	}
+
+	return user, line, nil
+}
+
+// field returns the value of the named column, or "" if there is no such
+// column
+func (r *csvReader) field(record []string, name string) string {
+	i, exists := r.columns[name]
+	if !exists {
+		return ""
+	}
+	return record[i]
+}
+
+// time parses the value of the named column, returning nil if it is empty
+func (r *csvReader) time(record []string, name string) (*time.Time, error) {
+	value := strings.TrimSpace(r.field(record, name))
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s %q", entities.ErrInvalidRecord, name, value)
+	}
+	return &t, nil
+}
@@ -0,0 +1,80 @@
+// Package userio reads and writes users in the file formats used to move
+// them between environments: JSON Lines, one user object per line, and CSV
+// with a header row.
+package userio
+
+import (
+	"errors"
+	"io"
+
+	"agent-orchestration/entities"
+)
+
+// Format is a user file format
+type Format string
+
+// Supported formats
+const (
+	FormatJSONL Format = "jsonl"
+	FormatCSV   Format = "csv"
+)
+
+// ErrUnknownFormat is returned for format names other than jsonl and csv
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat returns the format called name. An empty name selects JSON
+// Lines.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case "", FormatJSONL:
+		return FormatJSONL, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// ContentType returns the media type of files in the format
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Writer writes users to a file
+type Writer interface {
+	// Write writes a user
+	Write(user *entities.User) error
+
+	// Flush writes any buffered data, and the CSV header if no user was
+	// written
+	Flush() error
+}
+
+// Reader reads users from a file
+type Reader interface {
+	// Read returns the next user and the line of the file it starts on. It
+	// returns io.EOF after the last user, and an error wrapping
+	// entities.ErrInvalidRecord for a record that can't be decoded, after
+	// which reading goes on with the next record.
+	Read() (*entities.User, int, error)
+}
+
+// NewWriter returns a writer of users in format f
+func NewWriter(w io.Writer, f Format) Writer {
+	if f == FormatCSV {
+		return newCSVWriter(w)
+	}
+	return newJSONLWriter(w)
+}
+
+// NewReader returns a reader of users in format f. For CSV it reads the
+// header row, and fails if the name or email column is missing.
+func NewReader(r io.Reader, f Format) (Reader, error) {
+	if f == FormatCSV {
+		return newCSVReader(r)
+	}
+	return newJSONLReader(r), nil
+}
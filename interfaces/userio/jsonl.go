@@ -0,0 +1,64 @@
+package userio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"agent-orchestration/entities"
+)
+
+// jsonlWriter writes one JSON object per user and line
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	buf := bufio.NewWriter(w)
+	return &jsonlWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+func (w *jsonlWriter) Write(user *entities.User) error {
+	return w.enc.Encode(user)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+// jsonlReader reads one JSON object per line, skipping blank lines
+type jsonlReader struct {
+	buf  *bufio.Reader
+	line int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	return &jsonlReader{buf: bufio.NewReader(r)}
+}
+
+func (r *jsonlReader) Read() (*entities.User, int, error) {
+	for {
+		data, err := r.buf.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return nil, r.line, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, r.line, err
+		}
+		r.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var user entities.User
+		if err := json.Unmarshal(data, &user); err != nil {
+			return nil, r.line, fmt.Errorf("%w: %v", entities.ErrInvalidRecord, err)
+		}
+		return &user, r.line, nil
+	}
+}
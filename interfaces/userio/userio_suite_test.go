@@ -0,0 +1,13 @@
+package userio_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestUserio(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Userio Suite")
+}
@@ -0,0 +1,163 @@
+package userio_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/userio"
+)
+
+// readAll reads every user from r, failing on any error
+func readAll(r userio.Reader) []*entities.User {
+	users := make([]*entities.User, 0)
+	for {
+		user, _, err := r.Read()
+		if err == io.EOF {
+			return users
+		}
+		Expect(err).To(BeNil())
+		users = append(users, user)
+	}
+}
+
+var _ = Describe("userio", func() {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	deletedAt := created.Add(time.Hour)
+	users := []*entities.User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Created: created, Updated: created, Version: 2},
+		{ID: 2, Name: `Jane "JJ", Doe`, Email: "jane@example.com", Created: created, Updated: deletedAt, Version: 3, DeletedAt: &deletedAt},
+	}
+
+	Describe("ParseFormat", func() {
+		It("should default to JSON Lines", func() {
+			Expect(userio.ParseFormat("")).To(Equal(userio.FormatJSONL))
+			Expect(userio.ParseFormat("csv")).To(Equal(userio.FormatCSV))
+		})
+
+		It("should reject unknown formats", func() {
+			_, err := userio.ParseFormat("xml")
+			Expect(err).To(Equal(userio.ErrUnknownFormat))
+		})
+	})
+
+	DescribeTable("round trip",
+		func(format userio.Format) {
+			var buf bytes.Buffer
+			writer := userio.NewWriter(&buf, format)
+			for _, user := range users {
+				Expect(writer.Write(user)).To(Succeed())
+			}
+			Expect(writer.Flush()).To(Succeed())
+
+			reader, err := userio.NewReader(&buf, format)
+			Expect(err).To(BeNil())
+			read := readAll(reader)
+
+			Expect(read).To(HaveLen(2))
+			for i, user := range read {
+				Expect(user.Name).To(Equal(users[i].Name))
+				Expect(user.Email).To(Equal(users[i].Email))
+				Expect(user.Created.Equal(users[i].Created)).To(BeTrue())
+				Expect(user.Updated.Equal(users[i].Updated)).To(BeTrue())
+			}
+			Expect(read[0].DeletedAt).To(BeNil())
+			Expect(read[1].DeletedAt.Equal(deletedAt)).To(BeTrue())
+		},
+		Entry("JSON Lines", userio.FormatJSONL),
+		Entry("CSV", userio.FormatCSV),
+	)
+
+	Describe("JSON Lines", func() {
+		It("should report invalid lines and go on", func() {
+			input := `{"name":"John Doe","email":"john@example.com"}
+
+{"name": broken
+{"name":"Jane Doe","email":"jane@example.com","created":"yesterday"}
+{"name":"Jim Doe","email":"jim@example.com"}`
+			reader, err := userio.NewReader(strings.NewReader(input), userio.FormatJSONL)
+			Expect(err).To(BeNil())
+
+			user, line, err := reader.Read()
+			Expect(err).To(BeNil())
+			Expect(user.Name).To(Equal("John Doe"))
+			Expect(line).To(Equal(1))
+
+			for _, expected := range []int{3, 4} {
+				_, line, err = reader.Read()
+				Expect(err).To(MatchError(entities.ErrInvalidRecord))
+				Expect(line).To(Equal(expected))
+			}
+
+			user, line, err = reader.Read()
+			Expect(err).To(BeNil())
+			Expect(user.Name).To(Equal("Jim Doe"))
+			Expect(line).To(Equal(5))
+
+			_, _, err = reader.Read()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+
+	Describe("CSV", func() {
+		It("should write just the header when there are no users", func() {
+			var buf bytes.Buffer
+			Expect(userio.NewWriter(&buf, userio.FormatCSV).Flush()).To(Succeed())
+			Expect(buf.String()).To(Equal("id,name,email,created,updated,deleted_at\n"))
+		})
+
+		It("should read the name and email columns in any order", func() {
+			input := "\ufeffEmail, Name ,team\njohn@example.com,John Doe,blue\n"
+			reader, err := userio.NewReader(strings.NewReader(input), userio.FormatCSV)
+			Expect(err).To(BeNil())
+
+			read := readAll(reader)
+			Expect(read).To(HaveLen(1))
+			Expect(read[0].Name).To(Equal("John Doe"))
+			Expect(read[0].Email).To(Equal("john@example.com"))
+			Expect(read[0].Created.IsZero()).To(BeTrue())
+		})
+
+		It("should report invalid rows and go on", func() {
+			input := "name,email,created\n" +
+				"John Doe,john@example.com,\n" +
+				"Jane Doe,jane@example.com\n" +
+				"Jim Doe,jim@example.com,yesterday\n" +
+				"\"Joe \"Doe\",joe@example.com,\n" +
+				"Jill Doe,jill@example.com,2024-01-02T03:04:05Z\n"
+			reader, err := userio.NewReader(strings.NewReader(input), userio.FormatCSV)
+			Expect(err).To(BeNil())
+
+			user, line, err := reader.Read()
+			Expect(err).To(BeNil())
+			Expect(user.Name).To(Equal("John Doe"))
+			Expect(line).To(Equal(2))
+
+			for _, expected := range []int{3, 4, 5} {
+				_, line, err = reader.Read()
+				Expect(err).To(MatchError(entities.ErrInvalidRecord))
+				Expect(line).To(Equal(expected))
+			}
+
+			user, line, err = reader.Read()
+			Expect(err).To(BeNil())
+			Expect(user.Name).To(Equal("Jill Doe"))
+			Expect(line).To(Equal(6))
+		})
+
+		DescribeTable("invalid headers",
+			func(input, message string) {
+				_, err := userio.NewReader(strings.NewReader(input), userio.FormatCSV)
+				Expect(err).To(MatchError(message))
+			},
+			Entry("empty file", "", "missing CSV header"),
+			Entry("missing email", "id,name\n", `invalid CSV header: missing column "email"`),
+			Entry("duplicate column", "name,email,Name\n", `invalid CSV header: duplicate column "name"`),
+		)
+	})
+})
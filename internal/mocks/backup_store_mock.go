@@ -0,0 +1,174 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"agent-orchestration/interfaces/repository"
+)
+
+// Ensure, that BackupStoreMock does implement BackupStore.
+// If this is not the case, regenerate this file with moq.
+//var _ repository.BackupStore = &BackupStoreMock{}
+
+// BackupStoreMock is a mock implementation of BackupStore.
+//
+//	func TestSomethingThatUsesBackupStore(t *testing.T) {
+//
+//		// make and configure a mocked BackupStore
+//		mockedBackupStore := &BackupStoreMock{
+//			SaveFunc: func(ctx context.Context, snapshot *repository.UserSnapshot) (*repository.BackupInfo, error) {
+//				panic("mock out the Save method")
+//			},
+//			ListFunc: func(ctx context.Context) ([]*repository.BackupInfo, error) {
+//				panic("mock out the List method")
+//			},
+//			LoadFunc: func(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+//				panic("mock out the Load method")
+//			},
+//		}
+//
+//		// use mockedBackupStore in code that requires BackupStore
+//		// and then make assertions.
+//
+//	}
+type BackupStoreMock struct {
+	// SaveFunc mocks the Save method.
+	SaveFunc func(ctx context.Context, snapshot *repository.UserSnapshot) (*repository.BackupInfo, error)
+
+	// ListFunc mocks the List method.
+	ListFunc func(ctx context.Context) ([]*repository.BackupInfo, error)
+
+	// LoadFunc mocks the Load method.
+	LoadFunc func(ctx context.Context, name string) (*repository.UserSnapshot, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Save holds details about calls to the Save method.
+		Save []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Snapshot is the snapshot argument value.
+			Snapshot *repository.UserSnapshot
+		}
+		// List holds details about calls to the List method.
+		List []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// Load holds details about calls to the Load method.
+		Load []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Name is the name argument value.
+			Name string
+		}
+	}
+	lockSave sync.RWMutex
+	lockList sync.RWMutex
+	lockLoad sync.RWMutex
+}
+
+// Save calls SaveFunc.
+func (mock *BackupStoreMock) Save(ctx context.Context, snapshot *repository.UserSnapshot) (*repository.BackupInfo, error) {
+	if mock.SaveFunc == nil {
+		panic("BackupStoreMock.SaveFunc: method is nil but BackupStore.Save was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Snapshot *repository.UserSnapshot
+	}{
+		Ctx:      ctx,
+		Snapshot: snapshot,
+	}
+	mock.lockSave.Lock()
+	mock.calls.Save = append(mock.calls.Save, callInfo)
+	mock.lockSave.Unlock()
+	return mock.SaveFunc(ctx, snapshot)
+}
+
+// SaveCalls gets all the calls that were made to Save.
+// Check the length with:
+//
+//	len(mockedBackupStore.SaveCalls())
+func (mock *BackupStoreMock) SaveCalls() []struct {
+	Ctx      context.Context
+	Snapshot *repository.UserSnapshot
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Snapshot *repository.UserSnapshot
+	}
+	mock.lockSave.RLock()
+	calls = mock.calls.Save
+	mock.lockSave.RUnlock()
+	return calls
+}
+
+// List calls ListFunc.
+func (mock *BackupStoreMock) List(ctx context.Context) ([]*repository.BackupInfo, error) {
+	if mock.ListFunc == nil {
+		panic("BackupStoreMock.ListFunc: method is nil but BackupStore.List was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockList.Lock()
+	mock.calls.List = append(mock.calls.List, callInfo)
+	mock.lockList.Unlock()
+	return mock.ListFunc(ctx)
+}
+
+// ListCalls gets all the calls that were made to List.
+// Check the length with:
+//
+//	len(mockedBackupStore.ListCalls())
+func (mock *BackupStoreMock) ListCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockList.RLock()
+	calls = mock.calls.List
+	mock.lockList.RUnlock()
+	return calls
+}
+
+// Load calls LoadFunc.
+func (mock *BackupStoreMock) Load(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+	if mock.LoadFunc == nil {
+		panic("BackupStoreMock.LoadFunc: method is nil but BackupStore.Load was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Name string
+	}{
+		Ctx:  ctx,
+		Name: name,
+	}
+	mock.lockLoad.Lock()
+	mock.calls.Load = append(mock.calls.Load, callInfo)
+	mock.lockLoad.Unlock()
+	return mock.LoadFunc(ctx, name)
+}
+
+// LoadCalls gets all the calls that were made to Load.
+// Check the length with:
+//
+//	len(mockedBackupStore.LoadCalls())
+func (mock *BackupStoreMock) LoadCalls() []struct {
+	Ctx  context.Context
+	Name string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Name string
+	}
+	mock.lockLoad.RLock()
+	calls = mock.calls.Load
+	mock.lockLoad.RUnlock()
+	return calls
+}
@@ -0,0 +1,142 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"agent-orchestration/entities"
+)
+
+// Ensure, that HistoryRepositoryMock does implement HistoryRepository.
+// If this is not the case, regenerate this file with moq.
+//var _ repository.HistoryRepository = &HistoryRepositoryMock{}
+
+// HistoryRepositoryMock is a mock implementation of HistoryRepository.
+//
+//	func TestSomethingThatUsesHistoryRepository(t *testing.T) {
+//
+//		// make and configure a mocked HistoryRepository
+//		mockedHistoryRepository := &HistoryRepositoryMock{
+//			AppendFunc: func(ctx context.Context, entry *entities.HistoryEntry) error {
+//				panic("mock out the Append method")
+//			},
+//			ListByUserFunc: func(ctx context.Context, userID int, offset int, limit int) ([]*entities.HistoryEntry, int, error) {
+//				panic("mock out the ListByUser method")
+//			},
+//		}
+//
+//		// use mockedHistoryRepository in code that requires HistoryRepository
+//		// and then make assertions.
+//
+//	}
+type HistoryRepositoryMock struct {
+	// AppendFunc mocks the Append method.
+	AppendFunc func(ctx context.Context, entry *entities.HistoryEntry) error
+
+	// ListByUserFunc mocks the ListByUser method.
+	ListByUserFunc func(ctx context.Context, userID int, offset int, limit int) ([]*entities.HistoryEntry, int, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Append holds details about calls to the Append method.
+		Append []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Entry is the entry argument value.
+			Entry *entities.HistoryEntry
+		}
+		// ListByUser holds details about calls to the ListByUser method.
+		ListByUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// UserID is the userID argument value.
+			UserID int
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+		}
+	}
+	lockAppend     sync.RWMutex
+	lockListByUser sync.RWMutex
+}
+
+// Append calls AppendFunc.
+func (mock *HistoryRepositoryMock) Append(ctx context.Context, entry *entities.HistoryEntry) error {
+	if mock.AppendFunc == nil {
+		panic("HistoryRepositoryMock.AppendFunc: method is nil but HistoryRepository.Append was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Entry *entities.HistoryEntry
+	}{
+		Ctx:   ctx,
+		Entry: entry,
+	}
+	mock.lockAppend.Lock()
+	mock.calls.Append = append(mock.calls.Append, callInfo)
+	mock.lockAppend.Unlock()
+	return mock.AppendFunc(ctx, entry)
+}
+
+// AppendCalls gets all the calls that were made to Append.
+// Check the length with:
+//
+//	len(mockedHistoryRepository.AppendCalls())
+func (mock *HistoryRepositoryMock) AppendCalls() []struct {
+	Ctx   context.Context
+	Entry *entities.HistoryEntry
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Entry *entities.HistoryEntry
+	}
+	mock.lockAppend.RLock()
+	calls = mock.calls.Append
+	mock.lockAppend.RUnlock()
+	return calls
+}
+
+// ListByUser calls ListByUserFunc.
+func (mock *HistoryRepositoryMock) ListByUser(ctx context.Context, userID int, offset int, limit int) ([]*entities.HistoryEntry, int, error) {
+	if mock.ListByUserFunc == nil {
+		panic("HistoryRepositoryMock.ListByUserFunc: method is nil but HistoryRepository.ListByUser was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		UserID int
+		Offset int
+		Limit  int
+	}{
+		Ctx:    ctx,
+		UserID: userID,
+		Offset: offset,
+		Limit:  limit,
+	}
+	mock.lockListByUser.Lock()
+	mock.calls.ListByUser = append(mock.calls.ListByUser, callInfo)
+	mock.lockListByUser.Unlock()
+	return mock.ListByUserFunc(ctx, userID, offset, limit)
+}
+
+// ListByUserCalls gets all the calls that were made to ListByUser.
+// Check the length with:
+//
+//	len(mockedHistoryRepository.ListByUserCalls())
+func (mock *HistoryRepositoryMock) ListByUserCalls() []struct {
+	Ctx    context.Context
+	UserID int
+	Offset int
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		UserID int
+		Offset int
+		Limit  int
+	}
+	mock.lockListByUser.RLock()
+	calls = mock.calls.ListByUser
+	mock.lockListByUser.RUnlock()
+	return calls
+}
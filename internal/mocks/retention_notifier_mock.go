@@ -0,0 +1,87 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"agent-orchestration/entities"
+)
+
+// Ensure, that RetentionNotifierMock does implement RetentionNotifier.
+// If this is not the case, regenerate this file with moq.
+//var _ use_cases.RetentionNotifier = &RetentionNotifierMock{}
+
+// RetentionNotifierMock is a mock implementation of RetentionNotifier.
+//
+//	func TestSomethingThatUsesRetentionNotifier(t *testing.T) {
+//
+//		// make and configure a mocked RetentionNotifier
+//		mockedRetentionNotifier := &RetentionNotifierMock{
+//			WarnInactiveFunc: func(ctx context.Context, user *entities.User, deleteAfter time.Time) error {
+//				panic("mock out the WarnInactive method")
+//			},
+//		}
+//
+//		// use mockedRetentionNotifier in code that requires RetentionNotifier
+//		// and then make assertions.
+//
+//	}
+type RetentionNotifierMock struct {
+	// WarnInactiveFunc mocks the WarnInactive method.
+	WarnInactiveFunc func(ctx context.Context, user *entities.User, deleteAfter time.Time) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// WarnInactive holds details about calls to the WarnInactive method.
+		WarnInactive []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// User is the user argument value.
+			User *entities.User
+			// DeleteAfter is the deleteAfter argument value.
+			DeleteAfter time.Time
+		}
+	}
+	lockWarnInactive sync.RWMutex
+}
+
+// WarnInactive calls WarnInactiveFunc.
+func (mock *RetentionNotifierMock) WarnInactive(ctx context.Context, user *entities.User, deleteAfter time.Time) error {
+	if mock.WarnInactiveFunc == nil {
+		panic("RetentionNotifierMock.WarnInactiveFunc: method is nil but RetentionNotifier.WarnInactive was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		User        *entities.User
+		DeleteAfter time.Time
+	}{
+		Ctx:         ctx,
+		User:        user,
+		DeleteAfter: deleteAfter,
+	}
+	mock.lockWarnInactive.Lock()
+	mock.calls.WarnInactive = append(mock.calls.WarnInactive, callInfo)
+	mock.lockWarnInactive.Unlock()
+	return mock.WarnInactiveFunc(ctx, user, deleteAfter)
+}
+
+// WarnInactiveCalls gets all the calls that were made to WarnInactive.
+// Check the length with:
+//
+//	len(mockedRetentionNotifier.WarnInactiveCalls())
+func (mock *RetentionNotifierMock) WarnInactiveCalls() []struct {
+	Ctx         context.Context
+	User        *entities.User
+	DeleteAfter time.Time
+} {
+	var calls []struct {
+		Ctx         context.Context
+		User        *entities.User
+		DeleteAfter time.Time
+	}
+	mock.lockWarnInactive.RLock()
+	calls = mock.calls.WarnInactive
+	mock.lockWarnInactive.RUnlock()
+	return calls
+}
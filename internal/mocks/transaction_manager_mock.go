@@ -0,0 +1,78 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+)
+
+// Ensure, that TransactionManagerMock does implement TransactionManager.
+// If this is not the case, regenerate this file with moq.
+//var _ repository.TransactionManager = &TransactionManagerMock{}
+
+// TransactionManagerMock is a mock implementation of TransactionManager.
+//
+//	func TestSomethingThatUsesTransactionManager(t *testing.T) {
+//
+//		// make and configure a mocked TransactionManager
+//		mockedTransactionManager := &TransactionManagerMock{
+//			WithinTransactionFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+//				panic("mock out the WithinTransaction method")
+//			},
+//		}
+//
+//		// use mockedTransactionManager in code that requires TransactionManager
+//		// and then make assertions.
+//
+//	}
+type TransactionManagerMock struct {
+	// WithinTransactionFunc mocks the WithinTransaction method.
+	WithinTransactionFunc func(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// WithinTransaction holds details about calls to the WithinTransaction method.
+		WithinTransaction []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Fn is the fn argument value.
+			Fn func(ctx context.Context) error
+		}
+	}
+	lockWithinTransaction sync.RWMutex
+}
+
+// WithinTransaction calls WithinTransactionFunc.
+func (mock *TransactionManagerMock) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mock.WithinTransactionFunc == nil {
+		panic("TransactionManagerMock.WithinTransactionFunc: method is nil but TransactionManager.WithinTransaction was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Fn  func(ctx context.Context) error
+	}{
+		Ctx: ctx,
+		Fn:  fn,
+	}
+	mock.lockWithinTransaction.Lock()
+	mock.calls.WithinTransaction = append(mock.calls.WithinTransaction, callInfo)
+	mock.lockWithinTransaction.Unlock()
+	return mock.WithinTransactionFunc(ctx, fn)
+}
+
+// WithinTransactionCalls gets all the calls that were made to WithinTransaction.
+// Check the length with:
+//
+//	len(mockedTransactionManager.WithinTransactionCalls())
+func (mock *TransactionManagerMock) WithinTransactionCalls() []struct {
+	Ctx context.Context
+	Fn  func(ctx context.Context) error
+} {
+	var calls []struct {
+		Ctx context.Context
+		Fn  func(ctx context.Context) error
+	}
+	mock.lockWithinTransaction.RLock()
+	calls = mock.calls.WithinTransaction
+	mock.lockWithinTransaction.RUnlock()
+	return calls
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
 )
 
 // Ensure, that UserRepositoryMock does implement UserRepository.
@@ -32,6 +33,9 @@ import (
 //			ListFunc: func(ctx context.Context) ([]*entities.User, error) {
 //				panic("mock out the List method")
 //			},
+//			ListPageFunc: func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+//				panic("mock out the ListPage method")
+//			},
 //			UpdateFunc: func(ctx context.Context, user *entities.User) error {
 //				panic("mock out the Update method")
 //			},
@@ -57,6 +61,9 @@ type UserRepositoryMock struct {
 	// ListFunc mocks the List method.
 	ListFunc func(ctx context.Context) ([]*entities.User, error)
 
+	// ListPageFunc mocks the ListPage method.
+	ListPageFunc func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error)
+
 	// UpdateFunc mocks the Update method.
 	UpdateFunc func(ctx context.Context, user *entities.User) error
 
@@ -95,6 +102,13 @@ type UserRepositoryMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// ListPage holds details about calls to the ListPage method.
+		ListPage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Opts is the opts argument value.
+			Opts repository.ListOptions
+		}
 		// Update holds details about calls to the Update method.
 		Update []struct {
 			// Ctx is the ctx argument value.
@@ -108,6 +122,7 @@ type UserRepositoryMock struct {
 	lockGetByEmail sync.RWMutex
 	lockGetByID    sync.RWMutex
 	lockList       sync.RWMutex
+	lockListPage   sync.RWMutex
 	lockUpdate     sync.RWMutex
 }
 
@@ -287,6 +302,42 @@ func (mock *UserRepositoryMock) ListCalls() []struct {
 	return calls
 }
 
+// ListPage calls ListPageFunc.
+func (mock *UserRepositoryMock) ListPage(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	if mock.ListPageFunc == nil {
+		panic("UserRepositoryMock.ListPageFunc: method is nil but UserRepository.ListPage was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Opts repository.ListOptions
+	}{
+		Ctx:  ctx,
+		Opts: opts,
+	}
+	mock.lockListPage.Lock()
+	mock.calls.ListPage = append(mock.calls.ListPage, callInfo)
+	mock.lockListPage.Unlock()
+	return mock.ListPageFunc(ctx, opts)
+}
+
+// ListPageCalls gets all the calls that were made to ListPage.
+// Check the length with:
+//
+//	len(mockedUserRepository.ListPageCalls())
+func (mock *UserRepositoryMock) ListPageCalls() []struct {
+	Ctx  context.Context
+	Opts repository.ListOptions
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Opts repository.ListOptions
+	}
+	mock.lockListPage.RLock()
+	calls = mock.calls.ListPage
+	mock.lockListPage.RUnlock()
+	return calls
+}
+
 // Update calls UpdateFunc.
 func (mock *UserRepositoryMock) Update(ctx context.Context, user *entities.User) error {
 	if mock.UpdateFunc == nil {
@@ -0,0 +1,92 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"agent-orchestration/interfaces/repository"
+)
+
+// Ensure, that UserSearchIndexMock does implement UserSearchIndex.
+// If this is not the case, regenerate this file with moq.
+//var _ repository.UserSearchIndex = &UserSearchIndexMock{}
+
+// UserSearchIndexMock is a mock implementation of UserSearchIndex.
+//
+//	func TestSomethingThatUsesUserSearchIndex(t *testing.T) {
+//
+//		// make and configure a mocked UserSearchIndex
+//		mockedUserSearchIndex := &UserSearchIndexMock{
+//			SearchFunc: func(ctx context.Context, query string, offset int, limit int) ([]repository.SearchHit, int, error) {
+//				panic("mock out the Search method")
+//			},
+//		}
+//
+//		// use mockedUserSearchIndex in code that requires UserSearchIndex
+//		// and then make assertions.
+//
+//	}
+type UserSearchIndexMock struct {
+	// SearchFunc mocks the Search method.
+	SearchFunc func(ctx context.Context, query string, offset int, limit int) ([]repository.SearchHit, int, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Search holds details about calls to the Search method.
+		Search []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Query is the query argument value.
+			Query string
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+		}
+	}
+	lockSearch sync.RWMutex
+}
+
+// Search calls SearchFunc.
+func (mock *UserSearchIndexMock) Search(ctx context.Context, query string, offset int, limit int) ([]repository.SearchHit, int, error) {
+	if mock.SearchFunc == nil {
+		panic("UserSearchIndexMock.SearchFunc: method is nil but UserSearchIndex.Search was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Query  string
+		Offset int
+		Limit  int
+	}{
+		Ctx:    ctx,
+		Query:  query,
+		Offset: offset,
+		Limit:  limit,
+	}
+	mock.lockSearch.Lock()
+	mock.calls.Search = append(mock.calls.Search, callInfo)
+	mock.lockSearch.Unlock()
+	return mock.SearchFunc(ctx, query, offset, limit)
+}
+
+// SearchCalls gets all the calls that were made to Search.
+// Check the length with:
+//
+//	len(mockedUserSearchIndex.SearchCalls())
+func (mock *UserSearchIndexMock) SearchCalls() []struct {
+	Ctx    context.Context
+	Query  string
+	Offset int
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Query  string
+		Offset int
+		Limit  int
+	}
+	mock.lockSearch.RLock()
+	calls = mock.calls.Search
+	mock.lockSearch.RUnlock()
+	return calls
+}
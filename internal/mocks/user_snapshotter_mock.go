@@ -0,0 +1,124 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"agent-orchestration/interfaces/repository"
+)
+
+// Ensure, that UserSnapshotterMock does implement UserSnapshotter.
+// If this is not the case, regenerate this file with moq.
+//var _ repository.UserSnapshotter = &UserSnapshotterMock{}
+
+// UserSnapshotterMock is a mock implementation of UserSnapshotter.
+//
+//	func TestSomethingThatUsesUserSnapshotter(t *testing.T) {
+//
+//		// make and configure a mocked UserSnapshotter
+//		mockedUserSnapshotter := &UserSnapshotterMock{
+//			SnapshotUsersFunc: func(ctx context.Context) (*repository.UserSnapshot, error) {
+//				panic("mock out the SnapshotUsers method")
+//			},
+//			RestoreUsersFunc: func(ctx context.Context, snapshot *repository.UserSnapshot) error {
+//				panic("mock out the RestoreUsers method")
+//			},
+//		}
+//
+//		// use mockedUserSnapshotter in code that requires UserSnapshotter
+//		// and then make assertions.
+//
+//	}
+type UserSnapshotterMock struct {
+	// SnapshotUsersFunc mocks the SnapshotUsers method.
+	SnapshotUsersFunc func(ctx context.Context) (*repository.UserSnapshot, error)
+
+	// RestoreUsersFunc mocks the RestoreUsers method.
+	RestoreUsersFunc func(ctx context.Context, snapshot *repository.UserSnapshot) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// SnapshotUsers holds details about calls to the SnapshotUsers method.
+		SnapshotUsers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// RestoreUsers holds details about calls to the RestoreUsers method.
+		RestoreUsers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Snapshot is the snapshot argument value.
+			Snapshot *repository.UserSnapshot
+		}
+	}
+	lockSnapshotUsers sync.RWMutex
+	lockRestoreUsers  sync.RWMutex
+}
+
+// SnapshotUsers calls SnapshotUsersFunc.
+func (mock *UserSnapshotterMock) SnapshotUsers(ctx context.Context) (*repository.UserSnapshot, error) {
+	if mock.SnapshotUsersFunc == nil {
+		panic("UserSnapshotterMock.SnapshotUsersFunc: method is nil but UserSnapshotter.SnapshotUsers was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockSnapshotUsers.Lock()
+	mock.calls.SnapshotUsers = append(mock.calls.SnapshotUsers, callInfo)
+	mock.lockSnapshotUsers.Unlock()
+	return mock.SnapshotUsersFunc(ctx)
+}
+
+// SnapshotUsersCalls gets all the calls that were made to SnapshotUsers.
+// Check the length with:
+//
+//	len(mockedUserSnapshotter.SnapshotUsersCalls())
+func (mock *UserSnapshotterMock) SnapshotUsersCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockSnapshotUsers.RLock()
+	calls = mock.calls.SnapshotUsers
+	mock.lockSnapshotUsers.RUnlock()
+	return calls
+}
+
+// RestoreUsers calls RestoreUsersFunc.
+func (mock *UserSnapshotterMock) RestoreUsers(ctx context.Context, snapshot *repository.UserSnapshot) error {
+	if mock.RestoreUsersFunc == nil {
+		panic("UserSnapshotterMock.RestoreUsersFunc: method is nil but UserSnapshotter.RestoreUsers was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Snapshot *repository.UserSnapshot
+	}{
+		Ctx:      ctx,
+		Snapshot: snapshot,
+	}
+	mock.lockRestoreUsers.Lock()
+	mock.calls.RestoreUsers = append(mock.calls.RestoreUsers, callInfo)
+	mock.lockRestoreUsers.Unlock()
+	return mock.RestoreUsersFunc(ctx, snapshot)
+}
+
+// RestoreUsersCalls gets all the calls that were made to RestoreUsers.
+// Check the length with:
+//
+//	len(mockedUserSnapshotter.RestoreUsersCalls())
+func (mock *UserSnapshotterMock) RestoreUsersCalls() []struct {
+	Ctx      context.Context
+	Snapshot *repository.UserSnapshot
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Snapshot *repository.UserSnapshot
+	}
+	mock.lockRestoreUsers.RLock()
+	calls = mock.calls.RestoreUsers
+	mock.lockRestoreUsers.RUnlock()
+	return calls
+}
@@ -0,0 +1,80 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"agent-orchestration/interfaces/repository"
+)
+
+// Ensure, that UserWatcherMock does implement UserWatcher.
+// If this is not the case, regenerate this file with moq.
+//var _ repository.UserWatcher = &UserWatcherMock{}
+
+// UserWatcherMock is a mock implementation of UserWatcher.
+//
+//	func TestSomethingThatUsesUserWatcher(t *testing.T) {
+//
+//		// make and configure a mocked UserWatcher
+//		mockedUserWatcher := &UserWatcherMock{
+//			WatchFunc: func(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+//				panic("mock out the Watch method")
+//			},
+//		}
+//
+//		// use mockedUserWatcher in code that requires UserWatcher
+//		// and then make assertions.
+//
+//	}
+type UserWatcherMock struct {
+	// WatchFunc mocks the Watch method.
+	WatchFunc func(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Watch holds details about calls to the Watch method.
+		Watch []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// FromSeq is the fromSeq argument value.
+			FromSeq int64
+		}
+	}
+	lockWatch sync.RWMutex
+}
+
+// Watch calls WatchFunc.
+func (mock *UserWatcherMock) Watch(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+	if mock.WatchFunc == nil {
+		panic("UserWatcherMock.WatchFunc: method is nil but UserWatcher.Watch was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		FromSeq int64
+	}{
+		Ctx:     ctx,
+		FromSeq: fromSeq,
+	}
+	mock.lockWatch.Lock()
+	mock.calls.Watch = append(mock.calls.Watch, callInfo)
+	mock.lockWatch.Unlock()
+	return mock.WatchFunc(ctx, fromSeq)
+}
+
+// WatchCalls gets all the calls that were made to Watch.
+// Check the length with:
+//
+//	len(mockedUserWatcher.WatchCalls())
+func (mock *UserWatcherMock) WatchCalls() []struct {
+	Ctx     context.Context
+	FromSeq int64
+} {
+	var calls []struct {
+		Ctx     context.Context
+		FromSeq int64
+	}
+	mock.lockWatch.RLock()
+	calls = mock.calls.Watch
+	mock.lockWatch.RUnlock()
+	return calls
+}
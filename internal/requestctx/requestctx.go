@@ -0,0 +1,35 @@
+// Package requestctx carries request scoped metadata, such as the request ID
+// and the acting principal, from the transport layer to the use cases
+// without tying them to a particular HTTP framework.
+package requestctx
+
+import "context"
+
+type (
+	requestIDKey struct{}
+	principalKey struct{}
+)
+
+// WithRequestID returns a context carrying the ID of the current request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithPrincipal returns a context carrying the identity acting in the
+// current request
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal returns the acting principal carried by ctx, or "" if the
+// request is anonymous
+func Principal(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
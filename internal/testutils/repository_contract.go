@@ -0,0 +1,860 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+)
+
+// UserRepositoryContract declares the specs every repository.UserRepository
+// implementation must pass. Call it inside a Describe block; newRepo is
+// invoked before each spec and must return an empty repository. Register any
+// cleanup with DeferCleanup from within newRepo.
+func UserRepositoryContract(newRepo func() repository.UserRepository) {
+	var (
+		repo repository.UserRepository
+		ctx  context.Context
+	)
+
+	BeforeEach(func() {
+		repo = newRepo()
+		ctx = context.Background()
+	})
+
+	newUser := func(name, email string) *entities.User {
+		return NewTestUserBuilder().
+			WithID(0).
+			WithName(name).
+			WithEmail(email).
+			WithCreated(time.Now()).
+			WithUpdated(time.Now()).
+			Build()
+	}
+
+	create := func(name, email string) *entities.User {
+		user := newUser(name, email)
+		Expect(repo.Create(ctx, user)).To(Succeed())
+		return user
+	}
+
+	Describe("Create", func() {
+		It("should assign a unique positive ID to every user", func() {
+			first := create("John Doe", "john@example.com")
+			second := create("Jane Doe", "jane@example.com")
+
+			Expect(first.ID).To(BeNumerically(">", 0))
+			Expect(second.ID).To(BeNumerically(">", first.ID))
+		})
+
+		It("should start every user at version 1", func() {
+			user := newUser("John Doe", "john@example.com")
+			user.Version = 7
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			Expect(user.Version).To(Equal(1))
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Version).To(Equal(1))
+		})
+
+		It("should ignore an ID set by the caller", func() {
+			user := newUser("John Doe", "john@example.com")
+			user.ID = 999
+			Expect(repo.Create(ctx, user)).To(Succeed())
+			create("Jane Doe", "jane@example.com")
+
+			_, err := repo.GetByID(ctx, 999)
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+
+		It("should reject a duplicate email with ErrUserAlreadyExists", func() {
+			original := create("John Doe", "john@example.com")
+
+			err := repo.Create(ctx, newUser("Impostor", "john@example.com"))
+			Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+
+			stored, err := repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(BeNil())
+			Expect(stored.ID).To(Equal(original.ID))
+			Expect(stored.Name).To(Equal("John Doe"))
+
+			users, err := repo.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(1))
+		})
+
+		It("should not be affected by later changes to the created value", func() {
+			user := create("John Doe", "john@example.com")
+			user.Name = "Changed Outside"
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Name).To(Equal("John Doe"))
+		})
+	})
+
+	Describe("GetByID", func() {
+		It("should return the stored user", func() {
+			user := create("John Doe", "john@example.com")
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(AssertUserEqual(user, stored, true)).To(BeTrue())
+			Expect(stored.Created).To(BeTemporally("==", user.Created))
+			Expect(stored.Updated).To(BeTemporally("==", user.Updated))
+		})
+
+		It("should return ErrUserNotFound for an unknown ID", func() {
+			stored, err := repo.GetByID(ctx, 12345)
+			Expect(stored).To(BeNil())
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+
+		It("should return a copy", func() {
+			user := create("John Doe", "john@example.com")
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			stored.Name = "Mutated"
+			stored.Email = "mutated@example.com"
+
+			again, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(again.Name).To(Equal("John Doe"))
+			Expect(again.Email).To(Equal("john@example.com"))
+		})
+	})
+
+	Describe("GetByEmail", func() {
+		It("should return the stored user", func() {
+			user := create("John Doe", "john@example.com")
+
+			stored, err := repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(BeNil())
+			Expect(stored.ID).To(Equal(user.ID))
+		})
+
+		It("should return ErrUserNotFound for an unknown email", func() {
+			stored, err := repo.GetByEmail(ctx, "missing@example.com")
+			Expect(stored).To(BeNil())
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+
+		It("should return a copy", func() {
+			create("John Doe", "john@example.com")
+
+			stored, err := repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(BeNil())
+			stored.Name = "Mutated"
+
+			again, err := repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(BeNil())
+			Expect(again.Name).To(Equal("John Doe"))
+		})
+	})
+
+	Describe("Update", func() {
+		It("should persist changed fields", func() {
+			user := create("John Doe", "john@example.com")
+			user.Name = "John Updated"
+			user.Updated = time.Now().Add(time.Minute)
+			Expect(repo.Update(ctx, user)).To(Succeed())
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Name).To(Equal("John Updated"))
+			Expect(stored.Updated).To(BeTemporally("==", user.Updated))
+		})
+
+		It("should persist the roles, down to none", func() {
+			user := newUser("John Doe", "john@example.com")
+			user.Roles = []string{entities.RoleAdmin, entities.RoleSupport}
+			Expect(repo.Create(ctx, user)).To(Succeed())
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Roles).To(Equal([]string{entities.RoleAdmin, entities.RoleSupport}))
+
+			user.RevokeRole(entities.RoleAdmin)
+			user.RevokeRole(entities.RoleSupport)
+			Expect(repo.Update(ctx, user)).To(Succeed())
+
+			stored, err = repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Roles).To(BeEmpty())
+		})
+
+		It("should increment the version on every update", func() {
+			user := create("John Doe", "john@example.com")
+
+			user.Name = "First Update"
+			Expect(repo.Update(ctx, user)).To(Succeed())
+			Expect(user.Version).To(Equal(2))
+
+			user.Name = "Second Update"
+			Expect(repo.Update(ctx, user)).To(Succeed())
+			Expect(user.Version).To(Equal(3))
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Version).To(Equal(3))
+		})
+
+		It("should reject an update based on a stale version with ErrVersionConflict", func() {
+			user := create("John Doe", "john@example.com")
+			stale, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+
+			user.Name = "Winner"
+			Expect(repo.Update(ctx, user)).To(Succeed())
+
+			stale.Name = "Loser"
+			Expect(repo.Update(ctx, stale)).To(Equal(entities.ErrVersionConflict))
+			Expect(stale.Version).To(Equal(1))
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Name).To(Equal("Winner"))
+			Expect(stored.Version).To(Equal(2))
+		})
+
+		It("should move the email index when the email changes", func() {
+			user := create("John Doe", "john@example.com")
+			user.Email = "john.new@example.com"
+			Expect(repo.Update(ctx, user)).To(Succeed())
+
+			_, err := repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+
+			stored, err := repo.GetByEmail(ctx, "john.new@example.com")
+			Expect(err).To(BeNil())
+			Expect(stored.ID).To(Equal(user.ID))
+
+			// The old address is free again
+			create("Someone Else", "john@example.com")
+		})
+
+		It("should allow keeping the same email", func() {
+			user := create("John Doe", "john@example.com")
+			user.Name = "Same Email"
+			Expect(repo.Update(ctx, user)).To(Succeed())
+		})
+
+		It("should reject an email owned by another user and keep both users intact", func() {
+			john := create("John Doe", "john@example.com")
+			create("Jane Doe", "jane@example.com")
+
+			john.Email = "jane@example.com"
+			Expect(repo.Update(ctx, john)).To(Equal(entities.ErrUserAlreadyExists))
+
+			stored, err := repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(BeNil())
+			Expect(stored.ID).To(Equal(john.ID))
+
+			stored, err = repo.GetByEmail(ctx, "jane@example.com")
+			Expect(err).To(BeNil())
+			Expect(stored.Name).To(Equal("Jane Doe"))
+		})
+
+		It("should return ErrUserNotFound for an unknown ID", func() {
+			user := newUser("Nobody", "nobody@example.com")
+			user.ID = 12345
+			Expect(repo.Update(ctx, user)).To(Equal(entities.ErrUserNotFound))
+
+			_, err := repo.GetByEmail(ctx, "nobody@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+
+		It("should not be affected by later changes to the updated value", func() {
+			user := create("John Doe", "john@example.com")
+			user.Name = "John Updated"
+			Expect(repo.Update(ctx, user)).To(Succeed())
+			user.Name = "Changed Outside"
+
+			stored, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(stored.Name).To(Equal("John Updated"))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("should remove the user and free its email", func() {
+			user := create("John Doe", "john@example.com")
+			Expect(repo.Delete(ctx, user.ID)).To(Succeed())
+
+			_, err := repo.GetByID(ctx, user.ID)
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+			_, err = repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+
+			replacement := create("John Again", "john@example.com")
+			Expect(replacement.ID).NotTo(Equal(user.ID))
+		})
+
+		It("should return ErrUserNotFound for an unknown ID", func() {
+			Expect(repo.Delete(ctx, 12345)).To(Equal(entities.ErrUserNotFound))
+		})
+	})
+
+	Describe("List", func() {
+		It("should order users by ID", func() {
+			for _, user := range CreateTestUsers(5) {
+				create(user.Name, user.Email)
+			}
+
+			users, err := repo.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(5))
+			for i := 1; i < len(users); i++ {
+				Expect(users[i].ID).To(BeNumerically(">", users[i-1].ID))
+			}
+		})
+
+		It("should return an empty, non-nil slice when there are no users", func() {
+			users, err := repo.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(users).NotTo(BeNil())
+			Expect(users).To(BeEmpty())
+		})
+
+		It("should return every user as a copy", func() {
+			for _, user := range CreateTestUsers(3) {
+				create(user.Name, user.Email)
+			}
+
+			users, err := repo.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(3))
+
+			for _, user := range users {
+				user.Name = "Mutated"
+			}
+			users, err = repo.List(ctx)
+			Expect(err).To(BeNil())
+			for _, user := range users {
+				Expect(user.Name).NotTo(Equal("Mutated"))
+			}
+		})
+	})
+
+	Describe("ListPage", func() {
+		base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		var ids map[string]int
+
+		BeforeEach(func() {
+			ids = make(map[string]int)
+			for _, fixture := range []struct {
+				name, email      string
+				created, updated time.Duration
+				status           entities.Status
+			}{
+				{"Carol", "carol@b.example", 3 * time.Hour, 4 * time.Hour, entities.StatusSuspended},
+				{"alice", "Alice@A.example", 1 * time.Hour, 1 * time.Hour, entities.StatusActive},
+				{"Bob", "bob@a.example", 2 * time.Hour, 2 * time.Hour, ""},
+				{"Bob", "bob2@b.example", 2 * time.Hour, 3 * time.Hour, entities.StatusSuspended},
+				{"Dave", "dave@c.example", 0, 10 * time.Hour, entities.StatusPending},
+			} {
+				user := newUser(fixture.name, fixture.email)
+				user.Created = base.Add(fixture.created)
+				user.Updated = base.Add(fixture.updated)
+				user.Status = fixture.status
+				Expect(repo.Create(ctx, user)).To(Succeed())
+				ids[fixture.email] = user.ID
+			}
+		})
+
+		idsOf := func(users []*entities.User) []int {
+			result := make([]int, 0, len(users))
+			for _, user := range users {
+				result = append(result, user.ID)
+			}
+			return result
+		}
+
+		// walk pages through opts two users at a time, following Next to the
+		// end and Prev back to the start, and returns the IDs in page order
+		walk := func(opts repository.ListOptions) []int {
+			opts.Limit = 2
+			var pages [][]int
+			var page *repository.UserPage
+			for {
+				var err error
+				page, err = repo.ListPage(ctx, opts)
+				Expect(err).To(BeNil())
+				if len(pages) == 0 {
+					Expect(page.Prev).To(BeEmpty())
+				}
+				pages = append(pages, idsOf(page.Users))
+				if page.Next == "" {
+					break
+				}
+				opts.Cursor = page.Next
+				Expect(len(pages)).To(BeNumerically("<=", 5))
+			}
+
+			for i := len(pages) - 2; i >= 0; i-- {
+				Expect(page.Prev).NotTo(BeEmpty())
+				opts.Cursor = page.Prev
+				var err error
+				page, err = repo.ListPage(ctx, opts)
+				Expect(err).To(BeNil())
+				Expect(idsOf(page.Users)).To(Equal(pages[i]))
+				Expect(page.Next).NotTo(BeEmpty())
+			}
+			Expect(page.Prev).To(BeEmpty())
+
+			all := make([]int, 0)
+			for _, ids := range pages {
+				all = append(all, ids...)
+			}
+			return all
+		}
+
+		DescribeTable("should page through users in sort order, breaking ties by ID",
+			func(sortBy repository.SortField, descending bool, emails ...string) {
+				expected := make([]int, 0, len(emails))
+				for _, email := range emails {
+					expected = append(expected, ids[email])
+				}
+				Expect(walk(repository.ListOptions{SortBy: sortBy, Descending: descending})).To(Equal(expected))
+			},
+			Entry("by ID", repository.SortByID, false,
+				"carol@b.example", "Alice@A.example", "bob@a.example", "bob2@b.example", "dave@c.example"),
+			Entry("by ID, descending", repository.SortByID, true,
+				"dave@c.example", "bob2@b.example", "bob@a.example", "Alice@A.example", "carol@b.example"),
+			Entry("by name", repository.SortByName, false,
+				"bob@a.example", "bob2@b.example", "carol@b.example", "dave@c.example", "Alice@A.example"),
+			Entry("by name, descending", repository.SortByName, true,
+				"Alice@A.example", "dave@c.example", "carol@b.example", "bob2@b.example", "bob@a.example"),
+			Entry("by canonical email", repository.SortByEmail, false,
+				"Alice@A.example", "bob2@b.example", "bob@a.example", "carol@b.example", "dave@c.example"),
+			Entry("by created", repository.SortByCreated, false,
+				"dave@c.example", "Alice@A.example", "bob@a.example", "bob2@b.example", "carol@b.example"),
+			Entry("by created, descending", repository.SortByCreated, true,
+				"carol@b.example", "bob2@b.example", "bob@a.example", "Alice@A.example", "dave@c.example"),
+			Entry("by updated", repository.SortByUpdated, false,
+				"Alice@A.example", "bob@a.example", "bob2@b.example", "carol@b.example", "dave@c.example"),
+		)
+
+		DescribeTable("should filter users",
+			func(opts repository.ListOptions, emails ...string) {
+				expected := make([]int, 0, len(emails))
+				for _, email := range emails {
+					expected = append(expected, ids[email])
+				}
+				Expect(walk(opts)).To(Equal(expected))
+			},
+			Entry("by case-sensitive name prefix", repository.ListOptions{NamePrefix: "Bo"},
+				"bob@a.example", "bob2@b.example"),
+			Entry("by email domain in any form", repository.ListOptions{EmailDomain: "@B.Example"},
+				"carol@b.example", "bob2@b.example"),
+			Entry("by created range, including the start and excluding the end",
+				repository.ListOptions{CreatedAfter: base.Add(time.Hour), CreatedBefore: base.Add(3 * time.Hour)},
+				"Alice@A.example", "bob@a.example", "bob2@b.example"),
+			Entry("by updated range", repository.ListOptions{UpdatedAfter: base.Add(3 * time.Hour), SortBy: repository.SortByUpdated},
+				"bob2@b.example", "carol@b.example", "dave@c.example"),
+			Entry("by status", repository.ListOptions{Status: entities.StatusSuspended},
+				"carol@b.example", "bob2@b.example"),
+			Entry("by active status, counting users without one", repository.ListOptions{Status: entities.StatusActive},
+				"Alice@A.example", "bob@a.example"),
+			Entry("by several filters at once", repository.ListOptions{NamePrefix: "Bob", EmailDomain: "a.example"},
+				"bob@a.example"),
+			Entry("to nothing", repository.ListOptions{NamePrefix: "Zed"}),
+		)
+
+		It("should return a single page without cursors when everything fits", func() {
+			page, err := repo.ListPage(ctx, repository.ListOptions{Limit: 10})
+			Expect(err).To(BeNil())
+			Expect(page.Users).To(HaveLen(5))
+			Expect(page.Next).To(BeEmpty())
+			Expect(page.Prev).To(BeEmpty())
+		})
+
+		It("should return users as copies", func() {
+			page, err := repo.ListPage(ctx, repository.ListOptions{Limit: 10})
+			Expect(err).To(BeNil())
+			page.Users[0].Name = "Mutated"
+
+			found, err := repo.GetByID(ctx, page.Users[0].ID)
+			Expect(err).To(BeNil())
+			Expect(found.Name).NotTo(Equal("Mutated"))
+		})
+
+		It("should hide soft-deleted users unless the context includes them", func() {
+			deleted, err := repo.GetByEmail(ctx, "bob@a.example")
+			Expect(err).To(BeNil())
+			deletedAt := time.Now()
+			deleted.DeletedAt = &deletedAt
+			Expect(repo.Update(ctx, deleted)).To(Succeed())
+
+			page, err := repo.ListPage(ctx, repository.ListOptions{Limit: 10, NamePrefix: "Bob"})
+			Expect(err).To(BeNil())
+			Expect(idsOf(page.Users)).To(Equal([]int{ids["bob2@b.example"]}))
+
+			page, err = repo.ListPage(repository.IncludeDeleted(ctx), repository.ListOptions{Limit: 10, NamePrefix: "Bob"})
+			Expect(err).To(BeNil())
+			Expect(page.Users).To(HaveLen(2))
+		})
+
+		It("should keep paging from a cursor after the user it points at is deleted", func() {
+			first, err := repo.ListPage(ctx, repository.ListOptions{Limit: 2})
+			Expect(err).To(BeNil())
+			Expect(repo.Delete(ctx, first.Users[1].ID)).To(Succeed())
+
+			next, err := repo.ListPage(ctx, repository.ListOptions{Limit: 2, Cursor: first.Next})
+			Expect(err).To(BeNil())
+			Expect(idsOf(next.Users)).To(Equal([]int{ids["bob@a.example"], ids["bob2@b.example"]}))
+		})
+
+		It("should reject a malformed cursor", func() {
+			_, err := repo.ListPage(ctx, repository.ListOptions{Limit: 2, Cursor: "not a cursor"})
+			Expect(err).To(Equal(entities.ErrInvalidCursor))
+		})
+
+		It("should reject a cursor issued for another sort order", func() {
+			page, err := repo.ListPage(ctx, repository.ListOptions{Limit: 2, SortBy: repository.SortByName})
+			Expect(err).To(BeNil())
+
+			_, err = repo.ListPage(ctx, repository.ListOptions{Limit: 2, SortBy: repository.SortByEmail, Cursor: page.Next})
+			Expect(err).To(Equal(entities.ErrInvalidCursor))
+			_, err = repo.ListPage(ctx, repository.ListOptions{Limit: 2, SortBy: repository.SortByName, Descending: true, Cursor: page.Next})
+			Expect(err).To(Equal(entities.ErrInvalidCursor))
+		})
+
+		It("should reject invalid options", func() {
+			_, err := repo.ListPage(ctx, repository.ListOptions{})
+			Expect(err).To(Equal(entities.ErrInvalidPagination))
+			_, err = repo.ListPage(ctx, repository.ListOptions{Limit: 2, SortBy: "password"})
+			Expect(err).To(Equal(entities.ErrInvalidSortField))
+			_, err = repo.ListPage(ctx, repository.ListOptions{Limit: 2, Status: "banned"})
+			Expect(err).To(Equal(entities.ErrInvalidStatus))
+		})
+	})
+
+	Describe("Email normalization", func() {
+		It("should treat emails differing in domain case, whitespace or domain encoding as duplicates", func() {
+			create("John Doe", "john@bücher.example")
+
+			for _, email := range []string{"john@Bücher.Example", " john@bücher.example ", "john@xn--bcher-kva.example"} {
+				err := repo.Create(ctx, newUser("Other", email))
+				Expect(err).To(Equal(entities.ErrUserAlreadyExists), email)
+			}
+		})
+
+		It("should keep emails whose local parts differ in case apart", func() {
+			john := create("John Doe", "john@example.com")
+			other := create("Other", "JOHN@example.com")
+			Expect(other.ID).NotTo(Equal(john.ID))
+
+			found, err := repo.GetByEmail(ctx, "JOHN@example.com")
+			Expect(err).To(BeNil())
+			Expect(found.ID).To(Equal(other.ID))
+		})
+
+		It("should find a user by any form of its email and keep the address as typed", func() {
+			user := create("John Doe", "John.Doe@Example.com")
+
+			for _, email := range []string{"John.Doe@example.com", " John.Doe@EXAMPLE.COM "} {
+				found, err := repo.GetByEmail(ctx, email)
+				Expect(err).To(BeNil(), email)
+				Expect(found.ID).To(Equal(user.ID))
+				Expect(found.Email).To(Equal("John.Doe@Example.com"))
+			}
+		})
+
+		It("should reject an update to another user's email with a differently cased domain", func() {
+			create("John Doe", "john@example.com")
+			jane := create("Jane Doe", "jane@example.com")
+
+			jane.Email = "john@Example.com"
+			Expect(repo.Update(ctx, jane)).To(Equal(entities.ErrUserAlreadyExists))
+		})
+
+		It("should allow changing only the case of the user's own email", func() {
+			user := create("John Doe", "john@example.com")
+
+			user.Email = "John@Example.com"
+			Expect(repo.Update(ctx, user)).To(Succeed())
+
+			found, err := repo.GetByEmail(ctx, "John@example.com")
+			Expect(err).To(BeNil())
+			Expect(found.Email).To(Equal("John@Example.com"))
+		})
+	})
+
+	Describe("Soft delete", func() {
+		var deleted *entities.User
+
+		BeforeEach(func() {
+			deleted = create("John Doe", "john@example.com")
+			create("Jane Doe", "jane@example.com")
+
+			deletedAt := time.Now().UTC().Truncate(time.Second)
+			deleted.DeletedAt = &deletedAt
+			Expect(repo.Update(ctx, deleted)).To(Succeed())
+		})
+
+		It("should hide soft-deleted users from reads", func() {
+			_, err := repo.GetByID(ctx, deleted.ID)
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+			_, err = repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+
+			users, err := repo.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(1))
+			Expect(users[0].Email).To(Equal("jane@example.com"))
+		})
+
+		It("should return soft-deleted users when the context includes them", func() {
+			included := repository.IncludeDeleted(ctx)
+
+			found, err := repo.GetByID(included, deleted.ID)
+			Expect(err).To(BeNil())
+			Expect(found.DeletedAt).NotTo(BeNil())
+			Expect(found.DeletedAt.Equal(*deleted.DeletedAt)).To(BeTrue())
+
+			_, err = repo.GetByEmail(included, "john@example.com")
+			Expect(err).To(BeNil())
+
+			users, err := repo.List(included)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(2))
+		})
+
+		It("should keep the email of a soft-deleted user reserved", func() {
+			err := repo.Create(ctx, newUser("John Again", "john@example.com"))
+			Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+		})
+
+		It("should restore a user when DeletedAt is cleared", func() {
+			deleted.DeletedAt = nil
+			Expect(repo.Update(ctx, deleted)).To(Succeed())
+
+			found, err := repo.GetByID(ctx, deleted.ID)
+			Expect(err).To(BeNil())
+			Expect(found.DeletedAt).To(BeNil())
+		})
+
+		It("should free the email once the user is permanently deleted", func() {
+			Expect(repo.Delete(ctx, deleted.ID)).To(Succeed())
+
+			replacement := create("John Again", "john@example.com")
+			Expect(replacement.ID).NotTo(Equal(deleted.ID))
+		})
+	})
+
+	Describe("Tenants", func() {
+		var (
+			acme, globex     context.Context
+			inAcme, inGlobex *entities.User
+		)
+
+		BeforeEach(func() {
+			acme = repository.WithTenant(ctx, "acme")
+			globex = repository.WithTenant(ctx, "globex")
+
+			inAcme = newUser("John Doe", "john@example.com")
+			Expect(repo.Create(acme, inAcme)).To(Succeed())
+			inGlobex = newUser("John Smith", "john@example.com")
+			Expect(repo.Create(globex, inGlobex)).To(Succeed())
+		})
+
+		It("should create users in the tenant of the context", func() {
+			Expect(inAcme.TenantID).To(Equal("acme"))
+
+			found, err := repo.GetByID(globex, inGlobex.ID)
+			Expect(err).To(BeNil())
+			Expect(found.TenantID).To(Equal("globex"))
+
+			user := create("Jane Doe", "jane@example.com")
+			Expect(user.TenantID).To(Equal(entities.DefaultTenant))
+		})
+
+		It("should only keep emails unique within a tenant", func() {
+			Expect(inGlobex.ID).NotTo(Equal(inAcme.ID))
+
+			err := repo.Create(acme, newUser("Other", "john@EXAMPLE.com"))
+			Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+		})
+
+		It("should find users by email in the tenant of the context only", func() {
+			found, err := repo.GetByEmail(acme, "john@example.com")
+			Expect(err).To(BeNil())
+			Expect(found.ID).To(Equal(inAcme.ID))
+
+			found, err = repo.GetByEmail(globex, "john@example.com")
+			Expect(err).To(BeNil())
+			Expect(found.ID).To(Equal(inGlobex.ID))
+
+			_, err = repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+
+		It("should hide the users of other tenants from reads", func() {
+			_, err := repo.GetByID(acme, inGlobex.ID)
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+			_, err = repo.GetByID(repository.IncludeDeleted(acme), inGlobex.ID)
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+
+			users, err := repo.List(acme)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(1))
+			Expect(users[0].ID).To(Equal(inAcme.ID))
+
+			page, err := repo.ListPage(globex, repository.ListOptions{Limit: 10})
+			Expect(err).To(BeNil())
+			Expect(page.Users).To(HaveLen(1))
+			Expect(page.Users[0].ID).To(Equal(inGlobex.ID))
+		})
+
+		It("should refuse to change or delete the users of other tenants", func() {
+			stolen := *inGlobex
+			stolen.Name = "Stolen"
+			Expect(repo.Update(acme, &stolen)).To(Equal(entities.ErrUserNotFound))
+			Expect(repo.Delete(acme, inGlobex.ID)).To(Equal(entities.ErrUserNotFound))
+
+			found, err := repo.GetByID(globex, inGlobex.ID)
+			Expect(err).To(BeNil())
+			Expect(found.Name).To(Equal("John Smith"))
+		})
+
+		It("should keep the tenant of a user on update", func() {
+			inAcme.Name = "John Updated"
+			inAcme.TenantID = "globex"
+			Expect(repo.Update(acme, inAcme)).To(Succeed())
+
+			found, err := repo.GetByID(acme, inAcme.ID)
+			Expect(err).To(BeNil())
+			Expect(found.Name).To(Equal("John Updated"))
+			Expect(found.TenantID).To(Equal("acme"))
+		})
+
+		It("should reach every tenant when the context includes them all", func() {
+			all := repository.IncludeAllTenants(ctx)
+
+			users, err := repo.List(all)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(2))
+
+			found, err := repo.GetByID(all, inGlobex.ID)
+			Expect(err).To(BeNil())
+			Expect(found.TenantID).To(Equal("globex"))
+
+			Expect(repo.Delete(all, inAcme.ID)).To(Succeed())
+			_, err = repo.GetByID(acme, inAcme.ID)
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+	})
+
+	Describe("Concurrent access", func() {
+		const workers = 20
+
+		It("should assign distinct IDs to concurrent creates", func() {
+			var wg sync.WaitGroup
+			ids := make(chan int, workers)
+
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func(index int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					user := newUser(fmt.Sprintf("User %d", index), fmt.Sprintf("user%d@example.com", index))
+					Expect(repo.Create(ctx, user)).To(Succeed())
+					ids <- user.ID
+				}(i)
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := make(map[int]bool)
+			for id := range ids {
+				Expect(seen).NotTo(HaveKey(id))
+				seen[id] = true
+			}
+			Expect(seen).To(HaveLen(workers))
+		})
+
+		It("should let exactly one of several concurrent creates claim an email", func() {
+			var wg sync.WaitGroup
+			results := make(chan error, workers)
+
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func(index int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					results <- repo.Create(ctx, newUser(fmt.Sprintf("User %d", index), "contended@example.com"))
+				}(i)
+			}
+			wg.Wait()
+			close(results)
+
+			succeeded := 0
+			for err := range results {
+				if err == nil {
+					succeeded++
+					continue
+				}
+				Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+			}
+			Expect(succeeded).To(Equal(1))
+		})
+
+		It("should stay consistent under concurrent reads and writes", func() {
+			user := create("John Doe", "john@example.com")
+			var (
+				wg        sync.WaitGroup
+				succeeded = make(chan struct{}, workers)
+			)
+
+			for i := 0; i < workers; i++ {
+				wg.Add(2)
+				go func(index int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					update, err := repo.GetByID(ctx, user.ID)
+					Expect(err).To(BeNil())
+					update.Name = fmt.Sprintf("Name %d", index)
+
+					err = repo.Update(ctx, update)
+					if err == nil {
+						succeeded <- struct{}{}
+						return
+					}
+					Expect(err).To(Equal(entities.ErrVersionConflict))
+				}(i)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					stored, err := repo.GetByEmail(ctx, "john@example.com")
+					Expect(err).To(BeNil())
+					Expect(stored.ID).To(Equal(user.ID))
+					_, err = repo.List(ctx)
+					Expect(err).To(BeNil())
+				}()
+			}
+			wg.Wait()
+			close(succeeded)
+
+			users, err := repo.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(1))
+
+			// Every successful update bumped the version exactly once
+			Expect(succeeded).NotTo(BeEmpty())
+			Expect(users[0].Version).To(Equal(1 + len(succeeded)))
+		})
+	})
+}
@@ -5,13 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/metrics"
 	httphandler "agent-orchestration/interfaces/http"
 )
 
@@ -20,6 +24,17 @@ const (
 	timeout   = 30 * time.Second
 )
 
+// emailSeq makes fixture emails unique across specs. Deleted users keep
+// their email reserved until they are purged, so cleaned up fixtures cannot
+// give their address to the next spec.
+var emailSeq int
+
+// uniqueEmail returns an email address that no earlier spec has used
+func uniqueEmail(prefix string) string {
+	emailSeq++
+	return fmt.Sprintf("%s-%d@example.com", prefix, emailSeq)
+}
+
 var _ = Describe("User E2E Tests", Ordered, func() {
 	var (
 		serverCmd *exec.Cmd
@@ -31,9 +46,30 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 			Timeout: 10 * time.Second,
 		}
 
+		// Build the server binary so that killing the process stops the
+		// server itself rather than only the go tool wrapping it
+		binary := filepath.Join(GinkgoT().TempDir(), "server")
+		build := exec.Command("go", "build", "-o", binary, "../../cmd/server")
+		build.Stdout = GinkgoWriter
+		build.Stderr = GinkgoWriter
+		Expect(build.Run()).To(Succeed())
+
+		// Stored emails are encrypted with a keyring of their own
+		keyring := filepath.Join(GinkgoT().TempDir(), "keyring.json")
+		initKeyring := exec.Command(binary, "keyring", "-email-keyring", keyring, "init")
+		initKeyring.Stdout = GinkgoWriter
+		initKeyring.Stderr = GinkgoWriter
+		Expect(initKeyring.Run()).To(Succeed())
+
 		// Start the server
-		serverCmd = exec.Command("go", "run", "../../cmd/server/main.go")
+		serverCmd = exec.Command(binary)
 		serverCmd.Dir = "."
+		serverCmd.Env = append(os.Environ(),
+			"BACKUP_DIR="+GinkgoT().TempDir(),
+			"EMAIL_KEYRING="+keyring,
+			"RETENTION_MONTHS=12",
+			"CHAOS_ENABLED=true",
+		)
 		serverCmd.Stdout = GinkgoWriter
 		serverCmd.Stderr = GinkgoWriter
 		
@@ -77,6 +113,29 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 		})
 	})
 
+	Describe("Store Metrics", func() {
+		It("should publish the latency and errors of user store calls", func() {
+			resp, err := httpClient.Get(serverURL + "/users/999999")
+			Expect(err).To(BeNil())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+			varsResp, err := httpClient.Get(serverURL + "/debug/vars")
+			Expect(err).To(BeNil())
+			defer varsResp.Body.Close()
+			Expect(varsResp.StatusCode).To(Equal(http.StatusOK))
+
+			var vars struct {
+				UserStore metrics.Stats `json:"user_store"`
+			}
+			Expect(json.NewDecoder(varsResp.Body).Decode(&vars)).To(Succeed())
+			getByID := vars.UserStore[metrics.OperationGetByID]
+			Expect(getByID.Calls).To(BeNumerically(">=", 1))
+			Expect(getByID.Errors.NotFound).To(BeNumerically(">=", 1))
+			Expect(getByID.Latency.Count).To(Equal(getByID.Calls))
+		})
+	})
+
 	Describe("User API E2E", func() {
 		var createdUserIDs []int
 
@@ -123,11 +182,11 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 				body, _ := json.Marshal(createReq)
 				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
 				Expect(err).To(BeNil())
-				resp.Body.Close()
 				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
 
 				var firstUser entities.User
 				json.NewDecoder(resp.Body).Decode(&firstUser)
+				resp.Body.Close()
 				createdUserIDs = append(createdUserIDs, firstUser.ID)
 
 				// Try to create second user with same email
@@ -153,7 +212,7 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 				// Create a test user
 				createReq := httphandler.CreateUserRequest{
 					Name:  "Get Test User",
-					Email: "gettest@example.com",
+					Email: uniqueEmail("gettest"),
 				}
 
 				body, _ := json.Marshal(createReq)
@@ -197,11 +256,547 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 
 				Expect(resp.StatusCode).To(Equal(http.StatusOK))
 
-				var users []*entities.User
-				err = json.NewDecoder(resp.Body).Decode(&users)
+				var page httphandler.ListUsersResponse
+				err = json.NewDecoder(resp.Body).Decode(&page)
+				Expect(err).To(BeNil())
+				Expect(page.Users).To(HaveLen(1))
+				Expect(page.Users[0].ID).To(Equal(testUser.ID))
+			})
+
+			It("should page through filtered users with cursors", func() {
+				prefix := fmt.Sprintf("Pager %d", time.Now().UnixNano())
+				for _, suffix := range []string{"C", "A", "B"} {
+					body, _ := json.Marshal(httphandler.CreateUserRequest{
+						Name:  prefix + " " + suffix,
+						Email: uniqueEmail("pager"),
+					})
+					resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+					Expect(err).To(BeNil())
+					var user entities.User
+					Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+					resp.Body.Close()
+					createdUserIDs = append(createdUserIDs, user.ID)
+				}
+
+				getPage := func(query url.Values) httphandler.ListUsersResponse {
+					resp, err := httpClient.Get(serverURL + "/users?" + query.Encode())
+					Expect(err).To(BeNil())
+					defer resp.Body.Close()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+					var page httphandler.ListUsersResponse
+					Expect(json.NewDecoder(resp.Body).Decode(&page)).To(Succeed())
+					return page
+				}
+
+				query := url.Values{"name_prefix": {prefix}, "sort": {"name"}, "limit": {"2"}}
+				first := getPage(query)
+				Expect(first.Users).To(HaveLen(2))
+				Expect(first.Users[0].Name).To(Equal(prefix + " A"))
+				Expect(first.Users[1].Name).To(Equal(prefix + " B"))
+				Expect(first.Prev).To(BeEmpty())
+				Expect(first.Next).NotTo(BeEmpty())
+
+				query.Set("cursor", first.Next)
+				second := getPage(query)
+				Expect(second.Users).To(HaveLen(1))
+				Expect(second.Users[0].Name).To(Equal(prefix + " C"))
+				Expect(second.Next).To(BeEmpty())
+
+				query.Set("cursor", second.Prev)
+				Expect(getPage(query).Users).To(Equal(first.Users))
+			})
+		})
+
+		Context("when searching users", func() {
+			It("should find a user by a misspelled name and stop once it is deleted", func() {
+				body, _ := json.Marshal(httphandler.CreateUserRequest{
+					Name:  "Bartholomew Searchable",
+					Email: uniqueEmail("searchable"),
+				})
+				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				var user entities.User
+				Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+				resp.Body.Close()
+				createdUserIDs = append(createdUserIDs, user.ID)
+
+				search := func() httphandler.SearchResponse {
+					resp, err := httpClient.Get(serverURL + "/users/search?q=" + url.QueryEscape("bartholomew serchable") + "&limit=5")
+					Expect(err).To(BeNil())
+					defer resp.Body.Close()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+					var response httphandler.SearchResponse
+					Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+					return response
+				}
+
+				found := search()
+				Expect(found.Total).To(Equal(1))
+				Expect(found.Results[0].User.ID).To(Equal(user.ID))
+
+				req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/users/%d", serverURL, user.ID), nil)
+				resp, err = httpClient.Do(req)
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+
+				Expect(search().Results).To(BeEmpty())
+			})
+
+			It("should require a query", func() {
+				resp, err := httpClient.Get(serverURL + "/users/search")
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when exporting and importing users", func() {
+			It("should import users from CSV after a dry run and export them again", func() {
+				email := uniqueEmail("imported")
+				csv := "name,email\nImported User," + email + "\nImported Twin," + email + "\n"
+
+				importUsers := func(dryRun bool) httphandler.ImportResponse {
+					target := fmt.Sprintf("%s/users/import?format=csv&dry_run=%t", serverURL, dryRun)
+					resp, err := httpClient.Post(target, "text/csv", bytes.NewBufferString(csv))
+					Expect(err).To(BeNil())
+					defer resp.Body.Close()
+					Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+					var report httphandler.ImportResponse
+					Expect(json.NewDecoder(resp.Body).Decode(&report)).To(Succeed())
+					return report
+				}
+
+				report := importUsers(true)
+				Expect(report.Created).To(Equal(1))
+				Expect(report.Duplicates).To(Equal(1))
+				Expect(report.Rows[0].ID).To(BeZero())
+
+				report = importUsers(false)
+				Expect(report.Created).To(Equal(1))
+				Expect(report.Rows[0].ID).NotTo(BeZero())
+				createdUserIDs = append(createdUserIDs, report.Rows[0].ID)
+
+				resp, err := httpClient.Get(serverURL + "/users/export?format=jsonl")
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				exported := make(map[int]entities.User)
+				decoder := json.NewDecoder(resp.Body)
+				for decoder.More() {
+					var user entities.User
+					Expect(decoder.Decode(&user)).To(Succeed())
+					exported[user.ID] = user
+				}
+				Expect(exported).To(HaveKey(report.Rows[0].ID))
+				Expect(exported[report.Rows[0].ID].Email).To(Equal(email))
+			})
+		})
+
+		Context("when backing up and restoring users", func() {
+			It("should restore a user deleted after the backup", func() {
+				userData := map[string]string{
+					"name":  "Backed Up User",
+					"email": uniqueEmail("backedup"),
+				}
+				jsonData, _ := json.Marshal(userData)
+				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewBuffer(jsonData))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+				var user entities.User
+				Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+				createdUserIDs = append(createdUserIDs, user.ID)
+
+				backupResp, err := httpClient.Post(serverURL+"/admin/backups", "application/json", nil)
+				Expect(err).To(BeNil())
+				defer backupResp.Body.Close()
+				Expect(backupResp.StatusCode).To(Equal(http.StatusCreated))
+
+				var backup httphandler.BackupResponse
+				Expect(json.NewDecoder(backupResp.Body).Decode(&backup)).To(Succeed())
+				Expect(backup.Users).To(BeNumerically(">=", 1))
+
+				req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/users/%d", serverURL, user.ID), nil)
+				deleteResp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				deleteResp.Body.Close()
+				Expect(deleteResp.StatusCode).To(Equal(http.StatusNoContent))
+
+				restoreResp, err := httpClient.Post(fmt.Sprintf("%s/admin/backups/%s/restore", serverURL, backup.Name), "application/json", nil)
+				Expect(err).To(BeNil())
+				defer restoreResp.Body.Close()
+				Expect(restoreResp.StatusCode).To(Equal(http.StatusOK))
+
+				getResp, err := httpClient.Get(fmt.Sprintf("%s/users/%d", serverURL, user.ID))
+				Expect(err).To(BeNil())
+				defer getResp.Body.Close()
+				Expect(getResp.StatusCode).To(Equal(http.StatusOK))
+
+				listResp, err := httpClient.Get(serverURL + "/admin/backups")
+				Expect(err).To(BeNil())
+				defer listResp.Body.Close()
+				var backups httphandler.ListBackupsResponse
+				Expect(json.NewDecoder(listResp.Body).Decode(&backups)).To(Succeed())
+				Expect(backups.Backups[0].Name).To(Equal(backup.Name))
+			})
+
+			It("should not restore a backup that doesn't exist", func() {
+				resp, err := httpClient.Post(serverURL+"/admin/backups/users-20000101T000000.000000000Z.backup/restore", "application/json", nil)
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("when applying the retention policy", func() {
+			It("should place a user on legal hold and report the policy without enforcing it", func() {
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: "Held User", Email: uniqueEmail("held")})
+				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+				var user entities.User
+				Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+				createdUserIDs = append(createdUserIDs, user.ID)
+
+				req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/users/%d/legal-hold", serverURL, user.ID), nil)
+				holdResp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				defer holdResp.Body.Close()
+				Expect(holdResp.StatusCode).To(Equal(http.StatusOK))
+				var held entities.User
+				Expect(json.NewDecoder(holdResp.Body).Decode(&held)).To(Succeed())
+				Expect(held.LegalHold).To(BeTrue())
+				Expect(held.Updated).To(Equal(user.Updated))
+
+				reportResp, err := httpClient.Get(serverURL + "/admin/retention")
+				Expect(err).To(BeNil())
+				defer reportResp.Body.Close()
+				Expect(reportResp.StatusCode).To(Equal(http.StatusOK))
+				var report httphandler.RetentionResponse
+				Expect(json.NewDecoder(reportResp.Body).Decode(&report)).To(Succeed())
+				Expect(report.DryRun).To(BeTrue())
+				Expect(report.Cutoff).To(BeTemporally("~", time.Now().AddDate(-1, 0, 0), time.Minute))
+
+				// Freshly created users aren't inactive
+				for _, row := range report.Users {
+					Expect(row.ID).NotTo(Equal(user.ID))
+				}
+
+				req, _ = http.NewRequest("DELETE", fmt.Sprintf("%s/users/%d/legal-hold", serverURL, user.ID), nil)
+				releaseResp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				defer releaseResp.Body.Close()
+				Expect(releaseResp.StatusCode).To(Equal(http.StatusOK))
+				var released entities.User
+				Expect(json.NewDecoder(releaseResp.Body).Decode(&released)).To(Succeed())
+				Expect(released.LegalHold).To(BeFalse())
+			})
+		})
+
+		Context("when changing the status of users", func() {
+			It("should move a user through its lifecycle and filter by status", func() {
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: "Lifecycle User", Email: uniqueEmail("lifecycle")})
+				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+				var user entities.User
+				Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+				createdUserIDs = append(createdUserIDs, user.ID)
+				Expect(user.Status).To(Equal(entities.StatusPending))
+
+				changeStatus := func(action string) *http.Response {
+					body, _ := json.Marshal(httphandler.StatusChangeRequest{Reason: "e2e " + action})
+					resp, err := httpClient.Post(fmt.Sprintf("%s/users/%d/%s", serverURL, user.ID, action), "application/json", bytes.NewReader(body))
+					Expect(err).To(BeNil())
+					DeferCleanup(resp.Body.Close)
+					return resp
+				}
+
+				for _, action := range []string{"activate", "suspend"} {
+					Expect(changeStatus(action).StatusCode).To(Equal(http.StatusOK))
+				}
+
+				listResp, err := httpClient.Get(serverURL + "/users?status=suspended&limit=100")
+				Expect(err).To(BeNil())
+				defer listResp.Body.Close()
+				Expect(listResp.StatusCode).To(Equal(http.StatusOK))
+				var page httphandler.ListUsersResponse
+				Expect(json.NewDecoder(listResp.Body).Decode(&page)).To(Succeed())
+				Expect(page.Users).To(ContainElement(HaveField("ID", user.ID)))
+				for _, listed := range page.Users {
+					Expect(listed.Status).To(Equal(entities.StatusSuspended))
+				}
+
+				deactivateResp := changeStatus("deactivate")
+				Expect(deactivateResp.StatusCode).To(Equal(http.StatusOK))
+				var deactivated entities.User
+				Expect(json.NewDecoder(deactivateResp.Body).Decode(&deactivated)).To(Succeed())
+				Expect(deactivated.Status).To(Equal(entities.StatusDeactivated))
+				Expect(deactivated.StatusReason).To(Equal("e2e deactivate"))
+
+				// A deactivated user cannot be suspended
+				Expect(changeStatus("suspend").StatusCode).To(Equal(http.StatusConflict))
+
+				historyResp, err := httpClient.Get(fmt.Sprintf("%s/users/%d/history", serverURL, user.ID))
+				Expect(err).To(BeNil())
+				defer historyResp.Body.Close()
+				Expect(historyResp.StatusCode).To(Equal(http.StatusOK))
+				var history httphandler.HistoryResponse
+				Expect(json.NewDecoder(historyResp.Body).Decode(&history)).To(Succeed())
+				operations := make([]string, 0, len(history.Entries))
+				for _, entry := range history.Entries {
+					operations = append(operations, entry.Operation)
+				}
+				Expect(operations).To(Equal([]string{
+					entities.OperationCreated,
+					entities.OperationActivated,
+					entities.OperationSuspended,
+					entities.OperationDeactivated,
+				}))
+			})
+		})
+
+		Context("when assigning roles", func() {
+			It("should assign and revoke a built-in role", func() {
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: "Role User", Email: uniqueEmail("roles")})
+				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+				var user entities.User
+				Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+				createdUserIDs = append(createdUserIDs, user.ID)
+
+				changeRole := func(method, role string) *http.Response {
+					req, err := http.NewRequest(method, fmt.Sprintf("%s/users/%d/roles/%s", serverURL, user.ID, role), nil)
+					Expect(err).To(BeNil())
+					resp, err := httpClient.Do(req)
+					Expect(err).To(BeNil())
+					DeferCleanup(resp.Body.Close)
+					return resp
+				}
+
+				assignResp := changeRole(http.MethodPut, entities.RoleSupport)
+				Expect(assignResp.StatusCode).To(Equal(http.StatusOK))
+				var assigned entities.User
+				Expect(json.NewDecoder(assignResp.Body).Decode(&assigned)).To(Succeed())
+				Expect(assigned.Roles).To(Equal([]string{entities.RoleSupport}))
+
+				Expect(changeRole(http.MethodPut, "root").StatusCode).To(Equal(http.StatusNotFound))
+
+				revokeResp := changeRole(http.MethodDelete, entities.RoleSupport)
+				Expect(revokeResp.StatusCode).To(Equal(http.StatusOK))
+				var revoked entities.User
+				Expect(json.NewDecoder(revokeResp.Body).Decode(&revoked)).To(Succeed())
+				Expect(revoked.Roles).To(BeEmpty())
+
+				rolesResp, err := httpClient.Get(serverURL + "/roles")
+				Expect(err).To(BeNil())
+				defer rolesResp.Body.Close()
+				Expect(rolesResp.StatusCode).To(Equal(http.StatusOK))
+				var roles []entities.Role
+				Expect(json.NewDecoder(rolesResp.Body).Decode(&roles)).To(Succeed())
+				Expect(roles).To(HaveLen(3))
+			})
+		})
+
+		Context("when serving several tenants", func() {
+			// inTenant sends a request acting in tenant
+			inTenant := func(tenant, method, path string, body []byte) *http.Response {
+				req, err := http.NewRequest(method, serverURL+path, bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set(httphandler.TenantHeader, tenant)
+				resp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				DeferCleanup(resp.Body.Close)
+				return resp
+			}
+
+			It("should keep the users of one tenant out of reach of another", func() {
+				email := uniqueEmail("tenant")
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: "Tenant User", Email: email})
+
+				ids := map[string]int{}
+				for _, tenant := range []string{"e2e-acme", "e2e-globex"} {
+					resp := inTenant(tenant, http.MethodPost, "/users", body)
+					Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+					var user entities.User
+					Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+					Expect(user.TenantID).To(Equal(tenant))
+					ids[tenant] = user.ID
+					DeferCleanup(func() {
+						req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/%d", serverURL, user.ID), nil)
+						req.Header.Set(httphandler.TenantHeader, tenant)
+						if resp, err := httpClient.Do(req); err == nil {
+							resp.Body.Close()
+						}
+					})
+				}
+				Expect(inTenant("e2e-acme", http.MethodPost, "/users", body).StatusCode).To(Equal(http.StatusConflict))
+
+				own := inTenant("e2e-acme", http.MethodGet, fmt.Sprintf("/users/%d", ids["e2e-acme"]), nil)
+				Expect(own.StatusCode).To(Equal(http.StatusOK))
+				other := inTenant("e2e-acme", http.MethodGet, fmt.Sprintf("/users/%d", ids["e2e-globex"]), nil)
+				Expect(other.StatusCode).To(Equal(http.StatusNotFound))
+				deleted := inTenant("e2e-acme", http.MethodDelete, fmt.Sprintf("/users/%d", ids["e2e-globex"]), nil)
+				Expect(deleted.StatusCode).To(Equal(http.StatusNotFound))
+
+				resp, err := httpClient.Get(fmt.Sprintf("%s/users/%d", serverURL, ids["e2e-acme"]))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+				Expect(inTenant("Not A Tenant", http.MethodGet, "/users", nil).StatusCode).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when watching user changes", func() {
+			It("should stream a change and resume after it", func() {
+				watchResp, err := httpClient.Get(serverURL + "/users/changes")
+				Expect(err).To(BeNil())
+				defer watchResp.Body.Close()
+				Expect(watchResp.StatusCode).To(Equal(http.StatusOK))
+				Expect(watchResp.Header.Get("Content-Type")).To(Equal("application/x-ndjson"))
+
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: "Watched User", Email: uniqueEmail("watched")})
+				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+				var user entities.User
+				Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+				createdUserIDs = append(createdUserIDs, user.ID)
+
+				var created httphandler.ChangeResponse
+				Expect(json.NewDecoder(watchResp.Body).Decode(&created)).To(Succeed())
+				Expect(created.Operation).To(Equal("created"))
+				Expect(created.User.ID).To(Equal(user.ID))
+				Expect(created.User.Email).To(Equal(user.Email))
+
+				req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/users/%d", serverURL, user.ID), nil)
+				deleteResp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				deleteResp.Body.Close()
+				Expect(deleteResp.StatusCode).To(Equal(http.StatusNoContent))
+
+				resumeResp, err := httpClient.Get(fmt.Sprintf("%s/users/changes?from=%d", serverURL, created.Seq))
+				Expect(err).To(BeNil())
+				defer resumeResp.Body.Close()
+				Expect(resumeResp.StatusCode).To(Equal(http.StatusOK))
+				var deleted httphandler.ChangeResponse
+				Expect(json.NewDecoder(resumeResp.Body).Decode(&deleted)).To(Succeed())
+				Expect(deleted.Operation).To(Equal("deleted"))
+				Expect(deleted.User.ID).To(Equal(user.ID))
+				Expect(deleted.Seq).To(BeNumerically(">", created.Seq))
+			})
+
+			It("should refuse to resume from a change no longer retained", func() {
+				resp, err := httpClient.Get(serverURL + "/users/changes?from=0")
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusGone))
+			})
+		})
+
+		Context("when the user store misbehaves", func() {
+			var user entities.User
+
+			// setRules replaces the fault injection rules, returning the response
+			setRules := func(rules string) *http.Response {
+				req, _ := http.NewRequest("PUT", serverURL+"/admin/chaos", bytes.NewBufferString(rules))
+				req.Header.Set("Content-Type", "application/json")
+				resp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				return resp
+			}
+
+			BeforeEach(func() {
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: "Fragile User", Email: uniqueEmail("fragile")})
+				resp, err := httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+				Expect(json.NewDecoder(resp.Body).Decode(&user)).To(Succeed())
+				createdUserIDs = append(createdUserIDs, user.ID)
+			})
+
+			// Runs before the users are cleaned up, so they can be deleted
+			AfterEach(func() {
+				req, _ := http.NewRequest("DELETE", serverURL+"/admin/chaos", nil)
+				resp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+
+			It("should fail the lookups of the selected users until the rules are removed", func() {
+				resp := setRules(fmt.Sprintf(`{"rules": [{"operation": "get_by_id", "min_id": %d, "max_id": %d, "rate": 1, "fault": "error"}]}`, user.ID, user.ID))
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				getResp, err := httpClient.Get(fmt.Sprintf("%s/users/%d", serverURL, user.ID))
+				Expect(err).To(BeNil())
+				getResp.Body.Close()
+				Expect(getResp.StatusCode).To(Equal(http.StatusInternalServerError))
+
+				req, _ := http.NewRequest("DELETE", serverURL+"/admin/chaos", nil)
+				clearResp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				clearResp.Body.Close()
+
+				getResp, err = httpClient.Get(fmt.Sprintf("%s/users/%d", serverURL, user.ID))
+				Expect(err).To(BeNil())
+				getResp.Body.Close()
+				Expect(getResp.StatusCode).To(Equal(http.StatusOK))
+			})
+
+			It("should roll back an update whose answer was lost", func() {
+				resp := setRules(fmt.Sprintf(`{"rules": [{"operation": "update", "min_id": %d, "rate": 1, "latency": "10ms", "fault": "partial"}]}`, user.ID))
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				var rules struct {
+					Rules []map[string]any `json:"rules"`
+				}
+				Expect(json.NewDecoder(resp.Body).Decode(&rules)).To(Succeed())
+				Expect(rules.Rules).To(HaveLen(1))
+				Expect(rules.Rules[0]["latency"]).To(Equal("10ms"))
+
+				body, _ := json.Marshal(httphandler.UpdateUserRequest{Name: "Changed User"})
+				req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/users/%d", serverURL, user.ID), bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				updateResp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				updateResp.Body.Close()
+				Expect(updateResp.StatusCode).To(Equal(http.StatusInternalServerError))
+
+				getResp, err := httpClient.Get(fmt.Sprintf("%s/users/%d", serverURL, user.ID))
 				Expect(err).To(BeNil())
-				Expect(users).To(HaveLen(1))
-				Expect(users[0].ID).To(Equal(testUser.ID))
+				defer getResp.Body.Close()
+				var stored entities.User
+				Expect(json.NewDecoder(getResp.Body).Decode(&stored)).To(Succeed())
+				Expect(stored.Name).To(Equal("Fragile User"))
+			})
+
+			It("should reject invalid rules", func() {
+				resp := setRules(`{"rules": [{"operation": "update", "rate": 2, "fault": "error"}]}`)
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+				var body map[string]string
+				Expect(json.NewDecoder(resp.Body).Decode(&body)).To(Succeed())
+				Expect(body["error"]).To(ContainSubstring("invalid rate"))
 			})
 		})
 
@@ -212,7 +807,7 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 				// Create a test user
 				createReq := httphandler.CreateUserRequest{
 					Name:  "Update Test User",
-					Email: "updatetest@example.com",
+					Email: uniqueEmail("updatetest"),
 				}
 
 				body, _ := json.Marshal(createReq)
@@ -251,6 +846,34 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 				Expect(updatedUser.Updated).To(BeTemporally(">", testUser.Updated))
 			})
 
+			It("should honour If-Match preconditions", func() {
+				getResp, err := httpClient.Get(fmt.Sprintf("%s/users/%d", serverURL, testUser.ID))
+				Expect(err).To(BeNil())
+				getResp.Body.Close()
+				etag := getResp.Header.Get("ETag")
+				Expect(etag).To(Equal(fmt.Sprintf(`"%d"`, testUser.Version)))
+
+				update := func(name string) *http.Response {
+					body, _ := json.Marshal(httphandler.UpdateUserRequest{Name: name})
+					req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/users/%d", serverURL, testUser.ID), bytes.NewReader(body))
+					req.Header.Set("Content-Type", "application/json")
+					req.Header.Set("If-Match", etag)
+					resp, err := httpClient.Do(req)
+					Expect(err).To(BeNil())
+					resp.Body.Close()
+					return resp
+				}
+
+				// The first writer wins and moves the version on
+				resp := update("First Writer")
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(resp.Header.Get("ETag")).NotTo(Equal(etag))
+
+				// The second writer still holds the old ETag
+				resp = update("Second Writer")
+				Expect(resp.StatusCode).To(Equal(http.StatusPreconditionFailed))
+			})
+
 			It("should return 404 for non-existent user update", func() {
 				updateReq := httphandler.UpdateUserRequest{
 					Name: "Should Not Work",
@@ -275,7 +898,7 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 				// Create a test user
 				createReq := httphandler.CreateUserRequest{
 					Name:  "Delete Test User",
-					Email: "deletetest@example.com",
+					Email: uniqueEmail("deletetest"),
 				}
 
 				body, _ := json.Marshal(createReq)
@@ -313,6 +936,53 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 				}
 			})
 
+			It("should restore a deleted user", func() {
+				req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/users/%d", serverURL, testUser.ID), nil)
+				resp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				// The email stays reserved while the user is deleted
+				body, _ := json.Marshal(httphandler.CreateUserRequest{Name: "Taker", Email: testUser.Email})
+				resp, err = httpClient.Post(serverURL+"/users", "application/json", bytes.NewReader(body))
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+
+				resp, err = httpClient.Post(fmt.Sprintf("%s/users/%d/restore", serverURL, testUser.ID), "application/json", nil)
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				getResp, err := httpClient.Get(fmt.Sprintf("%s/users/%d", serverURL, testUser.ID))
+				Expect(err).To(BeNil())
+				defer getResp.Body.Close()
+				Expect(getResp.StatusCode).To(Equal(http.StatusOK))
+			})
+
+			It("should keep the history of a deleted user", func() {
+				req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/users/%d", serverURL, testUser.ID), nil)
+				req.Header.Set(httphandler.PrincipalHeader, "e2e-admin")
+				resp, err := httpClient.Do(req)
+				Expect(err).To(BeNil())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+
+				resp, err = httpClient.Get(fmt.Sprintf("%s/users/%d/history", serverURL, testUser.ID))
+				Expect(err).To(BeNil())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+				var history httphandler.HistoryResponse
+				Expect(json.NewDecoder(resp.Body).Decode(&history)).To(Succeed())
+				Expect(history.Total).To(Equal(2))
+				Expect(history.Entries[0].Operation).To(Equal(entities.OperationCreated))
+				Expect(history.Entries[1].Operation).To(Equal(entities.OperationDeleted))
+				Expect(history.Entries[1].Principal).To(Equal("e2e-admin"))
+				Expect(history.Entries[1].RequestID).NotTo(BeEmpty())
+			})
+
 			It("should return 404 for non-existent user deletion", func() {
 				req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/users/99999", serverURL), nil)
 				resp, err := httpClient.Do(req)
@@ -351,12 +1021,15 @@ var _ = Describe("User E2E Tests", Ordered, func() {
 				Expect(err).To(BeNil())
 				defer resp.Body.Close()
 
-				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
 
-				var errorResp map[string]string
+				var errorResp httphandler.ErrorResponse
 				err = json.NewDecoder(resp.Body).Decode(&errorResp)
 				Expect(err).To(BeNil())
-				Expect(errorResp["error"]).To(Equal(entities.ErrUserNameRequired.Error()))
+				Expect(errorResp.Code).To(Equal(string(entities.CodeValidationFailed)))
+				Expect(errorResp.Violations).To(Equal([]httphandler.ViolationResponse{
+					{Field: "name", Code: string(entities.CodeUserNameRequired), Error: entities.ErrUserNameRequired.Error()},
+				}))
 			})
 		})
 	})
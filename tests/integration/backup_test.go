@@ -0,0 +1,281 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/backup"
+	"agent-orchestration/infrastructure/cache"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/infrastructure/search"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+var _ = Describe("User Backups", func() {
+	var (
+		ctx   context.Context
+		store *backup.FileStore
+		dir   string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dir = filepath.Join(GinkgoT().TempDir(), "backups")
+		store = backup.NewFileStore(backup.Options{Dir: dir})
+	})
+
+	// roundTrip backs up repo, changes it and restores the backup, checking
+	// that users, soft deletes and the ID sequence come back as they were
+	roundTrip := func(repo repository.UserRepository) {
+		userUseCase := use_cases.NewUserUseCase(repo, use_cases.WithBackups(store))
+		john, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		jane, err := userUseCase.CreateUser(ctx, "Jane Doe", "jane@example.com")
+		Expect(err).To(BeNil())
+		gone, err := userUseCase.CreateUser(ctx, "Gone User", "gone@example.com")
+		Expect(err).To(BeNil())
+		Expect(userUseCase.DeleteUser(ctx, gone.ID)).To(Succeed())
+		_, err = userUseCase.UpdateUser(ctx, jane.ID, "Jane Smith", "jane@example.com")
+		Expect(err).To(BeNil())
+
+		deletedCtx := repository.IncludeDeleted(ctx)
+		before, err := repo.List(deletedCtx)
+		Expect(err).To(BeNil())
+
+		info, err := userUseCase.BackupUsers(ctx)
+		Expect(err).To(BeNil())
+		Expect(info.Users).To(Equal(3))
+
+		// Changes made after the backup are undone by the restore
+		_, err = userUseCase.CreateUser(ctx, "Later User", "later@example.com")
+		Expect(err).To(BeNil())
+		_, err = userUseCase.UpdateUser(ctx, john.ID, "John Changed", "changed@example.com")
+		Expect(err).To(BeNil())
+		Expect(repo.Delete(ctx, jane.ID)).To(Succeed())
+
+		snapshot, err := userUseCase.RestoreUsers(ctx, info.Name)
+		Expect(err).To(BeNil())
+		Expect(snapshot.Users).To(HaveLen(3))
+
+		after, err := repo.List(deletedCtx)
+		Expect(err).To(BeNil())
+		Expect(after).To(HaveLen(len(before)))
+		for i := range before {
+			Expect(after[i].ID).To(Equal(before[i].ID))
+			Expect(after[i].Name).To(Equal(before[i].Name))
+			Expect(after[i].Email).To(Equal(before[i].Email))
+			Expect(after[i].Version).To(Equal(before[i].Version))
+			Expect(after[i].DeletedAt == nil).To(Equal(before[i].DeletedAt == nil))
+		}
+
+		_, err = repo.GetByID(ctx, gone.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		restored, err := repo.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(BeNil())
+		Expect(restored.ID).To(Equal(john.ID))
+		_, err = repo.GetByEmail(ctx, "changed@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+
+		// The ID sequence is restored too, so IDs handed out after the
+		// backup are handed out again
+		next, err := userUseCase.CreateUser(ctx, "Next User", "next@example.com")
+		Expect(err).To(BeNil())
+		Expect(next.ID).To(Equal(gone.ID + 1))
+
+		_, err = userUseCase.CreateUser(ctx, "Duplicate", "john@EXAMPLE.com")
+		Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+	}
+
+	Describe("snapshot and restore", func() {
+		It("should round trip the in-memory repository", func() {
+			roundTrip(database.NewInMemoryUserRepository())
+		})
+
+		It("should round trip the SQL repository", func() {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "users.db"))
+			DeferCleanup(db.Close)
+
+			roundTrip(database.NewSQLUserRepository(db))
+		})
+
+		It("should persist a restore of the durable in-memory repository", func() {
+			opts := database.DurableOptions{Dir: GinkgoT().TempDir(), SyncWrites: true}
+			repo, err := database.NewDurableInMemoryUserRepository(opts)
+			Expect(err).To(BeNil())
+
+			roundTrip(repo)
+			Expect(repo.Close()).To(Succeed())
+
+			repo, err = database.NewDurableInMemoryUserRepository(opts)
+			Expect(err).To(BeNil())
+			DeferCleanup(repo.Close)
+			users, err := repo.List(repository.IncludeDeleted(ctx))
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(4))
+			Expect(users[3].Email).To(Equal("next@example.com"))
+		})
+
+		It("should drop cached lookups on restore", func() {
+			repo := cache.NewCachedUserRepository(database.NewInMemoryUserRepository(), cache.Options{
+				Size:        100,
+				TTL:         time.Hour,
+				NegativeTTL: time.Hour,
+			})
+
+			roundTrip(repo)
+		})
+
+		It("should rebuild the search index on restore", func() {
+			index := search.NewIndex()
+			repo := search.NewIndexedUserRepository(database.NewInMemoryUserRepository(), index)
+
+			roundTrip(repo)
+
+			hits, _, err := index.Search(ctx, "later", 0, 10)
+			Expect(err).To(BeNil())
+			Expect(hits).To(BeEmpty())
+			hits, _, err = index.Search(ctx, "jane", 0, 10)
+			Expect(err).To(BeNil())
+			Expect(hits).To(HaveLen(1))
+		})
+
+		It("should refuse to restore the in-memory repository inside a transaction", func() {
+			repo := database.NewInMemoryUserRepository()
+			tx := database.NewInMemoryTransactionManager()
+
+			err := tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				return repo.(repository.UserSnapshotter).RestoreUsers(ctx, &repository.UserSnapshot{NextID: 1})
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("FileStore", func() {
+		snapshotAt := func(taken time.Time) *repository.UserSnapshot {
+			return &repository.UserSnapshot{
+				Taken:  taken,
+				NextID: 3,
+				Users: []*entities.User{
+					{ID: 2, Name: "John Doe", Email: "john@example.com", Version: 1, Created: taken, Updated: taken},
+				},
+			}
+		}
+
+		It("should save and load an archive", func() {
+			taken := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+			info, err := store.Save(ctx, snapshotAt(taken))
+			Expect(err).To(BeNil())
+			Expect(info.Name).To(Equal("users-20240102T030405.000000006Z.backup"))
+			Expect(info.Users).To(Equal(1))
+
+			infos, err := store.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].Taken).To(Equal(taken))
+			Expect(infos[0].Users).To(Equal(1))
+			Expect(infos[0].Size).To(Equal(info.Size))
+
+			snapshot, err := store.Load(ctx, info.Name)
+			Expect(err).To(BeNil())
+			Expect(snapshot.Taken).To(Equal(taken))
+			Expect(snapshot.NextID).To(Equal(3))
+			Expect(snapshot.Users).To(HaveLen(1))
+			Expect(snapshot.Users[0].Email).To(Equal("john@example.com"))
+		})
+
+		It("should list nothing before the first backup", func() {
+			infos, err := store.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(infos).To(BeEmpty())
+			_, err = os.Stat(dir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		DescribeTable("should reject damaged archives",
+			func(damage func([]byte) []byte) {
+				info, err := store.Save(ctx, snapshotAt(time.Now()))
+				Expect(err).To(BeNil())
+				path := filepath.Join(dir, info.Name)
+				data, err := os.ReadFile(path)
+				Expect(err).To(BeNil())
+				Expect(os.WriteFile(path, damage(data), 0o644)).To(Succeed())
+
+				_, err = store.Load(ctx, info.Name)
+				Expect(errors.Is(err, entities.ErrInvalidBackup)).To(BeTrue())
+			},
+			Entry("a changed payload", func(data []byte) []byte {
+				return bytes.Replace(data, []byte("john@example.com"), []byte("joan@example.com"), 1)
+			}),
+			Entry("a truncated payload", func(data []byte) []byte {
+				return data[:len(data)-10]
+			}),
+			Entry("trailing data", func(data []byte) []byte {
+				return append(data, '\n')
+			}),
+			Entry("a newer version", func(data []byte) []byte {
+				return bytes.Replace(data, []byte(`"version":1`), []byte(`"version":2`), 1)
+			}),
+			Entry("another format", func(data []byte) []byte {
+				return []byte("id,name,email\n")
+			}),
+			Entry("an empty file", func(data []byte) []byte {
+				return nil
+			}),
+		)
+
+		DescribeTable("should not load names it didn't generate",
+			func(name string) {
+				_, err := store.Load(ctx, name)
+				Expect(err).To(Equal(entities.ErrBackupNotFound))
+			},
+			Entry("a missing backup", "users-20240102T030405.000000000Z.backup"),
+			Entry("a path", "../users-20240102T030405.000000000Z.backup"),
+			Entry("another file", "users.db"),
+		)
+
+		It("should keep only the newest backups", func() {
+			store = backup.NewFileStore(backup.Options{Dir: dir, Keep: 2})
+			start := time.Now().Add(-time.Hour)
+			for i := 0; i < 4; i++ {
+				_, err := store.Save(ctx, snapshotAt(start.Add(time.Duration(i)*time.Minute)))
+				Expect(err).To(BeNil())
+			}
+
+			infos, err := store.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(infos).To(HaveLen(2))
+			Expect(infos[0].Taken).To(BeTemporally("==", start.Add(3*time.Minute)))
+			Expect(infos[1].Taken).To(BeTemporally("==", start.Add(2*time.Minute)))
+		})
+
+		It("should drop old backups but always keep the newest", func() {
+			store = backup.NewFileStore(backup.Options{Dir: dir, MaxAge: 24 * time.Hour})
+			now := time.Now()
+			for _, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour} {
+				_, err := store.Save(ctx, snapshotAt(now.Add(-age)))
+				Expect(err).To(BeNil())
+			}
+
+			infos, err := store.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(infos).To(HaveLen(1))
+			Expect(infos[0].Taken).To(BeTemporally("==", now.Add(-time.Hour)))
+
+			store = backup.NewFileStore(backup.Options{Dir: dir, MaxAge: time.Minute})
+			_, err = store.Save(ctx, snapshotAt(now.Add(-time.Hour)))
+			Expect(err).To(BeNil())
+			infos, err = store.List(ctx)
+			Expect(err).To(BeNil())
+			Expect(infos).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,311 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/cache"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/testutils"
+)
+
+// countingUserRepository counts the lookups that reach the repository and
+// can hold them until gate is closed
+type countingUserRepository struct {
+	repository.UserRepository
+	lookups atomic.Int32
+	gate    chan struct{}
+}
+
+func (r *countingUserRepository) GetByID(ctx context.Context, id int) (*entities.User, error) {
+	r.wait()
+	return r.UserRepository.GetByID(ctx, id)
+}
+
+func (r *countingUserRepository) GetByEmail(ctx context.Context, email string) (*entities.User, error) {
+	r.wait()
+	return r.UserRepository.GetByEmail(ctx, email)
+}
+
+func (r *countingUserRepository) wait() {
+	r.lookups.Add(1)
+	if r.gate != nil {
+		<-r.gate
+	}
+}
+
+var _ = Describe("CachedUserRepository", func() {
+	Describe("contract", func() {
+		testutils.UserRepositoryContract(func() repository.UserRepository {
+			return cache.NewCachedUserRepository(database.NewInMemoryUserRepository(), cache.Options{
+				Size:        100,
+				TTL:         time.Minute,
+				NegativeTTL: time.Minute,
+			})
+		})
+	})
+
+	var (
+		backend *countingUserRepository
+		cached  *cache.CachedUserRepository
+		tx      repository.TransactionManager
+		ctx     context.Context
+		opts    cache.Options
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		opts = cache.Options{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute}
+		backend = &countingUserRepository{UserRepository: database.NewInMemoryUserRepository()}
+		tx = database.NewInMemoryTransactionManager()
+	})
+
+	JustBeforeEach(func() {
+		cached = cache.NewCachedUserRepository(backend, opts)
+	})
+
+	create := func(name, email string) *entities.User {
+		user := &entities.User{Name: name, Email: email, Created: time.Now(), Updated: time.Now()}
+		Expect(cached.Create(ctx, user)).To(Succeed())
+		return user
+	}
+
+	It("should serve repeated lookups from the cache", func() {
+		user := create("John Doe", "john@example.com")
+
+		for i := 0; i < 3; i++ {
+			found, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(found.Name).To(Equal("John Doe"))
+		}
+		for _, email := range []string{"john@example.com", "john@EXAMPLE.com "} {
+			_, err := cached.GetByEmail(ctx, email)
+			Expect(err).To(BeNil())
+		}
+
+		Expect(backend.lookups.Load()).To(Equal(int32(2)))
+		Expect(cached.Stats()).To(Equal(cache.Stats{Hits: 3, Misses: 2}))
+	})
+
+	It("should return copies", func() {
+		user := create("John Doe", "john@example.com")
+
+		found, err := cached.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		found.Name = "Mutated"
+
+		found, err = cached.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(found.Name).To(Equal("John Doe"))
+	})
+
+	It("should invalidate the old and the new email on update", func() {
+		user := create("John Doe", "john@example.com")
+		_, err := cached.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(BeNil())
+		_, err = cached.GetByEmail(ctx, "johnny@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		_, err = cached.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+
+		user.Email = "johnny@example.com"
+		Expect(cached.Update(ctx, user)).To(Succeed())
+
+		_, err = cached.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		found, err := cached.GetByEmail(ctx, "johnny@example.com")
+		Expect(err).To(BeNil())
+		Expect(found.ID).To(Equal(user.ID))
+		found, err = cached.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(found.Email).To(Equal("johnny@example.com"))
+	})
+
+	It("should invalidate on delete", func() {
+		user := create("John Doe", "john@example.com")
+		_, err := cached.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		_, err = cached.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(BeNil())
+
+		Expect(cached.Delete(ctx, user.ID)).To(Succeed())
+
+		_, err = cached.GetByID(ctx, user.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		_, err = cached.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+	})
+
+	It("should cache misses until the user is created", func() {
+		for i := 0; i < 2; i++ {
+			_, err := cached.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+			_, err = cached.GetByID(ctx, 1)
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		}
+		Expect(backend.lookups.Load()).To(Equal(int32(2)))
+
+		user := create("John Doe", "john@example.com")
+		Expect(user.ID).To(Equal(1))
+
+		_, err := cached.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(BeNil())
+		_, err = cached.GetByID(ctx, 1)
+		Expect(err).To(BeNil())
+	})
+
+	Context("without negative caching", func() {
+		BeforeEach(func() {
+			opts.NegativeTTL = 0
+		})
+
+		It("should look up missing users every time", func() {
+			for i := 0; i < 2; i++ {
+				_, err := cached.GetByID(ctx, 1)
+				Expect(err).To(Equal(entities.ErrUserNotFound))
+			}
+			Expect(backend.lookups.Load()).To(Equal(int32(2)))
+		})
+	})
+
+	Context("with a short TTL", func() {
+		BeforeEach(func() {
+			opts.TTL = 20 * time.Millisecond
+		})
+
+		It("should look users up again once they expire", func() {
+			user := create("John Doe", "john@example.com")
+			_, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+
+			time.Sleep(2 * opts.TTL)
+
+			_, err = cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(backend.lookups.Load()).To(Equal(int32(2)))
+		})
+	})
+
+	Context("when full", func() {
+		BeforeEach(func() {
+			opts.Size = 2
+		})
+
+		It("should evict the least recently used entry", func() {
+			first := create("First User", "first@example.com")
+			second := create("Second User", "second@example.com")
+			third := create("Third User", "third@example.com")
+
+			for _, id := range []int{first.ID, second.ID, first.ID, third.ID} {
+				_, err := cached.GetByID(ctx, id)
+				Expect(err).To(BeNil())
+			}
+			Expect(cached.Stats().Evictions).To(Equal(uint64(1)))
+
+			// The second user was used least recently
+			_, err := cached.GetByID(ctx, first.ID)
+			Expect(err).To(BeNil())
+			Expect(backend.lookups.Load()).To(Equal(int32(3)))
+			_, err = cached.GetByID(ctx, second.ID)
+			Expect(err).To(BeNil())
+			Expect(backend.lookups.Load()).To(Equal(int32(4)))
+		})
+	})
+
+	It("should collapse concurrent misses for the same key into one lookup", func() {
+		user := create("John Doe", "john@example.com")
+		backend.gate = make(chan struct{})
+
+		const readers = 10
+		var wg sync.WaitGroup
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				found, err := cached.GetByID(ctx, user.ID)
+				Expect(err).To(BeNil())
+				Expect(found.ID).To(Equal(user.ID))
+			}()
+		}
+
+		Eventually(func() uint64 { return cached.Stats().Misses }).Should(Equal(uint64(readers)))
+		close(backend.gate)
+		wg.Wait()
+
+		Expect(backend.lookups.Load()).To(Equal(int32(1)))
+	})
+
+	It("should share entries between reads with and without soft-deleted users", func() {
+		user := create("John Doe", "john@example.com")
+		deletedAt := time.Now()
+		user.DeletedAt = &deletedAt
+		Expect(cached.Update(ctx, user)).To(Succeed())
+
+		_, err := cached.GetByID(ctx, user.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		found, err := cached.GetByID(repository.IncludeDeleted(ctx), user.ID)
+		Expect(err).To(BeNil())
+		Expect(found.IsDeleted()).To(BeTrue())
+
+		Expect(backend.lookups.Load()).To(Equal(int32(1)))
+	})
+
+	Describe("transactions", func() {
+		It("should not cache reads made inside a transaction", func() {
+			user := create("John Doe", "john@example.com")
+
+			Expect(tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				for i := 0; i < 2; i++ {
+					_, err := cached.GetByID(ctx, user.ID)
+					Expect(err).To(BeNil())
+				}
+				return nil
+			})).To(Succeed())
+
+			Expect(backend.lookups.Load()).To(Equal(int32(2)))
+			Expect(cached.Stats()).To(Equal(cache.Stats{}))
+		})
+
+		It("should serve the committed state after a transaction", func() {
+			user := create("John Doe", "john@example.com")
+			_, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+
+			Expect(tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				user.Name = "John Updated"
+				return cached.Update(ctx, user)
+			})).To(Succeed())
+
+			found, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(found.Name).To(Equal("John Updated"))
+		})
+
+		It("should serve the old state after a rollback", func() {
+			user := create("John Doe", "john@example.com")
+			_, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+
+			errAbort := errors.New("abort")
+			Expect(tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				changed := *user
+				changed.Name = "John Updated"
+				Expect(cached.Update(ctx, &changed)).To(Succeed())
+				return errAbort
+			})).To(Equal(errAbort))
+
+			found, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(found.Name).To(Equal("John Doe"))
+		})
+	})
+})
@@ -0,0 +1,250 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/backup"
+	"agent-orchestration/infrastructure/changefeed"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+// changefeedSpecs declares the change stream specs run against every store
+func changefeedSpecs(newStores func() transactionalStores) {
+	var (
+		stores      transactionalStores
+		ctx         context.Context
+		users       *changefeed.WatchedUserRepository
+		userUseCase *use_cases.UserUseCase
+	)
+
+	// watch starts watching the store from fromSeq, for at most a few
+	// seconds
+	watch := func(fromSeq int64) repository.UserChangeStream {
+		watchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		DeferCleanup(cancel)
+		stream, err := userUseCase.WatchUsers(watchCtx, fromSeq)
+		Expect(err).To(BeNil())
+		return stream
+	}
+
+	// next returns the next change of stream
+	next := func(stream repository.UserChangeStream) *repository.UserChange {
+		change, err := stream.Next()
+		Expect(err).To(BeNil())
+		return change
+	}
+
+	// expectIdle checks that no change follows on a stream started from
+	// fromSeq
+	expectIdle := func(fromSeq int64) {
+		idleCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		stream, err := users.Watch(idleCtx, fromSeq)
+		Expect(err).To(BeNil())
+		_, err = stream.Next()
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	}
+
+	newUseCase := func(logSize int) {
+		users = changefeed.NewWatchedUserRepository(stores.users, changefeed.NewLog(logSize))
+		userUseCase = use_cases.NewUserUseCase(users,
+			use_cases.WithHistory(stores.history),
+			use_cases.WithTransactions(stores.tx),
+			use_cases.WithBackups(backup.NewFileStore(backup.Options{Dir: GinkgoT().TempDir()})))
+	}
+
+	BeforeEach(func() {
+		stores = newStores()
+		ctx = context.Background()
+		newUseCase(changefeed.DefaultLogSize)
+	})
+
+	It("should stream every change in order with the full record", func() {
+		stream := watch(repository.WatchFromLatest)
+
+		user, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		_, err = userUseCase.UpdateUser(ctx, user.ID, "John Smith", "john@example.com")
+		Expect(err).To(BeNil())
+		Expect(userUseCase.DeleteUser(ctx, user.ID)).To(Succeed())
+		purged, err := userUseCase.PurgeDeletedUsers(ctx, 0)
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(1))
+
+		created := next(stream)
+		Expect(created.Operation).To(Equal(repository.ChangeCreated))
+		Expect(created.User.ID).To(Equal(user.ID))
+		Expect(created.User.Email).To(Equal("john@example.com"))
+		Expect(created.User.Version).To(Equal(1))
+
+		updated := next(stream)
+		Expect(updated.Operation).To(Equal(repository.ChangeUpdated))
+		Expect(updated.User.Name).To(Equal("John Smith"))
+		Expect(updated.Seq).To(BeNumerically(">", created.Seq))
+
+		deleted := next(stream)
+		Expect(deleted.Operation).To(Equal(repository.ChangeDeleted))
+		Expect(deleted.User.IsDeleted()).To(BeTrue())
+
+		gone := next(stream)
+		Expect(gone.Operation).To(Equal(repository.ChangePurged))
+		Expect(gone.User.ID).To(Equal(user.ID))
+		Expect(gone.User.Name).To(Equal("John Smith"))
+		Expect(gone.Seq).To(BeNumerically(">", deleted.Seq))
+
+		expectIdle(gone.Seq)
+	})
+
+	It("should resume after the last change seen", func() {
+		stream := watch(repository.WatchFromLatest)
+		for i := 0; i < 3; i++ {
+			_, err := userUseCase.CreateUser(ctx, "User", fmt.Sprintf("user%d@example.com", i))
+			Expect(err).To(BeNil())
+		}
+		first := next(stream)
+
+		resumed := watch(first.Seq)
+		Expect(next(resumed).User.Email).To(Equal("user1@example.com"))
+		Expect(next(resumed).User.Email).To(Equal("user2@example.com"))
+		expectIdle(first.Seq + 2)
+	})
+
+	It("should only stream the changes of the tenant of the watcher", func() {
+		acme := repository.WithTenant(ctx, "acme")
+		watchCtx, cancel := context.WithTimeout(acme, 5*time.Second)
+		DeferCleanup(cancel)
+		stream, err := userUseCase.WatchUsers(watchCtx, repository.WatchFromLatest)
+		Expect(err).To(BeNil())
+
+		_, err = userUseCase.CreateUser(repository.WithTenant(ctx, "globex"), "John Smith", "john@example.com")
+		Expect(err).To(BeNil())
+		user, err := userUseCase.CreateUser(acme, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+
+		created := next(stream)
+		Expect(created.User.ID).To(Equal(user.ID))
+		Expect(created.User.TenantID).To(Equal("acme"))
+	})
+
+	It("should tell a watcher too far behind instead of blocking writers", func() {
+		newUseCase(2)
+		stream := watch(repository.WatchFromLatest)
+
+		// Nobody reads while these are written
+		for i := 0; i < 5; i++ {
+			_, err := userUseCase.CreateUser(ctx, "User", fmt.Sprintf("user%d@example.com", i))
+			Expect(err).To(BeNil())
+		}
+
+		_, err := stream.Next()
+		Expect(err).To(Equal(entities.ErrWatchTooFarBehind))
+
+		_, err = users.Watch(ctx, 0)
+		Expect(err).To(Equal(entities.ErrWatchTooFarBehind))
+
+		// The latest changes are still retained
+		latest := watch(repository.WatchFromLatest)
+		_, err = userUseCase.CreateUser(ctx, "User", "last@example.com")
+		Expect(err).To(BeNil())
+		Expect(next(latest).User.Email).To(Equal("last@example.com"))
+	})
+
+	It("should reject a sequence number not issued yet", func() {
+		user, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		latest := watch(repository.WatchFromLatest)
+		_, err = userUseCase.UpdateUser(ctx, user.ID, "John Smith", "john@example.com")
+		Expect(err).To(BeNil())
+		change := next(latest)
+
+		_, err = users.Watch(ctx, change.Seq+1)
+		Expect(err).To(Equal(entities.ErrInvalidSequence))
+	})
+
+	It("should never stream changes rolled back", func() {
+		stream := watch(repository.WatchFromLatest)
+
+		rollback := errors.New("rollback")
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			Expect(users.Create(ctx, &entities.User{Name: "Rolled Back", Email: "rolled@example.com"})).To(Succeed())
+			return rollback
+		})
+		Expect(err).To(Equal(rollback))
+
+		_, err = userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		Expect(next(stream).User.Email).To(Equal("john@example.com"))
+	})
+
+	It("should start watchers over after a restore", func() {
+		stream := watch(repository.WatchFromLatest)
+		_, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		created := next(stream)
+		info, err := userUseCase.BackupUsers(ctx)
+		Expect(err).To(BeNil())
+
+		_, err = userUseCase.RestoreUsers(ctx, info.Name)
+		Expect(err).To(BeNil())
+
+		_, err = stream.Next()
+		Expect(err).To(Equal(entities.ErrWatchTooFarBehind))
+		_, err = users.Watch(ctx, created.Seq)
+		Expect(err).To(Equal(entities.ErrWatchTooFarBehind))
+
+		// Changes of restored users are streamed again
+		restarted := watch(repository.WatchFromLatest)
+		_, err = userUseCase.UpdateUser(ctx, created.User.ID, "John Smith", "john@example.com")
+		Expect(err).To(BeNil())
+		Expect(next(restarted).User.Name).To(Equal("John Smith"))
+	})
+
+	It("should end the stream when its context is done", func() {
+		watchCtx, cancel := context.WithCancel(ctx)
+		stream, err := users.Watch(watchCtx, repository.WatchFromLatest)
+		Expect(err).To(BeNil())
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := stream.Next()
+			done <- err
+		}()
+		cancel()
+
+		Eventually(done).Should(Receive(Equal(context.Canceled)))
+	})
+}
+
+var _ = Describe("User Change Stream", func() {
+	Describe("with the memory store", func() {
+		changefeedSpecs(func() transactionalStores {
+			return transactionalStores{
+				users:   database.NewInMemoryUserRepository(),
+				history: database.NewInMemoryHistoryRepository(),
+				tx:      database.NewInMemoryTransactionManager(),
+			}
+		})
+	})
+
+	Describe("with the SQL store", func() {
+		changefeedSpecs(func() transactionalStores {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "changes.db"))
+			DeferCleanup(db.Close)
+			return transactionalStores{
+				users:   database.NewSQLUserRepository(db),
+				history: database.NewSQLHistoryRepository(db),
+				tx:      database.NewSQLTransactionManager(db),
+			}
+		})
+	})
+})
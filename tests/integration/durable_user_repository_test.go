@@ -0,0 +1,213 @@
+package integration_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/testutils"
+	"agent-orchestration/use_cases"
+)
+
+var _ = Describe("Durable In-Memory Repository Integration Tests", func() {
+	var (
+		dir  string
+		opts database.DurableOptions
+		repo *database.InMemoryUserRepository
+		ctx  context.Context
+	)
+
+	open := func() *database.InMemoryUserRepository {
+		r, err := database.NewDurableInMemoryUserRepository(opts)
+		Expect(err).To(BeNil())
+		return r
+	}
+
+	walPath := func() string {
+		return filepath.Join(dir, "users.wal")
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		opts = database.DurableOptions{Dir: dir, SyncWrites: true}
+		repo = open()
+		DeferCleanup(func() {
+			repo.Close()
+		})
+		ctx = context.Background()
+	})
+
+	// reopen simulates a crash: the log file is released without taking the
+	// final snapshot that Close would write
+	crashAndReopen := func() {
+		copyDir := GinkgoT().TempDir()
+		for _, name := range []string{"users.wal", "users.snapshot"} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if os.IsNotExist(err) {
+				continue
+			}
+			Expect(err).To(BeNil())
+			Expect(os.WriteFile(filepath.Join(copyDir, name), data, 0o644)).To(Succeed())
+		}
+		repo.Close()
+		dir, opts.Dir = copyDir, copyDir
+		repo = open()
+	}
+
+	It("should replay the log after a crash, including ID assignment", func() {
+		userUseCase := use_cases.NewUserUseCase(repo)
+		john, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		jane, err := userUseCase.CreateUser(ctx, "Jane Doe", "jane@example.com")
+		Expect(err).To(BeNil())
+		_, err = userUseCase.UpdateUser(ctx, john.ID, "John Updated", "john.updated@example.com")
+		Expect(err).To(BeNil())
+		Expect(userUseCase.DeleteUser(ctx, jane.ID)).To(Succeed())
+
+		crashAndReopen()
+		userUseCase = use_cases.NewUserUseCase(repo)
+
+		page, err := userUseCase.ListUsers(ctx, repository.ListOptions{})
+		Expect(err).To(BeNil())
+		users := page.Users
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].Name).To(Equal("John Updated"))
+		Expect(users[0].Email).To(Equal("john.updated@example.com"))
+
+		_, err = repo.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+
+		// The deleted user's ID is never handed out again
+		next, err := userUseCase.CreateUser(ctx, "Next User", "next@example.com")
+		Expect(err).To(BeNil())
+		Expect(next.ID).To(BeNumerically(">", jane.ID))
+	})
+
+	It("should restore from a snapshot plus the log written after it", func() {
+		for _, user := range testutils.CreateTestUsers(3) {
+			Expect(repo.Create(ctx, testutils.CloneUser(user))).To(Succeed())
+		}
+		Expect(repo.Snapshot()).To(Succeed())
+
+		info, err := os.Stat(walPath())
+		Expect(err).To(BeNil())
+		Expect(info.Size()).To(BeZero())
+
+		Expect(repo.Delete(ctx, 1)).To(Succeed())
+		extra := &entities.User{Name: "After Snapshot", Email: "after@example.com", Created: time.Now(), Updated: time.Now()}
+		Expect(repo.Create(ctx, extra)).To(Succeed())
+
+		crashAndReopen()
+
+		users, err := repo.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(3))
+		_, err = repo.GetByID(ctx, 1)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		stored, err := repo.GetByEmail(ctx, "after@example.com")
+		Expect(err).To(BeNil())
+		Expect(stored.ID).To(Equal(extra.ID))
+	})
+
+	It("should compact the log automatically after SnapshotEvery records", func() {
+		repo.Close()
+		opts.SnapshotEvery = 2
+		repo = open()
+
+		for _, user := range testutils.CreateTestUsers(4) {
+			Expect(repo.Create(ctx, testutils.CloneUser(user))).To(Succeed())
+		}
+
+		Eventually(func() error {
+			_, err := os.Stat(filepath.Join(dir, "users.snapshot"))
+			return err
+		}).Should(Succeed())
+	})
+
+	It("should truncate a torn final record", func() {
+		Expect(repo.Create(ctx, testutils.NewTestUserBuilder().WithEmail("first@example.com").Build())).To(Succeed())
+		Expect(repo.Create(ctx, testutils.NewTestUserBuilder().WithEmail("second@example.com").Build())).To(Succeed())
+
+		info, err := os.Stat(walPath())
+		Expect(err).To(BeNil())
+		intact := info.Size()
+
+		// Chop the last record in half, as a crash during the write would
+		Expect(os.Truncate(walPath(), intact-10)).To(Succeed())
+		data, err := os.ReadFile(walPath())
+		Expect(err).To(BeNil())
+
+		repo.Close()
+		dir = GinkgoT().TempDir()
+		opts.Dir = dir
+		Expect(os.WriteFile(walPath(), data, 0o644)).To(Succeed())
+		repo = open()
+
+		users, err := repo.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].Email).To(Equal("first@example.com"))
+
+		// New records are appended right after the last intact one
+		Expect(repo.Create(ctx, testutils.NewTestUserBuilder().WithEmail("third@example.com").Build())).To(Succeed())
+		crashAndReopen()
+		users, err = repo.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(2))
+	})
+
+	It("should truncate a final record whose checksum does not match", func() {
+		Expect(repo.Create(ctx, testutils.NewTestUserBuilder().WithEmail("first@example.com").Build())).To(Succeed())
+		Expect(repo.Create(ctx, testutils.NewTestUserBuilder().WithEmail("second@example.com").Build())).To(Succeed())
+
+		data, err := os.ReadFile(walPath())
+		Expect(err).To(BeNil())
+		data[len(data)-2] ^= 0xff
+
+		repo.Close()
+		dir = GinkgoT().TempDir()
+		opts.Dir = dir
+		Expect(os.WriteFile(walPath(), data, 0o644)).To(Succeed())
+		repo = open()
+
+		users, err := repo.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+	})
+
+	It("should refuse to start when a record in the middle of the log is corrupt", func() {
+		Expect(repo.Create(ctx, testutils.NewTestUserBuilder().WithEmail("first@example.com").Build())).To(Succeed())
+		Expect(repo.Create(ctx, testutils.NewTestUserBuilder().WithEmail("second@example.com").Build())).To(Succeed())
+
+		data, err := os.ReadFile(walPath())
+		Expect(err).To(BeNil())
+		data[12] ^= 0xff
+
+		repo.Close()
+		dir = GinkgoT().TempDir()
+		opts.Dir = dir
+		Expect(os.WriteFile(walPath(), data, 0o644)).To(Succeed())
+
+		_, err = database.NewDurableInMemoryUserRepository(opts)
+		Expect(err).To(MatchError(database.ErrCorruptLog))
+
+		opts.Dir = GinkgoT().TempDir()
+		repo = open()
+	})
+
+	Describe("contract", func() {
+		testutils.UserRepositoryContract(func() repository.UserRepository {
+			r, err := database.NewDurableInMemoryUserRepository(database.DurableOptions{Dir: GinkgoT().TempDir()})
+			Expect(err).To(BeNil())
+			DeferCleanup(r.Close)
+			return r
+		})
+	})
+})
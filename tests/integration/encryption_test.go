@@ -0,0 +1,486 @@
+package integration_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/backup"
+	"agent-orchestration/infrastructure/cache"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/infrastructure/encryption"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/testutils"
+	"agent-orchestration/use_cases"
+)
+
+// cancellingUserRepository cancels a context after a number of updates,
+// interrupting whatever makes them
+type cancellingUserRepository struct {
+	repository.UserRepository
+	updates int
+	cancel  context.CancelFunc
+}
+
+func (r *cancellingUserRepository) Update(ctx context.Context, user *entities.User) error {
+	if err := r.UserRepository.Update(ctx, user); err != nil {
+		return err
+	}
+	r.updates--
+	if r.updates == 0 {
+		r.cancel()
+	}
+	return nil
+}
+
+var _ = Describe("Email encryption", func() {
+	newKeyring := func() *encryption.Keyring {
+		keyring, err := encryption.NewKeyring()
+		Expect(err).To(BeNil())
+		return keyring
+	}
+
+	Describe("contract", func() {
+		Describe("in memory", func() {
+			testutils.UserRepositoryContract(func() repository.UserRepository {
+				return encryption.NewEncryptedUserRepository(database.NewInMemoryUserRepository(), newKeyring())
+			})
+		})
+
+		Describe("in SQL", func() {
+			testutils.UserRepositoryContract(func() repository.UserRepository {
+				db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "encrypted.db"))
+				DeferCleanup(db.Close)
+				return encryption.NewEncryptedUserRepository(database.NewSQLUserRepository(db), newKeyring())
+			})
+		})
+	})
+
+	var (
+		ctx       context.Context
+		keyring   *encryption.Keyring
+		inner     repository.UserRepository
+		encrypted *encryption.EncryptedUserRepository
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		keyring = newKeyring()
+		inner = database.NewInMemoryUserRepository()
+		encrypted = encryption.NewEncryptedUserRepository(inner, keyring)
+	})
+
+	createUser := func(repo repository.UserRepository, name, email string) *entities.User {
+		user := &entities.User{Name: name, Email: email, Created: time.Now(), Updated: time.Now()}
+		Expect(repo.Create(ctx, user)).To(Succeed())
+		return user
+	}
+
+	It("should store emails sealed and return them as typed", func() {
+		user := createUser(encrypted, "John Doe", "John.Doe@Example.com")
+		Expect(user.Email).To(Equal("John.Doe@Example.com"))
+		Expect(user.ID).To(BeNumerically(">", 0))
+
+		stored, err := inner.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(stored.Email).To(HavePrefix(encryption.SealedPrefix))
+		Expect(strings.ToLower(stored.Email)).NotTo(ContainSubstring("john"))
+
+		found, err := encrypted.GetByEmail(ctx, " John.Doe@EXAMPLE.com")
+		Expect(err).To(BeNil())
+		Expect(found.ID).To(Equal(user.ID))
+		Expect(found.Email).To(Equal("John.Doe@Example.com"))
+	})
+
+	It("should seal the same address differently every time under the same index", func() {
+		first, err := keyring.Seal("john@example.com")
+		Expect(err).To(BeNil())
+		second, err := keyring.Seal("john@EXAMPLE.com")
+		Expect(err).To(BeNil())
+
+		Expect(first).NotTo(Equal(second))
+		firstKey := encryption.EmailKey(first)
+		secondKey := encryption.EmailKey(second)
+		Expect(firstKey).To(Equal(secondKey))
+	})
+
+	It("should refuse a sealed address moved under another index", func() {
+		john, err := keyring.Seal("john@example.com")
+		Expect(err).To(BeNil())
+		jane, err := keyring.Seal("jane@example.com")
+		Expect(err).To(BeNil())
+
+		johnKey := encryption.EmailKey(john)
+		janeKey := encryption.EmailKey(jane)
+		tampered := strings.Replace(john, johnKey, janeKey, 1)
+
+		_, _, err = keyring.Open(tampered)
+		Expect(err).To(MatchError(encryption.ErrMalformed))
+	})
+
+	It("should not open addresses sealed with another keyring", func() {
+		sealed, err := newKeyring().Seal("john@example.com")
+		Expect(err).To(BeNil())
+
+		_, _, err = keyring.Open(sealed)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should keep emails unique whatever their form", func() {
+		createUser(encrypted, "John Doe", "john@example.com")
+
+		duplicate := &entities.User{Name: "John Again", Email: " john@EXAMPLE.com "}
+		Expect(encrypted.Create(ctx, duplicate)).To(Equal(entities.ErrUserAlreadyExists))
+	})
+
+	DescribeTable("email keys of the wrapped repository",
+		func(email, expected string) {
+			Expect(encryption.EmailKey(email)).To(Equal(expected))
+		},
+		Entry("sealed address", "sealed:0a1b:1:Q2lwaGVy", "sealed:0a1b"),
+		Entry("blind index key", "sealed:0a1b", "sealed:0a1b"),
+		Entry("plain address", " John@Example.com", "John@example.com"),
+		Entry("missing index", "sealed::1:Q2lwaGVy", "sealed::1:Q2lwaGVy"),
+	)
+
+	It("should keep keying sealed emails by their index once a durable store is reopened", func() {
+		opts := database.DurableOptions{Dir: GinkgoT().TempDir()}
+		durable, err := database.NewDurableInMemoryUserRepository(opts)
+		Expect(err).To(BeNil())
+		user := createUser(encryption.NewEncryptedUserRepository(durable, keyring), "John Doe", "john@example.com")
+		Expect(durable.Close()).To(Succeed())
+
+		durable, err = database.NewDurableInMemoryUserRepository(opts)
+		Expect(err).To(BeNil())
+		DeferCleanup(durable.Close)
+		encrypted = encryption.NewEncryptedUserRepository(durable, keyring)
+
+		found, err := encrypted.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(BeNil())
+		Expect(found.ID).To(Equal(user.ID))
+		duplicate := &entities.User{Name: "John Again", Email: "john@example.com"}
+		Expect(encrypted.Create(ctx, duplicate)).To(Equal(entities.ErrUserAlreadyExists))
+	})
+
+	It("should back up and restore users with their emails sealed", func() {
+		dir := GinkgoT().TempDir()
+		userUseCase := use_cases.NewUserUseCase(encrypted,
+			use_cases.WithBackups(encryption.NewEncryptedBackupStore(backup.NewFileStore(backup.Options{Dir: dir}), keyring)),
+		)
+		john := createUser(encrypted, "John Doe", "John.Doe@Example.com")
+		createUser(inner, "Legacy User", "legacy@example.com")
+
+		info, err := userUseCase.BackupUsers(ctx)
+		Expect(err).To(BeNil())
+		files, err := os.ReadDir(dir)
+		Expect(err).To(BeNil())
+		Expect(files).To(HaveLen(1))
+		data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+		Expect(err).To(BeNil())
+		Expect(strings.ToLower(string(data))).NotTo(ContainSubstring("example.com"))
+
+		Expect(encrypted.Delete(ctx, john.ID)).To(Succeed())
+		snapshot, err := userUseCase.RestoreUsers(ctx, info.Name)
+		Expect(err).To(BeNil())
+		Expect(snapshot.Users).To(HaveLen(2))
+		Expect(snapshot.Users[0].Email).To(Equal("John.Doe@Example.com"))
+
+		found, err := encrypted.GetByEmail(ctx, "John.Doe@example.com")
+		Expect(err).To(BeNil())
+		Expect(found.ID).To(Equal(john.ID))
+		stored, err := inner.GetByID(ctx, found.ID)
+		Expect(err).To(BeNil())
+		Expect(stored.Email).To(HavePrefix(encryption.SealedPrefix))
+	})
+
+	It("should seal the emails recorded in the history", func() {
+		history := database.NewInMemoryHistoryRepository()
+		userUseCase := use_cases.NewUserUseCase(encrypted,
+			use_cases.WithHistory(encryption.NewEncryptedHistoryRepository(history, keyring)),
+		)
+		user, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		_, err = userUseCase.UpdateUser(ctx, user.ID, "John Doe", "johnny@example.com")
+		Expect(err).To(BeNil())
+
+		raw, _, err := history.ListByUser(ctx, user.ID, 0, 10)
+		Expect(err).To(BeNil())
+		for _, entry := range raw {
+			for _, change := range entry.Changes {
+				Expect(change.Before).NotTo(ContainSubstring("john"))
+				Expect(change.After).NotTo(ContainSubstring("john"))
+			}
+		}
+
+		entries, _, err := userUseCase.GetUserHistory(ctx, user.ID, 0, 10)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[1].Changes).To(ContainElement(entities.FieldChange{
+			Field:  "email",
+			Before: "john@example.com",
+			After:  "johnny@example.com",
+		}))
+	})
+
+	It("should keep finding users stored before encryption was enabled", func() {
+		legacy := createUser(inner, "Legacy User", "Legacy@example.com")
+
+		found, err := encrypted.GetByEmail(ctx, "Legacy@EXAMPLE.com")
+		Expect(err).To(BeNil())
+		Expect(found.ID).To(Equal(legacy.ID))
+		Expect(found.Email).To(Equal("Legacy@example.com"))
+
+		_, err = use_cases.NewUserUseCase(encrypted).CreateUser(ctx, "Copycat", "Legacy@Example.COM")
+		Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+	})
+
+	It("should sort and filter by the plain address", func() {
+		createUser(encrypted, "Carol", "carol@b.example")
+		createUser(encrypted, "Alice", "alice@a.example")
+		createUser(encrypted, "Bob", "bob@b.example")
+
+		page, err := encrypted.ListPage(ctx, repository.ListOptions{Limit: 1, SortBy: repository.SortByEmail, EmailDomain: "b.example"})
+		Expect(err).To(BeNil())
+		Expect(page.Users).To(HaveLen(1))
+		Expect(page.Users[0].Email).To(Equal("bob@b.example"))
+
+		page, err = encrypted.ListPage(ctx, repository.ListOptions{Limit: 1, SortBy: repository.SortByEmail, EmailDomain: "b.example", Cursor: page.Next})
+		Expect(err).To(BeNil())
+		Expect(page.Users[0].Email).To(Equal("carol@b.example"))
+		Expect(page.Next).To(BeEmpty())
+	})
+
+	Describe("Keyring", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "keyring.json")
+		})
+
+		It("should save and load its keys", func() {
+			sealed, err := keyring.Seal("john@example.com")
+			Expect(err).To(BeNil())
+			Expect(keyring.Save(path)).To(Succeed())
+
+			info, err := os.Stat(path)
+			Expect(err).To(BeNil())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+
+			loaded, err := encryption.LoadKeyring(path)
+			Expect(err).To(BeNil())
+			Expect(loaded.Primary()).To(Equal(keyring.Primary()))
+			Expect(loaded.Index("john@example.com")).To(Equal(keyring.Index("john@example.com")))
+			email, keyID, err := loaded.Open(sealed)
+			Expect(err).To(BeNil())
+			Expect(email).To(Equal("john@example.com"))
+			Expect(keyID).To(Equal(1))
+		})
+
+		It("should keep opening old addresses after a rotation", func() {
+			sealed, err := keyring.Seal("john@example.com")
+			Expect(err).To(BeNil())
+			index := keyring.Index("john@example.com")
+
+			id, err := keyring.Rotate()
+			Expect(err).To(BeNil())
+			Expect(id).To(Equal(2))
+			Expect(keyring.Primary()).To(Equal(2))
+			Expect(keyring.Index("john@example.com")).To(Equal(index))
+
+			email, keyID, err := keyring.Open(sealed)
+			Expect(err).To(BeNil())
+			Expect(email).To(Equal("john@example.com"))
+			Expect(keyID).To(Equal(1))
+		})
+
+		DescribeTable("should reject invalid keyring files",
+			func(content string) {
+				Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+				_, err := encryption.LoadKeyring(path)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("not JSON", "keys"),
+			Entry("unknown version", `{"version":2}`),
+			Entry("short index key", `{"version":1,"primary":1,"index_key":"AAAA","keys":[]}`),
+			Entry("missing primary key", `{"version":1,"primary":1,"index_key":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=","keys":[]}`),
+		)
+	})
+
+	Describe("Reencryptor", func() {
+		var checkpoint string
+
+		BeforeEach(func() {
+			checkpoint = filepath.Join(GinkgoT().TempDir(), "keyring.json.reencrypt")
+		})
+
+		// keyIDs returns the IDs of the keys the stored emails are sealed
+		// with, 0 for plain ones
+		keyIDs := func() []int {
+			users, err := inner.List(repository.IncludeDeleted(ctx))
+			Expect(err).To(BeNil())
+			ids := make([]int, 0, len(users))
+			for _, user := range users {
+				_, keyID, err := keyring.Open(user.Email)
+				if err != nil {
+					keyID = 0
+				}
+				ids = append(ids, keyID)
+			}
+			return ids
+		}
+
+		It("should seal every email with the primary key after a rotation", func() {
+			createUser(inner, "Legacy User", "legacy@example.com")
+			for i := 0; i < 4; i++ {
+				createUser(encrypted, "User", "user"+string(rune('a'+i))+"@example.com")
+			}
+			deleted := createUser(encrypted, "Deleted User", "deleted@example.com")
+			Expect(use_cases.NewUserUseCase(encrypted).DeleteUser(ctx, deleted.ID)).To(Succeed())
+			_, err := keyring.Rotate()
+			Expect(err).To(BeNil())
+			Expect(keyIDs()).To(Equal([]int{0, 1, 1, 1, 1, 1}))
+
+			report, err := encryption.NewReencryptor(encrypted, encrypted, encryption.ReencryptOptions{
+				Checkpoint: checkpoint,
+				BatchSize:  2,
+			}).Run(ctx)
+
+			Expect(err).To(BeNil())
+			Expect(report.Checked).To(Equal(6))
+			Expect(report.Resealed).To(Equal(6))
+			Expect(keyIDs()).To(Equal([]int{2, 2, 2, 2, 2, 2}))
+			_, err = os.Stat(checkpoint)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			legacy, err := encrypted.GetByEmail(ctx, "legacy@example.com")
+			Expect(err).To(BeNil())
+			Expect(legacy.Email).To(Equal("legacy@example.com"))
+			_, err = encrypted.GetByEmail(ctx, "deleted@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+
+		It("should resume an interrupted pass from its checkpoint", func() {
+			for i := 0; i < 6; i++ {
+				createUser(encrypted, "User", "user"+string(rune('a'+i))+"@example.com")
+			}
+			_, err := keyring.Rotate()
+			Expect(err).To(BeNil())
+
+			interrupted, cancel := context.WithCancel(ctx)
+			defer cancel()
+			writer := &cancellingUserRepository{UserRepository: encrypted, updates: 3, cancel: cancel}
+			report, err := encryption.NewReencryptor(encrypted, writer, encryption.ReencryptOptions{
+				Checkpoint: checkpoint,
+				BatchSize:  2,
+			}).Run(interrupted)
+
+			// The batch in progress is finished before stopping
+			Expect(err).To(Equal(context.Canceled))
+			Expect(report.Resealed).To(Equal(4))
+			Expect(checkpoint).To(BeAnExistingFile())
+
+			report, err = encryption.NewReencryptor(encrypted, encrypted, encryption.ReencryptOptions{
+				Checkpoint: checkpoint,
+				BatchSize:  2,
+			}).Run(ctx)
+
+			Expect(err).To(BeNil())
+			Expect(report.Resumed).To(BeTrue())
+			Expect(report.Checked).To(Equal(2))
+			Expect(report.Resealed).To(Equal(2))
+			Expect(keyIDs()).To(Equal([]int{2, 2, 2, 2, 2, 2}))
+		})
+
+		It("should start over when the primary key changed since the checkpoint", func() {
+			for i := 0; i < 4; i++ {
+				createUser(encrypted, "User", "user"+string(rune('a'+i))+"@example.com")
+			}
+			_, err := keyring.Rotate()
+			Expect(err).To(BeNil())
+
+			interrupted, cancel := context.WithCancel(ctx)
+			defer cancel()
+			writer := &cancellingUserRepository{UserRepository: encrypted, updates: 2, cancel: cancel}
+			_, err = encryption.NewReencryptor(encrypted, writer, encryption.ReencryptOptions{
+				Checkpoint: checkpoint,
+				BatchSize:  2,
+			}).Run(interrupted)
+			Expect(err).To(Equal(context.Canceled))
+
+			_, err = keyring.Rotate()
+			Expect(err).To(BeNil())
+			report, err := encryption.NewReencryptor(encrypted, encrypted, encryption.ReencryptOptions{
+				Checkpoint: checkpoint,
+				BatchSize:  2,
+			}).Run(ctx)
+
+			Expect(err).To(BeNil())
+			Expect(report.Resumed).To(BeFalse())
+			Expect(report.Resealed).To(Equal(4))
+			Expect(keyIDs()).To(Equal([]int{3, 3, 3, 3}))
+		})
+
+		It("should rewrite users through the decorators in front of the store", func() {
+			cached := cache.NewCachedUserRepository(encrypted, cache.Options{Size: 10, TTL: time.Hour})
+			user := createUser(cached, "John Doe", "john@example.com")
+			before, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			_, err = keyring.Rotate()
+			Expect(err).To(BeNil())
+
+			_, err = encryption.NewReencryptor(encrypted, cached, encryption.ReencryptOptions{}).Run(ctx)
+			Expect(err).To(BeNil())
+
+			after, err := cached.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			Expect(after.Version).To(Equal(before.Version + 1))
+			Expect(after.Email).To(Equal("john@example.com"))
+		})
+
+		It("should seal the emails of the SQL store", func() {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "users.db"))
+			DeferCleanup(db.Close)
+			inner = database.NewSQLUserRepository(db)
+			encrypted = encryption.NewEncryptedUserRepository(inner, keyring)
+
+			createUser(inner, "Legacy User", "legacy@example.com")
+			createUser(encrypted, "John Doe", "john@example.com")
+
+			_, err := encryption.NewReencryptor(encrypted, encrypted, encryption.ReencryptOptions{Checkpoint: checkpoint}).Run(ctx)
+			Expect(err).To(BeNil())
+
+			Expect(storedEmails(db)).To(HaveLen(2))
+			for _, email := range storedEmails(db) {
+				Expect(email).To(HavePrefix(encryption.SealedPrefix))
+				Expect(email).NotTo(ContainSubstring("example.com"))
+			}
+			found, err := encrypted.GetByEmail(ctx, "legacy@EXAMPLE.com")
+			Expect(err).To(BeNil())
+			Expect(found.Name).To(Equal("Legacy User"))
+		})
+	})
+})
+
+// storedEmails returns the email and normalized email columns of every
+// row of the users table
+func storedEmails(db *sql.DB) []string {
+	rows, err := db.Query(`SELECT email || ' ' || COALESCE(email_normalized, '') FROM users`)
+	Expect(err).To(BeNil())
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		Expect(rows.Scan(&email)).To(Succeed())
+		emails = append(emails, email)
+	}
+	Expect(rows.Err()).To(BeNil())
+	return emails
+}
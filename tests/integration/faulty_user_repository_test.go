@@ -0,0 +1,188 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/chaos"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/use_cases"
+)
+
+// faultySpecs declares the fault injection specs run against every store
+func faultySpecs(newStores func() transactionalStores) {
+	var (
+		ctx         context.Context
+		stores      transactionalStores
+		injector    *chaos.Injector
+		repo        *chaos.FaultyUserRepository
+		userUseCase *use_cases.UserUseCase
+		john        *entities.User
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		stores = newStores()
+		injector = chaos.NewInjector()
+		repo = chaos.NewFaultyUserRepository(stores.users, injector)
+		userUseCase = use_cases.NewUserUseCase(repo,
+			use_cases.WithHistory(stores.history),
+			use_cases.WithTransactions(stores.tx))
+
+		var err error
+		john, err = userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+	})
+
+	It("should behave without rules", func() {
+		user, err := repo.GetByID(ctx, john.ID)
+		Expect(err).To(BeNil())
+		Expect(user.Email).To(Equal("john@example.com"))
+	})
+
+	It("should only fail the calls its rules select", func() {
+		Expect(injector.SetRules([]chaos.Rule{
+			{Operation: chaos.OperationGetByID, MinID: john.ID + 1, Rate: 1, Fault: chaos.FaultError},
+		})).To(Succeed())
+		jane, err := userUseCase.CreateUser(ctx, "Jane Doe", "jane@example.com")
+		Expect(err).To(BeNil())
+
+		_, err = repo.GetByID(ctx, jane.ID)
+		Expect(err).To(Equal(chaos.ErrInjected))
+		_, err = repo.GetByID(ctx, john.ID)
+		Expect(err).To(BeNil())
+		_, err = repo.GetByEmail(ctx, "jane@example.com")
+		Expect(err).To(BeNil())
+
+		Expect(injector.SetRules(nil)).To(Succeed())
+		_, err = repo.GetByID(ctx, jane.ID)
+		Expect(err).To(BeNil())
+	})
+
+	It("should fail about the configured share of calls", func() {
+		Expect(injector.SetRules([]chaos.Rule{
+			{Operation: chaos.OperationGetByID, Rate: 0.1, Fault: chaos.FaultError},
+		})).To(Succeed())
+
+		failed := 0
+		for i := 0; i < 2000; i++ {
+			if _, err := repo.GetByID(ctx, john.ID); err != nil {
+				Expect(err).To(Equal(chaos.ErrInjected))
+				failed++
+			}
+		}
+		Expect(failed).To(BeNumerically("~", 200, 80))
+	})
+
+	It("should delay calls and give up with their context", func() {
+		Expect(injector.SetRules([]chaos.Rule{
+			{Operation: chaos.OperationList, Rate: 1, Latency: 30 * time.Millisecond},
+		})).To(Succeed())
+
+		start := time.Now()
+		users, err := repo.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+		Expect(time.Since(start)).To(BeNumerically(">=", 30*time.Millisecond))
+
+		shortCtx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		_, err = repo.List(shortCtx)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+	})
+
+	It("should time out calls without reaching the store", func() {
+		Expect(injector.SetRules([]chaos.Rule{
+			{Operation: chaos.OperationUpdate, Rate: 1, Fault: chaos.FaultDeadline},
+		})).To(Succeed())
+
+		_, err := userUseCase.UpdateUser(ctx, john.ID, "John Smith", "john@example.com")
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+		Expect(injector.SetRules(nil)).To(Succeed())
+		user, err := repo.GetByID(ctx, john.ID)
+		Expect(err).To(BeNil())
+		Expect(user.Name).To(Equal("John Doe"))
+	})
+
+	It("should keep the changes of a partial failure", func() {
+		Expect(injector.SetRules([]chaos.Rule{
+			{Operation: chaos.OperationUpdate, Rate: 1, Fault: chaos.FaultPartial},
+		})).To(Succeed())
+		user := *john
+		user.Name = "John Smith"
+
+		Expect(repo.Update(ctx, &user)).To(Equal(chaos.ErrInjected))
+
+		stored, err := stores.users.GetByID(ctx, john.ID)
+		Expect(err).To(BeNil())
+		Expect(stored.Name).To(Equal("John Smith"))
+	})
+
+	It("should roll back a partial failure within a transaction", func() {
+		Expect(injector.SetRules([]chaos.Rule{
+			{Operation: chaos.OperationUpdate, Rate: 1, Fault: chaos.FaultPartial},
+		})).To(Succeed())
+
+		_, err := userUseCase.UpdateUser(ctx, john.ID, "John Smith", "john@example.com")
+		Expect(err).To(Equal(chaos.ErrInjected))
+
+		stored, err := stores.users.GetByID(ctx, john.ID)
+		Expect(err).To(BeNil())
+		Expect(stored.Name).To(Equal("John Doe"))
+		entries, _, err := userUseCase.GetUserHistory(ctx, john.ID, 0, 10)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+	})
+}
+
+var _ = Describe("Faulty User Repository", func() {
+	Describe("with the memory store", func() {
+		faultySpecs(func() transactionalStores {
+			return transactionalStores{
+				users:   database.NewInMemoryUserRepository(),
+				history: database.NewInMemoryHistoryRepository(),
+				tx:      database.NewInMemoryTransactionManager(),
+			}
+		})
+	})
+
+	Describe("with the SQL store", func() {
+		faultySpecs(func() transactionalStores {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "faulty.db"))
+			DeferCleanup(db.Close)
+			return transactionalStores{
+				users:   database.NewSQLUserRepository(db),
+				history: database.NewSQLHistoryRepository(db),
+				tx:      database.NewSQLTransactionManager(db),
+			}
+		})
+	})
+
+	DescribeTable("should reject invalid rules, keeping the ones in force",
+		func(rule chaos.Rule, message string) {
+			injector := chaos.NewInjector()
+			kept := chaos.Rule{Rate: 1, Fault: chaos.FaultError}
+			Expect(injector.SetRules([]chaos.Rule{kept})).To(Succeed())
+
+			err := injector.SetRules([]chaos.Rule{kept, rule})
+
+			Expect(err).To(MatchError(chaos.ErrInvalidRule))
+			Expect(err).To(MatchError(HaveSuffix("rule 2: " + message)))
+			Expect(injector.Rules()).To(Equal([]chaos.Rule{kept}))
+		},
+		Entry("unknown operation", chaos.Rule{Operation: "upsert", Rate: 1, Fault: chaos.FaultError}, `unknown operation "upsert"`),
+		Entry("reversed ID range", chaos.Rule{MinID: 10, MaxID: 5, Rate: 1, Fault: chaos.FaultError}, "invalid ID range 10 to 5"),
+		Entry("zero rate", chaos.Rule{Fault: chaos.FaultError}, "invalid rate 0, it must be above 0 and up to 1"),
+		Entry("rate over 1", chaos.Rule{Rate: 1.5, Fault: chaos.FaultError}, "invalid rate 1.5, it must be above 0 and up to 1"),
+		Entry("negative latency", chaos.Rule{Rate: 1, Latency: -time.Second}, "invalid latency -1s"),
+		Entry("nothing to inject", chaos.Rule{Rate: 1}, "rule has neither a latency nor a fault"),
+		Entry("unknown fault", chaos.Rule{Rate: 1, Fault: "explode"}, `unknown fault "explode"`),
+	)
+})
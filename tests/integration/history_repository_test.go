@@ -0,0 +1,167 @@
+package integration_test
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/requestctx"
+	"agent-orchestration/use_cases"
+)
+
+// historyRepositorySpecs declares the specs shared by every HistoryRepository
+func historyRepositorySpecs(newRepo func() repository.HistoryRepository) {
+	var (
+		repo repository.HistoryRepository
+		ctx  context.Context
+	)
+
+	BeforeEach(func() {
+		repo = newRepo()
+		ctx = context.Background()
+	})
+
+	appendEntry := func(userID int, operation string) *entities.HistoryEntry {
+		entry := &entities.HistoryEntry{
+			UserID:    userID,
+			Operation: operation,
+			Changes:   []entities.FieldChange{{Field: "name", Before: "Old", After: "New"}},
+			Timestamp: time.Now().UTC().Truncate(time.Second),
+			RequestID: "req-1",
+			Principal: "admin",
+		}
+		Expect(repo.Append(ctx, entry)).To(Succeed())
+		return entry
+	}
+
+	It("should assign increasing IDs", func() {
+		first := appendEntry(1, entities.OperationCreated)
+		second := appendEntry(1, entities.OperationUpdated)
+		Expect(first.ID).To(BeNumerically(">", 0))
+		Expect(second.ID).To(BeNumerically(">", first.ID))
+	})
+
+	It("should return the entries of one user, oldest first", func() {
+		created := appendEntry(1, entities.OperationCreated)
+		appendEntry(2, entities.OperationCreated)
+		updated := appendEntry(1, entities.OperationUpdated)
+
+		entries, total, err := repo.ListByUser(ctx, 1, 0, 10)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(2))
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].ID).To(Equal(created.ID))
+		Expect(entries[1].ID).To(Equal(updated.ID))
+
+		Expect(entries[0].Operation).To(Equal(entities.OperationCreated))
+		Expect(entries[0].Changes).To(Equal(created.Changes))
+		Expect(entries[0].Timestamp.Equal(created.Timestamp)).To(BeTrue())
+		Expect(entries[0].RequestID).To(Equal("req-1"))
+		Expect(entries[0].Principal).To(Equal("admin"))
+	})
+
+	It("should paginate with offset and limit", func() {
+		for i := 0; i < 5; i++ {
+			appendEntry(1, entities.OperationUpdated)
+		}
+
+		entries, total, err := repo.ListByUser(ctx, 1, 3, 10)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(5))
+		Expect(entries).To(HaveLen(2))
+
+		entries, _, err = repo.ListByUser(ctx, 1, 1, 2)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(2))
+	})
+
+	It("should only return the entries of the tenant of the context", func() {
+		entry := &entities.HistoryEntry{
+			UserID:    1,
+			TenantID:  "acme",
+			Operation: entities.OperationCreated,
+			Timestamp: time.Now().UTC().Truncate(time.Second),
+		}
+		Expect(repo.Append(ctx, entry)).To(Succeed())
+
+		entries, total, err := repo.ListByUser(ctx, 1, 0, 10)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(0))
+		Expect(entries).To(BeEmpty())
+
+		entries, total, err = repo.ListByUser(repository.WithTenant(ctx, "acme"), 1, 0, 10)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(1))
+		Expect(entries[0].TenantID).To(Equal("acme"))
+	})
+
+	It("should return an empty, non-nil page for a user without history", func() {
+		entries, total, err := repo.ListByUser(ctx, 42, 0, 10)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(0))
+		Expect(entries).NotTo(BeNil())
+		Expect(entries).To(BeEmpty())
+	})
+}
+
+var _ = Describe("HistoryRepository", func() {
+	Describe("InMemoryHistoryRepository", func() {
+		historyRepositorySpecs(database.NewInMemoryHistoryRepository)
+	})
+
+	Describe("SQLHistoryRepository", func() {
+		historyRepositorySpecs(func() repository.HistoryRepository {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "history.db"))
+			DeferCleanup(db.Close)
+			return database.NewSQLHistoryRepository(db)
+		})
+	})
+})
+
+var _ = Describe("User history", func() {
+	It("should record every change and keep it after the user is purged", func() {
+		db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "users.db"))
+		DeferCleanup(db.Close)
+
+		userUseCase := use_cases.NewUserUseCase(
+			database.NewSQLUserRepository(db),
+			use_cases.WithHistory(database.NewSQLHistoryRepository(db)),
+		)
+		ctx := requestctx.WithPrincipal(requestctx.WithRequestID(context.Background(), "req-42"), "support")
+
+		user, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		_, err = userUseCase.UpdateUser(ctx, user.ID, "", "johnny@example.com")
+		Expect(err).To(BeNil())
+		Expect(userUseCase.DeleteUser(ctx, user.ID)).To(Succeed())
+		purged, err := userUseCase.PurgeDeletedUsers(ctx, 0)
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(1))
+
+		entries, total, err := userUseCase.GetUserHistory(ctx, user.ID, 0, 0)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(4))
+
+		operations := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			operations = append(operations, entry.Operation)
+			Expect(entry.RequestID).To(Equal("req-42"))
+			Expect(entry.Principal).To(Equal("support"))
+		}
+		Expect(operations).To(Equal([]string{
+			entities.OperationCreated,
+			entities.OperationUpdated,
+			entities.OperationDeleted,
+			entities.OperationPurged,
+		}))
+		Expect(entries[1].Changes).To(Equal([]entities.FieldChange{
+			{Field: "email", Before: "john@example.com", After: "johnny@example.com"},
+		}))
+	})
+})
@@ -0,0 +1,160 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/infrastructure/metrics"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/mocks"
+	"agent-orchestration/internal/requestctx"
+)
+
+// instrumentedSpecs declares the instrumentation specs run against every
+// store
+func instrumentedSpecs(newRepo func() repository.UserRepository) {
+	var (
+		ctx  context.Context
+		repo *metrics.InstrumentedUserRepository
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		repo = metrics.NewInstrumentedUserRepository(newRepo(), metrics.Options{})
+	})
+
+	It("should count calls and errors by kind", func() {
+		user := &entities.User{Name: "John Doe", Email: "john@example.com"}
+		Expect(repo.Create(ctx, user)).To(Succeed())
+		Expect(repo.Create(ctx, &entities.User{Name: "John Again", Email: "john@example.com"})).To(Equal(entities.ErrUserAlreadyExists))
+
+		_, err := repo.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		_, err = repo.GetByID(ctx, user.ID+100)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+
+		stale := *user
+		user.Name = "John Smith"
+		Expect(repo.Update(ctx, user)).To(Succeed())
+		Expect(repo.Update(ctx, &stale)).To(Equal(entities.ErrVersionConflict))
+
+		_, err = repo.ListPage(ctx, repository.ListOptions{Limit: 10, Cursor: "bogus"})
+		Expect(err).To(Equal(entities.ErrInvalidCursor))
+
+		stats := repo.Stats()
+		Expect(stats[metrics.OperationCreate].Calls).To(Equal(uint64(2)))
+		Expect(stats[metrics.OperationCreate].Errors).To(Equal(metrics.ErrorCounts{Conflict: 1}))
+		Expect(stats[metrics.OperationGetByID].Errors).To(Equal(metrics.ErrorCounts{NotFound: 1}))
+		Expect(stats[metrics.OperationUpdate].Errors).To(Equal(metrics.ErrorCounts{Conflict: 1}))
+		Expect(stats[metrics.OperationListPage].Errors).To(Equal(metrics.ErrorCounts{Other: 1}))
+		Expect(stats[metrics.OperationDelete].Calls).To(BeZero())
+
+		latency := stats[metrics.OperationGetByID].Latency
+		Expect(latency.Count).To(Equal(uint64(2)))
+		Expect(latency.Buckets).To(HaveLen(len(metrics.DefaultBuckets) + 1))
+		Expect(latency.Buckets[len(latency.Buckets)-1].UpperBound).To(Equal("+Inf"))
+		Expect(latency.SumSeconds).To(BeNumerically(">", 0))
+	})
+
+	It("should forward backups to the store", func() {
+		Expect(repo.Create(ctx, &entities.User{Name: "John Doe", Email: "john@example.com"})).To(Succeed())
+
+		snapshot, err := repo.SnapshotUsers(ctx)
+		Expect(err).To(BeNil())
+		Expect(snapshot.Users).To(HaveLen(1))
+		Expect(repo.RestoreUsers(ctx, snapshot)).To(Succeed())
+
+		stats := repo.Stats()
+		Expect(stats[metrics.OperationSnapshotUsers].Calls).To(Equal(uint64(1)))
+		Expect(stats[metrics.OperationRestoreUsers].Calls).To(Equal(uint64(1)))
+	})
+}
+
+var _ = Describe("Instrumented User Repository", func() {
+	Describe("with the memory store", func() {
+		instrumentedSpecs(func() repository.UserRepository {
+			return database.NewInMemoryUserRepository()
+		})
+	})
+
+	Describe("with the SQL store", func() {
+		instrumentedSpecs(func() repository.UserRepository {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "instrumented.db"))
+			DeferCleanup(db.Close)
+			return database.NewSQLUserRepository(db)
+		})
+	})
+
+	Describe("with a slow store", func() {
+		var (
+			ctx     context.Context
+			backend *mocks.UserRepositoryMock
+			release chan struct{}
+			logs    *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			ctx = requestctx.WithRequestID(context.Background(), "req-42")
+			release = make(chan struct{})
+			backend = &mocks.UserRepositoryMock{
+				GetByIDFunc: func(ctx context.Context, id int) (*entities.User, error) {
+					<-release
+					return &entities.User{ID: id}, nil
+				},
+				GetByEmailFunc: func(ctx context.Context, email string) (*entities.User, error) {
+					return nil, entities.ErrUserNotFound
+				},
+			}
+
+			logs = &bytes.Buffer{}
+			previous := log.Writer()
+			log.SetOutput(logs)
+			DeferCleanup(func() { log.SetOutput(previous) })
+		})
+
+		It("should count the calls in flight", func() {
+			repo := metrics.NewInstrumentedUserRepository(backend, metrics.Options{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				repo.GetByID(ctx, 1)
+			}()
+
+			Eventually(func() int64 { return repo.Stats()[metrics.OperationGetByID].InFlight }).Should(Equal(int64(1)))
+			Expect(repo.Stats()[metrics.OperationGetByID].Calls).To(BeZero())
+
+			close(release)
+			Eventually(done).Should(BeClosed())
+			Expect(repo.Stats()[metrics.OperationGetByID].InFlight).To(BeZero())
+			Expect(repo.Stats()[metrics.OperationGetByID].Calls).To(Equal(uint64(1)))
+		})
+
+		It("should log the calls over the threshold with their request ID", func() {
+			repo := metrics.NewInstrumentedUserRepository(backend, metrics.Options{
+				SlowThreshold: 20 * time.Millisecond,
+				Buckets:       []time.Duration{10 * time.Millisecond},
+			})
+			time.AfterFunc(30*time.Millisecond, func() { close(release) })
+
+			_, err := repo.GetByID(ctx, 1)
+			Expect(err).To(BeNil())
+			_, err = repo.GetByEmail(ctx, "john@example.com")
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+
+			Expect(logs.String()).To(ContainSubstring("Slow user store get_by_id took"))
+			Expect(logs.String()).To(ContainSubstring("(request req-42)"))
+			Expect(logs.String()).NotTo(ContainSubstring("get_by_email"))
+
+			latency := repo.Stats()[metrics.OperationGetByID].Latency
+			Expect(latency.Buckets).To(Equal([]metrics.Bucket{{UpperBound: "10ms", Count: 0}, {UpperBound: "+Inf", Count: 1}}))
+		})
+	})
+})
@@ -0,0 +1,301 @@
+package integration_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+var _ = Describe("Schema Migration Integration Tests", func() {
+	var (
+		db     *sql.DB
+		dbPath string
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		var err error
+		dbPath = filepath.Join(GinkgoT().TempDir(), "migrate.db")
+		db, err = database.OpenSQLite(dbPath)
+		Expect(err).To(BeNil())
+		DeferCleanup(func() {
+			db.Close()
+		})
+		ctx = context.Background()
+	})
+
+	tableExists := func(name string) bool {
+		var count int
+		err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+		Expect(err).To(BeNil())
+		return count == 1
+	}
+
+	It("should apply, report and revert the embedded migrations in order", func() {
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		migrations := migrator.Migrations()
+		Expect(migrations).NotTo(BeEmpty())
+
+		statuses, err := migrator.Status(ctx)
+		Expect(err).To(BeNil())
+		for _, status := range statuses {
+			Expect(status.Applied).To(BeFalse())
+		}
+
+		applied, err := migrator.Up(ctx)
+		Expect(err).To(BeNil())
+		Expect(applied).To(Equal(migrations))
+		Expect(tableExists("users")).To(BeTrue())
+
+		applied, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+		Expect(applied).To(BeEmpty())
+
+		statuses, err = migrator.Status(ctx)
+		Expect(err).To(BeNil())
+		for _, status := range statuses {
+			Expect(status.Applied).To(BeTrue())
+			Expect(status.AppliedAt).NotTo(BeZero())
+		}
+
+		reverted, err := migrator.Down(ctx, len(migrations))
+		Expect(err).To(BeNil())
+		Expect(reverted).To(HaveLen(len(migrations)))
+		Expect(reverted[0].Version).To(Equal(migrations[len(migrations)-1].Version))
+		Expect(tableExists("users")).To(BeFalse())
+	})
+
+	It("should key existing emails and leave colliding ones to the duplicates report", func() {
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		_, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+
+		// Go back to the schema before emails were normalized
+		steps := 0
+		for _, migration := range migrator.Migrations() {
+			if migration.Version >= 5 {
+				steps++
+			}
+		}
+		_, err = migrator.Down(ctx, steps)
+		Expect(err).To(BeNil())
+
+		now := time.Now()
+		for _, email := range []string{"John@Example.com", "John@example.com", "john@example.com"} {
+			_, err := db.Exec(`INSERT INTO users (name, email, created, updated) VALUES (?, ?, ?, ?)`,
+				"Legacy", email, now, now)
+			Expect(err).To(BeNil())
+		}
+
+		_, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+
+		rows, err := db.Query(`SELECT id, email_normalized FROM users ORDER BY id`)
+		Expect(err).To(BeNil())
+		defer rows.Close()
+		keys := make(map[int]sql.NullString)
+		for rows.Next() {
+			var (
+				id  int
+				key sql.NullString
+			)
+			Expect(rows.Scan(&id, &key)).To(Succeed())
+			keys[id] = key
+		}
+		Expect(keys).To(Equal(map[int]sql.NullString{
+			1: {String: "John@example.com", Valid: true},
+			2: {},
+			3: {String: "john@example.com", Valid: true},
+		}))
+
+		userUseCase := use_cases.NewUserUseCase(database.NewSQLUserRepository(db))
+		duplicates, err := userUseCase.FindDuplicateEmails(ctx)
+		Expect(err).To(BeNil())
+		Expect(duplicates).To(HaveLen(1))
+		Expect(duplicates[0].EmailKey).To(Equal("John@example.com"))
+		Expect(duplicates[0].Users).To(HaveLen(2))
+		Expect(duplicates[0].Users[0].ID).To(Equal(1))
+		Expect(duplicates[0].Users[1].ID).To(Equal(2))
+	})
+
+	It("should make the users stored before statuses existed active", func() {
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		_, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+
+		// Go back to the schema before users had a status
+		steps := 0
+		for _, migration := range migrator.Migrations() {
+			if migration.Version >= 7 {
+				steps++
+			}
+		}
+		_, err = migrator.Down(ctx, steps)
+		Expect(err).To(BeNil())
+
+		now := time.Now()
+		_, err = db.Exec(`INSERT INTO users (name, email, email_normalized, created, updated) VALUES (?, ?, ?, ?, ?)`,
+			"Legacy", "legacy@example.com", "legacy@example.com", now, now)
+		Expect(err).To(BeNil())
+
+		_, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+
+		repo := database.NewSQLUserRepository(db)
+		user, err := repo.GetByEmail(ctx, "legacy@example.com")
+		Expect(err).To(BeNil())
+		Expect(user.Status).To(Equal(entities.StatusActive))
+		page, err := repo.ListPage(ctx, repository.ListOptions{Limit: 10, Status: entities.StatusActive})
+		Expect(err).To(BeNil())
+		Expect(page.Users).To(HaveLen(1))
+	})
+
+	It("should put the users stored before tenants existed in the default tenant", func() {
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		_, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+
+		// Go back to the schema before users had a tenant
+		steps := 0
+		for _, migration := range migrator.Migrations() {
+			if migration.Version >= 9 {
+				steps++
+			}
+		}
+		_, err = migrator.Down(ctx, steps)
+		Expect(err).To(BeNil())
+
+		now := time.Now()
+		_, err = db.Exec(`INSERT INTO users (name, email, email_normalized, created, updated) VALUES (?, ?, ?, ?, ?)`,
+			"Legacy", "legacy@example.com", "legacy@example.com", now, now)
+		Expect(err).To(BeNil())
+
+		_, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+
+		repo := database.NewSQLUserRepository(db)
+		user, err := repo.GetByEmail(ctx, "legacy@example.com")
+		Expect(err).To(BeNil())
+		Expect(user.TenantID).To(Equal(entities.DefaultTenant))
+
+		// The email is now only taken in the default tenant
+		other := &entities.User{Name: "Other", Email: "legacy@example.com", Created: now, Updated: now}
+		Expect(repo.Create(repository.WithTenant(ctx, "acme"), other)).To(Succeed())
+	})
+
+	It("should apply migrations once when several processes migrate concurrently", func() {
+		const migrators = 4
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			applied int
+		)
+
+		for i := 0; i < migrators; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				conn, err := database.OpenSQLite(dbPath)
+				Expect(err).To(BeNil())
+				defer conn.Close()
+
+				migrator, err := database.NewMigrator(conn)
+				Expect(err).To(BeNil())
+				migrations, err := migrator.Up(ctx)
+				Expect(err).To(BeNil())
+
+				mu.Lock()
+				applied += len(migrations)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		Expect(applied).To(Equal(len(migrator.Migrations())))
+	})
+
+	It("should wait while another process holds the lock", func() {
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		_, err = migrator.Status(ctx)
+		Expect(err).To(BeNil())
+
+		_, err = db.Exec(`INSERT INTO schema_migrations_lock (id, owner, acquired_at) VALUES (1, 'other', ?)`, time.Now().UTC())
+		Expect(err).To(BeNil())
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+		defer cancel()
+		_, err = migrator.Up(timeoutCtx)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(tableExists("users")).To(BeFalse())
+	})
+
+	It("should release the lock once migrated", func() {
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		_, err = migrator.Up(ctx)
+		Expect(err).To(BeNil())
+
+		var locks int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM schema_migrations_lock`).Scan(&locks)).To(Succeed())
+		Expect(locks).To(BeZero())
+	})
+
+	It("should report a lock taken over while migrating", func() {
+		migrator, err := database.NewMigrator(db)
+		Expect(err).To(BeNil())
+		_, err = migrator.Status(ctx)
+		Expect(err).To(BeNil())
+
+		// Another process takes the lock over as soon as a migration is applied
+		_, err = db.Exec(`CREATE TRIGGER steal_lock AFTER INSERT ON schema_migrations
+BEGIN
+	UPDATE schema_migrations_lock SET owner = 'other';
+END`)
+		Expect(err).To(BeNil())
+
+		_, err = migrator.Up(ctx)
+		Expect(err).To(MatchError(database.ErrMigrationLockLost))
+	})
+
+	It("should load migrations ordered by version", func() {
+		migrations, err := database.LoadMigrations(fstest.MapFS{
+			"0002_second.up.sql":  {Data: []byte("SELECT 2")},
+			"0001_first.up.sql":   {Data: []byte("SELECT 1")},
+			"0001_first.down.sql": {Data: []byte("SELECT -1")},
+			"0010_tenth.up.sql":   {Data: []byte("SELECT 10")},
+			"README.md":           {Data: []byte("ignored")},
+		})
+		Expect(err).To(BeNil())
+		Expect(migrations).To(HaveLen(3))
+		Expect(migrations[0]).To(Equal(database.Migration{Version: 1, Name: "first", Up: "SELECT 1", Down: "SELECT -1"}))
+		Expect(migrations[1].Version).To(Equal(2))
+		Expect(migrations[2].Version).To(Equal(10))
+	})
+
+	It("should reject a migration without an up script", func() {
+		_, err := database.LoadMigrations(fstest.MapFS{
+			"0001_first.down.sql": {Data: []byte("SELECT 1")},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
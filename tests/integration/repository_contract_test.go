@@ -0,0 +1,34 @@
+package integration_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/testutils"
+)
+
+var _ = Describe("UserRepository contract", func() {
+	Describe("InMemoryUserRepository", func() {
+		testutils.UserRepositoryContract(func() repository.UserRepository {
+			return database.NewInMemoryUserRepository()
+		})
+	})
+
+	Describe("ShardedInMemoryUserRepository", func() {
+		testutils.UserRepositoryContract(func() repository.UserRepository {
+			// Few shards, so users and emails share locks as well
+			return database.NewShardedInMemoryUserRepository(4)
+		})
+	})
+
+	Describe("SQLUserRepository", func() {
+		testutils.UserRepositoryContract(func() repository.UserRepository {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "contract.db"))
+			DeferCleanup(db.Close)
+			return database.NewSQLUserRepository(db)
+		})
+	})
+})
@@ -0,0 +1,243 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/infrastructure/notification"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+// retentionSpecs declares the retention policy specs run against every store
+func retentionSpecs(newStores func() transactionalStores) {
+	var (
+		stores      transactionalStores
+		ctx         context.Context
+		userUseCase *use_cases.UserUseCase
+		webhook     *httptest.Server
+		mutex       sync.Mutex
+		events      []notification.InactiveEvent
+		failing     bool
+	)
+
+	policy := use_cases.RetentionPolicy{Months: 12, Warning: 14 * 24 * time.Hour}
+
+	BeforeEach(func() {
+		stores = newStores()
+		ctx = context.Background()
+		events, failing = nil, false
+
+		webhook = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if failing {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			var event notification.InactiveEvent
+			Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+			events = append(events, event)
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		DeferCleanup(webhook.Close)
+
+		userUseCase = use_cases.NewUserUseCase(stores.users,
+			use_cases.WithHistory(stores.history),
+			use_cases.WithTransactions(stores.tx),
+			use_cases.WithRetention(policy, notification.NewWebhookNotifier(webhook.URL, time.Second)))
+	})
+
+	received := func() []notification.InactiveEvent {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]notification.InactiveEvent(nil), events...)
+	}
+
+	// createInactive stores a user last updated at updated
+	createInactive := func(name, email string, updated time.Time) *entities.User {
+		user := &entities.User{Name: name, Email: email, Created: updated, Updated: updated}
+		Expect(stores.users.Create(ctx, user)).To(Succeed())
+		return user
+	}
+
+	// backdateWarning moves the warning of a user back by d, as if that much
+	// time had passed since it was sent
+	backdateWarning := func(id int, d time.Duration) {
+		user, err := stores.users.GetByID(ctx, id)
+		Expect(err).To(BeNil())
+		warnedAt := user.InactivityWarnedAt.Add(-d)
+		user.InactivityWarnedAt = &warnedAt
+		Expect(stores.users.Update(ctx, user)).To(Succeed())
+	}
+
+	It("should warn inactive users and delete them once the warning is up", func() {
+		longAgo := time.Now().AddDate(-2, 0, 0).UTC().Truncate(time.Second)
+		inactive := createInactive("Inactive User", "inactive@example.com", longAgo)
+		held := createInactive("Held User", "held@example.com", longAgo)
+		active, err := userUseCase.CreateUser(ctx, "Active User", "active@example.com")
+		Expect(err).To(BeNil())
+		_, err = userUseCase.SetLegalHold(ctx, held.ID, true)
+		Expect(err).To(BeNil())
+
+		report, err := userUseCase.EnforceRetention(ctx, false)
+		Expect(err).To(BeNil())
+		Expect(report.Warned).To(Equal(1))
+		Expect(report.Held).To(Equal(1))
+		Expect(report.Rows).To(HaveLen(2))
+
+		Expect(received()).To(HaveLen(1))
+		event := received()[0]
+		Expect(event.Event).To(Equal(notification.EventUserInactive))
+		Expect(event.UserID).To(Equal(inactive.ID))
+		Expect(event.Email).To(Equal("inactive@example.com"))
+		Expect(event.LastActive).To(BeTemporally("~", longAgo, time.Millisecond))
+		Expect(event.DeleteAfter).To(BeTemporally("~", time.Now().Add(policy.Warning), time.Minute))
+
+		stored, err := stores.users.GetByID(ctx, inactive.ID)
+		Expect(err).To(BeNil())
+		Expect(stored.WarnedOfInactivity()).To(BeTrue())
+		Expect(stored.Updated).To(BeTemporally("~", longAgo, time.Millisecond))
+
+		// Warned users wait out the warning
+		report, err = userUseCase.EnforceRetention(ctx, false)
+		Expect(err).To(BeNil())
+		Expect(report.Pending).To(Equal(1))
+		Expect(report.Deleted).To(Equal(0))
+		Expect(received()).To(HaveLen(1))
+
+		backdateWarning(inactive.ID, policy.Warning+time.Hour)
+		report, err = userUseCase.EnforceRetention(ctx, false)
+		Expect(err).To(BeNil())
+		Expect(report.Deleted).To(Equal(1))
+
+		_, err = userUseCase.GetUserByID(ctx, inactive.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		_, err = userUseCase.GetUserByID(ctx, held.ID)
+		Expect(err).To(BeNil())
+		_, err = userUseCase.GetUserByID(ctx, active.ID)
+		Expect(err).To(BeNil())
+
+		entries, _, err := userUseCase.GetUserHistory(ctx, inactive.ID, 0, 10)
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Operation).To(Equal(entities.OperationWarned))
+		Expect(entries[1].Operation).To(Equal(entities.OperationDeleted))
+		Expect(entries[1].Principal).To(Equal(use_cases.RetentionPrincipal))
+	})
+
+	It("should warn again a user active since the warning", func() {
+		longAgo := time.Now().AddDate(-2, 0, 0)
+		user := createInactive("Inactive User", "inactive@example.com", longAgo)
+
+		_, err := userUseCase.EnforceRetention(ctx, false)
+		Expect(err).To(BeNil())
+
+		// Warned long ago, and updated after the warning but still long ago
+		stored, err := stores.users.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		warnedAt := longAgo.Add(24 * time.Hour)
+		stored.InactivityWarnedAt = &warnedAt
+		stored.Updated = warnedAt.Add(24 * time.Hour)
+		Expect(stores.users.Update(ctx, stored)).To(Succeed())
+
+		report, err := userUseCase.EnforceRetention(ctx, false)
+		Expect(err).To(BeNil())
+		Expect(report.Warned).To(Equal(1))
+		Expect(report.Deleted).To(Equal(0))
+		Expect(received()).To(HaveLen(2))
+	})
+
+	It("should change nothing on a dry run", func() {
+		user := createInactive("Inactive User", "inactive@example.com", time.Now().AddDate(-2, 0, 0))
+
+		report, err := userUseCase.EnforceRetention(ctx, true)
+		Expect(err).To(BeNil())
+		Expect(report.Warned).To(Equal(1))
+		Expect(received()).To(BeEmpty())
+
+		stored, err := stores.users.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(stored.InactivityWarnedAt).To(BeNil())
+		Expect(stored.Version).To(Equal(user.Version))
+	})
+
+	It("should retry a warning the webhook refused", func() {
+		user := createInactive("Inactive User", "inactive@example.com", time.Now().AddDate(-2, 0, 0))
+		mutex.Lock()
+		failing = true
+		mutex.Unlock()
+
+		report, err := userUseCase.EnforceRetention(ctx, false)
+		Expect(err).To(BeNil())
+		Expect(report.Failed).To(Equal(1))
+		Expect(report.Rows[0].Err).To(MatchError(ContainSubstring("502")))
+
+		stored, err := stores.users.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(stored.InactivityWarnedAt).To(BeNil())
+
+		mutex.Lock()
+		failing = false
+		mutex.Unlock()
+		report, err = userUseCase.EnforceRetention(ctx, false)
+		Expect(err).To(BeNil())
+		Expect(report.Warned).To(Equal(1))
+		Expect(received()).To(HaveLen(1))
+	})
+
+	It("should never purge a user on legal hold", func() {
+		user, err := userUseCase.CreateUser(ctx, "Held User", "held@example.com")
+		Expect(err).To(BeNil())
+		Expect(userUseCase.DeleteUser(ctx, user.ID)).To(Succeed())
+		held, err := userUseCase.SetLegalHold(ctx, user.ID, true)
+		Expect(err).To(BeNil())
+		Expect(held.IsDeleted()).To(BeTrue())
+
+		purged, err := userUseCase.PurgeDeletedUsers(ctx, 0)
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(0))
+
+		_, err = userUseCase.SetLegalHold(ctx, user.ID, false)
+		Expect(err).To(BeNil())
+		purged, err = userUseCase.PurgeDeletedUsers(ctx, 0)
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(1))
+		_, err = stores.users.GetByID(repository.IncludeDeleted(ctx), user.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+	})
+}
+
+var _ = Describe("Retention Policy", func() {
+	Describe("with the memory store", func() {
+		retentionSpecs(func() transactionalStores {
+			return transactionalStores{
+				users:   database.NewInMemoryUserRepository(),
+				history: database.NewInMemoryHistoryRepository(),
+				tx:      database.NewInMemoryTransactionManager(),
+			}
+		})
+	})
+
+	Describe("with the SQL store", func() {
+		retentionSpecs(func() transactionalStores {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "retention.db"))
+			DeferCleanup(db.Close)
+			return transactionalStores{
+				users:   database.NewSQLUserRepository(db),
+				history: database.NewSQLHistoryRepository(db),
+				tx:      database.NewSQLTransactionManager(db),
+			}
+		})
+	})
+})
@@ -0,0 +1,131 @@
+package integration_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+var _ = Describe("SQL User Repository Integration Tests", func() {
+	var (
+		db     *sql.DB
+		dbPath string
+		repo   repository.UserRepository
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		dbPath = filepath.Join(GinkgoT().TempDir(), "users.db")
+		db = openMigratedSQLite(dbPath)
+		DeferCleanup(func() {
+			db.Close()
+		})
+
+		repo = database.NewSQLUserRepository(db)
+		ctx = context.Background()
+	})
+
+	It("should handle repository operations correctly", func() {
+		user := &entities.User{
+			Name:    "SQL Test User",
+			Email:   "sql@example.com",
+			Created: time.Now(),
+			Updated: time.Now(),
+		}
+
+		err := repo.Create(ctx, user)
+		Expect(err).To(BeNil())
+		Expect(user.ID).To(BeNumerically(">", 0))
+
+		retrieved, err := repo.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(retrieved.Name).To(Equal(user.Name))
+		Expect(retrieved.Created).To(BeTemporally("==", user.Created))
+
+		retrieved, err = repo.GetByEmail(ctx, user.Email)
+		Expect(err).To(BeNil())
+		Expect(retrieved.ID).To(Equal(user.ID))
+
+		user.Name = "Updated SQL User"
+		Expect(repo.Update(ctx, user)).To(Succeed())
+
+		retrieved, err = repo.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(retrieved.Name).To(Equal("Updated SQL User"))
+
+		users, err := repo.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+
+		Expect(repo.Delete(ctx, user.ID)).To(Succeed())
+
+		_, err = repo.GetByID(ctx, user.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+	})
+
+	It("should report missing users with ErrUserNotFound", func() {
+		_, err := repo.GetByEmail(ctx, "missing@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+
+		err = repo.Update(ctx, &entities.User{ID: 42, Name: "Nobody", Email: "nobody@example.com"})
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+
+		err = repo.Delete(ctx, 42)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+	})
+
+	It("should enforce email uniqueness on create and update", func() {
+		userUseCase := use_cases.NewUserUseCase(repo)
+
+		john, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		_, err = userUseCase.CreateUser(ctx, "Jane Doe", "jane@example.com")
+		Expect(err).To(BeNil())
+
+		err = repo.Create(ctx, &entities.User{Name: "Copy", Email: "john@example.com"})
+		Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+
+		_, err = userUseCase.UpdateUser(ctx, john.ID, "", "jane@example.com")
+		Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+	})
+
+	It("should keep users after the database is reopened", func() {
+		userUseCase := use_cases.NewUserUseCase(repo)
+		created, err := userUseCase.CreateUser(ctx, "Durable User", "durable@example.com")
+		Expect(err).To(BeNil())
+		Expect(db.Close()).To(Succeed())
+
+		db = openMigratedSQLite(dbPath)
+		userUseCase = use_cases.NewUserUseCase(database.NewSQLUserRepository(db))
+
+		retrieved, err := userUseCase.GetUserByID(ctx, created.ID)
+		Expect(err).To(BeNil())
+		Expect(retrieved.Email).To(Equal("durable@example.com"))
+
+		next, err := userUseCase.CreateUser(ctx, "Next User", "next@example.com")
+		Expect(err).To(BeNil())
+		Expect(next.ID).To(BeNumerically(">", created.ID))
+	})
+})
+
+// openMigratedSQLite opens the SQLite database at path with every migration applied
+func openMigratedSQLite(path string) *sql.DB {
+	db, err := database.OpenSQLite(path)
+	Expect(err).To(BeNil())
+
+	migrator, err := database.NewMigrator(db)
+	Expect(err).To(BeNil())
+	_, err = migrator.Up(context.Background())
+	Expect(err).To(BeNil())
+
+	return db
+}
@@ -0,0 +1,358 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+// transactionalStores are repositories sharing one transaction manager
+type transactionalStores struct {
+	users   repository.UserRepository
+	history repository.HistoryRepository
+	tx      repository.TransactionManager
+}
+
+// transactionManagerSpecs declares the specs shared by every TransactionManager
+func transactionManagerSpecs(newStores func() transactionalStores) {
+	var (
+		stores   transactionalStores
+		ctx      context.Context
+		errAbort = errors.New("abort")
+	)
+
+	BeforeEach(func() {
+		stores = newStores()
+		ctx = context.Background()
+	})
+
+	newUser := func(name, email string) *entities.User {
+		return &entities.User{Name: name, Email: email, Created: time.Now(), Updated: time.Now()}
+	}
+
+	historyOf := func(userID int) int {
+		_, total, err := stores.history.ListByUser(ctx, userID, 0, 10)
+		Expect(err).To(BeNil())
+		return total
+	}
+
+	It("should commit every change when fn succeeds", func() {
+		user := newUser("John Doe", "john@example.com")
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := stores.users.Create(ctx, user); err != nil {
+				return err
+			}
+			return stores.history.Append(ctx, &entities.HistoryEntry{UserID: user.ID, Operation: entities.OperationCreated, Timestamp: time.Now()})
+		})
+		Expect(err).To(BeNil())
+
+		_, err = stores.users.GetByID(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(historyOf(user.ID)).To(Equal(1))
+	})
+
+	It("should roll back every change when fn returns an error", func() {
+		existing := newUser("Jane Doe", "jane@example.com")
+		Expect(stores.users.Create(ctx, existing)).To(Succeed())
+
+		created := newUser("John Doe", "john@example.com")
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			Expect(stores.users.Create(ctx, created)).To(Succeed())
+			Expect(stores.history.Append(ctx, &entities.HistoryEntry{UserID: created.ID, Timestamp: time.Now()})).To(Succeed())
+
+			existing.Email = "janet@example.com"
+			Expect(stores.users.Update(ctx, existing)).To(Succeed())
+			return errAbort
+		})
+		Expect(err).To(Equal(errAbort))
+
+		_, err = stores.users.GetByID(ctx, created.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		_, err = stores.users.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+		Expect(historyOf(created.ID)).To(Equal(0))
+
+		found, err := stores.users.GetByEmail(ctx, "jane@example.com")
+		Expect(err).To(BeNil())
+		Expect(found.Version).To(Equal(1))
+		_, err = stores.users.GetByEmail(ctx, "janet@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+
+		// The email of the rolled back user is free again
+		Expect(stores.users.Create(ctx, newUser("John Again", "john@example.com"))).To(Succeed())
+	})
+
+	It("should roll back a delete", func() {
+		user := newUser("John Doe", "john@example.com")
+		Expect(stores.users.Create(ctx, user)).To(Succeed())
+
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			Expect(stores.users.Delete(ctx, user.ID)).To(Succeed())
+			return errAbort
+		})
+		Expect(err).To(Equal(errAbort))
+
+		found, err := stores.users.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(BeNil())
+		Expect(found.ID).To(Equal(user.ID))
+	})
+
+	It("should roll back and re-raise a panic", func() {
+		user := newUser("John Doe", "john@example.com")
+		Expect(func() {
+			stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				Expect(stores.users.Create(ctx, user)).To(Succeed())
+				panic("boom")
+			})
+		}).To(PanicWith("boom"))
+
+		_, err := stores.users.GetByEmail(ctx, "john@example.com")
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+
+		// The manager is usable after the panic
+		Expect(stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			return stores.users.Create(ctx, user)
+		})).To(Succeed())
+	})
+
+	It("should join an outer transaction when nested", func() {
+		user := newUser("John Doe", "john@example.com")
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				return stores.users.Create(ctx, user)
+			})
+			Expect(err).To(BeNil())
+
+			// The outer transaction sees the inner change
+			_, err = stores.users.GetByID(ctx, user.ID)
+			Expect(err).To(BeNil())
+			return errAbort
+		})
+		Expect(err).To(Equal(errAbort))
+
+		_, err = stores.users.GetByID(ctx, user.ID)
+		Expect(err).To(Equal(entities.ErrUserNotFound))
+	})
+
+	It("should run after-commit hooks once the transaction has committed", func() {
+		var ran []string
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			repository.AfterCommit(ctx, func() { ran = append(ran, "outer") })
+			err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				repository.AfterCommit(ctx, func() {
+					ran = append(ran, "inner")
+
+					// The transaction has released the repositories
+					Expect(stores.tx.WithinTransaction(context.Background(), func(ctx context.Context) error {
+						return stores.users.Create(ctx, newUser("Hook User", "hook@example.com"))
+					})).To(Succeed())
+				})
+				return stores.users.Create(ctx, newUser("John Doe", "john@example.com"))
+			})
+			Expect(err).To(BeNil())
+			Expect(ran).To(BeEmpty())
+			return nil
+		})
+		Expect(err).To(BeNil())
+		Expect(ran).To(Equal([]string{"outer", "inner"}))
+
+		users, err := stores.users.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(2))
+	})
+
+	It("should drop after-commit hooks when the transaction rolls back", func() {
+		ran := false
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			repository.AfterCommit(ctx, func() { ran = true })
+			return errAbort
+		})
+		Expect(err).To(Equal(errAbort))
+		Expect(ran).To(BeFalse())
+	})
+
+	It("should let exactly one of several concurrent check-then-create transactions win", func() {
+		userUseCase := use_cases.NewUserUseCase(stores.users,
+			use_cases.WithHistory(stores.history),
+			use_cases.WithTransactions(stores.tx),
+		)
+
+		const workers = 10
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+					return
+				}
+				Expect(err).To(Equal(entities.ErrUserAlreadyExists))
+			}()
+		}
+		wg.Wait()
+
+		Expect(succeeded).To(Equal(1))
+		users, err := stores.users.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+		Expect(historyOf(users[0].ID)).To(Equal(1))
+	})
+
+	It("should not create a user when its history cannot be recorded", func() {
+		failing := &failingHistoryRepository{HistoryRepository: stores.history}
+		userUseCase := use_cases.NewUserUseCase(stores.users,
+			use_cases.WithHistory(failing),
+			use_cases.WithTransactions(stores.tx),
+		)
+
+		_, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+		Expect(err).To(Equal(errHistoryUnavailable))
+
+		users, err := stores.users.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(BeEmpty())
+	})
+}
+
+var errHistoryUnavailable = errors.New("history unavailable")
+
+// failingHistoryRepository fails every Append
+type failingHistoryRepository struct {
+	repository.HistoryRepository
+}
+
+// Append always fails
+func (r *failingHistoryRepository) Append(ctx context.Context, entry *entities.HistoryEntry) error {
+	return errHistoryUnavailable
+}
+
+var _ = Describe("TransactionManager", func() {
+	Describe("InMemoryTransactionManager", func() {
+		transactionManagerSpecs(func() transactionalStores {
+			return transactionalStores{
+				users:   database.NewInMemoryUserRepository(),
+				history: database.NewInMemoryHistoryRepository(),
+				tx:      database.NewInMemoryTransactionManager(),
+			}
+		})
+	})
+
+	Describe("InMemoryTransactionManager with a durable repository", func() {
+		transactionManagerSpecs(func() transactionalStores {
+			repo, err := database.NewDurableInMemoryUserRepository(database.DurableOptions{Dir: GinkgoT().TempDir()})
+			Expect(err).To(BeNil())
+			DeferCleanup(repo.Close)
+			return transactionalStores{
+				users:   repo,
+				history: database.NewInMemoryHistoryRepository(),
+				tx:      database.NewInMemoryTransactionManager(),
+			}
+		})
+	})
+
+	Describe("SQLTransactionManager", func() {
+		transactionManagerSpecs(func() transactionalStores {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "tx.db"))
+			DeferCleanup(db.Close)
+			return transactionalStores{
+				users:   database.NewSQLUserRepository(db),
+				history: database.NewSQLHistoryRepository(db),
+				tx:      database.NewSQLTransactionManager(db),
+			}
+		})
+	})
+
+	It("should run in-memory transactions over different repositories alongside", func() {
+		ctx := context.Background()
+		tx := database.NewInMemoryTransactionManager()
+		first := database.NewInMemoryUserRepository()
+		second := database.NewInMemoryUserRepository()
+
+		holding := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			done <- tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				if err := first.Create(ctx, &entities.User{Name: "John Doe", Email: "john@example.com"}); err != nil {
+					return err
+				}
+				close(holding)
+				<-release
+				return nil
+			})
+		}()
+		<-holding
+
+		// The first repository stays locked until its transaction ends
+		created := make(chan error, 1)
+		go func() {
+			created <- tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				return second.Create(ctx, &entities.User{Name: "Jane Doe", Email: "jane@example.com"})
+			})
+		}()
+		Eventually(created).Should(Receive(BeNil()))
+
+		close(release)
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("should only persist committed transactions of a durable repository", func() {
+		dir := GinkgoT().TempDir()
+		ctx := context.Background()
+		tx := database.NewInMemoryTransactionManager()
+
+		repo, err := database.NewDurableInMemoryUserRepository(database.DurableOptions{Dir: dir})
+		Expect(err).To(BeNil())
+
+		committed := &entities.User{Name: "John Doe", Email: "john@example.com"}
+		Expect(tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := repo.Create(ctx, committed); err != nil {
+				return err
+			}
+			committed.Name = "Johnny"
+			return repo.Update(ctx, committed)
+		})).To(Succeed())
+
+		Expect(tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			Expect(repo.Create(ctx, &entities.User{Name: "Jane Doe", Email: "jane@example.com"})).To(Succeed())
+			return errors.New("abort")
+		})).NotTo(Succeed())
+
+		// Reopen from the log alone, without the snapshot Close would write
+		reopened, err := database.NewDurableInMemoryUserRepository(database.DurableOptions{Dir: dir})
+		Expect(err).To(BeNil())
+		DeferCleanup(reopened.Close)
+		DeferCleanup(repo.Close)
+
+		users, err := reopened.List(ctx)
+		Expect(err).To(BeNil())
+		Expect(users).To(HaveLen(1))
+		Expect(users[0].Name).To(Equal("Johnny"))
+		Expect(users[0].Version).To(Equal(2))
+
+		// The rolled back create did not consume an ID
+		next := &entities.User{Name: "Jane Doe", Email: "jane@example.com"}
+		Expect(reopened.Create(ctx, next)).To(Succeed())
+		Expect(next.ID).To(Equal(committed.ID + 1))
+	})
+})
@@ -10,6 +10,7 @@ import (
 
 	"agent-orchestration/entities"
 	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
 	"agent-orchestration/use_cases"
 )
 
@@ -41,8 +42,9 @@ var _ = Describe("User Integration Tests", func() {
 			Expect(user2.ID).To(BeNumerically(">", user1.ID))
 
 			// List users - should have 2 users
-			users, err := userUseCase.ListUsers(ctx)
+			page, err := userUseCase.ListUsers(ctx, repository.ListOptions{})
 			Expect(err).To(BeNil())
+			users := page.Users
 			Expect(users).To(HaveLen(2))
 
 			// Get user by ID
@@ -73,8 +75,9 @@ var _ = Describe("User Integration Tests", func() {
 			Expect(err).To(Equal(entities.ErrUserNotFound))
 
 			// List users - should have 1 user remaining
-			users, err = userUseCase.ListUsers(ctx)
+			page, err = userUseCase.ListUsers(ctx, repository.ListOptions{})
 			Expect(err).To(BeNil())
+			users = page.Users
 			Expect(users).To(HaveLen(1))
 			Expect(users[0].Name).To(Equal("Jane Doe"))
 		})
@@ -118,8 +121,9 @@ var _ = Describe("User Integration Tests", func() {
 			}
 			
 			// Verify all users were created
-			users, err := userUseCase.ListUsers(ctx)
+			page, err := userUseCase.ListUsers(ctx, repository.ListOptions{})
 			Expect(err).To(BeNil())
+			users := page.Users
 			Expect(users).To(HaveLen(numGoroutines))
 		})
 
@@ -0,0 +1,113 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/interfaces/repository"
+)
+
+// benchmarkUsers is the number of users each benchmark starts with
+const benchmarkUsers = 10000
+
+// workload is the share of each operation in a mixed benchmark, in percent.
+// The rest are reads by ID.
+type workload struct {
+	name    string
+	byEmail int
+	updates int
+	creates int
+}
+
+var workloads = []workload{
+	{name: "read-heavy", byEmail: 20, updates: 8, creates: 2},
+	{name: "balanced", byEmail: 15, updates: 30, creates: 20},
+	{name: "write-heavy", byEmail: 5, updates: 55, creates: 35},
+}
+
+var benchmarkRepositories = []struct {
+	name string
+	new  func() repository.UserRepository
+}{
+	{name: "in-memory", new: database.NewInMemoryUserRepository},
+	{name: "sharded", new: func() repository.UserRepository {
+		return database.NewShardedInMemoryUserRepository(database.DefaultShardCount)
+	}},
+}
+
+// BenchmarkUserRepositoryMixed runs each workload against the in-memory
+// repositories from parallel goroutines. Run with -cpu to vary the number of
+// goroutines, e.g. go test -run '^$' -bench Mixed -cpu 1,4,16.
+func BenchmarkUserRepositoryMixed(b *testing.B) {
+	for _, w := range workloads {
+		for _, r := range benchmarkRepositories {
+			b.Run(w.name+"/"+r.name, func(b *testing.B) {
+				benchmarkMixed(b, r.new(), w)
+			})
+		}
+	}
+}
+
+func benchmarkMixed(b *testing.B, repo repository.UserRepository, w workload) {
+	ctx := context.Background()
+	for i := 1; i <= benchmarkUsers; i++ {
+		user := &entities.User{
+			Name:    fmt.Sprintf("User %d", i),
+			Email:   fmt.Sprintf("user%d@example.com", i),
+			Created: time.Now(),
+			Updated: time.Now(),
+		}
+		if err := repo.Create(ctx, user); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var created atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := rand.IntN(benchmarkUsers) + 1
+			switch op := rand.IntN(100); {
+			case op < w.creates:
+				n := created.Add(1)
+				user := &entities.User{
+					Name:    fmt.Sprintf("New User %d", n),
+					Email:   fmt.Sprintf("new%d@example.com", n),
+					Created: time.Now(),
+					Updated: time.Now(),
+				}
+				if err := repo.Create(ctx, user); err != nil {
+					b.Error(err)
+				}
+			case op < w.creates+w.updates:
+				user, err := repo.GetByID(ctx, id)
+				if err != nil {
+					b.Error(err)
+					continue
+				}
+				user.Name = fmt.Sprintf("User %d (updated)", id)
+				user.Updated = time.Now()
+				// Other goroutines may update the same user in between
+				if err := repo.Update(ctx, user); err != nil && !errors.Is(err, entities.ErrVersionConflict) {
+					b.Error(err)
+				}
+			case op < w.creates+w.updates+w.byEmail:
+				if _, err := repo.GetByEmail(ctx, fmt.Sprintf("user%d@example.com", id)); err != nil {
+					b.Error(err)
+				}
+			default:
+				if _, err := repo.GetByID(ctx, id); err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/infrastructure/database"
+	"agent-orchestration/infrastructure/search"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/use_cases"
+)
+
+// userSearchSpecs declares the search specs shared by every store
+func userSearchSpecs(newStores func() transactionalStores) {
+	var (
+		stores      transactionalStores
+		index       *search.Index
+		userUseCase *use_cases.UserUseCase
+		ctx         context.Context
+	)
+
+	BeforeEach(func() {
+		stores = newStores()
+		ctx = context.Background()
+		index = search.NewIndex()
+		userUseCase = use_cases.NewUserUseCase(search.NewIndexedUserRepository(stores.users, index),
+			use_cases.WithHistory(stores.history),
+			use_cases.WithTransactions(stores.tx),
+			use_cases.WithSearch(index),
+		)
+	})
+
+	create := func(name, email string) *entities.User {
+		user, err := userUseCase.CreateUser(ctx, name, email)
+		Expect(err).To(BeNil())
+		return user
+	}
+
+	// names returns the names of the users found for query, best first
+	names := func(query string) []string {
+		results, _, err := userUseCase.SearchUsers(ctx, query, 0, 0)
+		Expect(err).To(BeNil())
+		found := make([]string, 0, len(results))
+		for _, result := range results {
+			found = append(found, result.User.Name)
+		}
+		return found
+	}
+
+	Describe("matching", func() {
+		BeforeEach(func() {
+			create("John Doe", "john.doe@example.com")
+			create("Jane Doe", "jane@example.com")
+			create("Johanna Smith", "jsmith@corp.example")
+		})
+
+		It("should match whole words of the name and email", func() {
+			Expect(names("doe")).To(ConsistOf("John Doe", "Jane Doe"))
+			Expect(names("corp")).To(ConsistOf("Johanna Smith"))
+		})
+
+		It("should match word prefixes", func() {
+			Expect(names("joh")).To(ConsistOf("John Doe", "Johanna Smith"))
+		})
+
+		It("should tolerate typos", func() {
+			Expect(names("Jhon")).To(ConsistOf("John Doe"))
+			Expect(names("smiht")).To(ConsistOf("Johanna Smith"))
+		})
+
+		It("should find a user by a misspelled or partial email address", func() {
+			Expect(names("jhon.doe@example.com")).To(Equal([]string{"John Doe"}))
+			Expect(names("JANE@exam")).To(Equal([]string{"Jane Doe"}))
+		})
+
+		It("should require every query term to match", func() {
+			Expect(names("jane doe")).To(Equal([]string{"Jane Doe"}))
+			Expect(names("jane smith")).To(BeEmpty())
+		})
+
+		It("should not match short terms with typos", func() {
+			Expect(names("dom")).To(BeEmpty())
+		})
+	})
+
+	It("should rank exact matches above prefixes and prefixes above typos", func() {
+		create("Ana Brown", "u1@example.com")
+		create("Annabel Lee", "u2@example.com")
+		create("Anna Jones", "u3@example.com")
+
+		Expect(names("anna")).To(Equal([]string{"Anna Jones", "Annabel Lee", "Ana Brown"}))
+	})
+
+	It("should rank name matches above matches in the email domain", func() {
+		create("Corp Admin", "admin@a.example")
+		create("Someone Else", "else@corp.example")
+
+		Expect(names("corp")).To(Equal([]string{"Corp Admin", "Someone Else"}))
+	})
+
+	It("should page through the results", func() {
+		for _, name := range []string{"Sam One", "Sam Two", "Sam Three"} {
+			create(name, name[4:]+"@example.com")
+		}
+
+		results, total, err := userUseCase.SearchUsers(ctx, "sam", 1, 1)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(3))
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].User.Name).To(Equal("Sam Two"))
+	})
+
+	It("should follow every change made through the repository", func() {
+		user := create("John Doe", "john@example.com")
+
+		_, err := userUseCase.UpdateUser(ctx, user.ID, "Johnny Walker", "")
+		Expect(err).To(BeNil())
+		Expect(names("doe")).To(BeEmpty())
+		Expect(names("walker")).To(Equal([]string{"Johnny Walker"}))
+
+		Expect(userUseCase.DeleteUser(ctx, user.ID)).To(Succeed())
+		Expect(names("walker")).To(BeEmpty())
+
+		_, err = userUseCase.RestoreUser(ctx, user.ID)
+		Expect(err).To(BeNil())
+		Expect(names("walker")).To(Equal([]string{"Johnny Walker"}))
+
+		Expect(userUseCase.DeleteUser(ctx, user.ID)).To(Succeed())
+		purged, err := userUseCase.PurgeDeletedUsers(ctx, -time.Hour)
+		Expect(err).To(BeNil())
+		Expect(purged).To(Equal(1))
+		Expect(names("walker")).To(BeEmpty())
+	})
+
+	It("should not index changes that are rolled back", func() {
+		indexed := search.NewIndexedUserRepository(stores.users, index)
+		err := stores.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			user := &entities.User{Name: "Ghost User", Email: "ghost@example.com", Created: time.Now(), Updated: time.Now()}
+			Expect(indexed.Create(ctx, user)).To(Succeed())
+			return errors.New("abort")
+		})
+		Expect(err).To(HaveOccurred())
+
+		Expect(names("ghost")).To(BeEmpty())
+	})
+
+	It("should only find the users of the tenant of the context", func() {
+		acme := repository.WithTenant(ctx, "acme")
+		_, err := userUseCase.CreateUser(acme, "John Doe", "john@example.com")
+		Expect(err).To(BeNil())
+		create("John Smith", "john@example.com")
+		Expect(stores.users.Create(repository.WithTenant(ctx, "globex"), &entities.User{Name: "John Unindexed", Email: "john@example.com"})).To(Succeed())
+		Expect(index.Rebuild(ctx, stores.users)).To(Succeed())
+
+		Expect(names("john")).To(Equal([]string{"John Smith"}))
+		results, total, err := userUseCase.SearchUsers(acme, "john", 0, 0)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(1))
+		Expect(results[0].User.Name).To(Equal("John Doe"))
+		results, _, err = userUseCase.SearchUsers(repository.WithTenant(ctx, "globex"), "john", 0, 0)
+		Expect(err).To(BeNil())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].User.Name).To(Equal("John Unindexed"))
+	})
+
+	It("should rebuild from the users already stored", func() {
+		create("John Doe", "john@example.com")
+		Expect(stores.users.Create(ctx, &entities.User{Name: "Unindexed User", Email: "unindexed@example.com"})).To(Succeed())
+		Expect(names("unindexed")).To(BeEmpty())
+
+		Expect(index.Rebuild(ctx, stores.users)).To(Succeed())
+		Expect(names("unindexed")).To(Equal([]string{"Unindexed User"}))
+		Expect(names("john")).To(Equal([]string{"John Doe"}))
+	})
+}
+
+var _ = Describe("User search", func() {
+	Describe("with the in-memory repository", func() {
+		userSearchSpecs(func() transactionalStores {
+			return transactionalStores{
+				users:   database.NewInMemoryUserRepository(),
+				history: database.NewInMemoryHistoryRepository(),
+				tx:      database.NewInMemoryTransactionManager(),
+			}
+		})
+	})
+
+	Describe("with the SQL repository", func() {
+		userSearchSpecs(func() transactionalStores {
+			db := openMigratedSQLite(filepath.Join(GinkgoT().TempDir(), "search.db"))
+			DeferCleanup(db.Close)
+			return transactionalStores{
+				users:   database.NewSQLUserRepository(db),
+				history: database.NewSQLHistoryRepository(db),
+				tx:      database.NewSQLTransactionManager(db),
+			}
+		})
+	})
+
+	It("should ignore changes applied out of order", func() {
+		index := search.NewIndex()
+		index.Put(&entities.User{ID: 1, Name: "Newer Name", Email: "user@example.com", Version: 2})
+		index.Put(&entities.User{ID: 1, Name: "Older Name", Email: "user@example.com", Version: 1})
+		index.Remove(2)
+		index.Put(&entities.User{ID: 2, Name: "Removed Name", Email: "removed@example.com", Version: 3})
+
+		hits, total, err := index.Search(context.Background(), "name", 0, 10)
+		Expect(err).To(BeNil())
+		Expect(total).To(Equal(1))
+		Expect(hits).To(Equal([]repository.SearchHit{{UserID: 1, Score: hits[0].Score}}))
+
+		hits, _, err = index.Search(context.Background(), "newer", 0, 10)
+		Expect(err).To(BeNil())
+		Expect(hits).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,159 @@
+package use_cases
+
+import (
+	"context"
+	"errors"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/requestctx"
+)
+
+// accessControl checks the permissions of the principals calling the use
+// case
+type accessControl struct {
+	// admins are principals that hold the admin role without a user record,
+	// so an empty store can be administered
+	admins map[string]bool
+}
+
+// WithAccessControl checks that the principal of every call holds the
+// permission it needs, returning ErrForbidden otherwise. A principal acts
+// as the user whose email it is in the tenant of the call and holds the
+// permissions of that user's roles, as long as the user is active. admins
+// hold the admin role in every tenant, and are the only principals allowed
+// calls spanning every tenant, such as backups. Without access control
+// every call is allowed.
+func WithAccessControl(admins ...string) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.access = &accessControl{admins: make(map[string]bool, len(admins))}
+		for _, admin := range admins {
+			uc.access.admins[admin] = true
+		}
+	}
+}
+
+type systemKey struct{}
+
+// SystemContext returns a context in which every call is allowed, for the
+// jobs the server runs on its own, such as scheduled purges. It can't be
+// reached from a request.
+func SystemContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// isSystem reports whether ctx comes from SystemContext
+func isSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+	return system
+}
+
+// authorize returns ErrForbidden unless the principal of ctx holds
+// permission for the user with ID id, or for users in general when id is
+// 0. Roles limited to the own record grant nothing for users in general.
+func (uc *UserUseCase) authorize(ctx context.Context, permission entities.Permission, id int) error {
+	if uc.access == nil || isSystem(ctx) {
+		return nil
+	}
+	forbidden := entities.ErrForbidden.WithDetail("permission", string(permission))
+
+	principal := requestctx.Principal(ctx)
+	if principal == "" {
+		return forbidden
+	}
+	if uc.access.admins[principal] {
+		return nil
+	}
+	// Roles are granted within a tenant, so they never reach every tenant
+	if repository.IncludesAllTenants(ctx) {
+		return forbidden
+	}
+
+	actor, err := uc.userRepo.GetByEmail(ctx, principal)
+	if errors.Is(err, entities.ErrUserNotFound) {
+		return forbidden
+	}
+	if err != nil {
+		return err
+	}
+	if actor.CurrentStatus() != entities.StatusActive {
+		return forbidden
+	}
+
+	for _, name := range actor.Roles {
+		role, err := entities.LookupRole(name)
+		if err != nil || !role.Grants(permission) {
+			continue
+		}
+		if !role.OwnRecordOnly || (id != 0 && id == actor.ID) {
+			return nil
+		}
+	}
+	return forbidden
+}
+
+// ListRoles returns the roles users can be assigned
+func (uc *UserUseCase) ListRoles(ctx context.Context) ([]*entities.Role, error) {
+	if err := uc.authorize(ctx, entities.PermissionReadUsers, 0); err != nil {
+		return nil, err
+	}
+	return entities.Roles(), nil
+}
+
+// AssignRole assigns the role called name to a user and records the change
+func (uc *UserUseCase) AssignRole(ctx context.Context, id int, name string) (*entities.User, error) {
+	return uc.changeRoles(ctx, id, name, true)
+}
+
+// RevokeRole revokes the role called name from a user and records the
+// change
+func (uc *UserUseCase) RevokeRole(ctx context.Context, id int, name string) (*entities.User, error) {
+	return uc.changeRoles(ctx, id, name, false)
+}
+
+// changeRoles assigns or revokes a role. A user that already has the
+// requested roles is left alone.
+func (uc *UserUseCase) changeRoles(ctx context.Context, id int, name string, assign bool) (*entities.User, error) {
+	if id <= 0 {
+		return nil, entities.ErrInvalidID
+	}
+	if _, err := entities.LookupRole(name); err != nil {
+		return nil, err
+	}
+	if err := uc.authorize(ctx, entities.PermissionManageRoles, id); err != nil {
+		return nil, err
+	}
+
+	var user *entities.User
+	err := uc.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = uc.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if user.HasRole(name) == assign {
+			return nil
+		}
+		before := *user
+
+		operation := entities.OperationRoleAssigned
+		if assign {
+			if err := user.AssignRole(name); err != nil {
+				return err
+			}
+		} else {
+			user.RevokeRole(name)
+			operation = entities.OperationRoleRevoked
+		}
+		if err := uc.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+
+		return uc.recordChange(ctx, operation, user.ID, &before, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
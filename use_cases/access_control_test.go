@@ -0,0 +1,203 @@
+package use_cases_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/mocks"
+	"agent-orchestration/internal/requestctx"
+	"agent-orchestration/use_cases"
+)
+
+var _ = Describe("Access control", func() {
+	var (
+		userUseCase *use_cases.UserUseCase
+		mockRepo    *mocks.UserRepositoryMock
+		historyRepo *mocks.HistoryRepositoryMock
+		users       map[int]*entities.User
+	)
+
+	// as returns a context whose principal is the email of the user with ID id
+	as := func(id int) context.Context {
+		return requestctx.WithPrincipal(context.Background(), users[id].Email)
+	}
+
+	BeforeEach(func() {
+		users = map[int]*entities.User{
+			1: {ID: 1, Name: "Ada", Email: "ada@example.com", Status: entities.StatusActive, Roles: []string{entities.RoleAdmin}},
+			2: {ID: 2, Name: "Sam", Email: "sam@example.com", Status: entities.StatusActive, Roles: []string{entities.RoleSupport}},
+			3: {ID: 3, Name: "Eve", Email: "eve@example.com", Status: entities.StatusActive, Roles: []string{entities.RoleSelfService}},
+			4: {ID: 4, Name: "Ned", Email: "ned@example.com", Status: entities.StatusActive},
+		}
+		mockRepo = &mocks.UserRepositoryMock{
+			GetByIDFunc: func(ctx context.Context, id int) (*entities.User, error) {
+				user, ok := users[id]
+				if !ok {
+					return nil, entities.ErrUserNotFound
+				}
+				copied := *user
+				return &copied, nil
+			},
+			GetByEmailFunc: func(ctx context.Context, email string) (*entities.User, error) {
+				for _, user := range users {
+					if user.Email == email {
+						copied := *user
+						return &copied, nil
+					}
+				}
+				return nil, entities.ErrUserNotFound
+			},
+			UpdateFunc: func(ctx context.Context, user *entities.User) error {
+				return nil
+			},
+			DeleteFunc: func(ctx context.Context, id int) error {
+				return nil
+			},
+		}
+		historyRepo = &mocks.HistoryRepositoryMock{
+			AppendFunc: func(ctx context.Context, entry *entities.HistoryEntry) error {
+				return nil
+			},
+		}
+		userUseCase = use_cases.NewUserUseCase(mockRepo,
+			use_cases.WithHistory(historyRepo),
+			use_cases.WithAccessControl("root@example.com"))
+	})
+
+	It("should allow everything without access control", func() {
+		userUseCase = use_cases.NewUserUseCase(mockRepo)
+
+		Expect(userUseCase.DeleteUser(context.Background(), 4)).To(Succeed())
+	})
+
+	It("should forbid calls without a principal", func() {
+		_, err := userUseCase.GetUserByID(context.Background(), 4)
+
+		Expect(err).To(MatchError(entities.ErrForbidden))
+		Expect(mockRepo.GetByIDCalls()).To(BeEmpty())
+	})
+
+	It("should forbid principals without a user", func() {
+		ctx := requestctx.WithPrincipal(context.Background(), "stranger@example.com")
+
+		_, err := userUseCase.GetUserByID(ctx, 4)
+
+		Expect(err).To(MatchError(entities.ErrForbidden))
+	})
+
+	It("should name the missing permission", func() {
+		err := userUseCase.DeleteUser(as(4), 1)
+
+		var domainErr *entities.Error
+		Expect(errors.As(err, &domainErr)).To(BeTrue())
+		Expect(domainErr.Details).To(Equal(map[string]any{"permission": "users:delete"}))
+	})
+
+	It("should allow the admin principals without a user", func() {
+		ctx := requestctx.WithPrincipal(context.Background(), "root@example.com")
+
+		Expect(userUseCase.DeleteUser(ctx, 4)).To(Succeed())
+	})
+
+	It("should allow admins everything", func() {
+		Expect(userUseCase.DeleteUser(as(1), 4)).To(Succeed())
+	})
+
+	It("should let support read and suspend users but not delete them", func() {
+		_, err := userUseCase.GetUserByID(as(2), 4)
+		Expect(err).To(BeNil())
+
+		_, err = userUseCase.SuspendUser(as(2), 4, "chargeback")
+		Expect(err).To(BeNil())
+
+		_, err = userUseCase.ActivateUser(as(2), 4, "cleared")
+		Expect(err).To(MatchError(entities.ErrForbidden))
+		Expect(userUseCase.DeleteUser(as(2), 4)).To(MatchError(entities.ErrForbidden))
+		Expect(mockRepo.DeleteCalls()).To(BeEmpty())
+	})
+
+	It("should limit self-service to the own record", func() {
+		_, err := userUseCase.UpdateUser(as(3), 3, "Eve Smith", "")
+		Expect(err).To(BeNil())
+
+		_, err = userUseCase.UpdateUser(as(3), 4, "Ned Smith", "")
+		Expect(err).To(MatchError(entities.ErrForbidden))
+
+		_, err = userUseCase.ListUsers(as(3), repository.ListOptions{})
+		Expect(err).To(MatchError(entities.ErrForbidden))
+	})
+
+	It("should forbid users that aren't active", func() {
+		users[1].Status = entities.StatusSuspended
+
+		Expect(userUseCase.DeleteUser(as(1), 4)).To(MatchError(entities.ErrForbidden))
+	})
+
+	It("should only let the admin principals reach every tenant", func() {
+		Expect(userUseCase.DeleteUser(repository.IncludeAllTenants(as(1)), 4)).To(MatchError(entities.ErrForbidden))
+
+		ctx := requestctx.WithPrincipal(context.Background(), "root@example.com")
+		Expect(userUseCase.DeleteUser(repository.IncludeAllTenants(ctx), 4)).To(Succeed())
+	})
+
+	It("should allow everything in a system context", func() {
+		Expect(userUseCase.DeleteUser(use_cases.SystemContext(context.Background()), 4)).To(Succeed())
+	})
+
+	Describe("Role assignment", func() {
+		It("should assign a role and record the change", func() {
+			user, err := userUseCase.AssignRole(as(1), 4, entities.RoleSupport)
+
+			Expect(err).To(BeNil())
+			Expect(user.Roles).To(Equal([]string{entities.RoleSupport}))
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+			entry := historyRepo.AppendCalls()[0].Entry
+			Expect(entry.Operation).To(Equal(entities.OperationRoleAssigned))
+			Expect(entry.Principal).To(Equal("ada@example.com"))
+			Expect(entry.Changes).To(Equal([]entities.FieldChange{
+				{Field: "roles", Before: "", After: "support"},
+			}))
+		})
+
+		It("should revoke a role", func() {
+			user, err := userUseCase.RevokeRole(as(1), 2, entities.RoleSupport)
+
+			Expect(err).To(BeNil())
+			Expect(user.Roles).To(BeNil())
+			Expect(historyRepo.AppendCalls()[0].Entry.Operation).To(Equal(entities.OperationRoleRevoked))
+		})
+
+		It("should leave a user that already has the role alone", func() {
+			_, err := userUseCase.AssignRole(as(1), 2, entities.RoleSupport)
+
+			Expect(err).To(BeNil())
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+			Expect(historyRepo.AppendCalls()).To(BeEmpty())
+		})
+
+		It("should reject an unknown role", func() {
+			_, err := userUseCase.AssignRole(as(1), 4, "root")
+
+			Expect(err).To(MatchError(entities.ErrUnknownRole))
+		})
+
+		It("should forbid users that can't manage roles from assigning themselves one", func() {
+			_, err := userUseCase.AssignRole(as(3), 3, entities.RoleAdmin)
+
+			Expect(err).To(MatchError(entities.ErrForbidden))
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+		})
+
+		It("should list the roles", func() {
+			roles, err := userUseCase.ListRoles(as(2))
+
+			Expect(err).To(BeNil())
+			Expect(roles).To(HaveLen(3))
+		})
+	})
+})
@@ -2,38 +2,97 @@ package use_cases
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
 	"time"
 	
 	"agent-orchestration/entities"
 	"agent-orchestration/interfaces/repository"
+	"agent-orchestration/internal/requestctx"
 )
 
 // UserUseCase handles user business logic
 type UserUseCase struct {
-	userRepo repository.UserRepository
+	userRepo    repository.UserRepository
+	historyRepo repository.HistoryRepository
+	txManager   repository.TransactionManager
+	searchIndex repository.UserSearchIndex
+	backups     repository.BackupStore
+	retention   *RetentionPolicy
+	notifier    RetentionNotifier
+	access      *accessControl
+}
+
+// UserUseCaseOption configures optional dependencies of a UserUseCase
+type UserUseCaseOption func(*UserUseCase)
+
+// WithHistory records every change made through the use case in historyRepo
+func WithHistory(historyRepo repository.HistoryRepository) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.historyRepo = historyRepo
+	}
+}
+
+// WithTransactions makes every change, together with its history entry, a
+// single transaction of txManager. Without it the repository calls of a
+// change run one by one.
+func WithTransactions(txManager repository.TransactionManager) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.txManager = txManager
+	}
+}
+
+// WithSearch answers SearchUsers from searchIndex
+func WithSearch(searchIndex repository.UserSearchIndex) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.searchIndex = searchIndex
+	}
+}
+
+// WithBackups keeps the snapshots taken by BackupUsers in backups. Backups
+// also need a user repository that implements repository.UserSnapshotter.
+func WithBackups(backups repository.BackupStore) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.backups = backups
+	}
+}
+
+// WithRetention lets EnforceRetention remove inactive users under policy,
+// warning them through notifier first
+func WithRetention(policy RetentionPolicy, notifier RetentionNotifier) UserUseCaseOption {
+	return func(uc *UserUseCase) {
+		uc.retention = &policy
+		uc.notifier = notifier
+	}
 }
 
 // NewUserUseCase creates a new UserUseCase
-func NewUserUseCase(userRepo repository.UserRepository) *UserUseCase {
-	return &UserUseCase{
+func NewUserUseCase(userRepo repository.UserRepository, opts ...UserUseCaseOption) *UserUseCase {
+	uc := &UserUseCase{
 		userRepo: userRepo,
 	}
+	for _, opt := range opts {
+		opt(uc)
+	}
+	return uc
 }
 
 // CreateUser creates a new user
 func (uc *UserUseCase) CreateUser(ctx context.Context, name, email string) (*entities.User, error) {
-	// Check if user already exists
-	existingUser, _ := uc.userRepo.GetByEmail(ctx, email)
-	if existingUser != nil {
-		return nil, entities.ErrUserAlreadyExists
+	if err := uc.authorize(ctx, entities.PermissionCreateUsers, 0); err != nil {
+		return nil, err
 	}
 	
 	// Create new user
 	user := &entities.User{
-		Name:    name,
-		Email:   email,
+		Name:    entities.NormalizeName(name),
+		Email:   strings.TrimSpace(email),
 		Created: time.Now(),
 		Updated: time.Now(),
+		Status:  entities.StatusPending,
 	}
 	
 	// Validate user
@@ -41,19 +100,40 @@ func (uc *UserUseCase) CreateUser(ctx context.Context, name, email string) (*ent
 		return nil, err
 	}
 	
-	// Save user
-	if err := uc.userRepo.Create(ctx, user); err != nil {
+	if err := uc.create(ctx, user); err != nil {
 		return nil, err
 	}
 	
 	return user, nil
 }
 
+// create saves a validated new user and records its creation
+func (uc *UserUseCase) create(ctx context.Context, user *entities.User) error {
+	return uc.inTransaction(ctx, func(ctx context.Context) error {
+		// Check if user already exists. Soft-deleted users keep their email
+		// reserved until they are purged.
+		existingUser, _ := uc.userRepo.GetByEmail(repository.IncludeDeleted(ctx), user.Email)
+		if existingUser != nil {
+			return entities.ErrUserAlreadyExists
+		}
+	
+		// Save user
+		if err := uc.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+	
+		return uc.recordChange(ctx, entities.OperationCreated, user.ID, nil, user)
+	})
+}
+
 // GetUserByID retrieves a user by ID
 func (uc *UserUseCase) GetUserByID(ctx context.Context, id int) (*entities.User, error) {
 	if id <= 0 {
 		return nil, entities.ErrInvalidID
 	}
+	if err := uc.authorize(ctx, entities.PermissionReadUsers, id); err != nil {
+		return nil, err
+	}
 	
 	user, err := uc.userRepo.GetByID(ctx, id)
 	if err != nil {
@@ -63,56 +143,1017 @@ func (uc *UserUseCase) GetUserByID(ctx context.Context, id int) (*entities.User,
 	return user, nil
 }
 
+// AnyVersion disables the version precondition of UpdateUserIfMatch
+const AnyVersion = 0
+
 // UpdateUser updates an existing user
 func (uc *UserUseCase) UpdateUser(ctx context.Context, id int, name, email string) (*entities.User, error) {
+	return uc.UpdateUserIfMatch(ctx, id, AnyVersion, name, email)
+}
+
+// UpdateUserIfMatch updates an existing user only if its current version is
+// expectedVersion. It returns ErrPreconditionFailed when the caller's version
+// is stale and ErrVersionConflict when another update wins the race between
+// reading and saving the user.
+func (uc *UserUseCase) UpdateUserIfMatch(ctx context.Context, id, expectedVersion int, name, email string) (*entities.User, error) {
 	if id <= 0 {
 		return nil, entities.ErrInvalidID
 	}
+	if err := uc.authorize(ctx, entities.PermissionUpdateUsers, id); err != nil {
+		return nil, err
+	}
 	
-	// Get existing user
-	user, err := uc.userRepo.GetByID(ctx, id)
+	var user *entities.User
+	err := uc.inTransaction(ctx, func(ctx context.Context) error {
+		// Get existing user
+		var err error
+		user, err = uc.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+	
+		if expectedVersion != AnyVersion && user.Version != expectedVersion {
+			return entities.ErrPreconditionFailed
+		}
+		before := *user
+	
+		// Update user data, reporting the violations of both fields at once
+		var violations []*entities.Error
+		if name != "" {
+			if err := user.UpdateName(name); err != nil {
+				violations = append(violations, entities.Violations(err)...)
+			}
+		}
+	
+		if email != "" {
+			if err := user.UpdateEmail(email); err != nil {
+				violations = append(violations, entities.Violations(err)...)
+			}
+		}
+	
+		if len(violations) > 0 {
+			return entities.NewValidationError(violations...)
+		}
+	
+		// Save updated user
+		if err := uc.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	
+		return uc.recordChange(ctx, entities.OperationUpdated, user.ID, &before, user)
+	})
 	if err != nil {
 		return nil, err
 	}
 	
-	// Update user data
-	if name != "" {
-		if err := user.UpdateName(name); err != nil {
-			return nil, err
+	return user, nil
+}
+
+// DeleteUser soft-deletes a user by ID. The user can be brought back with
+// RestoreUser until PurgeDeletedUsers removes it for good.
+func (uc *UserUseCase) DeleteUser(ctx context.Context, id int) error {
+	if id <= 0 {
+		return entities.ErrInvalidID
+	}
+	if err := uc.authorize(ctx, entities.PermissionDeleteUsers, id); err != nil {
+		return err
+	}
+	
+	return uc.inTransaction(ctx, func(ctx context.Context) error {
+		// Check if user exists
+		user, err := uc.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
 		}
+		before := *user
+	
+		if err := user.MarkDeleted(time.Now()); err != nil {
+			return err
+		}
+	
+		if err := uc.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	
+		return uc.recordChange(ctx, entities.OperationDeleted, user.ID, &before, user)
+	})
+}
+
+// RestoreUser undoes the soft delete of a user
+func (uc *UserUseCase) RestoreUser(ctx context.Context, id int) (*entities.User, error) {
+	if id <= 0 {
+		return nil, entities.ErrInvalidID
+	}
+	if err := uc.authorize(ctx, entities.PermissionDeleteUsers, id); err != nil {
+		return nil, err
 	}
 	
-	if email != "" {
-		if err := user.UpdateEmail(email); err != nil {
-			return nil, err
+	var user *entities.User
+	err := uc.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = uc.userRepo.GetByID(repository.IncludeDeleted(ctx), id)
+		if err != nil {
+			return err
+		}
+		before := *user
+	
+		if err := user.Restore(); err != nil {
+			return err
 		}
+	
+		if err := uc.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	
+		return uc.recordChange(ctx, entities.OperationRestored, user.ID, &before, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return user, nil
+}
+
+// PurgeDeletedUsers permanently deletes users that were soft-deleted more
+// than gracePeriod ago and returns how many were removed. Users on legal
+// hold are kept. Each user is purged in its own transaction, so a user
+// restored in the meantime is kept.
+func (uc *UserUseCase) PurgeDeletedUsers(ctx context.Context, gracePeriod time.Duration) (int, error) {
+	if err := uc.authorize(ctx, entities.PermissionDeleteUsers, 0); err != nil {
+		return 0, err
 	}
 	
-	// Save updated user
-	if err := uc.userRepo.Update(ctx, user); err != nil {
+	users, err := uc.userRepo.List(repository.IncludeDeleted(ctx))
+	if err != nil {
+		return 0, err
+	}
+	
+	cutoff := time.Now().Add(-gracePeriod)
+	purged := 0
+	for _, user := range users {
+		if !user.IsDeleted() || user.DeletedAt.After(cutoff) || user.LegalHold {
+			continue
+		}
+	
+		removed, err := uc.purgeUser(ctx, user.ID, cutoff)
+		if err != nil {
+			return purged, err
+		}
+		if removed {
+			purged++
+		}
+	}
+	
+	return purged, nil
+}
+
+// purgeUser permanently deletes a user if it is still soft-deleted and was
+// deleted before cutoff
+func (uc *UserUseCase) purgeUser(ctx context.Context, id int, cutoff time.Time) (bool, error) {
+	removed := false
+	err := uc.inTransaction(ctx, func(ctx context.Context) error {
+		user, err := uc.userRepo.GetByID(repository.IncludeDeleted(ctx), id)
+		if errors.Is(err, entities.ErrUserNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !user.IsDeleted() || user.DeletedAt.After(cutoff) || user.LegalHold {
+			return nil
+		}
+	
+		if err := uc.userRepo.Delete(ctx, id); err != nil {
+			return err
+		}
+		removed = true
+	
+		return uc.recordChange(ctx, entities.OperationPurged, id, user, nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	
+	return removed, nil
+}
+
+// SetLegalHold places a user, soft-deleted or not, on legal hold or
+// releases it. A user on legal hold is skipped by the retention policy and
+// never purged. The hold is not user activity, so Updated is left alone.
+func (uc *UserUseCase) SetLegalHold(ctx context.Context, id int, hold bool) (*entities.User, error) {
+	if id <= 0 {
+		return nil, entities.ErrInvalidID
+	}
+	if err := uc.authorize(ctx, entities.PermissionAdministerUsers, id); err != nil {
+		return nil, err
+	}
+	
+	var user *entities.User
+	err := uc.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = uc.userRepo.GetByID(repository.IncludeDeleted(ctx), id)
+		if err != nil {
+			return err
+		}
+		if user.LegalHold == hold {
+			return nil
+		}
+		before := *user
+	
+		user.LegalHold = hold
+		if err := uc.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+	
+		operation := entities.OperationHeld
+		if !hold {
+			operation = entities.OperationReleased
+		}
+		return uc.recordChange(ctx, operation, user.ID, &before, user)
+	})
+	if err != nil {
 		return nil, err
 	}
 	
 	return user, nil
 }
 
-// DeleteUser deletes a user by ID
-func (uc *UserUseCase) DeleteUser(ctx context.Context, id int) error {
+// statusOperations maps every status to the history operation of moving a
+// user to it
+var statusOperations = map[entities.Status]string{
+	entities.StatusActive:      entities.OperationActivated,
+	entities.StatusSuspended:   entities.OperationSuspended,
+	entities.StatusDeactivated: entities.OperationDeactivated,
+}
+
+// ActivateUser activates a pending, suspended or deactivated user for
+// reason
+func (uc *UserUseCase) ActivateUser(ctx context.Context, id int, reason string) (*entities.User, error) {
+	return uc.changeStatus(ctx, id, entities.StatusActive, reason)
+}
+
+// SuspendUser suspends an active user for reason
+func (uc *UserUseCase) SuspendUser(ctx context.Context, id int, reason string) (*entities.User, error) {
+	return uc.changeStatus(ctx, id, entities.StatusSuspended, reason)
+}
+
+// DeactivateUser deactivates a user that isn't deactivated yet for reason
+func (uc *UserUseCase) DeactivateUser(ctx context.Context, id int, reason string) (*entities.User, error) {
+	return uc.changeStatus(ctx, id, entities.StatusDeactivated, reason)
+}
+
+// changeStatus moves a user to status and records the change along with
+// its reason. It returns ErrInvalidStatusTransition when the user may not
+// move to status from its current one.
+func (uc *UserUseCase) changeStatus(ctx context.Context, id int, status entities.Status, reason string) (*entities.User, error) {
 	if id <= 0 {
-		return entities.ErrInvalidID
+		return nil, entities.ErrInvalidID
+	}
+	permission := entities.PermissionChangeUserStatus
+	if status == entities.StatusSuspended {
+		permission = entities.PermissionSuspendUsers
 	}
+	if err := uc.authorize(ctx, permission, id); err != nil {
+		return nil, err
+	}
+	
+	var user *entities.User
+	err := uc.inTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = uc.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		before := *user
+	
+		if err := user.ChangeStatus(status, reason); err != nil {
+			return err
+		}
+		if err := uc.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
 	
-	// Check if user exists
-	_, err := uc.userRepo.GetByID(ctx, id)
+		return uc.recordChange(ctx, statusOperations[status], user.ID, &before, user)
+	})
 	if err != nil {
+		return nil, err
+	}
+	
+	return user, nil
+}
+
+// RetentionPolicy removes the accounts of users that stay inactive for too
+// long. A user is inactive since it was last updated.
+type RetentionPolicy struct {
+	// Months is how long a user may stay inactive
+	Months int
+
+	// Warning is how long before the removal a user is warned. A user is
+	// never removed sooner than Warning after the warning, even one that
+	// has been inactive for longer than Months already.
+	Warning time.Duration
+}
+
+// RetentionNotifier warns users that their account is about to be removed
+type RetentionNotifier interface {
+	// WarnInactive tells user that the account is deleted after deleteAfter
+	// unless it is used before
+	WarnInactive(ctx context.Context, user *entities.User, deleteAfter time.Time) error
+}
+
+// RetentionPrincipal is recorded as the principal of the changes made by
+// EnforceRetention when the context carries none
+const RetentionPrincipal = "retention-policy"
+
+// retentionBatchSize is the number of inactive users EnforceRetention reads
+// at a time
+const retentionBatchSize = 100
+
+// Outcomes of the retention policy for an inactive user
+const (
+	RetentionWarned  = "warned"
+	RetentionPending = "pending"
+	RetentionDeleted = "deleted"
+	RetentionHeld    = "held"
+	RetentionFailed  = "failed"
+)
+
+// RetentionRow is the outcome of the retention policy for one inactive
+// user. Pending users were warned and are deleted after DeleteAfter, which
+// is unset for users on legal hold.
+type RetentionRow struct {
+	ID          int
+	Email       string
+	Updated     time.Time
+	Status      string
+	DeleteAfter time.Time
+	Err         error
+}
+
+// RetentionReport describes the outcome of EnforceRetention, user by user.
+// Users updated before Cutoff have been inactive for longer than the policy
+// allows.
+type RetentionReport struct {
+	DryRun  bool
+	Cutoff  time.Time
+	Warned  int
+	Pending int
+	Deleted int
+	Held    int
+	Failed  int
+	Rows    []RetentionRow
+}
+
+// EnforceRetention applies the retention policy to every user that is
+// inactive, or becomes so within the warning period. Users who haven't been
+// warned since their last update are warned, and warned users whose time is
+// up are deleted through DeleteUser; users on legal hold are skipped. A
+// warning that can't be sent is reported as failed and retried by the next
+// run. With dryRun nothing is changed, but the report is the same.
+func (uc *UserUseCase) EnforceRetention(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+	if uc.retention == nil {
+		return nil, entities.ErrRetentionDisabled
+	}
+	if err := uc.authorize(ctx, entities.PermissionAdministerUsers, 0); err != nil {
+		return nil, err
+	}
+	if requestctx.Principal(ctx) == "" {
+		ctx = requestctx.WithPrincipal(ctx, RetentionPrincipal)
+	}
+	// The users are deleted through DeleteUser on behalf of the policy
+	ctx = SystemContext(ctx)
+	
+	now := time.Now()
+	policy := *uc.retention
+	report := &RetentionReport{
+		DryRun: dryRun,
+		Cutoff: now.AddDate(0, -policy.Months, 0),
+		Rows:   make([]RetentionRow, 0),
+	}
+	opts := repository.ListOptions{
+		Limit:         retentionBatchSize,
+		SortBy:        repository.SortByUpdated,
+		UpdatedBefore: now.Add(policy.Warning).AddDate(0, -policy.Months, 0),
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+	
+		// Warnings leave Updated alone and deletions move users past the
+		// cursor, so paging on goes on where it left off
+		page, err := uc.userRepo.ListPage(ctx, opts)
+		if err != nil {
+			return report, err
+		}
+	
+		for _, user := range page.Users {
+			row, err := uc.retainUser(ctx, user, policy, now, dryRun)
+			if err != nil {
+				return report, err
+			}
+			if row == nil {
+				continue
+			}
+	
+			switch row.Status {
+			case RetentionWarned:
+				report.Warned++
+			case RetentionPending:
+				report.Pending++
+			case RetentionDeleted:
+				report.Deleted++
+			case RetentionHeld:
+				report.Held++
+			case RetentionFailed:
+				report.Failed++
+			}
+			report.Rows = append(report.Rows, *row)
+		}
+	
+		if page.Next == "" {
+			return report, nil
+		}
+		opts.Cursor = page.Next
+	}
+}
+
+// retainUser applies policy to a single inactive user. It returns no row for
+// a user that changed since it was listed, and so is active again or gone.
+func (uc *UserUseCase) retainUser(ctx context.Context, user *entities.User, policy RetentionPolicy, now time.Time, dryRun bool) (*RetentionRow, error) {
+	row := &RetentionRow{ID: user.ID, Email: user.Email, Updated: user.Updated}
+	if user.LegalHold {
+		row.Status = RetentionHeld
+		return row, nil
+	}
+	
+	row.DeleteAfter = user.Updated.AddDate(0, policy.Months, 0)
+	var err error
+	if !user.WarnedOfInactivity() {
+		if notice := now.Add(policy.Warning); notice.After(row.DeleteAfter) {
+			row.DeleteAfter = notice
+		}
+		row.Status = RetentionWarned
+		if !dryRun {
+			if err := uc.notifier.WarnInactive(ctx, user, row.DeleteAfter); err != nil {
+				row.Status, row.Err = RetentionFailed, err
+				return row, nil
+			}
+			err = uc.recordWarning(ctx, user, now)
+		}
+	} else {
+		if notice := user.InactivityWarnedAt.Add(policy.Warning); notice.After(row.DeleteAfter) {
+			row.DeleteAfter = notice
+		}
+		if now.Before(row.DeleteAfter) {
+			row.Status = RetentionPending
+			return row, nil
+		}
+		row.Status = RetentionDeleted
+		if !dryRun {
+			err = uc.deleteInactive(ctx, user)
+		}
+	}
+	
+	switch {
+	case err == nil:
+		return row, nil
+	case errors.Is(err, entities.ErrVersionConflict), errors.Is(err, entities.ErrUserNotFound):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("retain user %d: %w", user.ID, err)
+	}
+}
+
+// recordWarning stores that user was warned at now, unless it changed since
+// it was listed. The warning is sent first, so one that can't be stored is
+// sent again by the next run rather than never.
+func (uc *UserUseCase) recordWarning(ctx context.Context, user *entities.User, now time.Time) error {
+	return uc.inTransaction(ctx, func(ctx context.Context) error {
+		current, err := uc.userRepo.GetByID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if current.Version != user.Version {
+			return entities.ErrVersionConflict
+		}
+		before := *current
+	
+		current.InactivityWarnedAt = &now
+		if err := uc.userRepo.Update(ctx, current); err != nil {
+			return err
+		}
+	
+		return uc.recordChange(ctx, entities.OperationWarned, current.ID, &before, current)
+	})
+}
+
+// deleteInactive deletes user unless it changed since it was listed
+func (uc *UserUseCase) deleteInactive(ctx context.Context, user *entities.User) error {
+	return uc.inTransaction(ctx, func(ctx context.Context) error {
+		current, err := uc.userRepo.GetByID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if current.Version != user.Version {
+			return entities.ErrVersionConflict
+		}
+	
+		return uc.DeleteUser(ctx, user.ID)
+	})
+}
+
+// DuplicateEmails is a group of users of a tenant whose email addresses
+// share the same canonical form
+type DuplicateEmails struct {
+	Tenant   string
+	EmailKey string
+	Users    []*entities.User
+}
+
+// FindDuplicateEmails reports the users, soft-deleted ones included, whose
+// email addresses only differ in case, whitespace or domain encoding. Such
+// duplicates can only have been stored before emails were normalized and
+// have to be resolved by hand. Groups are ordered by tenant and email key,
+// and users by ID.
+func (uc *UserUseCase) FindDuplicateEmails(ctx context.Context) ([]DuplicateEmails, error) {
+	if err := uc.authorize(ctx, entities.PermissionAdministerUsers, 0); err != nil {
+		return nil, err
+	}
+	
+	users, err := uc.userRepo.List(repository.IncludeDeleted(ctx))
+	if err != nil {
+		return nil, err
+	}
+	
+	byKey := make(map[string][]*entities.User)
+	for _, user := range users {
+		byKey[user.TenantEmailKey()] = append(byKey[user.TenantEmailKey()], user)
+	}
+	
+	duplicates := make([]DuplicateEmails, 0)
+	for _, group := range byKey {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].ID < group[j].ID })
+		duplicates = append(duplicates, DuplicateEmails{Tenant: group[0].Tenant(), EmailKey: group[0].EmailKey(), Users: group})
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].Tenant != duplicates[j].Tenant {
+			return duplicates[i].Tenant < duplicates[j].Tenant
+		}
+		return duplicates[i].EmailKey < duplicates[j].EmailKey
+	})
+	
+	return duplicates, nil
+}
+
+// User page sizes used by ListUsers
+const (
+	DefaultUserPageSize = 20
+	MaxUserPageSize     = 100
+)
+
+// ListUsers retrieves the page of users selected by opts. A limit of zero
+// selects DefaultUserPageSize and larger limits are capped at
+// MaxUserPageSize. Users are sorted by ID unless opts says otherwise.
+func (uc *UserUseCase) ListUsers(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+	if err := uc.authorize(ctx, entities.PermissionReadUsers, 0); err != nil {
+		return nil, err
+	}
+	
+	if opts.Limit < 0 {
+		return nil, entities.ErrInvalidPagination
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultUserPageSize
+	}
+	if opts.Limit > MaxUserPageSize {
+		opts.Limit = MaxUserPageSize
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = repository.SortByID
+	}
+	if !opts.SortBy.Valid() {
+		return nil, entities.ErrInvalidSortField
+	}
+	if opts.Status != "" && !opts.Status.Valid() {
+		return nil, entities.ErrInvalidStatus
+	}
+	
+	return uc.userRepo.ListPage(ctx, opts)
+}
+
+// exportBatchSize is the number of users ExportUsers reads at a time
+const exportBatchSize = 500
+
+// ExportUsers calls fn with every user, ordered by ID, stopping at the
+// first error. Users are read in batches, so users created or deleted
+// during the export may or may not be included.
+func (uc *UserUseCase) ExportUsers(ctx context.Context, fn func(user *entities.User) error) error {
+	if err := uc.authorize(ctx, entities.PermissionReadUsers, 0); err != nil {
 		return err
 	}
 	
-	// Delete user
-	return uc.userRepo.Delete(ctx, id)
+	opts := repository.ListOptions{Limit: exportBatchSize, SortBy: repository.SortByID}
+	for {
+		page, err := uc.userRepo.ListPage(ctx, opts)
+		if err != nil {
+			return err
+		}
+	
+		for _, user := range page.Users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+	
+		if page.Next == "" {
+			return nil
+		}
+		opts.Cursor = page.Next
+	}
+}
+
+// ImportSource reads the users of an import one at a time
+type ImportSource interface {
+	// Read returns the next user and the line of the input it starts on.
+	// It returns io.EOF after the last user, and an error wrapping
+	// entities.ErrInvalidRecord for a record that can't be decoded, after
+	// which reading goes on with the next record.
+	Read() (*entities.User, int, error)
+}
+
+// Outcomes of an imported row
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate"
+	ImportInvalid   = "invalid"
+)
+
+// ImportRow is the outcome of importing one row. ID is only set for users
+// that were created.
+type ImportRow struct {
+	Line   int
+	Status string
+	ID     int
+	Email  string
+	Err    error
+}
+
+// ImportReport describes the outcome of ImportUsers, row by row
+type ImportReport struct {
+	DryRun     bool
+	Created    int
+	Duplicates int
+	Invalid    int
+	Rows       []ImportRow
+}
+
+// ImportUsers creates a user for every row read from source. Rows that fail
+// validation are reported as invalid, and rows whose email is already taken,
+// by an existing user or an earlier row, as duplicates; neither stops the
+// import. Each user is created in its own transaction, so an error returned
+// part way leaves the earlier rows imported.
+//
+// Names, emails, creation and update times are imported; IDs and versions
+// are assigned afresh. Every user is imported pending and not deleted,
+// whatever the row says, since moving users on takes the permission to
+// change their status. With dryRun nothing is created, but the
+// report is the same as for a real import that doesn't race with other
+// writes.
+func (uc *UserUseCase) ImportUsers(ctx context.Context, source ImportSource, dryRun bool) (*ImportReport, error) {
+	if err := uc.authorize(ctx, entities.PermissionCreateUsers, 0); err != nil {
+		return nil, err
+	}
+	
+	report := &ImportReport{DryRun: dryRun, Rows: make([]ImportRow, 0)}
+	seen := make(map[string]bool)
+	for {
+		record, line, err := source.Read()
+		if err == io.EOF {
+			return report, nil
+		}
+	
+		row := ImportRow{Line: line}
+		switch {
+		case errors.Is(err, entities.ErrInvalidRecord):
+			row.Status, row.Err = ImportInvalid, err
+		case err != nil:
+			return nil, err
+		default:
+			row.Email = record.Email
+			if err := uc.importUser(ctx, record, &row, seen, dryRun); err != nil {
+				return nil, err
+			}
+		}
+	
+		switch row.Status {
+		case ImportCreated:
+			report.Created++
+		case ImportDuplicate:
+			report.Duplicates++
+		case ImportInvalid:
+			report.Invalid++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+}
+
+// importUser imports a single user, recording the outcome in row. seen
+// holds the email keys of the earlier rows. Roles are left behind, so
+// importing users never grants permissions, and so is the tenant: users are
+// imported into the tenant of ctx.
+func (uc *UserUseCase) importUser(ctx context.Context, record *entities.User, row *ImportRow, seen map[string]bool, dryRun bool) error {
+	now := time.Now()
+	user := &entities.User{
+		Name:    entities.NormalizeName(record.Name),
+		Email:   strings.TrimSpace(record.Email),
+		Status:  entities.StatusPending,
+		Created: record.Created,
+		Updated: record.Updated,
+	}
+	if user.Created.IsZero() {
+		user.Created = now
+	}
+	if user.Updated.IsZero() {
+		user.Updated = user.Created
+	}
+	
+	if err := user.Validate(); err != nil {
+		row.Status, row.Err = ImportInvalid, err
+		return nil
+	}
+	
+	key := user.EmailKey()
+	if seen[key] {
+		row.Status, row.Err = ImportDuplicate, entities.ErrUserAlreadyExists
+		return nil
+	}
+	seen[key] = true
+	
+	var err error
+	if dryRun {
+		_, err = uc.userRepo.GetByEmail(repository.IncludeDeleted(ctx), user.Email)
+		switch {
+		case err == nil:
+			err = entities.ErrUserAlreadyExists
+		case errors.Is(err, entities.ErrUserNotFound):
+			err = nil
+		}
+	} else {
+		err = uc.create(ctx, user)
+	}
+	
+	switch {
+	case err == nil:
+		row.Status, row.ID = ImportCreated, user.ID
+	case errors.Is(err, entities.ErrUserAlreadyExists):
+		row.Status, row.Err = ImportDuplicate, err
+	default:
+		return err
+	}
+	return nil
+}
+
+// BackupUsers saves a consistent snapshot of the user store, ID sequence
+// included, as a new backup. The history is not part of it. Backups hold
+// the users of every tenant.
+func (uc *UserUseCase) BackupUsers(ctx context.Context) (*repository.BackupInfo, error) {
+	ctx = repository.IncludeAllTenants(ctx)
+	if err := uc.authorize(ctx, entities.PermissionAdministerUsers, 0); err != nil {
+		return nil, err
+	}
+	
+	snapshotter, err := uc.snapshotter()
+	if err != nil {
+		return nil, err
+	}
+	
+	snapshot, err := snapshotter.SnapshotUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return uc.backups.Save(ctx, snapshot)
+}
+
+// ListBackups returns the stored backups, newest first
+func (uc *UserUseCase) ListBackups(ctx context.Context) ([]*repository.BackupInfo, error) {
+	ctx = repository.IncludeAllTenants(ctx)
+	if err := uc.authorize(ctx, entities.PermissionAdministerUsers, 0); err != nil {
+		return nil, err
+	}
+	
+	if _, err := uc.snapshotter(); err != nil {
+		return nil, err
+	}
+	return uc.backups.List(ctx)
+}
+
+// RestoreUsers replaces every user, of every tenant, with the ones in the
+// backup called name and returns the restored snapshot. The backup is
+// checked in full first, so a damaged or inconsistent one leaves the store
+// untouched.
+func (uc *UserUseCase) RestoreUsers(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+	ctx = repository.IncludeAllTenants(ctx)
+	if err := uc.authorize(ctx, entities.PermissionAdministerUsers, 0); err != nil {
+		return nil, err
+	}
+	
+	snapshotter, err := uc.snapshotter()
+	if err != nil {
+		return nil, err
+	}
+	
+	snapshot, err := uc.backups.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSnapshot(snapshot); err != nil {
+		return nil, err
+	}
+	
+	if err := snapshotter.RestoreUsers(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// snapshotter returns the user repository as a snapshotter, or
+// ErrBackupsUnsupported if backups aren't configured
+func (uc *UserUseCase) snapshotter() (repository.UserSnapshotter, error) {
+	snapshotter, ok := uc.userRepo.(repository.UserSnapshotter)
+	if !ok || uc.backups == nil {
+		return nil, entities.ErrBackupsUnsupported
+	}
+	return snapshotter, nil
 }
 
-// ListUsers retrieves all users
-func (uc *UserUseCase) ListUsers(ctx context.Context) ([]*entities.User, error) {
-	return uc.userRepo.List(ctx)
-}
\ No newline at end of file
+// validateSnapshot checks that every user in snapshot is valid, that IDs
+// are ascending and that the ID sequence is past all of them
+func validateSnapshot(snapshot *repository.UserSnapshot) error {
+	lastID := 0
+	for _, user := range snapshot.Users {
+		if user.ID <= lastID {
+			return fmt.Errorf("%w: user IDs are not unique and ascending at ID %d", entities.ErrInvalidBackup, user.ID)
+		}
+		lastID = user.ID
+	
+		if err := user.Validate(); err != nil {
+			return fmt.Errorf("%w: user %d: %v", entities.ErrInvalidBackup, user.ID, err)
+		}
+		if user.Version < 1 {
+			return fmt.Errorf("%w: user %d: invalid version %d", entities.ErrInvalidBackup, user.ID, user.Version)
+		}
+	}
+	
+	if snapshot.NextID <= lastID {
+		return fmt.Errorf("%w: next ID %d is not past user %d", entities.ErrInvalidBackup, snapshot.NextID, lastID)
+	}
+	return nil
+}
+
+// History page sizes used by GetUserHistory
+const (
+	DefaultHistoryPageSize = 20
+	MaxHistoryPageSize     = 100
+)
+
+// GetUserHistory returns a page of the change history of a user, oldest
+// first, along with the total number of entries. The history is kept after
+// the user is deleted. A limit of zero selects DefaultHistoryPageSize and
+// larger limits are capped at MaxHistoryPageSize.
+func (uc *UserUseCase) GetUserHistory(ctx context.Context, id, offset, limit int) ([]*entities.HistoryEntry, int, error) {
+	if id <= 0 {
+		return nil, 0, entities.ErrInvalidID
+	}
+	if err := uc.authorize(ctx, entities.PermissionReadUsers, id); err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 || limit < 0 {
+		return nil, 0, entities.ErrInvalidPagination
+	}
+	if limit == 0 {
+		limit = DefaultHistoryPageSize
+	}
+	if limit > MaxHistoryPageSize {
+		limit = MaxHistoryPageSize
+	}
+	
+	if uc.historyRepo == nil {
+		return make([]*entities.HistoryEntry, 0), 0, nil
+	}
+	return uc.historyRepo.ListByUser(ctx, id, offset, limit)
+}
+
+// Search page sizes used by SearchUsers
+const (
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 100
+)
+
+// SearchResult is a user found by SearchUsers
+type SearchResult struct {
+	User  *entities.User
+	Score float64
+}
+
+// SearchUsers finds users by a free text query over their names and emails,
+// tolerating partial words and typos, and returns a page of them, best match
+// first, along with the total number of matches. A limit of zero selects
+// DefaultSearchPageSize and larger limits are capped at MaxSearchPageSize.
+// Without a search index nothing is found.
+func (uc *UserUseCase) SearchUsers(ctx context.Context, query string, offset, limit int) ([]SearchResult, int, error) {
+	if err := uc.authorize(ctx, entities.PermissionReadUsers, 0); err != nil {
+		return nil, 0, err
+	}
+	
+	if strings.TrimSpace(query) == "" {
+		return nil, 0, entities.ErrSearchQueryRequired
+	}
+	if offset < 0 || limit < 0 {
+		return nil, 0, entities.ErrInvalidPagination
+	}
+	if limit == 0 {
+		limit = DefaultSearchPageSize
+	}
+	if limit > MaxSearchPageSize {
+		limit = MaxSearchPageSize
+	}
+	
+	results := make([]SearchResult, 0)
+	if uc.searchIndex == nil {
+		return results, 0, nil
+	}
+	
+	hits, total, err := uc.searchIndex.Search(ctx, query, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, hit := range hits {
+		user, err := uc.userRepo.GetByID(ctx, hit.UserID)
+		if errors.Is(err, entities.ErrUserNotFound) {
+			// Deleted since the index was searched
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, SearchResult{User: user, Score: hit.Score})
+	}
+	
+	return results, total, nil
+}
+
+// WatchUsers returns a stream of the user changes committed after fromSeq,
+// or from now on with repository.WatchFromLatest. A consumer resumes by
+// passing the Seq of the last change it received. Consumers that fall too
+// far behind get entities.ErrWatchTooFarBehind and must start over from
+// the current users.
+func (uc *UserUseCase) WatchUsers(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+	if err := uc.authorize(ctx, entities.PermissionReadUsers, 0); err != nil {
+		return nil, err
+	}
+	
+	if fromSeq < 0 && fromSeq != repository.WatchFromLatest {
+		return nil, entities.ErrInvalidSequence
+	}
+	watcher, ok := uc.userRepo.(repository.UserWatcher)
+	if !ok {
+		return nil, entities.ErrWatchUnsupported
+	}
+	return watcher.Watch(ctx, fromSeq)
+}
+
+// inTransaction runs fn in a transaction when a transaction manager is
+// configured, and directly otherwise
+func (uc *UserUseCase) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if uc.txManager == nil {
+		return fn(ctx)
+	}
+	return uc.txManager.WithinTransaction(ctx, fn)
+}
+
+// recordChange appends a history entry describing the change of a user from
+// before to after. It is a no-op when no history repository is configured.
+func (uc *UserUseCase) recordChange(ctx context.Context, operation string, userID int, before, after *entities.User) error {
+	if uc.historyRepo == nil {
+		return nil
+	}
+	
+	entry := &entities.HistoryEntry{
+		UserID:    userID,
+		Operation: operation,
+		Changes:   entities.DiffUsers(before, after),
+		Timestamp: time.Now(),
+		RequestID: requestctx.RequestID(ctx),
+		Principal: requestctx.Principal(ctx),
+	}
+	if after != nil {
+		entry.TenantID = after.Tenant()
+	} else {
+		entry.TenantID = before.Tenant()
+	}
+	return uc.historyRepo.Append(ctx, entry)
+}
@@ -3,13 +3,17 @@ package use_cases_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	"agent-orchestration/entities"
+	"agent-orchestration/interfaces/repository"
 	"agent-orchestration/internal/mocks"
+	"agent-orchestration/internal/requestctx"
 	"agent-orchestration/use_cases"
 )
 
@@ -59,6 +63,25 @@ var _ = Describe("UserUseCase", func() {
 			})
 		})
 
+		Context("when the email has surrounding whitespace", func() {
+			BeforeEach(func() {
+				mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+					return nil, entities.ErrUserNotFound
+				}
+				mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+					user.ID = 1
+					return nil
+				}
+			})
+
+			It("should store the address without it", func() {
+				user, err := userUseCase.CreateUser(ctx, validName, "  John@Example.com ")
+				
+				Expect(err).To(BeNil())
+				Expect(user.Email).To(Equal("John@Example.com"))
+			})
+		})
+
 		Context("when user already exists", func() {
 			BeforeEach(func() {
 				existingUser := &entities.User{
@@ -90,14 +113,33 @@ var _ = Describe("UserUseCase", func() {
 					user, err := userUseCase.CreateUser(ctx, name, email)
 					
 					Expect(user).To(BeNil())
-					Expect(err).To(Equal(expectedError))
+					Expect(err).To(MatchError(expectedError))
 					Expect(mockRepo.CreateCalls()).To(HaveLen(0))
 				},
 				Entry("empty name", "", validEmail, entities.ErrUserNameRequired),
 				Entry("empty email", validName, "", entities.ErrUserEmailRequired),
+				Entry("invalid email", validName, "john@@example.com", entities.ErrInvalidEmail),
+				Entry("email in sealed form", validName, "sealed:not an email:<script>", entities.ErrInvalidEmail),
+				Entry("control character in name", "John\tDoe", validEmail, entities.ErrInvalidUserName),
 			)
 		})
 
+		Context("when name is not normalized", func() {
+			It("should store its NFC form", func() {
+				mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+					return nil, entities.ErrUserNotFound
+				}
+				mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+					return nil
+				}
+
+				user, err := userUseCase.CreateUser(ctx, "Jose\u0301", validEmail)
+
+				Expect(err).To(BeNil())
+				Expect(user.Name).To(Equal("Jos\u00e9"))
+			})
+		})
+
 		Context("when repository create fails", func() {
 			BeforeEach(func() {
 				mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
@@ -243,6 +285,27 @@ var _ = Describe("UserUseCase", func() {
 			})
 		})
 
+		Context("when both fields are invalid", func() {
+			BeforeEach(func() {
+				mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+					user := *existingUser
+					return &user, nil
+				}
+			})
+
+			It("should report the violations of both", func() {
+				user, err := userUseCase.UpdateUser(ctx, 1, "Jane\x00Doe", "jane@")
+
+				Expect(user).To(BeNil())
+				Expect(err).To(MatchError(entities.ErrValidationFailed))
+				Expect(entities.Violations(err)).To(Equal([]*entities.Error{
+					entities.ErrInvalidUserName,
+					entities.ErrInvalidEmail,
+				}))
+				Expect(mockRepo.UpdateCalls()).To(HaveLen(0))
+			})
+		})
+
 		Context("when user not found", func() {
 			BeforeEach(func() {
 				mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
@@ -259,6 +322,48 @@ var _ = Describe("UserUseCase", func() {
 			})
 		})
 
+		Context("when an expected version is given", func() {
+			BeforeEach(func() {
+				existingUser.Version = 2
+				mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+					user := *existingUser
+					return &user, nil
+				}
+				mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+					user.Version++
+					return nil
+				}
+			})
+
+			It("should update when the version matches", func() {
+				user, err := userUseCase.UpdateUserIfMatch(ctx, 1, 2, "Jane Doe", "")
+
+				Expect(err).To(BeNil())
+				Expect(user.Name).To(Equal("Jane Doe"))
+				Expect(user.Version).To(Equal(3))
+				Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+			})
+
+			It("should return ErrPreconditionFailed when the version is stale", func() {
+				user, err := userUseCase.UpdateUserIfMatch(ctx, 1, 1, "Jane Doe", "")
+
+				Expect(user).To(BeNil())
+				Expect(err).To(Equal(entities.ErrPreconditionFailed))
+				Expect(mockRepo.UpdateCalls()).To(HaveLen(0))
+			})
+
+			It("should pass through ErrVersionConflict from the repository", func() {
+				mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+					return entities.ErrVersionConflict
+				}
+
+				user, err := userUseCase.UpdateUserIfMatch(ctx, 1, 2, "Jane Doe", "")
+
+				Expect(user).To(BeNil())
+				Expect(err).To(Equal(entities.ErrVersionConflict))
+			})
+		})
+
 		Context("when ID is invalid", func() {
 			It("should return ErrInvalidID", func() {
 				user, err := userUseCase.UpdateUser(ctx, 0, "New Name", "")
@@ -279,18 +384,20 @@ var _ = Describe("UserUseCase", func() {
 					}
 					return nil, entities.ErrUserNotFound
 				}
-				mockRepo.DeleteFunc = func(ctx context.Context, id int) error {
+				mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
 					return nil
 				}
 			})
 
-			It("should delete user successfully", func() {
+			It("should soft-delete the user", func() {
 				err := userUseCase.DeleteUser(ctx, 1)
 				
 				Expect(err).To(BeNil())
 				Expect(mockRepo.GetByIDCalls()).To(HaveLen(1))
-				Expect(mockRepo.DeleteCalls()).To(HaveLen(1))
-				Expect(mockRepo.DeleteCalls()[0].ID).To(Equal(1))
+				Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+				Expect(mockRepo.UpdateCalls()[0].User.ID).To(Equal(1))
+				Expect(mockRepo.UpdateCalls()[0].User.IsDeleted()).To(BeTrue())
+				Expect(mockRepo.DeleteCalls()).To(HaveLen(0))
 			})
 		})
 
@@ -319,57 +426,1117 @@ var _ = Describe("UserUseCase", func() {
 		})
 	})
 
-	Describe("ListUsers", func() {
-		Context("when users exist", func() {
-			expectedUsers := []*entities.User{
-				{ID: 1, Name: "User 1", Email: "user1@example.com"},
-				{ID: 2, Name: "User 2", Email: "user2@example.com"},
+	Describe("RestoreUser", func() {
+		var deletedAt time.Time
+
+		BeforeEach(func() {
+			deletedAt = time.Now().Add(-time.Hour)
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				if !repository.IncludesDeleted(ctx) {
+					return nil, entities.ErrUserNotFound
+				}
+				switch id {
+				case 1:
+					return &entities.User{ID: 1, Name: "John", Email: "john@example.com", Version: 2, DeletedAt: &deletedAt}, nil
+				case 2:
+					return &entities.User{ID: 2, Name: "Jane", Email: "jane@example.com", Version: 1}, nil
+				}
+				return nil, entities.ErrUserNotFound
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				user.Version++
+				return nil
+			}
+		})
+
+		It("should clear DeletedAt of a deleted user", func() {
+			user, err := userUseCase.RestoreUser(ctx, 1)
+			
+			Expect(err).To(BeNil())
+			Expect(user.IsDeleted()).To(BeFalse())
+			Expect(user.Version).To(Equal(3))
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+		})
+
+		It("should return ErrUserNotDeleted for a user that is not deleted", func() {
+			user, err := userUseCase.RestoreUser(ctx, 2)
+			
+			Expect(err).To(Equal(entities.ErrUserNotDeleted))
+			Expect(user).To(BeNil())
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(0))
+		})
+
+		It("should return ErrUserNotFound for an unknown user", func() {
+			_, err := userUseCase.RestoreUser(ctx, 999)
+			
+			Expect(err).To(Equal(entities.ErrUserNotFound))
+		})
+
+		It("should return ErrInvalidID for an invalid ID", func() {
+			_, err := userUseCase.RestoreUser(ctx, 0)
+			
+			Expect(err).To(Equal(entities.ErrInvalidID))
+			Expect(mockRepo.GetByIDCalls()).To(HaveLen(0))
+		})
+	})
+
+	Describe("PurgeDeletedUsers", func() {
+		It("should permanently delete users deleted before the grace period", func() {
+			old := time.Now().Add(-48 * time.Hour)
+			recent := time.Now().Add(-time.Hour)
+			users := []*entities.User{
+				{ID: 1, DeletedAt: &old},
+				{ID: 2, DeletedAt: &recent},
+				{ID: 3},
+			}
+			mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
+				Expect(repository.IncludesDeleted(ctx)).To(BeTrue())
+				return users, nil
+			}
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				user := *users[id-1]
+				return &user, nil
+			}
+			mockRepo.DeleteFunc = func(ctx context.Context, id int) error {
+				return nil
+			}
+
+			purged, err := userUseCase.PurgeDeletedUsers(ctx, 24*time.Hour)
+			
+			Expect(err).To(BeNil())
+			Expect(purged).To(Equal(1))
+			Expect(mockRepo.DeleteCalls()).To(HaveLen(1))
+			Expect(mockRepo.DeleteCalls()[0].ID).To(Equal(1))
+		})
+
+		It("should keep a user that was restored after it was listed", func() {
+			old := time.Now().Add(-48 * time.Hour)
+			mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
+				return []*entities.User{{ID: 1, DeletedAt: &old}}, nil
+			}
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: 1}, nil
+			}
+
+			purged, err := userUseCase.PurgeDeletedUsers(ctx, 24*time.Hour)
+			
+			Expect(err).To(BeNil())
+			Expect(purged).To(Equal(0))
+			Expect(mockRepo.DeleteCalls()).To(BeEmpty())
+		})
+
+		It("should keep a user on legal hold", func() {
+			old := time.Now().Add(-48 * time.Hour)
+			mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
+				return []*entities.User{{ID: 1, DeletedAt: &old, LegalHold: true}}, nil
+			}
+
+			purged, err := userUseCase.PurgeDeletedUsers(ctx, 24*time.Hour)
+			
+			Expect(err).To(BeNil())
+			Expect(purged).To(Equal(0))
+			Expect(mockRepo.DeleteCalls()).To(BeEmpty())
+		})
+
+		It("should return the repository error", func() {
+			expectedErr := errors.New("database error")
+			mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
+				return nil, expectedErr
+			}
+
+			_, err := userUseCase.PurgeDeletedUsers(ctx, time.Hour)
+			
+			Expect(err).To(Equal(expectedErr))
+		})
+	})
+
+	Describe("Transactions", func() {
+		type txKey struct{}
+		var txManager *mocks.TransactionManagerMock
+
+		BeforeEach(func() {
+			txManager = &mocks.TransactionManagerMock{
+				WithinTransactionFunc: func(ctx context.Context, fn func(ctx context.Context) error) error {
+					return fn(context.WithValue(ctx, txKey{}, true))
+				},
+			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithTransactions(txManager))
+
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				Expect(ctx.Value(txKey{})).To(Equal(true))
+				return &entities.User{ID: id, Name: "John Doe", Email: "john@example.com", Version: 1}, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				Expect(ctx.Value(txKey{})).To(Equal(true))
+				return nil
+			}
+		})
+
+		It("should read and write a user in the same transaction", func() {
+			_, err := userUseCase.UpdateUser(ctx, 1, "Jane Doe", "")
+			Expect(err).To(BeNil())
+
+			Expect(txManager.WithinTransactionCalls()).To(HaveLen(1))
+			Expect(mockRepo.GetByIDCalls()).To(HaveLen(1))
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+		})
+
+		It("should check the email and create the user in the same transaction", func() {
+			mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+				Expect(ctx.Value(txKey{})).To(Equal(true))
+				return nil, entities.ErrUserNotFound
+			}
+			mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+				Expect(ctx.Value(txKey{})).To(Equal(true))
+				user.ID = 1
+				return nil
+			}
+
+			_, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+			Expect(err).To(BeNil())
+			Expect(txManager.WithinTransactionCalls()).To(HaveLen(1))
+		})
+
+		It("should return the error of a failed transaction", func() {
+			expectedErr := errors.New("commit failed")
+			txManager.WithinTransactionFunc = func(ctx context.Context, fn func(ctx context.Context) error) error {
+				if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+					return err
+				}
+				return expectedErr
+			}
+
+			err := userUseCase.DeleteUser(ctx, 1)
+			Expect(err).To(Equal(expectedErr))
+		})
+	})
+
+	Describe("History", func() {
+		var historyRepo *mocks.HistoryRepositoryMock
+
+		BeforeEach(func() {
+			historyRepo = &mocks.HistoryRepositoryMock{
+				AppendFunc: func(ctx context.Context, entry *entities.HistoryEntry) error {
+					return nil
+				},
 			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithHistory(historyRepo))
+			ctx = requestctx.WithPrincipal(requestctx.WithRequestID(ctx, "req-1"), "admin")
 
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: "John Doe", Email: "john@example.com", Version: 1}, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				return nil
+			}
+		})
+
+		It("should record a created entry with the request ID and principal", func() {
+			mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+				return nil, entities.ErrUserNotFound
+			}
+			mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+				user.ID = 7
+				return nil
+			}
+
+			_, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+			Expect(err).To(BeNil())
+
+			Expect(historyRepo.AppendCalls()).To(HaveLen(1))
+			entry := historyRepo.AppendCalls()[0].Entry
+			Expect(entry.UserID).To(Equal(7))
+			Expect(entry.Operation).To(Equal(entities.OperationCreated))
+			Expect(entry.RequestID).To(Equal("req-1"))
+			Expect(entry.Principal).To(Equal("admin"))
+			Expect(entry.Timestamp).NotTo(BeZero())
+		})
+
+		It("should record the before and after values of an update", func() {
+			_, err := userUseCase.UpdateUser(ctx, 1, "", "johnny@example.com")
+			Expect(err).To(BeNil())
+
+			Expect(historyRepo.AppendCalls()).To(HaveLen(1))
+			entry := historyRepo.AppendCalls()[0].Entry
+			Expect(entry.Operation).To(Equal(entities.OperationUpdated))
+			Expect(entry.Changes).To(Equal([]entities.FieldChange{
+				{Field: "email", Before: "john@example.com", After: "johnny@example.com"},
+			}))
+		})
+
+		It("should record a deleted entry", func() {
+			Expect(userUseCase.DeleteUser(ctx, 1)).To(Succeed())
+
+			Expect(historyRepo.AppendCalls()).To(HaveLen(1))
+			entry := historyRepo.AppendCalls()[0].Entry
+			Expect(entry.Operation).To(Equal(entities.OperationDeleted))
+			Expect(entry.Changes).To(HaveLen(1))
+			Expect(entry.Changes[0].Field).To(Equal("deleted_at"))
+		})
+
+		It("should not record failed changes", func() {
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				return entities.ErrVersionConflict
+			}
+
+			_, err := userUseCase.UpdateUser(ctx, 1, "Jane", "")
+			Expect(err).To(Equal(entities.ErrVersionConflict))
+			Expect(historyRepo.AppendCalls()).To(BeEmpty())
+		})
+
+		Describe("GetUserHistory", func() {
 			BeforeEach(func() {
-				mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
-					return expectedUsers, nil
+				historyRepo.ListByUserFunc = func(ctx context.Context, userID, offset, limit int) ([]*entities.HistoryEntry, int, error) {
+					return []*entities.HistoryEntry{{ID: 1, UserID: userID}}, 1, nil
 				}
 			})
 
-			It("should return all users", func() {
-				users, err := userUseCase.ListUsers(ctx)
-				
+			It("should return the page from the repository", func() {
+				entries, total, err := userUseCase.GetUserHistory(ctx, 1, 0, 10)
 				Expect(err).To(BeNil())
-				Expect(users).To(Equal(expectedUsers))
-				Expect(mockRepo.ListCalls()).To(HaveLen(1))
+				Expect(total).To(Equal(1))
+				Expect(entries).To(HaveLen(1))
+				Expect(historyRepo.ListByUserCalls()[0].Limit).To(Equal(10))
+			})
+
+			DescribeTable("page size",
+				func(limit, expected int) {
+					_, _, err := userUseCase.GetUserHistory(ctx, 1, 0, limit)
+					Expect(err).To(BeNil())
+					Expect(historyRepo.ListByUserCalls()[0].Limit).To(Equal(expected))
+				},
+				Entry("defaults when zero", 0, use_cases.DefaultHistoryPageSize),
+				Entry("is capped", 1000, use_cases.MaxHistoryPageSize),
+			)
+
+			It("should reject negative offsets and limits", func() {
+				_, _, err := userUseCase.GetUserHistory(ctx, 1, -1, 0)
+				Expect(err).To(Equal(entities.ErrInvalidPagination))
+				_, _, err = userUseCase.GetUserHistory(ctx, 1, 0, -1)
+				Expect(err).To(Equal(entities.ErrInvalidPagination))
+			})
+
+			It("should reject an invalid ID", func() {
+				_, _, err := userUseCase.GetUserHistory(ctx, 0, 0, 0)
+				Expect(err).To(Equal(entities.ErrInvalidID))
 			})
 		})
+	})
+
+	Describe("SearchUsers", func() {
+		var searchIndex *mocks.UserSearchIndexMock
+
+		BeforeEach(func() {
+			searchIndex = &mocks.UserSearchIndexMock{
+				SearchFunc: func(ctx context.Context, query string, offset, limit int) ([]repository.SearchHit, int, error) {
+					return []repository.SearchHit{{UserID: 2, Score: 1.5}, {UserID: 1, Score: 0.5}}, 3, nil
+				},
+			}
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: fmt.Sprintf("User %d", id)}, nil
+			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithSearch(searchIndex))
+		})
+
+		It("should return the users of the hits in rank order", func() {
+			results, total, err := userUseCase.SearchUsers(ctx, "user", 0, 2)
+			
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(3))
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].User.ID).To(Equal(2))
+			Expect(results[0].Score).To(Equal(1.5))
+			Expect(results[1].User.ID).To(Equal(1))
+			Expect(searchIndex.SearchCalls()[0].Query).To(Equal("user"))
+		})
+
+		It("should skip users deleted since the index was searched", func() {
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				if id == 2 {
+					return nil, entities.ErrUserNotFound
+				}
+				return &entities.User{ID: id}, nil
+			}
+
+			results, _, err := userUseCase.SearchUsers(ctx, "user", 0, 2)
+			
+			Expect(err).To(BeNil())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].User.ID).To(Equal(1))
+		})
+
+		DescribeTable("page size",
+			func(limit, expected int) {
+				_, _, err := userUseCase.SearchUsers(ctx, "user", 0, limit)
+				Expect(err).To(BeNil())
+				Expect(searchIndex.SearchCalls()[0].Limit).To(Equal(expected))
+			},
+			Entry("defaults when zero", 0, use_cases.DefaultSearchPageSize),
+			Entry("is capped", 1000, use_cases.MaxSearchPageSize),
+		)
+
+		It("should reject an empty query", func() {
+			_, _, err := userUseCase.SearchUsers(ctx, "  ", 0, 0)
+			Expect(err).To(Equal(entities.ErrSearchQueryRequired))
+			Expect(searchIndex.SearchCalls()).To(BeEmpty())
+		})
+
+		It("should reject negative offsets and limits", func() {
+			_, _, err := userUseCase.SearchUsers(ctx, "user", -1, 0)
+			Expect(err).To(Equal(entities.ErrInvalidPagination))
+			_, _, err = userUseCase.SearchUsers(ctx, "user", 0, -1)
+			Expect(err).To(Equal(entities.ErrInvalidPagination))
+		})
+
+		It("should find nothing without a search index", func() {
+			results, total, err := use_cases.NewUserUseCase(mockRepo).SearchUsers(ctx, "user", 0, 0)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(0))
+			Expect(results).To(BeEmpty())
+		})
+	})
+
+	Describe("FindDuplicateEmails", func() {
+		It("should group users whose emails share a canonical form", func() {
+			mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
+				Expect(repository.IncludesDeleted(ctx)).To(BeTrue())
+				return []*entities.User{
+					{ID: 4, Email: "jane@EXAMPLE.com"},
+					{ID: 1, Email: "JANE@example.com"},
+					{ID: 3, Email: "jane@example.com "},
+					{ID: 2, Email: "other@example.com"},
+				}, nil
+			}
+
+			duplicates, err := userUseCase.FindDuplicateEmails(ctx)
+			
+			Expect(err).To(BeNil())
+			Expect(duplicates).To(HaveLen(1))
+			Expect(duplicates[0].EmailKey).To(Equal("jane@example.com"))
+			Expect(duplicates[0].Users).To(HaveLen(2))
+			Expect(duplicates[0].Users[0].ID).To(Equal(3))
+			Expect(duplicates[0].Users[1].ID).To(Equal(4))
+		})
+	})
+
+	Describe("ListUsers", func() {
+		Context("when users exist", func() {
+			expectedPage := &repository.UserPage{
+				Users: []*entities.User{
+					{ID: 1, Name: "User 1", Email: "user1@example.com"},
+					{ID: 2, Name: "User 2", Email: "user2@example.com"},
+				},
+				Next: "next-cursor",
+			}
 
-		Context("when no users exist", func() {
 			BeforeEach(func() {
-				mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
-					return []*entities.User{}, nil
+				mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+					return expectedPage, nil
 				}
 			})
 
-			It("should return empty slice", func() {
-				users, err := userUseCase.ListUsers(ctx)
+			It("should return the page", func() {
+				page, err := userUseCase.ListUsers(ctx, repository.ListOptions{})
 				
 				Expect(err).To(BeNil())
-				Expect(users).To(BeEmpty())
+				Expect(page).To(Equal(expectedPage))
+				Expect(mockRepo.ListPageCalls()).To(HaveLen(1))
+			})
+
+			It("should default to sorting by ID with the default page size", func() {
+				_, err := userUseCase.ListUsers(ctx, repository.ListOptions{})
+				
+				Expect(err).To(BeNil())
+				opts := mockRepo.ListPageCalls()[0].Opts
+				Expect(opts.Limit).To(Equal(use_cases.DefaultUserPageSize))
+				Expect(opts.SortBy).To(Equal(repository.SortByID))
+			})
+
+			It("should cap the page size", func() {
+				_, err := userUseCase.ListUsers(ctx, repository.ListOptions{Limit: 1000, SortBy: repository.SortByName})
+				
+				Expect(err).To(BeNil())
+				opts := mockRepo.ListPageCalls()[0].Opts
+				Expect(opts.Limit).To(Equal(use_cases.MaxUserPageSize))
+				Expect(opts.SortBy).To(Equal(repository.SortByName))
+			})
+		})
+
+		Context("when options are invalid", func() {
+			It("should reject a negative limit", func() {
+				_, err := userUseCase.ListUsers(ctx, repository.ListOptions{Limit: -1})
+				
+				Expect(err).To(Equal(entities.ErrInvalidPagination))
+				Expect(mockRepo.ListPageCalls()).To(BeEmpty())
+			})
+
+			It("should reject an unknown sort field", func() {
+				_, err := userUseCase.ListUsers(ctx, repository.ListOptions{SortBy: "password"})
+				
+				Expect(err).To(Equal(entities.ErrInvalidSortField))
+				Expect(mockRepo.ListPageCalls()).To(BeEmpty())
+			})
+
+			It("should reject an unknown status", func() {
+				_, err := userUseCase.ListUsers(ctx, repository.ListOptions{Status: "banned"})
+
+				Expect(err).To(Equal(entities.ErrInvalidStatus))
+				Expect(mockRepo.ListPageCalls()).To(BeEmpty())
 			})
 		})
 
 		Context("when repository fails", func() {
 			BeforeEach(func() {
-				mockRepo.ListFunc = func(ctx context.Context) ([]*entities.User, error) {
+				mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
 					return nil, errors.New("database error")
 				}
 			})
 
 			It("should return the error", func() {
-				users, err := userUseCase.ListUsers(ctx)
+				page, err := userUseCase.ListUsers(ctx, repository.ListOptions{})
 				
-				Expect(users).To(BeNil())
+				Expect(page).To(BeNil())
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(Equal("database error"))
 			})
 		})
 	})
-})
\ No newline at end of file
+
+	Describe("ExportUsers", func() {
+		It("should walk every page in ID order", func() {
+			mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+				Expect(opts.SortBy).To(Equal(repository.SortByID))
+				if opts.Cursor == "" {
+					return &repository.UserPage{Users: []*entities.User{{ID: 1}, {ID: 2}}, Next: "page-2"}, nil
+				}
+				Expect(opts.Cursor).To(Equal("page-2"))
+				return &repository.UserPage{Users: []*entities.User{{ID: 3}}}, nil
+			}
+
+			var ids []int
+			err := userUseCase.ExportUsers(ctx, func(user *entities.User) error {
+				ids = append(ids, user.ID)
+				return nil
+			})
+
+			Expect(err).To(BeNil())
+			Expect(ids).To(Equal([]int{1, 2, 3}))
+			Expect(mockRepo.ListPageCalls()).To(HaveLen(2))
+		})
+
+		It("should stop at the first error", func() {
+			mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+				return &repository.UserPage{Users: []*entities.User{{ID: 1}, {ID: 2}}, Next: "page-2"}, nil
+			}
+			errWrite := errors.New("write failed")
+
+			err := userUseCase.ExportUsers(ctx, func(user *entities.User) error {
+				return errWrite
+			})
+
+			Expect(err).To(Equal(errWrite))
+			Expect(mockRepo.ListPageCalls()).To(HaveLen(1))
+		})
+	})
+
+	Describe("ImportUsers", func() {
+		var source *sliceSource
+
+		BeforeEach(func() {
+			created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			source = &sliceSource{records: []sourceRecord{
+				{user: &entities.User{Name: "John Doe", Email: " john@example.com ", Created: created}},
+				{user: &entities.User{Name: "", Email: "nameless@example.com"}},
+				{err: fmt.Errorf("%w: unexpected end of JSON input", entities.ErrInvalidRecord)},
+				{user: &entities.User{Name: "John Again", Email: "john@EXAMPLE.com"}},
+				{user: &entities.User{Name: "Jane Doe", Email: "jane@example.com"}},
+			}}
+			mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+				Expect(repository.IncludesDeleted(ctx)).To(BeTrue())
+				if email == "jane@example.com" {
+					return &entities.User{ID: 9, Email: email}, nil
+				}
+				return nil, entities.ErrUserNotFound
+			}
+			mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+				user.ID = 1
+				return nil
+			}
+		})
+
+		It("should create valid users and report every row", func() {
+			report, err := userUseCase.ImportUsers(ctx, source, false)
+
+			Expect(err).To(BeNil())
+			Expect(report.DryRun).To(BeFalse())
+			Expect(report.Created).To(Equal(1))
+			Expect(report.Duplicates).To(Equal(2))
+			Expect(report.Invalid).To(Equal(2))
+
+			Expect(report.Rows).To(HaveLen(5))
+			Expect(report.Rows[0]).To(Equal(use_cases.ImportRow{Line: 1, Status: use_cases.ImportCreated, ID: 1, Email: " john@example.com "}))
+			Expect(report.Rows[1].Status).To(Equal(use_cases.ImportInvalid))
+			Expect(report.Rows[1].Err).To(MatchError(entities.ErrUserNameRequired))
+			Expect(report.Rows[2].Status).To(Equal(use_cases.ImportInvalid))
+			Expect(report.Rows[2].Err).To(MatchError(entities.ErrInvalidRecord))
+			Expect(report.Rows[3].Status).To(Equal(use_cases.ImportDuplicate))
+			Expect(report.Rows[4].Status).To(Equal(use_cases.ImportDuplicate))
+			Expect(report.Rows[4].Line).To(Equal(5))
+
+			Expect(mockRepo.CreateCalls()).To(HaveLen(1))
+			user := mockRepo.CreateCalls()[0].User
+			Expect(user.Email).To(Equal("john@example.com"))
+			Expect(user.Created).To(Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+			Expect(user.Updated).To(Equal(user.Created))
+		})
+
+		It("should import every user pending and not deleted", func() {
+			deleted := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+			source.records = []sourceRecord{
+				{user: &entities.User{Name: "John Doe", Email: "john@example.com", Status: entities.StatusSuspended, StatusReason: "abuse", DeletedAt: &deleted}},
+			}
+
+			report, err := userUseCase.ImportUsers(ctx, source, false)
+
+			Expect(err).To(BeNil())
+			Expect(report.Created).To(Equal(1))
+			user := mockRepo.CreateCalls()[0].User
+			Expect(user.Status).To(Equal(entities.StatusPending))
+			Expect(user.StatusReason).To(BeEmpty())
+			Expect(user.DeletedAt).To(BeNil())
+		})
+
+		It("should create nothing on a dry run", func() {
+			report, err := userUseCase.ImportUsers(ctx, source, true)
+
+			Expect(err).To(BeNil())
+			Expect(report.DryRun).To(BeTrue())
+			Expect(report.Created).To(Equal(1))
+			Expect(report.Duplicates).To(Equal(2))
+			Expect(report.Invalid).To(Equal(2))
+			Expect(report.Rows[0].ID).To(BeZero())
+			Expect(mockRepo.CreateCalls()).To(BeEmpty())
+		})
+
+		It("should stop on other errors", func() {
+			mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+				return errors.New("database error")
+			}
+
+			report, err := userUseCase.ImportUsers(ctx, source, false)
+
+			Expect(report).To(BeNil())
+			Expect(err).To(MatchError("database error"))
+		})
+	})
+
+	Describe("Backups", func() {
+		var (
+			snapshotter *mocks.UserSnapshotterMock
+			backups     *mocks.BackupStoreMock
+			snapshot    *repository.UserSnapshot
+		)
+
+		BeforeEach(func() {
+			snapshot = &repository.UserSnapshot{
+				Taken:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				NextID: 4,
+				Users: []*entities.User{
+					{ID: 1, Name: "John Doe", Email: "john@example.com", Version: 1},
+					{ID: 3, Name: "Jane Doe", Email: "jane@example.com", Version: 2},
+				},
+			}
+			snapshotter = &mocks.UserSnapshotterMock{
+				SnapshotUsersFunc: func(ctx context.Context) (*repository.UserSnapshot, error) {
+					return snapshot, nil
+				},
+				RestoreUsersFunc: func(ctx context.Context, snapshot *repository.UserSnapshot) error {
+					return nil
+				},
+			}
+			backups = &mocks.BackupStoreMock{
+				SaveFunc: func(ctx context.Context, snapshot *repository.UserSnapshot) (*repository.BackupInfo, error) {
+					return &repository.BackupInfo{Name: "backup", Taken: snapshot.Taken, Users: len(snapshot.Users)}, nil
+				},
+				LoadFunc: func(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+					return snapshot, nil
+				},
+			}
+			repo := struct {
+				*mocks.UserRepositoryMock
+				*mocks.UserSnapshotterMock
+			}{mockRepo, snapshotter}
+			userUseCase = use_cases.NewUserUseCase(repo, use_cases.WithBackups(backups))
+		})
+
+		It("should save a snapshot of the store", func() {
+			info, err := userUseCase.BackupUsers(ctx)
+
+			Expect(err).To(BeNil())
+			Expect(info.Name).To(Equal("backup"))
+			Expect(info.Users).To(Equal(2))
+			Expect(backups.SaveCalls()[0].Snapshot).To(Equal(snapshot))
+		})
+
+		It("should restore a valid backup", func() {
+			restored, err := userUseCase.RestoreUsers(ctx, "backup")
+
+			Expect(err).To(BeNil())
+			Expect(restored).To(Equal(snapshot))
+			Expect(backups.LoadCalls()[0].Name).To(Equal("backup"))
+			Expect(snapshotter.RestoreUsersCalls()[0].Snapshot).To(Equal(snapshot))
+		})
+
+		It("should pass on a missing backup", func() {
+			backups.LoadFunc = func(ctx context.Context, name string) (*repository.UserSnapshot, error) {
+				return nil, entities.ErrBackupNotFound
+			}
+
+			_, err := userUseCase.RestoreUsers(ctx, "missing")
+
+			Expect(err).To(Equal(entities.ErrBackupNotFound))
+			Expect(snapshotter.RestoreUsersCalls()).To(BeEmpty())
+		})
+
+		DescribeTable("should reject inconsistent backups without restoring them",
+			func(corrupt func(*repository.UserSnapshot)) {
+				corrupt(snapshot)
+
+				_, err := userUseCase.RestoreUsers(ctx, "backup")
+
+				Expect(errors.Is(err, entities.ErrInvalidBackup)).To(BeTrue())
+				Expect(snapshotter.RestoreUsersCalls()).To(BeEmpty())
+			},
+			Entry("duplicate IDs", func(s *repository.UserSnapshot) { s.Users[1].ID = 1 }),
+			Entry("unsorted IDs", func(s *repository.UserSnapshot) { s.Users[0].ID = 5 }),
+			Entry("an invalid user", func(s *repository.UserSnapshot) { s.Users[1].Email = "" }),
+			Entry("a missing version", func(s *repository.UserSnapshot) { s.Users[0].Version = 0 }),
+			Entry("a next ID behind the users", func(s *repository.UserSnapshot) { s.NextID = 3 }),
+		)
+
+		It("should be unsupported without a backup store", func() {
+			repo := struct {
+				*mocks.UserRepositoryMock
+				*mocks.UserSnapshotterMock
+			}{mockRepo, snapshotter}
+			userUseCase = use_cases.NewUserUseCase(repo)
+
+			_, err := userUseCase.BackupUsers(ctx)
+			Expect(err).To(Equal(entities.ErrBackupsUnsupported))
+		})
+
+		It("should be unsupported by repositories that can't snapshot", func() {
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithBackups(backups))
+
+			_, err := userUseCase.BackupUsers(ctx)
+			Expect(err).To(Equal(entities.ErrBackupsUnsupported))
+			_, err = userUseCase.RestoreUsers(ctx, "backup")
+			Expect(err).To(Equal(entities.ErrBackupsUnsupported))
+			Expect(backups.LoadCalls()).To(BeEmpty())
+		})
+	})
+
+	Describe("Status changes", func() {
+		var (
+			historyRepo *mocks.HistoryRepositoryMock
+			status      entities.Status
+		)
+
+		BeforeEach(func() {
+			historyRepo = &mocks.HistoryRepositoryMock{
+				AppendFunc: func(ctx context.Context, entry *entities.HistoryEntry) error {
+					return nil
+				},
+			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithHistory(historyRepo))
+
+			status = entities.StatusActive
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				return &entities.User{ID: id, Name: "John Doe", Status: status, Version: 1}, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				return nil
+			}
+		})
+
+		It("should create users pending", func() {
+			mockRepo.GetByEmailFunc = func(ctx context.Context, email string) (*entities.User, error) {
+				return nil, entities.ErrUserNotFound
+			}
+			mockRepo.CreateFunc = func(ctx context.Context, user *entities.User) error {
+				return nil
+			}
+
+			user, err := userUseCase.CreateUser(ctx, "John Doe", "john@example.com")
+
+			Expect(err).To(BeNil())
+			Expect(user.Status).To(Equal(entities.StatusPending))
+		})
+
+		It("should suspend an active user and record the reason", func() {
+			user, err := userUseCase.SuspendUser(ctx, 1, "chargeback")
+
+			Expect(err).To(BeNil())
+			Expect(user.Status).To(Equal(entities.StatusSuspended))
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+			Expect(historyRepo.AppendCalls()).To(HaveLen(1))
+			entry := historyRepo.AppendCalls()[0].Entry
+			Expect(entry.Operation).To(Equal(entities.OperationSuspended))
+			Expect(entry.Changes).To(Equal([]entities.FieldChange{
+				{Field: "status", Before: "active", After: "suspended"},
+				{Field: "status_reason", Before: "", After: "chargeback"},
+			}))
+		})
+
+		It("should activate a pending user", func() {
+			status = entities.StatusPending
+
+			user, err := userUseCase.ActivateUser(ctx, 1, "email verified")
+
+			Expect(err).To(BeNil())
+			Expect(user.Status).To(Equal(entities.StatusActive))
+			Expect(historyRepo.AppendCalls()[0].Entry.Operation).To(Equal(entities.OperationActivated))
+		})
+
+		It("should deactivate a suspended user", func() {
+			status = entities.StatusSuspended
+
+			user, err := userUseCase.DeactivateUser(ctx, 1, "closed by support")
+
+			Expect(err).To(BeNil())
+			Expect(user.Status).To(Equal(entities.StatusDeactivated))
+			Expect(historyRepo.AppendCalls()[0].Entry.Operation).To(Equal(entities.OperationDeactivated))
+		})
+
+		It("should refuse to suspend a deactivated user", func() {
+			status = entities.StatusDeactivated
+
+			user, err := userUseCase.SuspendUser(ctx, 1, "chargeback")
+
+			Expect(user).To(BeNil())
+			Expect(err).To(MatchError(entities.ErrInvalidStatusTransition))
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+			Expect(historyRepo.AppendCalls()).To(BeEmpty())
+		})
+
+		It("should require a reason", func() {
+			_, err := userUseCase.SuspendUser(ctx, 1, "")
+
+			Expect(err).To(MatchError(entities.ErrStatusReasonRequired))
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+		})
+
+		It("should reject an invalid ID", func() {
+			_, err := userUseCase.ActivateUser(ctx, 0, "email verified")
+			Expect(err).To(Equal(entities.ErrInvalidID))
+		})
+	})
+
+	Describe("SetLegalHold", func() {
+		var historyRepo *mocks.HistoryRepositoryMock
+
+		BeforeEach(func() {
+			historyRepo = &mocks.HistoryRepositoryMock{
+				AppendFunc: func(ctx context.Context, entry *entities.HistoryEntry) error {
+					return nil
+				},
+			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo, use_cases.WithHistory(historyRepo))
+
+			updated := time.Now().Add(-time.Hour)
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				Expect(repository.IncludesDeleted(ctx)).To(BeTrue())
+				return &entities.User{ID: id, Name: "John Doe", Updated: updated, Version: 1}, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				Expect(user.Updated).To(Equal(updated))
+				return nil
+			}
+		})
+
+		It("should place a user on hold without touching Updated", func() {
+			user, err := userUseCase.SetLegalHold(ctx, 1, true)
+
+			Expect(err).To(BeNil())
+			Expect(user.LegalHold).To(BeTrue())
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(1))
+			Expect(historyRepo.AppendCalls()).To(HaveLen(1))
+			entry := historyRepo.AppendCalls()[0].Entry
+			Expect(entry.Operation).To(Equal(entities.OperationHeld))
+			Expect(entry.Changes).To(Equal([]entities.FieldChange{
+				{Field: "legal_hold", Before: "", After: "true"},
+			}))
+		})
+
+		It("should leave a user that is already released alone", func() {
+			user, err := userUseCase.SetLegalHold(ctx, 1, false)
+
+			Expect(err).To(BeNil())
+			Expect(user.LegalHold).To(BeFalse())
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+			Expect(historyRepo.AppendCalls()).To(BeEmpty())
+		})
+
+		It("should reject an invalid ID", func() {
+			_, err := userUseCase.SetLegalHold(ctx, 0, true)
+			Expect(err).To(Equal(entities.ErrInvalidID))
+		})
+	})
+
+	Describe("EnforceRetention", func() {
+		var (
+			notifier    *mocks.RetentionNotifierMock
+			historyRepo *mocks.HistoryRepositoryMock
+			users       map[int]*entities.User
+			now         time.Time
+		)
+
+		policy := use_cases.RetentionPolicy{Months: 12, Warning: 14 * 24 * time.Hour}
+		day := 24 * time.Hour
+
+		BeforeEach(func() {
+			now = time.Now()
+			longAgo := now.AddDate(-2, 0, 0)
+			warnedLongAgo := now.Add(-20 * day)
+			warnedRecently := now.Add(-3 * day)
+			warnedBeforeUpdate := now.AddDate(0, -14, 0)
+			users = map[int]*entities.User{
+				// Inactive within the warning period
+				1: {ID: 1, Email: "one@example.com", Updated: now.AddDate(0, -12, 0).Add(7 * day), Version: 1},
+				2: {ID: 2, Email: "two@example.com", Updated: longAgo, Version: 1},
+				3: {ID: 3, Email: "three@example.com", Updated: longAgo, InactivityWarnedAt: &warnedLongAgo, Version: 1},
+				4: {ID: 4, Email: "four@example.com", Updated: longAgo, InactivityWarnedAt: &warnedRecently, Version: 1},
+				5: {ID: 5, Email: "five@example.com", Updated: longAgo, LegalHold: true, Version: 1},
+				6: {ID: 6, Email: "six@example.com", Updated: now.AddDate(0, -13, 0), InactivityWarnedAt: &warnedBeforeUpdate, Version: 1},
+			}
+
+			notifier = &mocks.RetentionNotifierMock{
+				WarnInactiveFunc: func(ctx context.Context, user *entities.User, deleteAfter time.Time) error {
+					return nil
+				},
+			}
+			historyRepo = &mocks.HistoryRepositoryMock{
+				AppendFunc: func(ctx context.Context, entry *entities.HistoryEntry) error {
+					return nil
+				},
+			}
+			userUseCase = use_cases.NewUserUseCase(mockRepo,
+				use_cases.WithHistory(historyRepo),
+				use_cases.WithRetention(policy, notifier))
+
+			mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+				Expect(opts.SortBy).To(Equal(repository.SortByUpdated))
+				Expect(opts.UpdatedBefore).To(BeTemporally("~", now.Add(policy.Warning).AddDate(0, -12, 0), time.Second))
+				page := &repository.UserPage{}
+				for id := 1; id <= len(users); id++ {
+					user := *users[id]
+					page.Users = append(page.Users, &user)
+				}
+				return page, nil
+			}
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				user := *users[id]
+				return &user, nil
+			}
+			mockRepo.UpdateFunc = func(ctx context.Context, user *entities.User) error {
+				return nil
+			}
+		})
+
+		statuses := func(report *use_cases.RetentionReport) map[int]string {
+			result := make(map[int]string)
+			for _, row := range report.Rows {
+				result[row.ID] = row.Status
+			}
+			return result
+		}
+
+		It("should warn, delete and skip users as the policy says", func() {
+			report, err := userUseCase.EnforceRetention(ctx, false)
+
+			Expect(err).To(BeNil())
+			Expect(statuses(report)).To(Equal(map[int]string{
+				1: use_cases.RetentionWarned,
+				2: use_cases.RetentionWarned,
+				3: use_cases.RetentionDeleted,
+				4: use_cases.RetentionPending,
+				5: use_cases.RetentionHeld,
+				6: use_cases.RetentionWarned,
+			}))
+			Expect(report.Warned).To(Equal(3))
+			Expect(report.Deleted).To(Equal(1))
+			Expect(report.Pending).To(Equal(1))
+			Expect(report.Held).To(Equal(1))
+			Expect(report.DryRun).To(BeFalse())
+			Expect(report.Cutoff).To(BeTemporally("~", now.AddDate(0, -12, 0), time.Second))
+
+			Expect(report.Rows[0].DeleteAfter).To(BeTemporally("~", now.Add(policy.Warning), time.Second))
+			Expect(report.Rows[3].DeleteAfter).To(Equal(users[4].InactivityWarnedAt.Add(policy.Warning)))
+			Expect(report.Rows[4].DeleteAfter).To(BeZero())
+
+			Expect(notifier.WarnInactiveCalls()).To(HaveLen(3))
+			Expect(notifier.WarnInactiveCalls()[1].User.ID).To(Equal(2))
+			Expect(notifier.WarnInactiveCalls()[1].DeleteAfter).To(Equal(report.Rows[1].DeleteAfter))
+
+			Expect(mockRepo.UpdateCalls()).To(HaveLen(4))
+			for _, call := range mockRepo.UpdateCalls() {
+				if call.User.ID == 3 {
+					Expect(call.User.IsDeleted()).To(BeTrue())
+					continue
+				}
+				Expect(call.User.WarnedOfInactivity()).To(BeTrue())
+				Expect(call.User.Updated).To(Equal(users[call.User.ID].Updated))
+			}
+		})
+
+		It("should record the changes as made by the retention policy", func() {
+			_, err := userUseCase.EnforceRetention(ctx, false)
+			Expect(err).To(BeNil())
+
+			Expect(historyRepo.AppendCalls()).To(HaveLen(4))
+			operations := make(map[int]string)
+			for _, call := range historyRepo.AppendCalls() {
+				Expect(call.Entry.Principal).To(Equal(use_cases.RetentionPrincipal))
+				operations[call.Entry.UserID] = call.Entry.Operation
+			}
+			Expect(operations).To(HaveKeyWithValue(2, entities.OperationWarned))
+			Expect(operations).To(HaveKeyWithValue(3, entities.OperationDeleted))
+		})
+
+		It("should report the same without changing anything on a dry run", func() {
+			report, err := userUseCase.EnforceRetention(ctx, true)
+
+			Expect(err).To(BeNil())
+			Expect(report.DryRun).To(BeTrue())
+			Expect(report.Warned).To(Equal(3))
+			Expect(report.Deleted).To(Equal(1))
+			Expect(notifier.WarnInactiveCalls()).To(BeEmpty())
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+		})
+
+		It("should report a warning that can't be sent and go on", func() {
+			expectedErr := errors.New("mail server down")
+			notifier.WarnInactiveFunc = func(ctx context.Context, user *entities.User, deleteAfter time.Time) error {
+				if user.ID == 2 {
+					return expectedErr
+				}
+				return nil
+			}
+
+			report, err := userUseCase.EnforceRetention(ctx, false)
+
+			Expect(err).To(BeNil())
+			Expect(report.Failed).To(Equal(1))
+			Expect(report.Warned).To(Equal(2))
+			Expect(report.Rows[1].Status).To(Equal(use_cases.RetentionFailed))
+			Expect(report.Rows[1].Err).To(Equal(expectedErr))
+			for _, call := range mockRepo.UpdateCalls() {
+				Expect(call.User.ID).NotTo(Equal(2))
+			}
+		})
+
+		It("should skip users that changed since they were listed", func() {
+			mockRepo.GetByIDFunc = func(ctx context.Context, id int) (*entities.User, error) {
+				user := *users[id]
+				user.Version++
+				return &user, nil
+			}
+
+			report, err := userUseCase.EnforceRetention(ctx, false)
+
+			Expect(err).To(BeNil())
+			Expect(statuses(report)).To(Equal(map[int]string{
+				4: use_cases.RetentionPending,
+				5: use_cases.RetentionHeld,
+			}))
+			Expect(mockRepo.UpdateCalls()).To(BeEmpty())
+		})
+
+		It("should return the repository error", func() {
+			expectedErr := errors.New("database error")
+			mockRepo.ListPageFunc = func(ctx context.Context, opts repository.ListOptions) (*repository.UserPage, error) {
+				return nil, expectedErr
+			}
+
+			_, err := userUseCase.EnforceRetention(ctx, false)
+			Expect(err).To(Equal(expectedErr))
+		})
+
+		It("should be disabled without a policy", func() {
+			userUseCase = use_cases.NewUserUseCase(mockRepo)
+
+			_, err := userUseCase.EnforceRetention(ctx, true)
+			Expect(err).To(Equal(entities.ErrRetentionDisabled))
+		})
+	})
+
+	Describe("WatchUsers", func() {
+		var watcher *mocks.UserWatcherMock
+
+		BeforeEach(func() {
+			watcher = &mocks.UserWatcherMock{
+				WatchFunc: func(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+					return nil, nil
+				},
+			}
+			repo := struct {
+				*mocks.UserRepositoryMock
+				*mocks.UserWatcherMock
+			}{mockRepo, watcher}
+			userUseCase = use_cases.NewUserUseCase(repo)
+		})
+
+		It("should watch the repository from the given sequence number", func() {
+			_, err := userUseCase.WatchUsers(ctx, 42)
+
+			Expect(err).To(BeNil())
+			Expect(watcher.WatchCalls()).To(HaveLen(1))
+			Expect(watcher.WatchCalls()[0].FromSeq).To(Equal(int64(42)))
+		})
+
+		It("should pass on a watcher that is too far behind", func() {
+			watcher.WatchFunc = func(ctx context.Context, fromSeq int64) (repository.UserChangeStream, error) {
+				return nil, entities.ErrWatchTooFarBehind
+			}
+
+			_, err := userUseCase.WatchUsers(ctx, 1)
+			Expect(err).To(Equal(entities.ErrWatchTooFarBehind))
+		})
+
+		It("should reject negative sequence numbers", func() {
+			_, err := userUseCase.WatchUsers(ctx, -2)
+
+			Expect(err).To(Equal(entities.ErrInvalidSequence))
+			Expect(watcher.WatchCalls()).To(BeEmpty())
+		})
+
+		It("should be unsupported by repositories that can't be watched", func() {
+			userUseCase = use_cases.NewUserUseCase(mockRepo)
+
+			_, err := userUseCase.WatchUsers(ctx, repository.WatchFromLatest)
+			Expect(err).To(Equal(entities.ErrWatchUnsupported))
+		})
+	})
+})
+
+
+// sourceRecord is a record read by sliceSource
+type sourceRecord struct {
+	user *entities.User
+	err  error
+}
+
+// sliceSource is an ImportSource reading one record per line
+type sliceSource struct {
+	records []sourceRecord
+	next    int
+}
+
+func (s *sliceSource) Read() (*entities.User, int, error) {
+	if s.next == len(s.records) {
+		return nil, s.next, io.EOF
+	}
+	record := s.records[s.next]
+	s.next++
+	return record.user, s.next, record.err
+}